@@ -242,6 +242,47 @@ func TestGetBestLocalAddress(t *testing.T) {
 	*/
 }
 
+func TestLocalAddresses(t *testing.T) {
+	amgr := addrmgr.New("", nil)
+	if addrs := amgr.LocalAddresses(); len(addrs) != 0 {
+		t.Errorf("LocalAddresses: got %d addresses, want none", len(addrs))
+	}
+
+	localAddrs := []struct {
+		addr     wire.NetAddress
+		priority addrmgr.AddressPriority
+	}{
+		{wire.NetAddress{IP: net.ParseIP("2620:100::1"), Port: 8334}, addrmgr.BoundPrio},
+		{wire.NetAddress{IP: net.ParseIP("192.168.0.100"), Port: 8334}, addrmgr.BoundPrio},
+		{wire.NetAddress{IP: net.ParseIP("204.124.1.1"), Port: 8334}, addrmgr.InterfacePrio},
+	}
+	for _, la := range localAddrs {
+		la := la
+		amgr.AddLocalAddress(&la.addr, la.priority)
+	}
+
+	want := []struct {
+		key   string
+		score addrmgr.AddressPriority
+	}{
+		{"204.124.1.1:8334", addrmgr.InterfacePrio},
+		{"[2620:100::1]:8334", addrmgr.BoundPrio},
+	}
+	addrs := amgr.LocalAddresses()
+	if len(addrs) != len(want) {
+		t.Fatalf("LocalAddresses: got %d addresses, want %d",
+			len(addrs), len(want))
+	}
+	for i, la := range addrs {
+		key := addrmgr.NetAddressKey(la.NetAddress)
+		if key != want[i].key || la.Score != want[i].score {
+			t.Errorf("LocalAddresses #%d: got %s with score %d, "+
+				"want %s with score %d", i, key, la.Score,
+				want[i].key, want[i].score)
+		}
+	}
+}
+
 func TestNetAddressKey(t *testing.T) {
 	addNaTests()
 
@@ -0,0 +1,243 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// banListFileName is the name of the file the bans are stored in so
+	// they survive restarts.
+	banListFileName = "banlist.json"
+
+	// banReasonMisbehaving is the reason recorded for bans of peers whose
+	// ban score crossed the ban threshold.
+	banReasonMisbehaving = "node misbehaving"
+
+	// banReasonManual is the reason recorded for bans added through the
+	// setban RPC.
+	banReasonManual = "manually added"
+)
+
+// banEntry describes the ban of a single subnet.
+type banEntry struct {
+	subnet  *net.IPNet
+	Created time.Time `json:"created"`
+	Until   time.Time `json:"until"`
+	Reason  string    `json:"reason"`
+}
+
+// Subnet returns the banned subnet in CIDR notation.
+func (e *banEntry) Subnet() string {
+	return e.subnet.String()
+}
+
+// banList houses the banned subnets and stores them on disk whenever they
+// change.  It is safe for concurrent access.
+type banList struct {
+	sync.Mutex
+	file string
+	bans map[string]*banEntry
+}
+
+// singleIPSubnet returns the subnet which only contains the passed IP address.
+func singleIPSubnet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// parseSubnet parses a subnet in CIDR notation, such as 192.168.0.0/16, or a
+// single IP address, which is treated as a subnet only containing that
+// address.
+func parseSubnet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %s", s)
+		}
+		return subnet, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %s", s)
+	}
+	return singleIPSubnet(ip), nil
+}
+
+// newBanList returns a ban list which is stored in the passed file, filled
+// with the bans stored there previously.  Bans which have ended already are
+// skipped.  The list starts out empty when the file doesn't exist or can't be
+// read.
+func newBanList(file string) *banList {
+	b := &banList{
+		file: file,
+		bans: make(map[string]*banEntry),
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			srvrLog.Errorf("Error opening file %s: %v", file, err)
+		}
+		return b
+	}
+	defer f.Close()
+
+	var saved map[string]*banEntry
+	if err := json.NewDecoder(f).Decode(&saved); err != nil {
+		srvrLog.Errorf("Error reading ban list from %s: %v", file, err)
+		return b
+	}
+	now := time.Now()
+	for s, entry := range saved {
+		subnet, err := parseSubnet(s)
+		if err != nil {
+			srvrLog.Warnf("Skipping ban of %s from %s: %v", s, file,
+				err)
+			continue
+		}
+		if entry == nil || !now.Before(entry.Until) {
+			continue
+		}
+		entry.subnet = subnet
+		b.bans[entry.Subnet()] = entry
+	}
+
+	srvrLog.Infof("Loaded %d banned subnets from %s", len(b.bans), file)
+	return b
+}
+
+// save stores the bans in the ban list file.  It must be called with the
+// lock held.
+func (b *banList) save() {
+	// Write to a temporary file first so a failure doesn't destroy the
+	// previously saved ban list.
+	tmpFile := b.file + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		srvrLog.Errorf("Error opening file %s: %v", tmpFile, err)
+		return
+	}
+	err = json.NewEncoder(f).Encode(b.bans)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		srvrLog.Errorf("Error writing ban list to %s: %v", tmpFile, err)
+		os.Remove(tmpFile)
+		return
+	}
+
+	if err := os.Rename(tmpFile, b.file); err != nil {
+		srvrLog.Errorf("Error writing ban list to %s: %v", b.file, err)
+	}
+}
+
+// removeExpired removes the bans which have ended.  It must be called with
+// the lock held.
+func (b *banList) removeExpired(now time.Time) {
+	for s, entry := range b.bans {
+		if !now.Before(entry.Until) {
+			srvrLog.Infof("Subnet %s is no longer banned", s)
+			delete(b.bans, s)
+		}
+	}
+}
+
+// Ban bans the passed subnet until the passed time for the passed reason.  An
+// existing ban of the same subnet is only ever extended.
+func (b *banList) Ban(subnet *net.IPNet, until time.Time, reason string) {
+	b.Lock()
+	defer b.Unlock()
+
+	s := subnet.String()
+	if entry, ok := b.bans[s]; ok && !until.After(entry.Until) {
+		return
+	}
+	b.bans[s] = &banEntry{
+		subnet:  subnet,
+		Created: time.Now(),
+		Until:   until,
+		Reason:  reason,
+	}
+	b.save()
+}
+
+// Unban lifts the ban of the passed subnet.  It returns whether the subnet
+// was banned.
+func (b *banList) Unban(subnet *net.IPNet) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	s := subnet.String()
+	if _, ok := b.bans[s]; !ok {
+		return false
+	}
+	delete(b.bans, s)
+	b.save()
+	return true
+}
+
+// Clear lifts all bans.
+func (b *banList) Clear() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.bans = make(map[string]*banEntry)
+	b.save()
+}
+
+// IsBanned returns whether the passed IP address is part of a banned subnet
+// and when the ban ends.  When the address is part of several banned subnets,
+// the latest end of their bans is returned.
+func (b *banList) IsBanned(ip net.IP) (time.Time, bool) {
+	if ip == nil {
+		return time.Time{}, false
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.removeExpired(time.Now())
+	var until time.Time
+	banned := false
+	for _, entry := range b.bans {
+		if entry.subnet.Contains(ip) && entry.Until.After(until) {
+			until = entry.Until
+			banned = true
+		}
+	}
+	return until, banned
+}
+
+// Bans returns the bans which have not ended yet, sorted by subnet.
+func (b *banList) Bans() []banEntry {
+	b.Lock()
+	defer b.Unlock()
+
+	b.removeExpired(time.Now())
+	subnets := make([]string, 0, len(b.bans))
+	for s := range b.bans {
+		subnets = append(subnets, s)
+	}
+	sort.Strings(subnets)
+
+	bans := make([]banEntry, 0, len(subnets))
+	for _, s := range subnets {
+		bans = append(bans, *b.bans[s])
+	}
+	return bans
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSubnet ensures single IP addresses and subnets in CIDR notation are
+// parsed into the expected subnets.
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"10.0.0.1", "10.0.0.1/32"},
+		{"10.0.0.1/24", "10.0.0.0/24"},
+		{"::ffff:10.0.0.1", "10.0.0.1/32"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+		{"10.0.0.256", ""},
+		{"10.0.0.1/33", ""},
+		{"example.com", ""},
+	}
+
+	for _, test := range tests {
+		subnet, err := parseSubnet(test.in)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("parseSubnet(%q): got %v, want an error",
+					test.in, subnet)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSubnet(%q): unexpected error: %v",
+				test.in, err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("parseSubnet(%q): got %v, want %v", test.in,
+				subnet, test.want)
+		}
+	}
+}
+
+// TestBanList ensures banned subnets are matched, survive being saved and
+// loaded again, except for those which ended in the meantime, and can be
+// lifted again.
+func TestBanList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, banListFileName)
+
+	b := newBanList(file)
+	if bans := b.Bans(); len(bans) != 0 {
+		t.Fatalf("newBanList: got %v for a missing file, want none",
+			bans)
+	}
+
+	banEnd := time.Now().Add(time.Hour).Round(time.Second)
+	for _, s := range []string{"10.0.0.0/24", "2001:db8::1"} {
+		subnet, err := parseSubnet(s)
+		if err != nil {
+			t.Fatalf("parseSubnet(%q): %v", s, err)
+		}
+		b.Ban(subnet, banEnd, banReasonManual)
+	}
+	expired := singleIPSubnet(net.ParseIP("10.0.1.1"))
+	b.Ban(expired, time.Now().Add(-time.Second), banReasonMisbehaving)
+
+	tests := []struct {
+		ip     string
+		banned bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.255", true},
+		{"::ffff:10.0.0.7", true},
+		{"10.0.1.1", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+	}
+	for _, test := range tests {
+		until, banned := b.IsBanned(net.ParseIP(test.ip))
+		if banned != test.banned {
+			t.Errorf("IsBanned(%s): got %v, want %v", test.ip,
+				banned, test.banned)
+			continue
+		}
+		if banned && !until.Equal(banEnd) {
+			t.Errorf("IsBanned(%s): got ban end %v, want %v",
+				test.ip, until, banEnd)
+		}
+	}
+
+	// Only the bans which haven't ended must be loaded again.
+	b = newBanList(file)
+	bans := b.Bans()
+	if len(bans) != 2 {
+		t.Fatalf("newBanList: got %d bans, want 2", len(bans))
+	}
+	for i, want := range []string{"10.0.0.0/24", "2001:db8::1/128"} {
+		if bans[i].Subnet() != want {
+			t.Errorf("Bans: got subnet %s at index %d, want %s",
+				bans[i].Subnet(), i, want)
+		}
+		if !bans[i].Until.Equal(banEnd) {
+			t.Errorf("Bans: got ban end %v for %s, want %v",
+				bans[i].Until, want, banEnd)
+		}
+		if bans[i].Reason != banReasonManual {
+			t.Errorf("Bans: got reason %q for %s, want %q",
+				bans[i].Reason, want, banReasonManual)
+		}
+	}
+
+	// Lifting a ban must only succeed for banned subnets and be saved.
+	subnet, _ := parseSubnet("10.0.0.0/24")
+	if !b.Unban(subnet) {
+		t.Errorf("Unban: %v was not banned", subnet)
+	}
+	if b.Unban(subnet) {
+		t.Errorf("Unban: %v was still banned", subnet)
+	}
+	if bans := newBanList(file).Bans(); len(bans) != 1 {
+		t.Errorf("Unban: got %d saved bans, want 1", len(bans))
+	}
+
+	b.Clear()
+	if bans := newBanList(file).Bans(); len(bans) != 0 {
+		t.Errorf("Clear: got %d saved bans, want none", len(bans))
+	}
+}
@@ -39,7 +39,15 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 	}
 	block.SetHeight(blockHeight)
 
+	// Ensure blocks are only merged mined from the AuxPow start height on
+	// and, when the network enforces a strict chain ID, no longer use the
+	// legacy block version from then on.
 	blockHeader := &block.MsgBlock().Header
+	err = checkAuxPowHeight(blockHeader, blockHeight, b.chainParams)
+	if err != nil {
+		return err
+	}
+
 	if !fastAdd {
 		// Ensure the difficulty specified in the block header matches
 		// the calculated difficulty based on the previous block and
@@ -91,6 +99,18 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 		return ruleError(ErrBadCheckpoint, str)
 	}
 
+	// Reject blocks which are marked invalid as well as blocks which build
+	// on top of a block known to be invalid.
+	if _, ok := b.invalidBlocks[*blockHash]; ok {
+		str := fmt.Sprintf("block %v is marked invalid", blockHash)
+		return ruleError(ErrInvalidAncestorBlock, str)
+	}
+	if prevNode != nil && b.isInvalidBranch(prevNode) {
+		str := fmt.Sprintf("block %v builds on top of an invalid "+
+			"block", blockHash)
+		return ruleError(ErrInvalidAncestorBlock, str)
+	}
+
 	// Find the previous checkpoint and prevent blocks which fork the main
 	// chain before it.  This prevents storage of new, otherwise valid,
 	// blocks which build off of old blocks that are likely at a much easier
@@ -108,14 +128,17 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 
 	if !fastAdd {
 		// Reject version 1 blocks once a majority of the network has
-		// upgraded.  This is part of BIP0034.
-		if blockHeader.Version < 2 {
+		// upgraded.  This is part of BIP0034.  Only the base version
+		// counts, since merged mined blocks encode the chain ID and
+		// AuxPow flag in the version as well.
+		baseVersion := blockHeader.BaseVersion()
+		if baseVersion < 2 {
 			if b.isMajorityVersion(2, prevNode,
 				b.chainParams.BlockRejectNumRequired) {
 
 				str := "new blocks with version %d are no " +
 					"longer valid"
-				str = fmt.Sprintf(str, blockHeader.Version)
+				str = fmt.Sprintf(str, baseVersion)
 				return ruleError(ErrBlockVersionTooOld, str)
 			}
 		}
@@ -124,7 +147,7 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 		// blocks whose version is the serializedHeightVersion or
 		// newer once a majority of the network has upgraded.  This is
 		// part of BIP0034.
-		if blockHeader.Version >= serializedHeightVersion {
+		if baseVersion >= serializedHeightVersion {
 			if b.isMajorityVersion(serializedHeightVersion,
 				prevNode,
 				b.chainParams.BlockEnforceNumRequired) {
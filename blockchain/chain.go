@@ -69,7 +69,13 @@ type blockNode struct {
 	// ancestor when switching chains.
 	inMainChain bool
 
-	// Some fields from block headers to aid in best chain selection.
+	// invalid denotes whether the block failed to connect to the main
+	// chain when its branch was about to become the main chain.
+	invalid bool
+
+	// Some fields from block headers to aid in best chain selection.  The
+	// version is the base version without the merged mining chain ID and
+	// AuxPow flag.
 	version   int32
 	bits      uint32
 	timestamp time.Time
@@ -89,7 +95,7 @@ func newBlockNode(blockHeader *wire.BlockHeader, blockSha *wire.ShaHash, height
 		parentHash: &prevHash,
 		workSum:    CalcWork(blockHeader.Bits),
 		height:     height,
-		version:    blockHeader.Version,
+		version:    blockHeader.BaseVersion(),
 		bits:       blockHeader.Bits,
 		timestamp:  blockHeader.Timestamp,
 	}
@@ -155,6 +161,7 @@ type BlockChain struct {
 	oldestOrphan        *orphanBlock
 	orphanLock          sync.RWMutex
 	blockCache          map[wire.ShaHash]*btcutil.Block
+	invalidBlocks       map[wire.ShaHash]struct{}
 	noVerify            bool
 	noCheckpoints       bool
 	nextCheckpoint      *chaincfg.Checkpoint
@@ -392,7 +399,8 @@ func (b *BlockChain) GenerateInitialIndex() error {
 		start += int64(len(hashList))
 	}
 
-	return nil
+	// Load the blocks which were marked invalid by InvalidateBlock.
+	return b.loadInvalidBlocks()
 }
 
 // loadBlockNode loads the block identified by hash from the block database,
@@ -613,6 +621,16 @@ func (b *BlockChain) pruneBlockNodes() error {
 func (b *BlockChain) isMajorityVersion(minVer int32, startNode *blockNode,
 	numRequired uint64) bool {
 
+	return b.countMajorityVersion(minVer, startNode, numRequired) >= numRequired
+}
+
+// countMajorityVersion returns how many of the previous blocks in the chain
+// starting with startNode are at least the minimum passed version.  At most
+// BlockUpgradeNumToCheck blocks are checked and counting stops early once
+// numRequired blocks have been found.
+func (b *BlockChain) countMajorityVersion(minVer int32, startNode *blockNode,
+	numRequired uint64) uint64 {
+
 	numFound := uint64(0)
 	iterNode := startNode
 	for i := uint64(0); i < b.chainParams.BlockUpgradeNumToCheck &&
@@ -634,7 +652,68 @@ func (b *BlockChain) isMajorityVersion(minVer int32, startNode *blockNode,
 		}
 	}
 
-	return numFound >= numRequired
+	return numFound
+}
+
+// VersionUpgrade describes the progress of the network upgrading to a block
+// version as measured by the rules introduced with BIP0034.
+type VersionUpgrade struct {
+	// Version is the block version being upgraded to.
+	Version int32
+
+	// Found is the number of blocks of at least Version among the last
+	// Window blocks of the main chain.
+	Found uint64
+
+	// Window is the number of blocks the majority is measured over.
+	Window uint64
+
+	// EnforceRequired is the number of blocks of at least Version needed
+	// for the rules of the new version to be enforced on new blocks of
+	// that version.
+	EnforceRequired uint64
+
+	// RejectRequired is the number of blocks of at least Version needed
+	// for blocks of older versions to be rejected.
+	RejectRequired uint64
+}
+
+// Enforced returns whether the rules of the new version are enforced for the
+// next block.
+func (u *VersionUpgrade) Enforced() bool {
+	return u.Found >= u.EnforceRequired
+}
+
+// Rejecting returns whether blocks of older versions are rejected from the
+// next block on.
+func (u *VersionUpgrade) Rejecting() bool {
+	return u.Found >= u.RejectRequired
+}
+
+// VersionUpgradeStatus returns the progress of the network upgrading to the
+// passed block version as of the end of the main chain.
+//
+// This function is NOT safe for concurrent access.
+func (b *BlockChain) VersionUpgradeStatus(version int32) *VersionUpgrade {
+	window := b.chainParams.BlockUpgradeNumToCheck
+	return &VersionUpgrade{
+		Version:         version,
+		Found:           b.countMajorityVersion(version, b.bestChain, window),
+		Window:          window,
+		EnforceRequired: b.chainParams.BlockEnforceNumRequired,
+		RejectRequired:  b.chainParams.BlockRejectNumRequired,
+	}
+}
+
+// BestChainWork returns the total amount of work in the main chain.  It is nil
+// when no main chain has been selected yet.
+//
+// This function is NOT safe for concurrent access.
+func (b *BlockChain) BestChainWork() *big.Int {
+	if b.bestChain == nil {
+		return nil
+	}
+	return new(big.Int).Set(b.bestChain.workSum)
 }
 
 // calcPastMedianTime calculates the median time of the previous few blocks
@@ -761,6 +840,13 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block) error {
 		return err
 	}
 
+	// Record the name operations of the block in the name database and
+	// expire the names which are too old.
+	expiredNames, err := b.connectNames(node, block)
+	if err != nil {
+		return err
+	}
+
 	// Add the new node to the memory main chain indices for faster
 	// lookups.
 	node.inMainChain = true
@@ -770,6 +856,13 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block) error {
 	// This node is now the end of the best chain.
 	b.bestChain = node
 
+	// Notify the caller about the names which expired at the height of the
+	// block.  This happens before the block itself is notified since the
+	// names expire before the name operations of the block are applied.
+	for _, entry := range expiredNames {
+		b.sendNotification(NTNameExpired, entry)
+	}
+
 	// Notify the caller that the block was connected to the main chain.
 	// The caller would typically want to react with actions such as
 	// updating wallets.
@@ -792,6 +885,10 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block) erro
 	if err != nil {
 		return err
 	}
+	restoredNames, err := b.disconnectNames(node)
+	if err != nil {
+		return err
+	}
 	err = b.db.DropAfterBlockBySha(prevNode.hash)
 	if err != nil {
 		return err
@@ -809,6 +906,13 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block) erro
 	// updating wallets.
 	b.sendNotification(NTBlockDisconnected, block)
 
+	// Notify the caller about the names which are active again since the
+	// block which expired them was disconnected.  This mirrors the order of
+	// the notifications when the block was connected.
+	for _, entry := range restoredNames {
+		b.sendNotification(NTNameExpiryReverted, entry)
+	}
+
 	return nil
 }
 
@@ -850,6 +954,11 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 		block := b.blockCache[*n.hash]
 		err := b.checkConnectBlock(n, block)
 		if err != nil {
+			// Remember blocks which violate the rules so the
+			// branch they are on can be reported as invalid.
+			if _, ok := err.(RuleError); ok && flags&BFDryRun != BFDryRun {
+				n.invalid = true
+			}
 			return err
 		}
 	}
@@ -891,10 +1000,14 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 		log.Infof("REORGANIZE: Chain forks at %v", forkNode.hash)
 	}
 
-	// Log the old and new best chain heads.
-	firstDetachNode := detachNodes.Front().Value.(*blockNode)
+	// Log the old and new best chain heads.  There are no nodes to detach
+	// when the chain is reorganized after invalidating blocks.
+	if detachNodes.Len() > 0 {
+		firstDetachNode := detachNodes.Front().Value.(*blockNode)
+		log.Infof("REORGANIZE: Old best chain head was %v",
+			firstDetachNode.hash)
+	}
 	lastAttachNode := attachNodes.Back().Value.(*blockNode)
-	log.Infof("REORGANIZE: Old best chain head was %v", firstDetachNode.hash)
 	log.Infof("REORGANIZE: New best chain head is %v", lastAttachNode.hash)
 
 	return nil
@@ -1095,6 +1208,7 @@ func New(db database.Db, params *chaincfg.Params, c NotificationCallback) *Block
 		orphans:             make(map[wire.ShaHash]*orphanBlock),
 		prevOrphans:         make(map[wire.ShaHash][]*orphanBlock),
 		blockCache:          make(map[wire.ShaHash]*btcutil.Block),
+		invalidBlocks:       make(map[wire.ShaHash]struct{}),
 	}
 	return &b
 }
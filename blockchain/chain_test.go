@@ -5,6 +5,7 @@
 package blockchain_test
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/melange-app/nmcd/blockchain"
@@ -112,3 +113,63 @@ func TestHaveBlock(t *testing.T) {
 		}
 	}
 }
+
+// TestVersionUpgradeStatus ensures the progress of block version upgrades and
+// the work of the main chain are reported as blocks are connected.
+func TestVersionUpgradeStatus(t *testing.T) {
+	params := regTestParams()
+	chain, teardownFunc, err := chainSetupParams("versionupgrade", params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Extend the version 1 genesis block with three version 2 blocks.
+	timeSource := blockchain.NewMedianTime()
+	subsidy := blockchain.CalcBlockSubsidy(1, params)
+	block := btcutil.NewBlock(params.GenesisBlock)
+	block.SetHeight(0)
+	for i := 0; i < 3; i++ {
+		block = newTestBlock(block, subsidy, 0)
+		_, err := chain.ProcessBlock(block, timeSource, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock: %v", err)
+		}
+	}
+
+	want := blockchain.VersionUpgrade{
+		Version:         2,
+		Found:           3,
+		Window:          params.BlockUpgradeNumToCheck,
+		EnforceRequired: params.BlockEnforceNumRequired,
+		RejectRequired:  params.BlockRejectNumRequired,
+	}
+	upgrade := chain.VersionUpgradeStatus(2)
+	if *upgrade != want {
+		t.Errorf("VersionUpgradeStatus: got %+v, want %+v", *upgrade,
+			want)
+	}
+	if upgrade.Enforced() || upgrade.Rejecting() {
+		t.Errorf("VersionUpgradeStatus: upgrade reported as enforced " +
+			"before reaching the majority")
+	}
+	if found := chain.VersionUpgradeStatus(1).Found; found != 4 {
+		t.Errorf("VersionUpgradeStatus: got %d blocks of version 1, "+
+			"want 4", found)
+	}
+
+	// The blocks encode the chain ID in their version, which must not be
+	// mistaken for a higher block version.
+	if found := chain.VersionUpgradeStatus(3).Found; found != 0 {
+		t.Errorf("VersionUpgradeStatus: got %d blocks of version 3, "+
+			"want 0", found)
+	}
+
+	// All blocks share the same difficulty, so the chain work is the work
+	// of a single block times the number of blocks.
+	wantWork := blockchain.CalcWork(params.PowLimitBits)
+	wantWork.Mul(wantWork, big.NewInt(4))
+	if work := chain.BestChainWork(); work == nil || work.Cmp(wantWork) != 0 {
+		t.Errorf("BestChainWork: got %v, want %v", work, wantWork)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/melange-app/nmcd/wire"
+)
+
+// ChainTipStatus represents the validation state of the branch of the block
+// chain which ends at a chain tip.
+type ChainTipStatus int
+
+// Constants for the status of a chain tip.
+const (
+	// StatusActive indicates the tip is the end of the main chain.
+	StatusActive ChainTipStatus = iota
+
+	// StatusValidFork indicates the tip is the end of a side chain whose
+	// blocks are not known to violate any rules.
+	StatusValidFork
+
+	// StatusHeadersOnly indicates the headers of the branch are known and
+	// valid, but not all of its blocks are available.  This is the case
+	// for side chains whose blocks are missing from the side chain block
+	// cache.
+	StatusHeadersOnly
+
+	// StatusInvalid indicates the branch contains a block which failed
+	// to connect to the main chain.
+	StatusInvalid
+)
+
+// chainTipStatusStrings is a map of chain tip statuses back to the names used
+// for them by the getchaintips RPC.
+var chainTipStatusStrings = map[ChainTipStatus]string{
+	StatusActive:      "active",
+	StatusValidFork:   "valid-fork",
+	StatusHeadersOnly: "headers-only",
+	StatusInvalid:     "invalid",
+}
+
+// String returns the ChainTipStatus in human-readable form.
+func (s ChainTipStatus) String() string {
+	if str, ok := chainTipStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown ChainTipStatus (%d)", int(s))
+}
+
+// ChainTip describes a block without any known children, which makes it the
+// end of either the main chain or one of its branches.
+type ChainTip struct {
+	// Height is the height of the tip.
+	Height int64
+
+	// Hash is the hash of the tip.
+	Hash wire.ShaHash
+
+	// BranchLen is the number of blocks between the tip and the main
+	// chain.  It is zero for the tip of the main chain.
+	BranchLen int64
+
+	// Status is the validation state of the branch.
+	Status ChainTipStatus
+}
+
+// chainTipSorter implements sort.Interface to allow a slice of chain tips to
+// be sorted by descending height.
+type chainTipSorter []ChainTip
+
+// Len returns the number of chain tips in the slice.  It is part of the
+// sort.Interface implementation.
+func (s chainTipSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the chain tips at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s chainTipSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the chain tip with index i should sort before the chain
+// tip with index j.  It is part of the sort.Interface implementation.
+func (s chainTipSorter) Less(i, j int) bool {
+	if s[i].Height != s[j].Height {
+		return s[i].Height > s[j].Height
+	}
+	return s[i].Hash.String() < s[j].Hash.String()
+}
+
+// ChainTips returns the tips of every branch of the block chain which is
+// currently held in memory, including the main chain, sorted by descending
+// height.  Branches which forked from the main chain before the oldest block
+// node kept in memory are no longer known and therefore not included.
+//
+// This function is NOT safe for concurrent access.
+func (b *BlockChain) ChainTips() []ChainTip {
+	var tips []ChainTip
+	for _, node := range b.index {
+		// The end of the main chain is always a tip, even when it has
+		// children on invalid branches.
+		if node == b.bestChain {
+			tips = append(tips, ChainTip{
+				Height: node.height,
+				Hash:   *node.hash,
+				Status: StatusActive,
+			})
+			continue
+		}
+		if node.inMainChain || len(node.children) != 0 {
+			continue
+		}
+
+		// Walk the branch back to the main chain to find its length and
+		// whether any of its blocks are invalid or missing.  The parent
+		// of the first block of the branch is on the main chain even
+		// when it has already been pruned from memory.
+		tip := ChainTip{
+			Height: node.height,
+			Hash:   *node.hash,
+			Status: StatusValidFork,
+		}
+		missing := false
+		for n := node; n != nil && !n.inMainChain; n = n.parent {
+			if n.invalid {
+				tip.Status = StatusInvalid
+			}
+			if _, exists := b.blockCache[*n.hash]; !exists {
+				missing = true
+			}
+			tip.BranchLen++
+		}
+		if missing && tip.Status != StatusInvalid {
+			tip.Status = StatusHeadersOnly
+		}
+		tips = append(tips, tip)
+	}
+
+	sort.Sort(chainTipSorter(tips))
+	return tips
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/btcutil"
+)
+
+// chainTip returns the chain tip expected for the passed block.
+func chainTip(block *btcutil.Block, branchLen int64, status blockchain.ChainTipStatus) blockchain.ChainTip {
+	hash, _ := block.Sha()
+	return blockchain.ChainTip{
+		Height:    block.Height(),
+		Hash:      *hash,
+		BranchLen: branchLen,
+		Status:    status,
+	}
+}
+
+// TestChainTips ensures the tips of the main chain and its branches are
+// reported with the expected branch lengths and statuses as blocks extend the
+// chain, fork it, reorganize it, fail to connect and go missing.
+func TestChainTips(t *testing.T) {
+	params := regTestParams()
+	chain, teardownFunc, err := chainSetupParams("chaintips", params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	genesis.SetHeight(0)
+	subsidy := blockchain.CalcBlockSubsidy(1, params)
+
+	timeSource := blockchain.NewMedianTime()
+	process := func(block *btcutil.Block) error {
+		_, err := chain.ProcessBlock(block, timeSource, blockchain.BFNone)
+		return err
+	}
+	checkTips := func(desc string, want ...blockchain.ChainTip) {
+		tips := chain.ChainTips()
+		if !reflect.DeepEqual(tips, want) {
+			t.Fatalf("ChainTips (%s): got %s, want %s", desc,
+				spew.Sdump(tips), spew.Sdump(want))
+		}
+	}
+
+	// Build the main chain genesis -> a1 -> a2 -> a3.
+	a1 := newTestBlock(genesis, subsidy, 0)
+	a2 := newTestBlock(a1, subsidy, 0)
+	a3 := newTestBlock(a2, subsidy, 0)
+	for _, block := range []*btcutil.Block{a1, a2, a3} {
+		if err := process(block); err != nil {
+			t.Fatalf("ProcessBlock: %v", err)
+		}
+	}
+	checkTips("main chain", chainTip(a3, 0, blockchain.StatusActive))
+
+	// Fork the chain at a1 without causing a reorganize.
+	b2 := newTestBlock(a1, subsidy, 1)
+	if err := process(b2); err != nil {
+		t.Fatalf("ProcessBlock: %v", err)
+	}
+	checkTips("fork", chainTip(a3, 0, blockchain.StatusActive),
+		chainTip(b2, 1, blockchain.StatusValidFork))
+
+	// Extend the fork until it becomes the main chain.
+	b3 := newTestBlock(b2, subsidy, 1)
+	b4 := newTestBlock(b3, subsidy, 1)
+	for _, block := range []*btcutil.Block{b3, b4} {
+		if err := process(block); err != nil {
+			t.Fatalf("ProcessBlock: %v", err)
+		}
+	}
+	checkTips("reorganize", chainTip(b4, 0, blockchain.StatusActive),
+		chainTip(a3, 2, blockchain.StatusValidFork))
+
+	// Extend the old main chain with a block paying too much, which fails
+	// to connect when the branch would become the main chain again.
+	a4 := newTestBlock(a3, subsidy, 0)
+	a5 := newTestBlock(a4, subsidy+1, 0)
+	if err := process(a4); err != nil {
+		t.Fatalf("ProcessBlock: %v", err)
+	}
+	if err := process(a5); err == nil {
+		t.Fatalf("ProcessBlock: did not reject block paying too much")
+	}
+	checkTips("invalid", chainTip(a5, 4, blockchain.StatusInvalid),
+		chainTip(b4, 0, blockchain.StatusActive))
+
+	// A branch with a block missing from the side chain block cache only
+	// has the header of that block available.
+	c4 := newTestBlock(b3, subsidy, 2)
+	if err := process(c4); err != nil {
+		t.Fatalf("ProcessBlock: %v", err)
+	}
+	c4Hash, _ := c4.Sha()
+	chain.TstRemoveSideChainBlock(c4Hash)
+	checkTips("missing block", chainTip(a5, 4, blockchain.StatusInvalid),
+		chainTip(c4, 1, blockchain.StatusHeadersOnly),
+		chainTip(b4, 0, blockchain.StatusActive))
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/melange-app/nmcd/blockchain"
 	"github.com/melange-app/nmcd/chaincfg"
@@ -55,6 +56,12 @@ func isSupportedDbType(dbType string) bool {
 // block already inserted.  In addition to the new chain instnce, it returns
 // a teardown function the caller should invoke when done testing to clean up.
 func chainSetup(dbName string) (*blockchain.BlockChain, func(), error) {
+	return chainSetupParams(dbName, &chaincfg.MainNetParams)
+}
+
+// chainSetupParams is the same as chainSetup except the chain instance is
+// created for the passed network.
+func chainSetupParams(dbName string, params *chaincfg.Params) (*blockchain.BlockChain, func(), error) {
 	if !isSupportedDbType(testDbType) {
 		return nil, nil, fmt.Errorf("unsupported db type %v", testDbType)
 	}
@@ -106,9 +113,9 @@ func chainSetup(dbName string) (*blockchain.BlockChain, func(), error) {
 		}
 	}
 
-	// Insert the main network genesis block.  This is part of the initial
-	// database setup.
-	genesisBlock := btcutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	// Insert the genesis block of the network.  This is part of the
+	// initial database setup.
+	genesisBlock := btcutil.NewBlock(params.GenesisBlock)
 	_, err := db.InsertBlock(genesisBlock)
 	if err != nil {
 		teardown()
@@ -116,10 +123,68 @@ func chainSetup(dbName string) (*blockchain.BlockChain, func(), error) {
 		return nil, nil, err
 	}
 
-	chain := blockchain.New(db, &chaincfg.MainNetParams, nil)
+	chain := blockchain.New(db, params, nil)
 	return chain, teardown, nil
 }
 
+// regTestParams returns a copy of the regression test network parameters for
+// use with chainSetupParams and newTestBlock.  The genesis hash is derived from
+// the genesis block since the two are not kept in sync for the test networks.
+func regTestParams() *chaincfg.Params {
+	params := chaincfg.RegressionNetParams
+	genesisHash, _ := params.GenesisBlock.Header.BlockSha()
+	params.GenesisHash = &genesisHash
+	return &params
+}
+
+// newTestBlock returns a block for the regression test network which extends
+// the passed parent block with a coinbase paying the passed value.  The extra
+// nonce is added to the coinbase script to tell apart blocks built on the same
+// parent.
+func newTestBlock(parent *btcutil.Block, value int64, extraNonce byte) *btcutil.Block {
+	params := &chaincfg.RegressionNetParams
+	height := parent.Height() + 1
+	parentHash, _ := parent.Sha()
+
+	coinbaseTx := wire.NewMsgTx()
+	coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&wire.ShaHash{},
+		wire.MaxPrevOutIndex), []byte{0x02, byte(height),
+		byte(height >> 8), 0x01, extraNonce}))
+	coinbaseTx.AddTxOut(wire.NewTxOut(value, []byte{0x51}))
+
+	var msgBlock wire.MsgBlock
+	msgBlock.Header = wire.BlockHeader{
+		PrevBlock: *parentHash,
+		Timestamp: parent.MsgBlock().Header.Timestamp.Add(
+			10 * time.Minute),
+		Bits: params.PowLimitBits,
+	}
+	msgBlock.Header.SetBaseVersion(2, params.AuxPowChainID)
+	msgBlock.AddTransaction(coinbaseTx)
+	solveTestBlock(&msgBlock)
+
+	block := btcutil.NewBlock(&msgBlock)
+	block.SetHeight(height)
+	return block
+}
+
+// solveTestBlock updates the merkle root of the passed block for its
+// transactions and finds a nonce which satisfies its target difficulty.
+func solveTestBlock(msgBlock *wire.MsgBlock) {
+	block := btcutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	target := blockchain.CompactToBig(msgBlock.Header.Bits)
+	for {
+		hash, _ := msgBlock.Header.BlockSha()
+		if blockchain.ShaHashToBig(&hash).Cmp(target) <= 0 {
+			break
+		}
+		msgBlock.Header.Nonce++
+	}
+}
+
 // loadTxStore returns a transaction store loaded from a file.
 func loadTxStore(filename string) (blockchain.TxStore, error) {
 	// The txstore file format is:
@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/melange-app/nmcd/chaincfg"
 	"github.com/melange-app/nmcd/wire"
 )
 
@@ -313,9 +314,18 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 		return 0, fmt.Errorf("unable to obtain previous retarget block")
 	}
 
+	return calcRetargetDifficulty(b.chainParams, lastNode.height,
+		lastNode.bits, firstNode.timestamp, lastNode.timestamp), nil
+}
+
+// calcRetargetDifficulty calculates the required difficulty for the block
+// after the passed height, which must be at a difficulty retarget interval,
+// from the difficulty bits of the previous block and the timestamps of the
+// first and the last block of the previous retarget interval.
+func calcRetargetDifficulty(chainParams *chaincfg.Params, lastHeight int64, lastBits uint32, firstTime, lastTime time.Time) uint32 {
 	// Limit the amount of adjustment that can occur to the previous
 	// difficulty.
-	actualTimespan := lastNode.timestamp.UnixNano() - firstNode.timestamp.UnixNano()
+	actualTimespan := lastTime.UnixNano() - firstTime.UnixNano()
 	adjustedTimespan := actualTimespan
 	if actualTimespan < minRetargetTimespan {
 		adjustedTimespan = minRetargetTimespan
@@ -328,13 +338,13 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 	// The result uses integer division which means it will be slightly
 	// rounded down.  Bitcoind also uses integer division to calculate this
 	// result.
-	oldTarget := CompactToBig(lastNode.bits)
+	oldTarget := CompactToBig(lastBits)
 	newTarget := new(big.Int).Mul(oldTarget, big.NewInt(adjustedTimespan))
 	newTarget.Div(newTarget, big.NewInt(int64(targetTimespan)))
 
 	// Limit new value to the proof of work limit.
-	if newTarget.Cmp(b.chainParams.PowLimit) > 0 {
-		newTarget.Set(b.chainParams.PowLimit)
+	if newTarget.Cmp(chainParams.PowLimit) > 0 {
+		newTarget.Set(chainParams.PowLimit)
 	}
 
 	// Log new target difficulty and return it.  The new target logging is
@@ -342,14 +352,85 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 	// newTarget since conversion to the compact representation loses
 	// precision.
 	newTargetBits := BigToCompact(newTarget)
-	log.Debugf("Difficulty retarget at block height %d", lastNode.height+1)
-	log.Debugf("Old target %08x (%064x)", lastNode.bits, oldTarget)
+	log.Debugf("Difficulty retarget at block height %d", lastHeight+1)
+	log.Debugf("Old target %08x (%064x)", lastBits, oldTarget)
 	log.Debugf("New target %08x (%064x)", newTargetBits, CompactToBig(newTargetBits))
 	log.Debugf("Actual timespan %v, adjusted timespan %v, target timespan %v",
 		time.Duration(actualTimespan), time.Duration(adjustedTimespan),
 		targetTimespan)
 
-	return newTargetBits, nil
+	return newTargetBits
+}
+
+// HeaderDifficultyFunc returns the difficulty bits and the timestamp of the
+// block at the passed height of a chain of block headers.
+type HeaderDifficultyFunc func(height int64) (uint32, time.Time, error)
+
+// CalcNextRequiredHeaderDifficulty calculates the required difficulty for the
+// block after the block at the passed height based on the difficulty retarget
+// rules.  Unlike CalcNextRequiredDifficulty, the chain does not need to be
+// known to the block chain, which allows the difficulty of block headers to be
+// verified before their blocks are downloaded.  The passed function provides
+// the difficulty bits and timestamps of the blocks of the chain by height.
+func CalcNextRequiredHeaderDifficulty(chainParams *chaincfg.Params, lastHeight int64, newBlockTime time.Time, fetch HeaderDifficultyFunc) (uint32, error) {
+	// Genesis block.
+	if lastHeight < 0 {
+		return chainParams.PowLimitBits, nil
+	}
+	lastBits, lastTime, err := fetch(lastHeight)
+	if err != nil {
+		return 0, err
+	}
+
+	// Return the previous block's difficulty requirements if this block
+	// is not at a difficulty retarget interval.
+	if (lastHeight+1)%BlocksPerRetarget != 0 {
+		// The test network rules allow minimum difficulty blocks after
+		// more than twice the desired amount of time needed to generate
+		// a block has elapsed.
+		if chainParams.ResetMinDifficulty {
+			// Return minimum difficulty when more than twice the
+			// desired amount of time needed to generate a block has
+			// elapsed.
+			allowMinTime := lastTime.Add(targetSpacing * 2)
+			if newBlockTime.After(allowMinTime) {
+				return chainParams.PowLimitBits, nil
+			}
+
+			// The block was mined within the desired timeframe, so
+			// return the difficulty for the last block which did
+			// not have the special minimum difficulty rule applied.
+			height, bits := lastHeight, lastBits
+			for height%BlocksPerRetarget != 0 &&
+				bits == chainParams.PowLimitBits {
+
+				height--
+				bits, _, err = fetch(height)
+				if err != nil {
+					return 0, err
+				}
+			}
+			return bits, nil
+		}
+
+		// For the main network (or any unrecognized networks), simply
+		// return the previous block's difficulty requirements.
+		return lastBits, nil
+	}
+
+	// Get the timestamp of the block at the previous retarget
+	// (targetTimespan days worth of blocks).
+	firstHeight := lastHeight - (BlocksPerRetarget - 1)
+	if firstHeight < 0 {
+		return 0, fmt.Errorf("unable to obtain previous retarget block")
+	}
+	_, firstTime, err := fetch(firstHeight)
+	if err != nil {
+		return 0, err
+	}
+
+	return calcRetargetDifficulty(chainParams, lastHeight, lastBits,
+		firstTime, lastTime), nil
 }
 
 // CalcNextRequiredDifficulty calculates the required difficulty for the block
@@ -7,8 +7,10 @@ package blockchain_test
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/chaincfg"
 )
 
 func TestBigToCompact(t *testing.T) {
@@ -69,3 +71,114 @@ func TestCalcWork(t *testing.T) {
 		}
 	}
 }
+
+// TestCalcNextRequiredHeaderDifficulty ensures the difficulty of block headers
+// follows the retarget rules, including the limits on the adjustment and the
+// minimum difficulty blocks of the test networks.
+func TestCalcNextRequiredHeaderDifficulty(t *testing.T) {
+	start := time.Unix(1300000000, 0)
+	const spacing = 10 * time.Minute
+	const targetTimespan = 14 * 24 * time.Hour
+	const interval = blockchain.BlocksPerRetarget
+
+	// chain returns a function which provides headers with the passed
+	// difficulty bits where the headers after the first retarget interval
+	// are the passed timespan later than the ones before.  The header at
+	// minHeight has the minimum difficulty of the regression test network.
+	regtestBits := chaincfg.RegressionNetParams.PowLimitBits
+	chain := func(bits uint32, timespan time.Duration, minHeight int64) blockchain.HeaderDifficultyFunc {
+		return func(height int64) (uint32, time.Time, error) {
+			timestamp := start
+			if height > interval {
+				timestamp = start.Add(timespan)
+			}
+			if height == minHeight {
+				return regtestBits, timestamp, nil
+			}
+			return bits, timestamp, nil
+		}
+	}
+
+	tests := []struct {
+		name      string
+		params    *chaincfg.Params
+		height    int64
+		blockTime time.Duration // time after the previous block
+		fetch     blockchain.HeaderDifficultyFunc
+		want      uint32
+	}{
+		{
+			name:   "genesis",
+			params: &chaincfg.MainNetParams,
+			height: -1,
+			fetch:  chain(0x1b00b269, targetTimespan, -1),
+			want:   chaincfg.MainNetParams.PowLimitBits,
+		},
+		{
+			name:   "no retarget",
+			params: &chaincfg.MainNetParams,
+			height: interval + 5,
+			fetch:  chain(0x1b00b269, targetTimespan/2, -1),
+			want:   0x1b00b269,
+		},
+		{
+			name:   "retarget on target",
+			params: &chaincfg.MainNetParams,
+			height: 2*interval - 1,
+			fetch:  chain(0x1b00b269, targetTimespan, -1),
+			want:   0x1b00b269,
+		},
+		{
+			name:   "retarget twice as fast",
+			params: &chaincfg.MainNetParams,
+			height: 2*interval - 1,
+			fetch:  chain(0x1b00b269, targetTimespan/2, -1),
+			want:   0x1a593480,
+		},
+		{
+			name:   "retarget limited",
+			params: &chaincfg.MainNetParams,
+			height: 2*interval - 1,
+			fetch:  chain(0x1b00b269, time.Second, -1),
+			want:   0x1a2c9a40,
+		},
+		{
+			name:   "retarget limited by pow limit",
+			params: &chaincfg.MainNetParams,
+			height: 2*interval - 1,
+			fetch:  chain(0x1d00ffff, 10*targetTimespan, -1),
+			want:   0x1d00ffff,
+		},
+		{
+			name:      "minimum difficulty after delay",
+			params:    &chaincfg.TestNet3Params,
+			height:    interval + 5,
+			blockTime: 2*spacing + time.Second,
+			fetch:     chain(0x1c0fffff, targetTimespan, -1),
+			want:      chaincfg.TestNet3Params.PowLimitBits,
+		},
+		{
+			name:      "difficulty before minimum difficulty blocks",
+			params:    &chaincfg.RegressionNetParams,
+			height:    interval + 5,
+			blockTime: spacing,
+			fetch:     chain(0x1f00ffff, targetTimespan, interval+5),
+			want:      0x1f00ffff,
+		},
+	}
+
+	for _, test := range tests {
+		_, lastTime, _ := test.fetch(test.height)
+		bits, err := blockchain.CalcNextRequiredHeaderDifficulty(
+			test.params, test.height, lastTime.Add(test.blockTime),
+			test.fetch)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if bits != test.want {
+			t.Errorf("%s: got bits %08x, want %08x", test.name,
+				bits, test.want)
+		}
+	}
+}
@@ -182,6 +182,29 @@ const (
 	// ErrAuxPowValidation indicates that there was an error while attempting
 	// to validate the AuxPow header of the block.
 	ErrAuxPowValidation
+
+	// ErrNameValidation indicates a transaction performs a name operation
+	// which violates the Namecoin name rules.
+	ErrNameValidation
+
+	// ErrWrongChainID indicates a block does not carry the merged mining
+	// chain ID of the network in its version or that the parent block in
+	// its AuxPow header does.
+	ErrWrongChainID
+
+	// ErrAuxPowTooEarly indicates a merged mined block appeared before the
+	// height merged mining starts at on the network.
+	ErrAuxPowTooEarly
+
+	// ErrLegacyBlockVersion indicates a block uses the legacy version 1,
+	// which predates chain IDs, after merged mining started on a network
+	// which enforces chain IDs.
+	ErrLegacyBlockVersion
+
+	// ErrInvalidAncestorBlock indicates a block was marked invalid, either
+	// manually or because it failed to connect to the main chain, or that
+	// it builds on top of such a block.
+	ErrInvalidAncestorBlock
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -225,6 +248,11 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrScriptMalformed:       "ErrScriptMalformed",
 	ErrScriptValidation:      "ErrScriptValidation",
 	ErrAuxPowValidation:      "ErrAuxPowValidation",
+	ErrNameValidation:        "ErrNameValidation",
+	ErrWrongChainID:          "ErrWrongChainID",
+	ErrAuxPowTooEarly:        "ErrAuxPowTooEarly",
+	ErrLegacyBlockVersion:    "ErrLegacyBlockVersion",
+	ErrInvalidAncestorBlock:  "ErrInvalidAncestorBlock",
 }
 
 // String returns the ErrorCode as a human-readable name.
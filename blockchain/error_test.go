@@ -55,6 +55,12 @@ func TestErrorCodeStringer(t *testing.T) {
 		{blockchain.ErrBadCoinbaseHeight, "ErrBadCoinbaseHeight"},
 		{blockchain.ErrScriptMalformed, "ErrScriptMalformed"},
 		{blockchain.ErrScriptValidation, "ErrScriptValidation"},
+		{blockchain.ErrAuxPowValidation, "ErrAuxPowValidation"},
+		{blockchain.ErrNameValidation, "ErrNameValidation"},
+		{blockchain.ErrWrongChainID, "ErrWrongChainID"},
+		{blockchain.ErrAuxPowTooEarly, "ErrAuxPowTooEarly"},
+		{blockchain.ErrLegacyBlockVersion, "ErrLegacyBlockVersion"},
+		{blockchain.ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -15,6 +15,8 @@ package blockchain
 import (
 	"sort"
 	"time"
+
+	"github.com/melange-app/nmcd/wire"
 )
 
 // TstSetCoinbaseMaturity makes the ability to set the coinbase maturity
@@ -42,3 +44,31 @@ func TstSetMaxMedianTimeEntries(val int) {
 // TstCheckBlockScripts makes the internal checkBlockScripts function available
 // to the test package.
 var TstCheckBlockScripts = checkBlockScripts
+
+// TstIsNameExpired makes the internal isNameExpired function available to the
+// test package.
+var TstIsNameExpired = isNameExpired
+
+// TstNameExpireHeight makes the internal nameExpireHeight function available
+// to the test package.
+var TstNameExpireHeight = nameExpireHeight
+
+// TstReadMergedMiningTransaction makes the internal readMergedMiningTransaction
+// function available to the test package.
+func TstReadMergedMiningTransaction(script []byte, root *wire.ShaHash) (wire.ShaHash, uint32, uint32, error) {
+	mm, err := readMergedMiningTransaction(script, root)
+	if err != nil {
+		return wire.ShaHash{}, 0, 0, err
+	}
+	return mm.BlockHash, mm.MerkleSize, mm.MerkleNonce, nil
+}
+
+// TstExpectedMergedMiningIndex makes the internal expectedMergedMiningIndex
+// function available to the test package.
+var TstExpectedMergedMiningIndex = expectedMergedMiningIndex
+
+// TstRemoveSideChainBlock makes the ability to remove a block from the side
+// chain block cache available to the test package.
+func (b *BlockChain) TstRemoveSideChainBlock(hash *wire.ShaHash) {
+	delete(b.blockCache, *hash)
+}
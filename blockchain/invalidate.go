@@ -0,0 +1,242 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/melange-app/nmcd/wire"
+)
+
+// loadInvalidBlocks loads the hashes of the blocks marked invalid from the
+// database.  Marked blocks are never part of the main chain unless the node
+// was stopped while the chain was being reorganized away from them, in which
+// case a warning is logged so the block can be invalidated again.
+func (b *BlockChain) loadInvalidBlocks() error {
+	hashes, err := b.db.FetchInvalidBlocks()
+	if err != nil {
+		return err
+	}
+
+	for i := range hashes {
+		hash := &hashes[i]
+		b.invalidBlocks[*hash] = struct{}{}
+
+		exists, err := b.db.ExistsSha(hash)
+		if err != nil {
+			return err
+		}
+		if exists {
+			log.Warnf("Block %v is marked invalid, but is part of "+
+				"the main chain", hash)
+		}
+	}
+
+	return nil
+}
+
+// isInvalidBranch returns whether the passed node or any of its ancestors back
+// to the main chain are marked invalid.
+func (b *BlockChain) isInvalidBranch(node *blockNode) bool {
+	for n := node; n != nil && !n.inMainChain; n = n.parent {
+		if n.invalid {
+			return true
+		}
+	}
+	return false
+}
+
+// isConnectableBranch returns whether the side chain which ends with the passed
+// node can be connected to the main chain.  That is the case when none of its
+// blocks are marked invalid, all of them are in the side chain block cache and
+// the branch is still linked to the main chain in memory.
+func (b *BlockChain) isConnectableBranch(node *blockNode) bool {
+	for n := node; !n.inMainChain; n = n.parent {
+		if n.invalid || n.parent == nil {
+			return false
+		}
+		if _, exists := b.blockCache[*n.hash]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// connectBestValidChain reorganizes the chain to the branch with the most
+// proof of work which can be connected.  A branch which turns out to violate
+// the rules while it is connected is marked invalid and the next best branch
+// is tried.
+func (b *BlockChain) connectBestValidChain() error {
+	for {
+		best := b.bestChain
+		for _, node := range b.index {
+			if node.inMainChain || node.workSum.Cmp(best.workSum) <= 0 {
+				continue
+			}
+			if b.isConnectableBranch(node) {
+				best = node
+			}
+		}
+
+		// Nothing to do when the main chain is already the best one.
+		if best == b.bestChain {
+			return nil
+		}
+
+		log.Infof("REORGANIZE: Block %v is the end of the best valid "+
+			"chain.", best.hash)
+		detachNodes, attachNodes := b.getReorganizeNodes(best)
+		err := b.reorganizeChain(detachNodes, attachNodes, BFNone)
+		if err != nil {
+			// The block which violates the rules is marked invalid
+			// by reorganizeChain, so the branch won't be selected
+			// again.
+			if _, ok := err.(RuleError); ok {
+				log.Infof("REORGANIZE: Unable to connect block "+
+					"%v: %v", best.hash, err)
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// lookupNode returns the block node for the passed hash.  Main chain blocks
+// which are no longer held in memory are loaded from the database.  The
+// returned node is nil when the block is not known.
+func (b *BlockChain) lookupNode(hash *wire.ShaHash) (*blockNode, error) {
+	if node, ok := b.index[*hash]; ok {
+		return node, nil
+	}
+
+	exists, err := b.db.ExistsSha(hash)
+	if err != nil || !exists {
+		return nil, err
+	}
+	height, err := b.db.FetchBlockHeightBySha(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the main chain backwards, loading the nodes which are missing,
+	// until the block is reached.
+	node := b.bestChain
+	for node != nil && node.height > height {
+		node, err = b.getPrevNodeFromNode(node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if node == nil || !node.hash.IsEqual(hash) {
+		return nil, fmt.Errorf("unable to find block %v in the main "+
+			"chain", hash)
+	}
+	return node, nil
+}
+
+// InvalidateBlock marks the block with the passed hash invalid, which also
+// makes all of its descendants invalid.  When the block is part of the main
+// chain, it is disconnected along with the blocks after it and the chain is
+// reorganized to the valid branch with the most proof of work.  The mark is
+// stored in the database so the block stays rejected after a restart until it
+// is reconsidered with ReconsiderBlock.
+//
+// This function is NOT safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *wire.ShaHash) error {
+	if hash.IsEqual(b.chainParams.GenesisHash) {
+		return fmt.Errorf("the genesis block can not be invalidated")
+	}
+
+	node, err := b.lookupNode(hash)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("block %v is not known", hash)
+	}
+
+	// Store the mark before touching the chain so it isn't lost should the
+	// node be stopped during the reorganize.
+	err = b.db.MarkBlockInvalid(hash)
+	if err != nil {
+		return err
+	}
+	b.invalidBlocks[*hash] = struct{}{}
+	node.invalid = true
+
+	// Disconnect the block and all blocks after it from the main chain.
+	// They are kept in the side chain block cache so they can be connected
+	// again when the block is reconsidered.
+	for node.inMainChain {
+		block, err := b.db.FetchBlockBySha(b.bestChain.hash)
+		if err != nil {
+			return err
+		}
+		err = b.disconnectBlock(b.bestChain, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.connectBestValidChain()
+}
+
+// ReconsiderBlock removes the invalid mark from the block with the passed hash
+// as well as from its ancestors and descendants, including the blocks which
+// were marked because they failed to connect.  The chain is then reorganized
+// to the valid branch with the most proof of work, which validates the blocks
+// again.
+//
+// Blocks which were marked invalid before a restart are no longer held in
+// memory.  Their mark is only removed, so they are accepted again when they
+// are received the next time.
+//
+// This function is NOT safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *wire.ShaHash) error {
+	// unmark removes the invalid mark of the passed block, including the
+	// one stored in the database.
+	unmark := func(hash *wire.ShaHash) error {
+		if _, ok := b.invalidBlocks[*hash]; !ok {
+			return nil
+		}
+		err := b.db.UnmarkBlockInvalid(hash)
+		if err != nil {
+			return err
+		}
+		delete(b.invalidBlocks, *hash)
+		return nil
+	}
+
+	node, ok := b.index[*hash]
+	if !ok {
+		if _, ok := b.invalidBlocks[*hash]; !ok {
+			return fmt.Errorf("block %v is not known", hash)
+		}
+		return unmark(hash)
+	}
+
+	// Remove the marks of the block and its ancestors back to the main
+	// chain.
+	for n := node; n != nil && !n.inMainChain; n = n.parent {
+		n.invalid = false
+		if err := unmark(n.hash); err != nil {
+			return err
+		}
+	}
+
+	// Remove the marks of all descendants of the block.
+	children := append([]*blockNode(nil), node.children...)
+	for len(children) > 0 {
+		n := children[len(children)-1]
+		children = append(children[:len(children)-1], n.children...)
+
+		n.invalid = false
+		if err := unmark(n.hash); err != nil {
+			return err
+		}
+	}
+
+	return b.connectBestValidChain()
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/btcutil"
+)
+
+// TestInvalidateBlock ensures invalidating a block reorganizes the chain to the
+// best valid branch, blocks building on top of the invalid block are rejected
+// and reconsidering the block reorganizes the chain back to it.
+func TestInvalidateBlock(t *testing.T) {
+	params := regTestParams()
+	chain, teardownFunc, err := chainSetupParams("invalidateblock", params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	genesis.SetHeight(0)
+	subsidy := blockchain.CalcBlockSubsidy(1, params)
+
+	timeSource := blockchain.NewMedianTime()
+	process := func(block *btcutil.Block) error {
+		_, err := chain.ProcessBlock(block, timeSource, blockchain.BFNone)
+		return err
+	}
+	checkBest := func(desc string, want *btcutil.Block) {
+		hash, _ := want.Sha()
+		for _, tip := range chain.ChainTips() {
+			if tip.Status != blockchain.StatusActive {
+				continue
+			}
+			if !tip.Hash.IsEqual(hash) {
+				t.Fatalf("%s: got best chain %v, want %v", desc,
+					tip.Hash, hash)
+			}
+			return
+		}
+		t.Fatalf("%s: no best chain", desc)
+	}
+	checkTips := func(desc string, want ...blockchain.ChainTip) {
+		tips := chain.ChainTips()
+		if !reflect.DeepEqual(tips, want) {
+			t.Fatalf("ChainTips (%s): got %s, want %s", desc,
+				spew.Sdump(tips), spew.Sdump(want))
+		}
+	}
+
+	// Build the main chain genesis -> a1 -> a2 -> a3 and the side chain
+	// a1 -> b2.
+	a1 := newTestBlock(genesis, subsidy, 0)
+	a2 := newTestBlock(a1, subsidy, 0)
+	a3 := newTestBlock(a2, subsidy, 0)
+	b2 := newTestBlock(a1, subsidy, 1)
+	for _, block := range []*btcutil.Block{a1, a2, a3, b2} {
+		if err := process(block); err != nil {
+			t.Fatalf("ProcessBlock: %v", err)
+		}
+	}
+	checkBest("initial", a3)
+
+	// Invalidating a2 makes the side chain the best valid chain.
+	a2Hash, _ := a2.Sha()
+	if err := chain.InvalidateBlock(a2Hash); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkBest("invalidate", b2)
+	checkTips("invalidate", chainTip(a3, 2, blockchain.StatusInvalid),
+		chainTip(b2, 0, blockchain.StatusActive))
+
+	// Blocks which build on top of the invalid block are rejected even
+	// though their branch has the most proof of work.
+	a4 := newTestBlock(a3, subsidy, 0)
+	err = process(a4)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrInvalidAncestorBlock {
+
+		t.Fatalf("ProcessBlock: got %v, want ErrInvalidAncestorBlock",
+			err)
+	}
+	checkBest("invalid ancestor", b2)
+
+	// Reconsidering a descendant of the invalid block also removes the
+	// mark of the block, which makes the original chain the best one
+	// again.
+	a3Hash, _ := a3.Sha()
+	if err := chain.ReconsiderBlock(a3Hash); err != nil {
+		t.Fatalf("ReconsiderBlock: %v", err)
+	}
+	checkBest("reconsider", a3)
+	checkTips("reconsider", chainTip(a3, 0, blockchain.StatusActive),
+		chainTip(b2, 1, blockchain.StatusValidFork))
+	if err := process(a4); err != nil {
+		t.Fatalf("ProcessBlock: %v", err)
+	}
+	checkBest("extend", a4)
+
+	// Invalidating the end of the main chain simply disconnects it since
+	// the side chain does not have more proof of work than the new end.
+	a4Hash, _ := a4.Sha()
+	if err := chain.InvalidateBlock(a4Hash); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkBest("invalidate tip", a3)
+	checkTips("invalidate tip", chainTip(a4, 1, blockchain.StatusInvalid),
+		chainTip(a3, 0, blockchain.StatusActive),
+		chainTip(b2, 1, blockchain.StatusValidFork))
+
+	// Unknown blocks and the genesis block can not be invalidated.
+	b3 := newTestBlock(b2, subsidy, 1)
+	b3Hash, _ := b3.Sha()
+	if err := chain.InvalidateBlock(b3Hash); err == nil {
+		t.Fatalf("InvalidateBlock: did not reject unknown block")
+	}
+	if err := chain.InvalidateBlock(params.GenesisHash); err == nil {
+		t.Fatalf("InvalidateBlock: did not reject genesis block")
+	}
+}
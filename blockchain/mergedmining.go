@@ -1,68 +1,119 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
 package blockchain
 
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/hex"
+	"fmt"
 
 	"github.com/melange-app/nmcd/wire"
 )
 
-type mergedMiningTransaction struct {
-	// BlockHash is the hash of the AuxPow Block Header
-	BlockHash wire.ShaHash
+const (
+	// maxMergedMiningRootOffset is the maximum offset into the parent
+	// coinbase script at which the merged mining merkle root may start
+	// when it is not preceded by the merged mining magic bytes.  This is
+	// enough room for the extra nonce and bits that parent chain miners
+	// used to put in front of it.
+	maxMergedMiningRootOffset = 20
 
-	// MerkleSize is the number of entries in the aux work
-	// merkle tree (probably just 1).
-	MerkleSize int32
-
-	// MerkleNonce is the nonce use to calculate indexes.
-	// Generally left as 0.
-	MerkleNonce int32
-}
+	// mergedMiningTrailerLen is the number of bytes following the merged
+	// mining merkle root in the parent coinbase script.  They encode the
+	// size of the merged mining merkle tree and the nonce used to pick the
+	// slot of each chain.
+	mergedMiningTrailerLen = 8
+)
 
+// mergedMiningMagicBytes are the bytes that mark the start of the merged
+// mining merkle root in the parent coinbase script.
 var mergedMiningMagicBytes = []byte{0xfa, 0xbe, 'm', 'm'}
 
-func readMergedMiningTransaction(t *wire.TxIn, blockHash *wire.ShaHash, root *wire.ShaHash) (*mergedMiningTransaction, error) {
-	script := t.SignatureScript
-
-	// Look for the magic number in the SignatureScript
-	idx := bytes.Index(script, mergedMiningMagicBytes)
+// mergedMiningTransaction houses the merged mining information a parent chain
+// block commits to in its coinbase script.
+type mergedMiningTransaction struct {
+	// BlockHash is the root of the merged mining merkle tree.  It is the
+	// hash of the merged mined block itself when only a single chain is
+	// merged mined.
+	BlockHash wire.ShaHash
 
-	if idx == -1 {
-		// Some block apparently omit "0xfabe6d6d", we will just look for the
-		// hash...
-		blockBytes, _ := hex.DecodeString(blockHash.String())
-		idx = bytes.Index(script, blockBytes)
-		if idx == -1 {
+	// MerkleSize is the number of leaves in the merged mining merkle
+	// tree.
+	MerkleSize uint32
 
-			blockBytes, _ := hex.DecodeString(root.String())
-			idx = bytes.Index(script, blockBytes)
+	// MerkleNonce is the nonce used to calculate the slot of each chain in
+	// the merged mining merkle tree.
+	MerkleNonce uint32
+}
 
-			if idx == -11 {
-				return nil, ruleError(
-					ErrAuxPowValidation,
-					"Unable to locate merged mining information in Coinbase.",
-				)
-			}
-		}
+// readMergedMiningTransaction extracts the merged mining information for the
+// passed merged mining merkle root from a parent coinbase script.  The root is
+// stored in the byte order it is displayed in and is either directly preceded
+// by the merged mining magic bytes or, for compatibility with older parent
+// blocks, starts within the first maxMergedMiningRootOffset bytes of the
+// script.  The root is followed by the size of the merkle tree and the merkle
+// nonce as little endian 32-bit integers.  A script may only commit to a single
+// merged mining merkle root.
+func readMergedMiningTransaction(script []byte, root *wire.ShaHash) (*mergedMiningTransaction, error) {
+	var rootBytes [wire.HashSize]byte
+	for i := 0; i < wire.HashSize; i++ {
+		rootBytes[i] = root[wire.HashSize-1-i]
+	}
 
-		idx = idx - len(mergedMiningMagicBytes)
+	rootIdx := bytes.Index(script, rootBytes[:])
+	if rootIdx == -1 {
+		return nil, ruleError(ErrAuxPowValidation, "parent coinbase "+
+			"does not commit to the merged mining merkle root")
 	}
 
-	data := script[idx+len(mergedMiningMagicBytes):]
+	magicIdx := bytes.Index(script, mergedMiningMagicBytes)
+	if magicIdx != -1 {
+		// Only a single merged mining header is allowed so the chain
+		// merkle root it commits to is unambiguous.
+		next := magicIdx + len(mergedMiningMagicBytes)
+		if bytes.Index(script[next:], mergedMiningMagicBytes) != -1 {
+			return nil, ruleError(ErrAuxPowValidation, "parent "+
+				"coinbase contains multiple merged mining "+
+				"headers")
+		}
+		if next != rootIdx {
+			return nil, ruleError(ErrAuxPowValidation, "merged "+
+				"mining header is not directly followed by the "+
+				"merged mining merkle root")
+		}
+	} else if rootIdx > maxMergedMiningRootOffset {
+		str := fmt.Sprintf("merged mining merkle root starts at "+
+			"offset %d of the parent coinbase without a merged "+
+			"mining header (max %d)", rootIdx,
+			maxMergedMiningRootOffset)
+		return nil, ruleError(ErrAuxPowValidation, str)
+	}
 
-	blockHashData := hex.EncodeToString(data[:32])
-	blockHash, err := wire.NewShaHashFromStr(blockHashData)
-	if err != nil {
-		return nil, err
+	trailer := script[rootIdx+wire.HashSize:]
+	if len(trailer) < mergedMiningTrailerLen {
+		return nil, ruleError(ErrAuxPowValidation, "parent coinbase "+
+			"is missing the merged mining merkle size and nonce")
 	}
 
-	merkleSizeData := data[32:36]
-	merkleNonceData := data[36:40]
 	return &mergedMiningTransaction{
-		BlockHash:   *blockHash,
-		MerkleSize:  int32(binary.LittleEndian.Uint32(merkleSizeData)),
-		MerkleNonce: int32(binary.LittleEndian.Uint32(merkleNonceData)),
+		BlockHash:   *root,
+		MerkleSize:  binary.LittleEndian.Uint32(trailer[0:4]),
+		MerkleNonce: binary.LittleEndian.Uint32(trailer[4:8]),
 	}, nil
 }
+
+// expectedMergedMiningIndex returns the slot a chain with the passed chain ID
+// must occupy in a merged mining merkle tree of the passed height which was
+// built with the passed merkle nonce.  Deriving the slot from the nonce and
+// chain ID prevents a parent block from committing to several blocks of the
+// same chain.
+func expectedMergedMiningIndex(nonce uint32, chainID int32, height uint) uint32 {
+	rand := nonce
+	rand = rand*1103515245 + 12345
+	rand += uint32(chainID)
+	rand = rand*1103515245 + 12345
+
+	return rand % (1 << height)
+}
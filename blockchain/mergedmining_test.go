@@ -0,0 +1,512 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/chaincfg"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// mmMagic are the merged mining magic bytes that mark the start of the merged
+// mining merkle root in a parent coinbase script.
+var mmMagic = []byte{0xfa, 0xbe, 0x6d, 0x6d}
+
+// mmRootBytes returns the passed merged mining merkle root in the byte order it
+// is stored in parent coinbase scripts.
+func mmRootBytes(root *wire.ShaHash) []byte {
+	b := make([]byte, wire.HashSize)
+	for i := 0; i < wire.HashSize; i++ {
+		b[i] = root[wire.HashSize-1-i]
+	}
+	return b
+}
+
+// mmCommitment returns the data a parent coinbase script uses to commit to the
+// passed merged mining merkle root along with the size and nonce of the merged
+// mining merkle tree.
+func mmCommitment(magic bool, root *wire.ShaHash, size, nonce uint32) []byte {
+	var b []byte
+	if magic {
+		b = append(b, mmMagic...)
+	}
+	b = append(b, mmRootBytes(root)...)
+	b = append(b, byte(size), byte(size>>8), byte(size>>16), byte(size>>24))
+	b = append(b, byte(nonce), byte(nonce>>8), byte(nonce>>16),
+		byte(nonce>>24))
+	return b
+}
+
+// mmHashPair returns the hash of the merkle tree node with the passed children.
+func mmHashPair(left, right *wire.ShaHash) *wire.ShaHash {
+	var b [wire.HashSize * 2]byte
+	copy(b[:wire.HashSize], left[:])
+	copy(b[wire.HashSize:], right[:])
+	hash, _ := wire.NewShaHash(wire.DoubleSha256(b[:]))
+	return hash
+}
+
+// mmChainTree builds a merged mining merkle tree from the passed leaves, the
+// number of which must be a power of two, and returns its root along with the
+// branch proving the leaf at the passed index is part of it.
+func mmChainTree(leaves []wire.ShaHash, index int) (*wire.ShaHash, wire.MerkleBranch) {
+	branch := wire.MerkleBranch{BranchSideMask: int32(index)}
+	level := leaves
+	for len(level) > 1 {
+		branch.BranchHash = append(branch.BranchHash, level[index^1])
+		next := make([]wire.ShaHash, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, *mmHashPair(&level[i], &level[i+1]))
+		}
+		level = next
+		index >>= 1
+	}
+	return &level[0], branch
+}
+
+// TestReadMergedMiningTransaction ensures the merged mining information is
+// extracted from parent coinbase scripts which follow the merged mining rules
+// and that scripts which don't are rejected without panicking.
+func TestReadMergedMiningTransaction(t *testing.T) {
+	root, _ := wire.NewShaHashFromStr("9d1fb0fb0db6f4e6d7ad1d2a0a3e3c9c" +
+		"c8bd04c2ba1ab1a4b61d4b74ab2c9e87")
+	other, _ := wire.NewShaHashFromStr("000000000000000000b3ef74e1fbc0c8" +
+		"a0d10b3dc1f3de3dd6e1fd2b8a4b3e0b")
+
+	// height is the serialized height of a parent block as parent chain
+	// miners push it in front of the merged mining data, while pool is
+	// the extra nonce and marker a mining pool appends to it.
+	height := []byte{0x03, 0x7b, 0x1a, 0x06}
+	pool := append([]byte{0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x0d}, "/slush/mined/"...)
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	commitment := mmCommitment(true, root, 8, 0x5a1e)
+
+	tests := []struct {
+		name   string
+		script []byte
+		size   uint32
+		nonce  uint32
+		valid  bool
+	}{
+		{
+			name: "single chain with header",
+			script: join(height, []byte{0x2c},
+				mmCommitment(true, root, 1, 0), pool),
+			size:  1,
+			valid: true,
+		},
+		{
+			name:   "multiple chains with header",
+			script: join(height, []byte{0x2c}, commitment, pool),
+			size:   8,
+			nonce:  0x5a1e,
+			valid:  true,
+		},
+		{
+			name: "header at the end of the script",
+			script: join(height, pool, []byte{0x2c},
+				commitment),
+			size:  8,
+			nonce: 0x5a1e,
+			valid: true,
+		},
+		{
+			name: "legacy root at the start of the script",
+			script: join(mmCommitment(false, root, 4, 7),
+				pool),
+			size:  4,
+			nonce: 7,
+			valid: true,
+		},
+		{
+			name: "legacy root at offset 20",
+			script: join(make([]byte, 20),
+				mmCommitment(false, root, 1, 0)),
+			size:  1,
+			valid: true,
+		},
+		{
+			name: "legacy root at offset 21",
+			script: join(make([]byte, 21),
+				mmCommitment(false, root, 1, 0)),
+		},
+		{
+			name: "multiple headers",
+			script: join(height, commitment, pool,
+				mmCommitment(true, other, 1, 0)),
+		},
+		{
+			name: "header after the root",
+			script: join(mmCommitment(false, root, 1, 0),
+				mmMagic),
+		},
+		{
+			name: "header not directly before the root",
+			script: join(height, mmMagic, []byte{0x00},
+				mmCommitment(false, root, 1, 0)),
+		},
+		{
+			name:   "root of another tree",
+			script: join(height, mmCommitment(true, other, 1, 0)),
+		},
+		{
+			name:   "missing size and nonce",
+			script: join(height, mmMagic, mmRootBytes(root)),
+		},
+		{
+			name: "truncated nonce",
+			script: join(height, mmMagic, mmRootBytes(root),
+				[]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}),
+		},
+		{
+			name:   "header only",
+			script: mmMagic,
+		},
+		{
+			name:   "truncated root",
+			script: join(mmMagic, mmRootBytes(root)[:31]),
+		},
+		{
+			name:   "empty script",
+			script: nil,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		hash, size, nonce, err := blockchain.TstReadMergedMiningTransaction(
+			test.script, root)
+		if !test.valid {
+			rerr, ok := err.(blockchain.RuleError)
+			if !ok || rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+				t.Errorf("readMergedMiningTransaction #%d (%s): "+
+					"did not receive expected error - got %v",
+					i, test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readMergedMiningTransaction #%d (%s): "+
+				"unexpected error: %v", i, test.name, err)
+			continue
+		}
+		if !hash.IsEqual(root) || size != test.size ||
+			nonce != test.nonce {
+			t.Errorf("readMergedMiningTransaction #%d (%s): got "+
+				"root %v size %d nonce %d, want root %v size "+
+				"%d nonce %d", i, test.name, hash, size, nonce,
+				root, test.size, test.nonce)
+		}
+	}
+}
+
+// loadParentBlock returns the block stored in the passed test data file of a
+// parent chain block.  The block follows the network magic and its length.
+func loadParentBlock(filename string) (*wire.MsgBlock, error) {
+	fi, err := os.Open(filepath.Join("testdata", filename))
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	r := bzip2.NewReader(fi)
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	var block wire.MsgBlock
+	if err := block.Deserialize(r); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// TestReadMergedMiningTransactionMainNet ensures the merged mining information
+// is extracted from the coinbase of a Bitcoin mainnet block which was merged
+// mined with a Namecoin mainnet block.
+func TestReadMergedMiningTransactionMainNet(t *testing.T) {
+	// Bitcoin block 277647 was mined by BTC Guild, whose coinbase commits
+	// to a merged mining merkle tree of a single chain behind the magic
+	// bytes, followed by the pool marker and extra nonce.
+	parent, err := loadParentBlock("277647.dat.bz2")
+	if err != nil {
+		t.Fatalf("loadParentBlock: %v", err)
+	}
+	parentHash, _ := parent.Header.BlockSha()
+	wantParentHash := "0000000000000000054a714e580b16c583701712ab91060e" +
+		"92dbde6eb1e052a8"
+	if parentHash.String() != wantParentHash {
+		t.Fatalf("parent block hash: got %v, want %v", parentHash,
+			wantParentHash)
+	}
+
+	script := parent.Transactions[0].TxIn[0].SignatureScript
+	if !bytes.Contains(script, []byte("Mined by BTC Guild")) {
+		t.Fatalf("parent coinbase script %x is not from BTC Guild",
+			script)
+	}
+
+	// The tree only holds the Namecoin block, so it is the root.
+	root, _ := wire.NewShaHashFromStr("180ec2f9a5ff672bb0b3df6e14703def" +
+		"e4b6570194be38428122c0b001c5445b")
+	hash, size, nonce, err := blockchain.TstReadMergedMiningTransaction(
+		script, root)
+	if err != nil {
+		t.Fatalf("readMergedMiningTransaction: unexpected error: %v",
+			err)
+	}
+	if !hash.IsEqual(root) || size != 1 || nonce != 0 {
+		t.Errorf("readMergedMiningTransaction: got root %v size %d "+
+			"nonce %d, want root %v size 1 nonce 0", hash, size,
+			nonce, root)
+	}
+
+	// The coinbase doesn't commit to any other block.
+	_, _, _, err = blockchain.TstReadMergedMiningTransaction(script,
+		&parentHash)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("readMergedMiningTransaction: did not receive "+
+			"expected error for another root - got %v", err)
+	}
+}
+
+// TestReadMergedMiningTransactionFuzz ensures random and randomly corrupted
+// parent coinbase scripts never cause readMergedMiningTransaction to panic and
+// that any script it accepts commits to the requested root.
+func TestReadMergedMiningTransactionFuzz(t *testing.T) {
+	root, _ := wire.NewShaHashFromStr("9d1fb0fb0db6f4e6d7ad1d2a0a3e3c9c" +
+		"c8bd04c2ba1ab1a4b61d4b74ab2c9e87")
+	valid := append([]byte{0x03, 0x7b, 0x1a, 0x06, 0x2c},
+		mmCommitment(true, root, 4, 3)...)
+	rootBytes := mmRootBytes(root)
+
+	rng := rand.New(rand.NewSource(0x6e6d6364))
+	for i := 0; i < 20000; i++ {
+		var script []byte
+		switch i % 4 {
+		case 0:
+			// Entirely random script.
+			script = make([]byte, rng.Intn(wire.HashSize*3))
+			rng.Read(script)
+
+		case 1:
+			// Valid script truncated at a random position.
+			script = valid[:rng.Intn(len(valid)+1)]
+
+		case 2:
+			// Valid script with random bytes overwritten.
+			script = append([]byte(nil), valid...)
+			for j := rng.Intn(4); j >= 0; j-- {
+				script[rng.Intn(len(script))] = byte(rng.Intn(256))
+			}
+
+		case 3:
+			// Merged mining header and root scattered through
+			// random data.
+			script = make([]byte, rng.Intn(64))
+			rng.Read(script)
+			for j := rng.Intn(3); j >= 0; j-- {
+				part := mmMagic
+				if rng.Intn(2) == 0 {
+					part = rootBytes
+				}
+				pos := rng.Intn(len(script) + 1)
+				script = append(script[:pos], append(
+					append([]byte(nil), part...),
+					script[pos:]...)...)
+			}
+		}
+
+		hash, _, _, err := blockchain.TstReadMergedMiningTransaction(
+			script, root)
+		if err != nil {
+			continue
+		}
+		if !hash.IsEqual(root) || !bytes.Contains(script, rootBytes) {
+			t.Fatalf("readMergedMiningTransaction: accepted script "+
+				"%x which does not commit to %v", script, root)
+		}
+	}
+}
+
+// TestExpectedMergedMiningIndex ensures the slot of a chain in a merged mining
+// merkle tree is derived from the merkle nonce and chain ID as expected.
+func TestExpectedMergedMiningIndex(t *testing.T) {
+	tests := []struct {
+		nonce   uint32
+		chainID int32
+		height  uint
+		want    uint32
+	}{
+		{0, 1, 0, 0},
+		{0, 1, 1, 1},
+		{0, 1, 2, 3},
+		{0, 1, 3, 3},
+		{7, 1, 3, 2},
+		{0x12345678, 1, 4, 3},
+		{0, 0x62, 5, 24},
+		{0xffffffff, 1, 8, 130},
+		{0, 1, 30, 362964203},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := blockchain.TstExpectedMergedMiningIndex(test.nonce,
+			test.chainID, test.height)
+		if got != test.want {
+			t.Errorf("expectedMergedMiningIndex #%d: got %d, want %d",
+				i, got, test.want)
+		}
+	}
+}
+
+// TestCheckAuxPowMultipleChains ensures blocks which are merged mined along
+// with other chains are accepted when they occupy the slot of the chain in the
+// merged mining merkle tree and rejected otherwise.
+func TestCheckAuxPowMultipleChains(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+
+	var msgBlock wire.MsgBlock
+	msgBlock.Header = wire.BlockHeader{
+		Version:   2,
+		Timestamp: time.Unix(0x55000000, 0),
+		Bits:      params.PowLimitBits,
+	}
+
+	// mergeMine commits to the block with the passed chain ID in slot
+	// index of a merged mining merkle tree with 8 leaves, the other ones
+	// being blocks of other chains, and returns the block with the
+	// resulting AuxPow header.
+	mergeMine := func(chainID int32, index int,
+		size, nonce uint32) *btcutil.Block {
+
+		mb := msgBlock
+		mb.Header.SetAuxPowVersion(chainID)
+		blockHash, _ := mb.Header.BlockSha()
+
+		leaves := make([]wire.ShaHash, 8)
+		for i := range leaves {
+			leaves[i] = *mmHashPair(&blockHash, &wire.ShaHash{byte(i)})
+		}
+		leaves[index] = blockHash
+		root, branch := mmChainTree(leaves, index)
+
+		sigScript := append([]byte{0x03, 0x7b, 0x1a, 0x06, 0x2c},
+			mmCommitment(true, root, size, nonce)...)
+		sigScript = append(sigScript, "/P2Pool/"...)
+		coinbaseTx := wire.NewMsgTx()
+		coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&wire.ShaHash{},
+			math.MaxUint32), sigScript))
+		coinbaseTx.AddTxOut(wire.NewTxOut(2500000000, []byte{0x51}))
+		coinbaseHash, _ := coinbaseTx.TxSha()
+
+		// Put the coinbase first in a parent block with another
+		// transaction.
+		sibling := wire.ShaHash{0x01}
+		parent := wire.BlockHeader{
+			Version:    2,
+			MerkleRoot: *mmHashPair(&coinbaseHash, &sibling),
+			Timestamp:  msgBlock.Header.Timestamp,
+			Bits:       0x1d00ffff,
+		}
+		target := blockchain.CompactToBig(msgBlock.Header.Bits)
+		for {
+			parentHash, _ := parent.BlockSha()
+			if blockchain.ShaHashToBig(&parentHash).Cmp(target) <= 0 {
+				break
+			}
+			parent.Nonce++
+		}
+		parentHash, _ := parent.BlockSha()
+
+		mb.Header.AuxPowHeader = &wire.AuxPow{
+			CoinbaseTx: coinbaseTx,
+			BlockHash:  parentHash,
+			CoinbaseBranch: wire.MerkleBranch{
+				BranchHash: []wire.ShaHash{sibling},
+			},
+			BlockchainBranch: branch,
+			ParentBlock:      parent,
+		}
+		return btcutil.NewBlock(&mb)
+	}
+
+	const nonce = 0x12345678
+	chainID := params.AuxPowChainID
+	index := int(blockchain.TstExpectedMergedMiningIndex(nonce, chainID,
+		3))
+	block := mergeMine(chainID, index, 8, nonce)
+	if err := blockchain.CheckAuxPowProofOfWork(block, params); err != nil {
+		t.Fatalf("CheckAuxPowProofOfWork: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		block *btcutil.Block
+	}{
+		{"wrong slot", mergeMine(chainID, (index+1)%8, 8, nonce)},
+		{"wrong tree size", mergeMine(chainID, index, 4, nonce)},
+		{"wrong nonce", mergeMine(chainID, index, 8, nonce+1)},
+	}
+	for _, test := range tests {
+		err := blockchain.CheckAuxPowProofOfWork(test.block, params)
+		if rerr, ok := err.(blockchain.RuleError); !ok ||
+			rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+			t.Errorf("CheckAuxPowProofOfWork (%s): did not receive "+
+				"expected error - got %v", test.name, err)
+		}
+	}
+
+	// The parent coinbase must be the first transaction of the parent
+	// block.
+	block.MsgBlock().Header.AuxPowHeader.CoinbaseBranch.BranchSideMask = 1
+	err := blockchain.CheckAuxPowProofOfWork(block, params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("CheckAuxPowProofOfWork: did not receive expected "+
+			"error for coinbase not being first - got %v", err)
+	}
+
+	// A network which doesn't enforce a strict chain ID accepts blocks
+	// with the chain ID of another chain, which must occupy the slot of
+	// their own chain ID rather than that of the network.
+	looseParams := *params
+	looseParams.StrictChainID = false
+	otherIndex := index
+	for otherIndex == index {
+		chainID++
+		otherIndex = int(blockchain.TstExpectedMergedMiningIndex(nonce,
+			chainID, 3))
+	}
+	block = mergeMine(chainID, otherIndex, 8, nonce)
+	err = blockchain.CheckAuxPowProofOfWork(block, &looseParams)
+	if err != nil {
+		t.Errorf("CheckAuxPowProofOfWork: unexpected error for chain "+
+			"ID %d: %v", chainID, err)
+	}
+	block = mergeMine(chainID, index, 8, nonce)
+	err = blockchain.CheckAuxPowProofOfWork(block, &looseParams)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("CheckAuxPowProofOfWork: did not receive expected "+
+			"error for slot of chain ID %d - got %v",
+			params.AuxPowChainID, err)
+	}
+}
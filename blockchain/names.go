@@ -0,0 +1,657 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/chaincfg"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/txscript"
+	"github.com/melange-app/nmcd/wire"
+)
+
+const (
+	// MaxNameLength is the maximum length in bytes of a name.
+	MaxNameLength = 255
+
+	// MaxNameValueLength is the maximum length in bytes of the value of a
+	// name.
+	MaxNameValueLength = 1023
+
+	// maxNameRandLength is the maximum length in bytes of the salt revealed
+	// by a name_firstupdate.
+	maxNameRandLength = 20
+
+	// nameNewHashLength is the length in bytes of the salted hash
+	// committed to by a name_new.
+	nameNewHashLength = 20
+
+	// NameNewMaturity is the number of blocks a name_new must be buried
+	// before a name_firstupdate can spend it.  This prevents others from
+	// front running the registration once the name is revealed.
+	NameNewMaturity = 12
+
+	// MinNameAmount is the minimum amount in satoshi an output holding a
+	// name must lock.  It is enforced from the MinNameAmountHeight of the
+	// network parameters.
+	MinNameAmount = btcutil.SatoshiPerBitcent
+)
+
+// NameView houses the state of a set of names from the point of view of a
+// position in the block chain.  A nil entry means the name has never been
+// registered as of that position.
+type NameView map[string]*database.NameEntry
+
+// nameExpirationDepth returns the number of blocks after its last update at
+// which a name is expired as of the given height.  On the main network the
+// depth was raised from 12000 to 36000 blocks early in the history of
+// Namecoin.
+func nameExpirationDepth(height int64, chainParams *chaincfg.Params) int64 {
+	initialDepth := chainParams.InitialNameExpirationDepth
+	switch {
+	case height-initialDepth < initialDepth:
+		return initialDepth
+	case height-initialDepth < chainParams.NameExpirationDepth:
+		return height - initialDepth
+	}
+	return chainParams.NameExpirationDepth
+}
+
+// isNameExpired returns whether a name last updated at lastHeight is expired
+// at the given height.
+func isNameExpired(lastHeight, height int64, chainParams *chaincfg.Params) bool {
+	return lastHeight+nameExpirationDepth(height, chainParams) <= height
+}
+
+// nameExpireHeight returns the height of the first block at which a name last
+// updated at lastHeight is expired.  Names last updated up to the initial
+// expiration depth expire that many blocks later, while later names only
+// expire once the depth has settled at its final value.
+func nameExpireHeight(lastHeight int64, chainParams *chaincfg.Params) int64 {
+	if lastHeight <= chainParams.InitialNameExpirationDepth {
+		return lastHeight + chainParams.InitialNameExpirationDepth
+	}
+	return lastHeight + chainParams.NameExpirationDepth
+}
+
+// lastExpiringHeight returns the highest height of a last update which makes
+// a name expired at the given height.  It never decreases as the height
+// increases, so a name never becomes active again without being updated.
+func lastExpiringHeight(height int64, chainParams *chaincfg.Params) int64 {
+	return height - nameExpirationDepth(height, chainParams)
+}
+
+// nameEntriesForBlock returns the new state of every name registered or
+// updated by the passed block, which is at the given height, in the order
+// the operations appear in the block.
+func nameEntriesForBlock(block *btcutil.Block, height int64, chainParams *chaincfg.Params) []*database.NameEntry {
+	var entries []*database.NameEntry
+	for _, tx := range block.Transactions() {
+		entries = append(entries, nameEntriesForTx(tx, height,
+			chainParams)...)
+	}
+
+	return entries
+}
+
+// nameEntriesForTx returns the new state of every name registered or updated
+// by the passed transaction when it is included at the given height.  Only
+// Namecoin transactions other than coinbases operate on names, so the name
+// outputs of any other transaction are ignored.
+func nameEntriesForTx(tx *btcutil.Tx, height int64, chainParams *chaincfg.Params) []*database.NameEntry {
+	if IsCoinBase(tx) || tx.MsgTx().Version != wire.NamecoinTxVersion {
+		return nil
+	}
+
+	var entries []*database.NameEntry
+	for i, txOut := range tx.MsgTx().TxOut {
+		ns := txscript.ExtractNameScript(txOut.PkScript)
+		if ns == nil || ns.Op == txscript.OP_NAME_NEW {
+			continue
+		}
+
+		entries = append(entries, &database.NameEntry{
+			Name:         ns.Name,
+			Value:        ns.Value,
+			TxSha:        *tx.Sha(),
+			TxOutIndex:   uint32(i),
+			Height:       height,
+			ExpireHeight: nameExpireHeight(height, chainParams),
+			Script:       ns.AddressScript,
+		})
+	}
+
+	return entries
+}
+
+// connectNameEntries updates the passed view with the passed entries.  Only
+// names already in the view are updated.
+func connectNameEntries(view NameView, entries []*database.NameEntry) {
+	for _, entry := range entries {
+		if _, exists := view[string(entry.Name)]; exists {
+			view[string(entry.Name)] = entry
+		}
+	}
+}
+
+// fetchNameViewMain fetches the state of the provided set of names from the
+// point of view of the end of the main chain.
+func fetchNameViewMain(db database.Db, nameSet map[string]struct{}) (NameView, error) {
+	view := make(NameView, len(nameSet))
+	for name := range nameSet {
+		entry, err := db.FetchName([]byte(name))
+		if err != nil && err != database.ErrNameMissing {
+			return nil, err
+		}
+		view[name] = entry
+	}
+
+	return view, nil
+}
+
+// fetchNameView fetches the state of the provided set of names from the point
+// of view of the position of the passed node in the block chain.  See
+// fetchTxStore for more details on what the point of view entails.
+func (b *BlockChain) fetchNameView(node *blockNode, nameSet map[string]struct{}) (NameView, error) {
+	prevNode, err := b.getPrevNodeFromNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := fetchNameViewMain(b.db, nameSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// The view is already correct when the node extends the end of the
+	// main chain.
+	if b.bestChain == nil || (prevNode != nil && prevNode.hash.IsEqual(b.bestChain.hash)) {
+		return view, nil
+	}
+
+	// Restore the state the names had before each of the blocks which
+	// would be disconnected during a reorganize.  The blocks are visited
+	// from the end of the main chain backwards, so the state before the
+	// oldest block updating a name is the one which remains.
+	detachNodes, attachNodes := b.getReorganizeNodes(prevNode)
+	for e := detachNodes.Front(); e != nil; e = e.Next() {
+		n := e.Value.(*blockNode)
+		before, err := b.db.FetchNamesBeforeBlock(n.height)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, entry := range before {
+			if _, exists := view[name]; exists {
+				view[name] = entry
+			}
+		}
+	}
+
+	// Apply the name operations of each of the side chain blocks to
+	// attach.
+	for e := attachNodes.Front(); e != nil; e = e.Next() {
+		n := e.Value.(*blockNode)
+		block, exists := b.blockCache[*n.hash]
+		if !exists {
+			return nil, fmt.Errorf("unable to find block %v in "+
+				"side chain cache for name search", n.hash)
+		}
+
+		connectNameEntries(view, nameEntriesForBlock(block, n.height,
+			b.chainParams))
+	}
+
+	return view, nil
+}
+
+// fetchBlockNameView fetches the state of every name registered or updated by
+// the passed block from the point of view of its position in the block chain.
+func (b *BlockChain) fetchBlockNameView(node *blockNode, block *btcutil.Block) (NameView, error) {
+	nameSet := make(map[string]struct{})
+	for _, entry := range nameEntriesForBlock(block, node.height,
+		b.chainParams) {
+
+		nameSet[string(entry.Name)] = struct{}{}
+	}
+
+	return b.fetchNameView(node, nameSet)
+}
+
+// FetchNameView fetches the state of every name registered or updated by the
+// passed transaction from the point of view of the end of the main chain.
+func (b *BlockChain) FetchNameView(tx *btcutil.Tx) (NameView, error) {
+	nameSet := make(map[string]struct{})
+	for _, entry := range nameEntriesForTx(tx, 0, b.chainParams) {
+		nameSet[string(entry.Name)] = struct{}{}
+	}
+
+	return fetchNameViewMain(b.db, nameSet)
+}
+
+// CheckTransactionNames performs a series of checks on the name operations of
+// a transaction to ensure they follow the Namecoin name rules.  Examples of
+// the checks include ensuring only Namecoin transactions operate on names,
+// enforcing the name and value length limits, verifying a name_firstupdate
+// reveals the salt committed to by a mature name_new and ensuring a
+// name_update spends the previous operation on the same name.
+//
+// The passed transaction store must contain the input transactions and the
+// name view must contain the names the transaction operates on, both from the
+// point of view of the height the transaction is included at.
+func CheckTransactionNames(tx *btcutil.Tx, txHeight int64, txStore TxStore, names NameView, chainParams *chaincfg.Params) error {
+	// Coinbase transactions have no inputs and can't perform name
+	// operations on behalf of anybody, so they may not have name outputs.
+	txHash := tx.Sha()
+	msgTx := tx.MsgTx()
+	if IsCoinBase(tx) {
+		for _, txOut := range msgTx.TxOut {
+			if txscript.ExtractNameScript(txOut.PkScript) != nil {
+				str := fmt.Sprintf("coinbase transaction %v "+
+					"has a name output", txHash)
+				return ruleError(ErrNameValidation, str)
+			}
+		}
+		return nil
+	}
+
+	// Locate the name inputs and outputs of the transaction.  At most one
+	// of each is allowed.
+	var prevOp *txscript.NameScript
+	var prevHeight int64
+	for _, txIn := range msgTx.TxIn {
+		originTx, exists := txStore[txIn.PreviousOutPoint.Hash]
+		if !exists || originTx.Err != nil || originTx.Tx == nil {
+			str := fmt.Sprintf("unable to find input transaction "+
+				"%v for transaction %v",
+				txIn.PreviousOutPoint.Hash, txHash)
+			return ruleError(ErrMissingTx, str)
+		}
+		originTxOuts := originTx.Tx.MsgTx().TxOut
+		originTxIndex := txIn.PreviousOutPoint.Index
+		if originTxIndex >= uint32(len(originTxOuts)) {
+			str := fmt.Sprintf("out of bounds input index %d in "+
+				"transaction %v referenced from transaction %v",
+				originTxIndex, txIn.PreviousOutPoint.Hash, txHash)
+			return ruleError(ErrBadTxInput, str)
+		}
+
+		ns := txscript.ExtractNameScript(originTxOuts[originTxIndex].PkScript)
+		if ns == nil {
+			continue
+		}
+		if prevOp != nil {
+			str := fmt.Sprintf("transaction %v has multiple name "+
+				"inputs", txHash)
+			return ruleError(ErrNameValidation, str)
+		}
+		prevOp = ns
+		prevHeight = originTx.BlockHeight
+	}
+
+	var op *txscript.NameScript
+	var opValue int64
+	for _, txOut := range msgTx.TxOut {
+		ns := txscript.ExtractNameScript(txOut.PkScript)
+		if ns == nil {
+			continue
+		}
+		if op != nil {
+			str := fmt.Sprintf("transaction %v has multiple name "+
+				"outputs", txHash)
+			return ruleError(ErrNameValidation, str)
+		}
+		op = ns
+		opValue = txOut.Value
+	}
+
+	// Only Namecoin transactions may operate on names, and they must
+	// actually do so.
+	if msgTx.Version != wire.NamecoinTxVersion {
+		if prevOp != nil || op != nil {
+			str := fmt.Sprintf("transaction %v with version %d "+
+				"has name inputs or outputs", txHash,
+				msgTx.Version)
+			return ruleError(ErrNameValidation, str)
+		}
+		return nil
+	}
+	if op == nil {
+		str := fmt.Sprintf("Namecoin transaction %v has no name "+
+			"output", txHash)
+		return ruleError(ErrNameValidation, str)
+	}
+
+	// Reject "greedy" names which don't lock enough coins.
+	if txHeight >= chainParams.MinNameAmountHeight && opValue < MinNameAmount {
+		str := fmt.Sprintf("name output of transaction %v locks %v "+
+			"which is less than the minimum of %v", txHash,
+			opValue, MinNameAmount)
+		return ruleError(ErrNameValidation, str)
+	}
+
+	// A name_new only commits to the name and must not spend another
+	// name operation.
+	if op.Op == txscript.OP_NAME_NEW {
+		if prevOp != nil {
+			str := fmt.Sprintf("name_new transaction %v spends a "+
+				"previous name operation", txHash)
+			return ruleError(ErrNameValidation, str)
+		}
+		if len(op.Hash) != nameNewHashLength {
+			str := fmt.Sprintf("name_new transaction %v commits "+
+				"to a hash of %d bytes instead of %d", txHash,
+				len(op.Hash), nameNewHashLength)
+			return ruleError(ErrNameValidation, str)
+		}
+		return nil
+	}
+
+	// The remaining operations update a name and must spend the previous
+	// name operation.
+	if prevOp == nil {
+		str := fmt.Sprintf("transaction %v updates name %q without a "+
+			"name input", txHash, op.Name)
+		return ruleError(ErrNameValidation, str)
+	}
+	if len(op.Name) > MaxNameLength {
+		str := fmt.Sprintf("transaction %v operates on a name of %d "+
+			"bytes which is more than the max of %d", txHash,
+			len(op.Name), MaxNameLength)
+		return ruleError(ErrNameValidation, str)
+	}
+	if len(op.Value) > MaxNameValueLength {
+		str := fmt.Sprintf("transaction %v assigns a value of %d "+
+			"bytes which is more than the max of %d", txHash,
+			len(op.Value), MaxNameValueLength)
+		return ruleError(ErrNameValidation, str)
+	}
+
+	// A name_update must spend the last operation on the same name, which
+	// in turn must not have expired.
+	if op.Op == txscript.OP_NAME_UPDATE {
+		if prevOp.Op == txscript.OP_NAME_NEW {
+			str := fmt.Sprintf("name_update transaction %v spends "+
+				"a name_new", txHash)
+			return ruleError(ErrNameValidation, str)
+		}
+		if !bytes.Equal(prevOp.Name, op.Name) {
+			str := fmt.Sprintf("name_update transaction %v of "+
+				"name %q spends an operation on name %q",
+				txHash, op.Name, prevOp.Name)
+			return ruleError(ErrNameValidation, str)
+		}
+		if isNameExpired(prevHeight, txHeight, chainParams) {
+			str := fmt.Sprintf("name_update transaction %v "+
+				"updates expired name %q", txHash, op.Name)
+			return ruleError(ErrNameValidation, str)
+		}
+		return nil
+	}
+
+	// A name_firstupdate must spend a mature name_new and reveal the salt
+	// of its commitment.
+	if prevOp.Op != txscript.OP_NAME_NEW {
+		str := fmt.Sprintf("name_firstupdate transaction %v does not "+
+			"spend a name_new", txHash)
+		return ruleError(ErrNameValidation, str)
+	}
+	if txHeight-prevHeight < NameNewMaturity {
+		str := fmt.Sprintf("name_firstupdate transaction %v spends a "+
+			"name_new from height %d at height %d before "+
+			"required maturity of %d blocks", txHash, prevHeight,
+			txHeight, NameNewMaturity)
+		return ruleError(ErrNameValidation, str)
+	}
+	if len(op.Rand) > maxNameRandLength {
+		str := fmt.Sprintf("name_firstupdate transaction %v reveals "+
+			"a salt of %d bytes which is more than the max of %d",
+			txHash, len(op.Rand), maxNameRandLength)
+		return ruleError(ErrNameValidation, str)
+	}
+	commitment := make([]byte, 0, len(op.Rand)+len(op.Name))
+	commitment = append(commitment, op.Rand...)
+	commitment = append(commitment, op.Name...)
+	if !bytes.Equal(btcutil.Hash160(commitment), prevOp.Hash) {
+		str := fmt.Sprintf("name_firstupdate transaction %v does not "+
+			"match the commitment of its name_new", txHash)
+		return ruleError(ErrNameValidation, str)
+	}
+
+	// The name must not be registered already.
+	entry, exists := names[string(op.Name)]
+	if !exists {
+		return fmt.Errorf("name %q of transaction %v is missing from "+
+			"the name view", op.Name, txHash)
+	}
+	if entry != nil && !isNameExpired(entry.Height, txHeight,
+		chainParams) {
+
+		str := fmt.Sprintf("name_firstupdate transaction %v registers "+
+			"name %q which is already registered at height %d",
+			txHash, op.Name, entry.Height)
+		return ruleError(ErrNameValidation, str)
+	}
+
+	return nil
+}
+
+// expiringNames returns the names which expire at the given height, which
+// are those last updated after the last expiring height of the previous
+// block up to the last expiring height of the given block.  The names are
+// sorted by name.
+func (b *BlockChain) expiringNames(height int64) ([]*database.NameEntry, error) {
+	var expiring []*database.NameEntry
+	first := lastExpiringHeight(height-1, b.chainParams) + 1
+	if first < 1 {
+		first = 1
+	}
+	last := lastExpiringHeight(height, b.chainParams)
+	for lastHeight := first; lastHeight <= last; lastHeight++ {
+		// The names updated by the block at the last height are those
+		// with a previous state recorded for it.
+		updated, err := b.db.FetchNamesBeforeBlock(lastHeight)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(updated))
+		for name := range updated {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// Only names which have not been updated again since then
+		// expire.
+		for _, name := range names {
+			entry, err := b.db.FetchName([]byte(name))
+			if err != nil {
+				return nil, err
+			}
+			if entry.Height == lastHeight && !entry.Expired {
+				expiring = append(expiring, entry)
+			}
+		}
+	}
+
+	return expiring, nil
+}
+
+// connectNames updates the name database with the name operations of the
+// passed block which is being connected to the main chain and makes it the tip
+// of the name index.  The names which expire at the height of the block are
+// marked expired before the operations of the block are applied, so the undo
+// data of the block restores them when it is disconnected again.  The expired
+// names are returned.
+func (b *BlockChain) connectNames(node *blockNode, block *btcutil.Block) ([]*database.NameEntry, error) {
+	expired, err := b.expiringNames(node.height)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*database.NameEntry, 0, len(expired))
+	for _, entry := range expired {
+		expiredEntry := *entry
+		expiredEntry.Expired = true
+		entries = append(entries, &expiredEntry)
+	}
+	entries = append(entries, nameEntriesForBlock(block, node.height,
+		b.chainParams)...)
+
+	// The block is recorded as the tip of the name index even without any
+	// entries, so CheckNameIndex knows how far the index has been built.
+	err = b.db.UpdateNamesForBlock(node.hash, node.height, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, nil
+}
+
+// disconnectNames restores the name database to the state before the passed
+// block which is being disconnected from the main chain.  This also restores
+// the names which expired at the height of the block, which are returned sorted
+// by name.
+func (b *BlockChain) disconnectNames(node *blockNode) ([]*database.NameEntry, error) {
+	before, err := b.db.FetchNamesBeforeBlock(node.height)
+	if err != nil {
+		return nil, err
+	}
+
+	// The names which were still active before the block but are expired
+	// as of its height are the ones the block expired.
+	names := make([]string, 0, len(before))
+	for name, entry := range before {
+		if entry != nil && !entry.Expired &&
+			isNameExpired(entry.Height, node.height, b.chainParams) {
+
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	restored := make([]*database.NameEntry, 0, len(names))
+	for _, name := range names {
+		restored = append(restored, before[name])
+	}
+
+	err = b.db.DropNamesForBlock(node.height)
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// rollBackNameIndex drops the blocks which are not part of the main chain up
+// to the passed height from the tip of the name index, such as a block whose
+// names were disconnected but which was not removed from the database before
+// a crash.  It returns the height of the resulting tip.
+func (b *BlockChain) rollBackNameIndex(bestHeight int64) (int64, error) {
+	for {
+		sha, height, err := b.db.FetchNameIndexTip()
+		if err != nil {
+			return 0, err
+		}
+		if height <= bestHeight {
+			mainSha, err := b.db.FetchBlockShaByHeight(height)
+			if err != nil {
+				return 0, err
+			}
+			if mainSha.IsEqual(sha) {
+				return height, nil
+			}
+		}
+
+		log.Infof("Removing block %v (height %d) from the name index",
+			sha, height)
+		if err := b.db.DropNamesForBlock(height); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// CheckNameIndex ensures the name index of the database matches the main chain
+// stored in it.  The name index is rebuilt from the blocks of the main chain
+// when it is missing, such as in a database created before the name index
+// existed, when an earlier rebuild was interrupted, or when it was built by
+// another version.  Otherwise the tip of the name index is moved back to the
+// main chain and then forward to its best block, since the name index is not
+// updated in the same transaction as the blocks.
+func (b *BlockChain) CheckNameIndex() error {
+	_, bestHeight, err := b.db.NewestSha()
+	if err != nil {
+		return err
+	}
+
+	version, err := b.db.FetchNameIndexVersion()
+	if err != nil && err != database.ErrNameIndexDoesNotExist {
+		return err
+	}
+	rebuild := err != nil || version != database.NameIndexVersion
+
+	var tipHeight int64
+	if !rebuild {
+		tipHeight, err = b.rollBackNameIndex(bestHeight)
+		switch err {
+		case nil:
+		case database.ErrNameIndexDoesNotExist:
+			rebuild = true
+		default:
+			return err
+		}
+	}
+
+	if rebuild {
+		if bestHeight > 0 {
+			log.Infof("Rebuilding name index up to height %d.  "+
+				"This may take a while...", bestHeight)
+		}
+		if err := b.db.DeleteNameIndex(); err != nil {
+			return err
+		}
+
+		// The genesis block has no name operations, so it only
+		// becomes the tip of the index.
+		genesisSha, err := b.db.FetchBlockShaByHeight(0)
+		if err != nil {
+			return err
+		}
+		err = b.db.UpdateNamesForBlock(genesisSha, 0, nil)
+		if err != nil {
+			return err
+		}
+		tipHeight = 0
+	} else if tipHeight < bestHeight {
+		log.Infof("Updating name index from height %d to %d",
+			tipHeight, bestHeight)
+	}
+
+	for blockHeight := tipHeight + 1; blockHeight <= bestHeight; blockHeight++ {
+		sha, err := b.db.FetchBlockShaByHeight(blockHeight)
+		if err != nil {
+			return err
+		}
+		block, err := b.db.FetchBlockBySha(sha)
+		if err != nil {
+			return err
+		}
+		node := &blockNode{hash: sha, height: blockHeight}
+		if _, err := b.connectNames(node, block); err != nil {
+			return err
+		}
+	}
+	if !rebuild {
+		return nil
+	}
+	if bestHeight > 0 {
+		log.Infof("Name index rebuilt")
+	}
+
+	return b.db.SetNameIndexVersion(database.NameIndexVersion)
+}
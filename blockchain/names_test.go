@@ -0,0 +1,601 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/chaincfg"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/txscript"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// nameAddrScript is the pay-to-pubkey-hash script which owns the names in the
+// name tests.
+var nameAddrScript = []byte{
+	0x76, 0xa9, 0x14, // OP_DUP OP_HASH160 OP_DATA_20
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a,
+	0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14,
+	0x88, 0xac, // OP_EQUALVERIFY OP_CHECKSIG
+}
+
+// nameNewScript returns a name_new script committing to name with the salt
+// rand.
+func nameNewScript(rand, name []byte) []byte {
+	hash := btcutil.Hash160(append(append([]byte{}, rand...), name...))
+	script, _ := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_NAME_NEW).AddData(hash).
+		AddOp(txscript.OP_2DROP).Script()
+	return append(script, nameAddrScript...)
+}
+
+// nameFirstUpdateScript returns a name_firstupdate script registering name
+// with the passed salt and value.
+func nameFirstUpdateScript(name, rand, value []byte) []byte {
+	script, _ := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_NAME_FIRSTUPDATE).AddData(name).
+		AddData(rand).AddData(value).AddOp(txscript.OP_2DROP).
+		AddOp(txscript.OP_2DROP).Script()
+	return append(script, nameAddrScript...)
+}
+
+// nameUpdateScript returns a name_update script assigning value to name.
+func nameUpdateScript(name, value []byte) []byte {
+	script, _ := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_NAME_UPDATE).AddData(name).AddData(value).
+		AddOp(txscript.OP_2DROP).AddOp(txscript.OP_DROP).Script()
+	return append(script, nameAddrScript...)
+}
+
+// nameTestTx returns a transaction with the passed version spending the first
+// output of each of the passed transactions and paying value to each of the
+// passed scripts.
+func nameTestTx(version int32, value int64, prevTxs []*btcutil.Tx, pkScripts ...[]byte) *btcutil.Tx {
+	msgTx := wire.NewMsgTx()
+	msgTx.Version = version
+	for _, prevTx := range prevTxs {
+		prevOut := wire.NewOutPoint(prevTx.Sha(), 0)
+		msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+	}
+	for _, pkScript := range pkScripts {
+		msgTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	}
+	return btcutil.NewTx(msgTx)
+}
+
+// nameCoinbaseTx returns a coinbase transaction with the passed version paying
+// value to each of the passed scripts.
+func nameCoinbaseTx(version int32, value int64, pkScripts ...[]byte) *btcutil.Tx {
+	msgTx := wire.NewMsgTx()
+	msgTx.Version = version
+	prevOut := wire.NewOutPoint(&wire.ShaHash{}, wire.MaxPrevOutIndex)
+	msgTx.AddTxIn(wire.NewTxIn(prevOut, []byte{0x01, 0x02}))
+	for _, pkScript := range pkScripts {
+		msgTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	}
+	return btcutil.NewTx(msgTx)
+}
+
+// TestCheckTransactionNames ensures CheckTransactionNames enforces the
+// Namecoin name rules.
+func TestCheckTransactionNames(t *testing.T) {
+	const (
+		height = 300000
+		value  = blockchain.MinNameAmount
+	)
+	name := []byte("d/example")
+	rand := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	longName := bytes.Repeat([]byte{'a'}, blockchain.MaxNameLength+1)
+	longValue := bytes.Repeat([]byte{'a'}, blockchain.MaxNameValueLength+1)
+	nmcVersion := int32(wire.NamecoinTxVersion)
+
+	// Previous transactions spent by the tested transactions along with
+	// the heights they were included at.
+	coinTx := nameTestTx(wire.TxVersion, value, nil, nameAddrScript)
+	newTx := nameTestTx(nmcVersion, value, nil, nameNewScript(rand, name))
+	longNewTx := nameTestTx(nmcVersion, value, nil,
+		nameNewScript(rand, longName))
+	regTx := nameTestTx(nmcVersion, value, nil,
+		nameFirstUpdateScript(name, rand, []byte("value")))
+	otherTx := nameTestTx(nmcVersion, value, nil,
+		nameUpdateScript([]byte("d/other"), []byte("value")))
+	staleTx := nameTestTx(nmcVersion, value, nil,
+		nameUpdateScript(name, []byte("stale")))
+	prevHeights := map[*btcutil.Tx]int64{
+		coinTx:    height - 100,
+		newTx:     height - blockchain.NameNewMaturity,
+		longNewTx: height - blockchain.NameNewMaturity,
+		regTx:     height - 100,
+		otherTx:   height - 100,
+		staleTx:   height - 36000,
+	}
+	txStore := make(blockchain.TxStore)
+	for tx, prevHeight := range prevHeights {
+		txStore[*tx.Sha()] = &blockchain.TxData{
+			Tx:          tx,
+			Hash:        tx.Sha(),
+			BlockHeight: prevHeight,
+			Spent:       make([]bool, len(tx.MsgTx().TxOut)),
+		}
+	}
+
+	// Name views with the name missing, registered and expired.
+	missing := blockchain.NameView{string(name): nil}
+	registered := blockchain.NameView{string(name): &database.NameEntry{
+		Name:   name,
+		Height: height - 100,
+	}}
+	expired := blockchain.NameView{string(name): &database.NameEntry{
+		Name:   name,
+		Height: height - 36000,
+	}}
+
+	tests := []struct {
+		name   string
+		tx     *btcutil.Tx
+		height int64
+		view   blockchain.NameView
+		valid  bool
+	}{
+		{
+			name:  "ordinary transaction",
+			tx:    nameTestTx(wire.TxVersion, value, []*btcutil.Tx{coinTx}, nameAddrScript),
+			valid: true,
+		},
+		{
+			name:  "name_new",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{coinTx}, nameNewScript(rand, name)),
+			valid: true,
+		},
+		{
+			name:  "coinbase",
+			tx:    nameCoinbaseTx(wire.TxVersion, value, nameAddrScript),
+			valid: true,
+		},
+		{
+			name:  "coinbase with name_firstupdate output",
+			tx:    nameCoinbaseTx(nmcVersion, value, nameAddrScript, nameFirstUpdateScript(name, rand, []byte("value"))),
+			view:  missing,
+			valid: false,
+		},
+		{
+			name:  "coinbase with name_update output",
+			tx:    nameCoinbaseTx(nmcVersion, value, nameUpdateScript(name, []byte("new"))),
+			view:  registered,
+			valid: false,
+		},
+		{
+			name:  "non-Namecoin coinbase with name_new output",
+			tx:    nameCoinbaseTx(wire.TxVersion, value, nameNewScript(rand, name)),
+			valid: false,
+		},
+		{
+			name:  "name_new in non-Namecoin transaction",
+			tx:    nameTestTx(wire.TxVersion, value, []*btcutil.Tx{coinTx}, nameNewScript(rand, name)),
+			valid: false,
+		},
+		{
+			name:  "Namecoin transaction without name output",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{coinTx}, nameAddrScript),
+			valid: false,
+		},
+		{
+			name:  "greedy name_new",
+			tx:    nameTestTx(nmcVersion, value-1, []*btcutil.Tx{coinTx}, nameNewScript(rand, name)),
+			valid: false,
+		},
+		{
+			name:   "greedy name_new before min amount height",
+			tx:     nameTestTx(nmcVersion, value-1, []*btcutil.Tx{coinTx}, nameNewScript(rand, name)),
+			height: chaincfg.MainNetParams.MinNameAmountHeight - 1,
+			valid:  true,
+		},
+		{
+			name:  "multiple name outputs",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{coinTx}, nameNewScript(rand, name), nameNewScript(rand, name)),
+			valid: false,
+		},
+		{
+			name:  "name_firstupdate",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameFirstUpdateScript(name, rand, []byte("value"))),
+			view:  missing,
+			valid: true,
+		},
+		{
+			name:  "name_firstupdate of expired name",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameFirstUpdateScript(name, rand, []byte("value"))),
+			view:  expired,
+			valid: true,
+		},
+		{
+			name:  "name_firstupdate of registered name",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameFirstUpdateScript(name, rand, []byte("value"))),
+			view:  registered,
+			valid: false,
+		},
+		{
+			name:   "immature name_firstupdate",
+			tx:     nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameFirstUpdateScript(name, rand, []byte("value"))),
+			height: height - 1,
+			view:   missing,
+			valid:  false,
+		},
+		{
+			name:  "name_firstupdate with wrong salt",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameFirstUpdateScript(name, []byte{0x01}, []byte("value"))),
+			view:  missing,
+			valid: false,
+		},
+		{
+			name:  "name_firstupdate without name_new",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{coinTx}, nameFirstUpdateScript(name, rand, []byte("value"))),
+			view:  missing,
+			valid: false,
+		},
+		{
+			name:  "name_firstupdate with too long name",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{longNewTx}, nameFirstUpdateScript(longName, rand, []byte("value"))),
+			view:  blockchain.NameView{string(longName): nil},
+			valid: false,
+		},
+		{
+			name:  "name_firstupdate with too long value",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameFirstUpdateScript(name, rand, longValue)),
+			view:  missing,
+			valid: false,
+		},
+		{
+			name:  "name_update",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{regTx, coinTx}, nameUpdateScript(name, []byte("new"))),
+			valid: true,
+		},
+		{
+			name:  "name_update in non-Namecoin transaction",
+			tx:    nameTestTx(wire.TxVersion, value, []*btcutil.Tx{regTx}, nameAddrScript),
+			valid: false,
+		},
+		{
+			name:  "name_update without name input",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{coinTx}, nameUpdateScript(name, []byte("new"))),
+			valid: false,
+		},
+		{
+			name:  "name_update spending name_new",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{newTx}, nameUpdateScript(name, []byte("new"))),
+			valid: false,
+		},
+		{
+			name:  "name_update of other name",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{otherTx}, nameUpdateScript(name, []byte("new"))),
+			valid: false,
+		},
+		{
+			name:  "name_update of expired name",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{staleTx}, nameUpdateScript(name, []byte("new"))),
+			valid: false,
+		},
+		{
+			name:  "multiple name inputs",
+			tx:    nameTestTx(nmcVersion, value, []*btcutil.Tx{regTx, otherTx}, nameUpdateScript(name, []byte("new"))),
+			valid: false,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		txHeight := test.height
+		if txHeight == 0 {
+			txHeight = height
+		}
+
+		err := blockchain.CheckTransactionNames(test.tx, txHeight,
+			txStore, test.view, &chaincfg.MainNetParams)
+		if test.valid {
+			if err != nil {
+				t.Errorf("CheckTransactionNames (%s): unexpected "+
+					"error %v", test.name, err)
+			}
+			continue
+		}
+
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok || rerr.ErrorCode != blockchain.ErrNameValidation {
+			t.Errorf("CheckTransactionNames (%s): unexpected "+
+				"result - got %v, want %v", test.name, err,
+				blockchain.ErrNameValidation)
+		}
+	}
+}
+
+// TestNameExpiration ensures names expire according to the expiration depth
+// schedule of each network and that the expiration height recorded for a name
+// is the first height at which it is expired.
+func TestNameExpiration(t *testing.T) {
+	mainNet := &chaincfg.MainNetParams
+	regTest := &chaincfg.RegressionNetParams
+	tests := []struct {
+		params       *chaincfg.Params
+		lastHeight   int64
+		expireHeight int64
+	}{
+		{mainNet, 1, 12001},
+		{mainNet, 11999, 23999},
+		{mainNet, 12000, 24000},
+		{mainNet, 12001, 48001},
+		{mainNet, 20000, 56000},
+		{mainNet, 47999, 83999},
+		{mainNet, 100000, 136000},
+		{regTest, 1, 31},
+		{regTest, 30, 60},
+		{regTest, 31, 61},
+		{regTest, 100000, 100030},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		expireHeight := blockchain.TstNameExpireHeight(test.lastHeight,
+			test.params)
+		if expireHeight != test.expireHeight {
+			t.Errorf("nameExpireHeight(%d) (%s): got %d, want %d",
+				test.lastHeight, test.params.Name, expireHeight,
+				test.expireHeight)
+			continue
+		}
+
+		if blockchain.TstIsNameExpired(test.lastHeight, expireHeight-1,
+			test.params) {
+
+			t.Errorf("isNameExpired(%d, %d) (%s): name expired "+
+				"early", test.lastHeight, expireHeight-1,
+				test.params.Name)
+		}
+		if !blockchain.TstIsNameExpired(test.lastHeight, expireHeight,
+			test.params) {
+
+			t.Errorf("isNameExpired(%d, %d) (%s): name not expired",
+				test.lastHeight, expireHeight, test.params.Name)
+		}
+	}
+
+	// Once expired, a name must stay expired however the depth changes.
+	for _, params := range []*chaincfg.Params{mainNet, regTest} {
+		for lastHeight := int64(1); lastHeight < 60000; lastHeight += 997 {
+			expireHeight := blockchain.TstNameExpireHeight(lastHeight,
+				params)
+			for height := expireHeight; height < expireHeight+50000; height += 1009 {
+				if !blockchain.TstIsNameExpired(lastHeight,
+					height, params) {
+
+					t.Errorf("isNameExpired(%d, %d) (%s): "+
+						"expired name became active "+
+						"again", lastHeight, height,
+						params.Name)
+					break
+				}
+			}
+		}
+	}
+}
+
+// TestNameRulesHeight ensures the name operations of blocks are only validated
+// from the height the network enforces the name rules at, whether or not the
+// checkpoints are enabled.
+func TestNameRulesHeight(t *testing.T) {
+	params := regTestParams()
+	params.NameRulesHeight = 2
+	chain, teardownFunc, err := chainSetupParams("namerulesheight", params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.DisableCheckpoints(true)
+
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	genesis.SetHeight(0)
+	subsidy := blockchain.CalcBlockSubsidy(1, params)
+	timeSource := blockchain.NewMedianTime()
+
+	// nameBlock returns a block extending the passed parent whose coinbase
+	// has a name output, which the name rules forbid.
+	nameBlock := func(parent *btcutil.Block) *btcutil.Block {
+		block := newTestBlock(parent, subsidy, 0)
+		msgBlock := block.MsgBlock()
+		coinbaseTx := msgBlock.Transactions[0]
+		coinbaseTx.Version = wire.NamecoinTxVersion
+		coinbaseTx.AddTxOut(wire.NewTxOut(0, nameUpdateScript(
+			[]byte("d/example"), []byte("value"))))
+		solveTestBlock(msgBlock)
+
+		solved := btcutil.NewBlock(msgBlock)
+		solved.SetHeight(block.Height())
+		return solved
+	}
+
+	// The block before the name rules height is accepted.
+	block1 := nameBlock(genesis)
+	_, err = chain.ProcessBlock(block1, timeSource, blockchain.BFNone)
+	if err != nil {
+		t.Fatalf("ProcessBlock: unexpected error for block before "+
+			"the name rules height: %v", err)
+	}
+
+	// The block at the name rules height is rejected.
+	_, err = chain.ProcessBlock(nameBlock(block1), timeSource,
+		blockchain.BFNone)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrNameValidation {
+		t.Errorf("ProcessBlock: did not receive expected error for "+
+			"block at the name rules height - got %v", err)
+	}
+}
+
+// TestCheckNameIndex ensures a missing name index is rebuilt from the blocks of
+// the main chain, dropping any stale entries, while a current one is kept and
+// only moved to the best block of the main chain.
+func TestCheckNameIndex(t *testing.T) {
+	db, err := database.CreateDB("memdb")
+	if err != nil {
+		t.Fatalf("CreateDB: %v", err)
+	}
+	defer db.Close()
+
+	params := &chaincfg.RegressionNetParams
+	genesis := btcutil.NewBlock(params.GenesisBlock)
+	if _, err := db.InsertBlock(genesis); err != nil {
+		t.Fatalf("InsertBlock: %v", err)
+	}
+	name := []byte("d/example")
+	value := []byte(`{"ip":"192.0.2.1"}`)
+	regTx := nameTestTx(wire.NamecoinTxVersion, 1000000, nil,
+		nameFirstUpdateScript(name, []byte("salt"), value))
+	genesisHash, err := genesis.Sha()
+	if err != nil {
+		t.Fatalf("Sha: %v", err)
+	}
+	// The coinbase of the block tries to take over a name, which must not
+	// end up in the name index.
+	coinbaseTx := nameCoinbaseTx(wire.NamecoinTxVersion, 1000000,
+		nameUpdateScript([]byte("d/coinbase"), value))
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{PrevBlock: *genesisHash})
+	msgBlock.AddTransaction(coinbaseTx.MsgTx())
+	msgBlock.AddTransaction(regTx.MsgTx())
+	block1 := btcutil.NewBlock(msgBlock)
+	if _, err := db.InsertBlock(block1); err != nil {
+		t.Fatalf("InsertBlock: %v", err)
+	}
+	block1Hash, err := block1.Sha()
+	if err != nil {
+		t.Fatalf("Sha: %v", err)
+	}
+
+	checkTip := func(wantHash *wire.ShaHash, wantHeight int64) {
+		hash, height, err := db.FetchNameIndexTip()
+		if err != nil {
+			t.Fatalf("FetchNameIndexTip: %v", err)
+		}
+		if !hash.IsEqual(wantHash) || height != wantHeight {
+			t.Errorf("FetchNameIndexTip: got %v (height %d), want "+
+				"%v (height %d)", hash, height, wantHash,
+				wantHeight)
+		}
+	}
+
+	// Leave a stale entry in the name index without a version, as a
+	// database created before the name index was versioned would.
+	stale := &database.NameEntry{Name: []byte("d/stale"), Height: 1}
+	err = db.UpdateNamesForBlock(block1Hash, 1,
+		[]*database.NameEntry{stale})
+	if err != nil {
+		t.Fatalf("UpdateNamesForBlock: %v", err)
+	}
+
+	chain := blockchain.New(db, params, nil)
+	if err := chain.CheckNameIndex(); err != nil {
+		t.Fatalf("CheckNameIndex: %v", err)
+	}
+	version, err := db.FetchNameIndexVersion()
+	if err != nil || version != database.NameIndexVersion {
+		t.Errorf("FetchNameIndexVersion: got %d (err %v), want %d",
+			version, err, database.NameIndexVersion)
+	}
+	if _, err := db.FetchName(stale.Name); err != database.ErrNameMissing {
+		t.Errorf("FetchName: got %v for stale name, want %v", err,
+			database.ErrNameMissing)
+	}
+	_, err = db.FetchName([]byte("d/coinbase"))
+	if err != database.ErrNameMissing {
+		t.Errorf("FetchName: got %v for name of coinbase, want %v", err,
+			database.ErrNameMissing)
+	}
+	entry, err := db.FetchName(name)
+	if err != nil {
+		t.Fatalf("FetchName: %v", err)
+	}
+	if !bytes.Equal(entry.Value, value) || entry.Height != 1 ||
+		!entry.TxSha.IsEqual(regTx.Sha()) {
+		t.Errorf("FetchName: got value %q at height %d in %v, want %q "+
+			"at height 1 in %v", entry.Value, entry.Height,
+			entry.TxSha, value, regTx.Sha())
+	}
+
+	checkTip(block1Hash, 1)
+
+	// A name index of the current version whose tip is the best block is
+	// left alone.
+	err = db.UpdateNamesForBlock(block1Hash, 1,
+		[]*database.NameEntry{stale})
+	if err != nil {
+		t.Fatalf("UpdateNamesForBlock: %v", err)
+	}
+	if err := chain.CheckNameIndex(); err != nil {
+		t.Fatalf("CheckNameIndex: %v", err)
+	}
+	if _, err := db.FetchName(stale.Name); err != nil {
+		t.Errorf("FetchName: current name index was rebuilt (%v)", err)
+	}
+
+	// A block connected to the main chain without its names, as after a
+	// crash between storing the block and updating the name index, is
+	// added to the index.
+	otherName := []byte("d/other")
+	otherTx := nameTestTx(wire.NamecoinTxVersion, 1000000, nil,
+		nameFirstUpdateScript(otherName, []byte("salt"), value))
+	msgBlock = wire.NewMsgBlock(&wire.BlockHeader{PrevBlock: *block1Hash})
+	msgBlock.AddTransaction(nameCoinbaseTx(1, 1000000).MsgTx())
+	msgBlock.AddTransaction(otherTx.MsgTx())
+	block2 := btcutil.NewBlock(msgBlock)
+	if _, err := db.InsertBlock(block2); err != nil {
+		t.Fatalf("InsertBlock: %v", err)
+	}
+	block2Hash, err := block2.Sha()
+	if err != nil {
+		t.Fatalf("Sha: %v", err)
+	}
+	if err := chain.CheckNameIndex(); err != nil {
+		t.Fatalf("CheckNameIndex: %v", err)
+	}
+	if _, err := db.FetchName(otherName); err != nil {
+		t.Errorf("FetchName: name of block added to the main chain: %v",
+			err)
+	}
+	if _, err := db.FetchName(stale.Name); err != nil {
+		t.Errorf("FetchName: current name index was rebuilt (%v)", err)
+	}
+	checkTip(block2Hash, 2)
+
+	// Blocks which are not part of the main chain, at the height of the
+	// best block and beyond it, are dropped from the index before the
+	// block of the main chain is added again.
+	if err := db.DropNamesForBlock(2); err != nil {
+		t.Fatalf("DropNamesForBlock: %v", err)
+	}
+	forked := &database.NameEntry{Name: []byte("d/forked"), Height: 2}
+	for height := int64(2); height <= 3; height++ {
+		err := db.UpdateNamesForBlock(&wire.ShaHash{byte(height)},
+			height, []*database.NameEntry{forked})
+		if err != nil {
+			t.Fatalf("UpdateNamesForBlock: %v", err)
+		}
+	}
+	if err := chain.CheckNameIndex(); err != nil {
+		t.Fatalf("CheckNameIndex: %v", err)
+	}
+	if _, err := db.FetchName(forked.Name); err != database.ErrNameMissing {
+		t.Errorf("FetchName: got %v for name of forked block, want %v",
+			err, database.ErrNameMissing)
+	}
+	if _, err := db.FetchName(otherName); err != nil {
+		t.Errorf("FetchName: name of block added to the main chain: %v",
+			err)
+	}
+	if _, err := db.FetchName(stale.Name); err != nil {
+		t.Errorf("FetchName: current name index was rebuilt (%v)", err)
+	}
+	checkTip(block2Hash, 2)
+}
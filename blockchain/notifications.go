@@ -29,14 +29,25 @@ const (
 	// NTBlockDisconnected indicates the associated block was disconnected
 	// from the main chain.
 	NTBlockDisconnected
+
+	// NTNameExpired indicates the associated name expired when the block
+	// at its expiration height was connected to the main chain.
+	NTNameExpired
+
+	// NTNameExpiryReverted indicates the associated name is active again
+	// because the block which expired it was disconnected from the main
+	// chain.
+	NTNameExpiryReverted
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
 // names for pretty printing.
 var notificationTypeStrings = map[NotificationType]string{
-	NTBlockAccepted:     "NTBlockAccepted",
-	NTBlockConnected:    "NTBlockConnected",
-	NTBlockDisconnected: "NTBlockDisconnected",
+	NTBlockAccepted:      "NTBlockAccepted",
+	NTBlockConnected:     "NTBlockConnected",
+	NTBlockDisconnected:  "NTBlockDisconnected",
+	NTNameExpired:        "NTNameExpired",
+	NTNameExpiryReverted: "NTNameExpiryReverted",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -50,9 +61,11 @@ func (n NotificationType) String() string {
 // Notification defines notification that is sent to the caller via the callback
 // function provided during the call to New and consists of a notification type
 // as well as associated data that depends on the type as follows:
-// 	- NTBlockAccepted:     *btcutil.Block
-// 	- NTBlockConnected:    *btcutil.Block
-// 	- NTBlockDisconnected: *btcutil.Block
+// 	- NTBlockAccepted:      *btcutil.Block
+// 	- NTBlockConnected:     *btcutil.Block
+// 	- NTBlockDisconnected:  *btcutil.Block
+// 	- NTNameExpired:        *database.NameEntry
+// 	- NTNameExpiryReverted: *database.NameEntry
 type Notification struct {
 	Type NotificationType
 	Data interface{}
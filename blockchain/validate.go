@@ -56,10 +56,6 @@ const (
 	// CoinbaseMaturity is the number of blocks required before newly
 	// mined bitcoins (coinbase transactions) can be spent.
 	CoinbaseMaturity = 100
-
-	// MinAuxPowBlock is the height of the first block that is allowed to
-	// be merged mined versus mined for Namecoin exclusively.
-	MinAuxPowBlock = 19200
 )
 
 var (
@@ -310,12 +306,36 @@ func checkMerkleBranch(m wire.MerkleBranch, root *wire.ShaHash, check *wire.ShaH
 	return nil, ruleError(ErrAuxPowValidation, "Merkle Branch does not successfully prove hash is in the tree.")
 }
 
-func checkAuxPowProofOfWork(block *btcutil.Block, powLimit *big.Int, flags BehaviorFlags) error {
-	current := block.MsgBlock().Header
+// checkAuxPowProofOfWork ensures the AuxPow header of a merged mined block
+// header proves that a parent chain block with a hash less than the target
+// difficulty of the block committed to it.  The parent coinbase must commit to
+// the merged mining merkle tree the block is part of as described by
+// readMergedMiningTransaction, and the block must occupy the slot of the chain
+// in that tree.
+//
+// The flags modify the behavior of this function as follows:
+//  - BFNoPoWCheck: The check to ensure the parent block hash is less than the
+//    target difficulty is not performed.
+func checkAuxPowProofOfWork(current *wire.BlockHeader, chainParams *chaincfg.Params, flags BehaviorFlags) error {
+	// The parent block may not be merged mined itself.
+	if current.AuxPowHeader.ParentBlock.IsAuxPow() {
+		return ruleError(ErrAuxPowValidation, "auxpow parent block has "+
+			"auxpow version")
+	}
+
+	// The parent block must belong to another chain, otherwise the same
+	// work could be used for a block of this chain twice.
+	chainID := current.ChainID()
+	parentChainID := current.AuxPowHeader.ParentBlock.ChainID()
+	if chainParams.StrictChainID && parentChainID == chainID {
+		str := fmt.Sprintf("auxpow parent block has our chain ID %d",
+			parentChainID)
+		return ruleError(ErrWrongChainID, str)
+	}
 
 	err := checkProofOfWorkBits(
 		&current.AuxPowHeader.ParentBlock,
-		powLimit,
+		chainParams.PowLimit,
 		current.Bits,
 		flags,
 	)
@@ -323,23 +343,40 @@ func checkAuxPowProofOfWork(block *btcutil.Block, powLimit *big.Int, flags Behav
 		return err
 	}
 
+	// The parent coinbase must be the first transaction of the parent
+	// block, so its merkle branch may only ever go left.
+	auxPow := current.AuxPowHeader
+	if auxPow.CoinbaseBranch.BranchSideMask != 0 {
+		return ruleError(ErrAuxPowValidation, "auxpow parent coinbase "+
+			"is not the first transaction of the parent block")
+	}
+	if len(auxPow.CoinbaseTx.TxIn) < 1 {
+		return ruleError(ErrAuxPowValidation, "auxpow parent coinbase "+
+			"has no inputs")
+	}
+
 	// Verify that the CoinbaseTx is in the Merkle Tree...
-	coinbaseSha, err := current.AuxPowHeader.CoinbaseTx.TxSha()
+	coinbaseSha, err := auxPow.CoinbaseTx.TxSha()
 	if err != nil {
 		return err
 	}
 
 	_, err = checkMerkleBranch(
-		current.AuxPowHeader.CoinbaseBranch,
-		&current.AuxPowHeader.ParentBlock.MerkleRoot,
+		auxPow.CoinbaseBranch,
+		&auxPow.ParentBlock.MerkleRoot,
 		&coinbaseSha,
 	)
 	if err != nil {
 		return err
 	}
 
-	if len(current.AuxPowHeader.CoinbaseTx.TxIn) < 1 {
-		return ruleError(ErrAuxPowValidation, "Could not find Parent Block's Coinbase Transaction.")
+	// Compute the root of the merged mining merkle tree the block is
+	// part of and ensure the parent coinbase commits to it.
+	branchLen := len(auxPow.BlockchainBranch.BranchHash)
+	if branchLen > wire.MaxMerkleBranchLen {
+		str := fmt.Sprintf("auxpow chain merkle branch is too long - "+
+			"got %d, max %d", branchLen, wire.MaxMerkleBranchLen)
+		return ruleError(ErrAuxPowValidation, str)
 	}
 
 	currentSha, err := current.BlockSha()
@@ -347,35 +384,35 @@ func checkAuxPowProofOfWork(block *btcutil.Block, powLimit *big.Int, flags Behav
 		return err
 	}
 
-	cbRoot, _ := checkMerkleBranch(
-		current.AuxPowHeader.BlockchainBranch,
+	chainRoot, _ := checkMerkleBranch(
+		auxPow.BlockchainBranch,
 		nil,
 		&currentSha,
 	)
 
-	coinbase := current.AuxPowHeader.CoinbaseTx.TxIn[0]
-	mm, err := readMergedMiningTransaction(coinbase, &currentSha, cbRoot)
+	coinbase := auxPow.CoinbaseTx.TxIn[0]
+	mm, err := readMergedMiningTransaction(coinbase.SignatureScript,
+		chainRoot)
 	if err != nil {
 		return err
 	}
 
-	if mm.MerkleNonce == 0 && mm.MerkleSize == 1 {
-		if !currentSha.IsEqual(&mm.BlockHash) {
-			errorMsg := fmt.Sprintf(
-				"Coinbase TX's hash (%s) doesn't match block's (%s).",
-				mm.BlockHash,
-				currentSha,
-			)
-			return ruleError(ErrAuxPowValidation, errorMsg)
-		}
-	} else {
-		if _, err := checkMerkleBranch(
-			current.AuxPowHeader.BlockchainBranch,
-			&mm.BlockHash,
-			&currentSha,
-		); err != nil {
-			return err
-		}
+	// The size of the merged mining merkle tree must match the branch and
+	// the block must occupy the slot determined by the merkle nonce and
+	// the chain ID of the block.  This is the chain ID of the network
+	// unless it doesn't enforce a strict chain ID.
+	if mm.MerkleSize != 1<<uint(branchLen) {
+		str := fmt.Sprintf("auxpow chain merkle tree size of %d does "+
+			"not match branch length %d", mm.MerkleSize, branchLen)
+		return ruleError(ErrAuxPowValidation, str)
+	}
+	index := uint32(auxPow.BlockchainBranch.BranchSideMask)
+	expectedIndex := expectedMergedMiningIndex(mm.MerkleNonce, chainID,
+		uint(branchLen))
+	if index != expectedIndex {
+		str := fmt.Sprintf("auxpow chain merkle index of %d is not "+
+			"the expected index %d", index, expectedIndex)
+		return ruleError(ErrAuxPowValidation, str)
 	}
 
 	return nil
@@ -426,27 +463,88 @@ func checkProofOfWorkBits(block *wire.BlockHeader, powLimit *big.Int, targetBits
 
 // checkProofOfWork ensures the block header bits which indicate the target
 // difficulty is in min/max range and that the block hash is less than the
-// target difficulty as claimed.
-//
+// target difficulty as claimed.  Blocks which are not legacy blocks must encode
+// the chain ID of the network in their version when it enforces a strict chain
+// ID.  The height dependent AuxPow rules are checked in maybeAcceptBlock.
 //
 // The flags modify the behavior of this function as follows:
 //  - BFNoPoWCheck: The check to ensure the block hash is less than the target
 //    difficulty is not performed.
-func checkProofOfWork(block *btcutil.Block, powLimit *big.Int, flags BehaviorFlags) error {
-	// We would check for Height issues here; however, because we do have checkpoint blocks,
-	// this shouldn't prove to be an issue.
-	if block.MsgBlock().Header.AuxPowHeader != nil {
-		return checkAuxPowProofOfWork(block, powLimit, flags)
+func checkProofOfWork(header *wire.BlockHeader, chainParams *chaincfg.Params, flags BehaviorFlags) error {
+	if !header.IsLegacy() && chainParams.StrictChainID &&
+		header.ChainID() != chainParams.AuxPowChainID {
+
+		str := fmt.Sprintf("block has chain ID %d instead of %d",
+			header.ChainID(), chainParams.AuxPowChainID)
+		return ruleError(ErrWrongChainID, str)
+	}
+
+	// The auxpow must be present if and only if the version says so.
+	if header.IsAuxPow() != (header.AuxPowHeader != nil) {
+		str := fmt.Sprintf("block version 0x%08x does not match "+
+			"presence of auxpow", header.Version)
+		return ruleError(ErrAuxPowValidation, str)
+	}
+
+	if header.AuxPowHeader != nil {
+		return checkAuxPowProofOfWork(header, chainParams, flags)
 	}
 
-	return checkProofOfWorkBits(&block.MsgBlock().Header, powLimit, block.MsgBlock().Header.Bits, flags)
+	return checkProofOfWorkBits(header, chainParams.PowLimit, header.Bits, flags)
+}
+
+// checkAuxPowHeight ensures blocks are only merged mined from the AuxPow start
+// height on and, when the network enforces a strict chain ID, no longer use the
+// legacy block version from then on.  The genesis block is exempt.
+func checkAuxPowHeight(header *wire.BlockHeader, height int64, chainParams *chaincfg.Params) error {
+	auxPowStartHeight := chainParams.AuxPowStartHeight
+	if header.IsAuxPow() && height < auxPowStartHeight {
+		str := fmt.Sprintf("block at height %d is merged mined before "+
+			"height %d", height, auxPowStartHeight)
+		return ruleError(ErrAuxPowTooEarly, str)
+	}
+	if chainParams.StrictChainID && header.IsLegacy() && height > 0 &&
+		height >= auxPowStartHeight {
+
+		str := fmt.Sprintf("legacy block version is no longer valid "+
+			"at height %d", height)
+		return ruleError(ErrLegacyBlockVersion, str)
+	}
+
+	return nil
 }
 
 // CheckProofOfWork ensures the block header bits which indicate the target
 // difficulty is in min/max range and that the block hash is less than the
 // target difficulty as claimed.
-func CheckProofOfWork(block *btcutil.Block, powLimit *big.Int) error {
-	return checkProofOfWork(block, powLimit, BFNone)
+func CheckProofOfWork(block *btcutil.Block, chainParams *chaincfg.Params) error {
+	return checkProofOfWork(&block.MsgBlock().Header, chainParams, BFNone)
+}
+
+// CheckAuxPowProofOfWork ensures the passed block is merged mined and that its
+// AuxPow header proves the block was committed to by a parent chain block with
+// a hash less than the target difficulty claimed by the block.
+func CheckAuxPowProofOfWork(block *btcutil.Block, chainParams *chaincfg.Params) error {
+	if block.MsgBlock().Header.AuxPowHeader == nil {
+		return ruleError(ErrAuxPowValidation, "block has no auxpow header")
+	}
+
+	return checkAuxPowProofOfWork(&block.MsgBlock().Header, chainParams,
+		BFNone)
+}
+
+// CheckBlockHeaderSanity performs the checks on the header of the block at the
+// passed height which don't depend on the blocks before it.  This includes the
+// proof of work, which is proven by the AuxPow header for merged mined blocks,
+// and the timestamp.  It allows headers to be validated as they are downloaded
+// before their blocks are available.
+func CheckBlockHeaderSanity(header *wire.BlockHeader, height int64, chainParams *chaincfg.Params, timeSource MedianTimeSource) error {
+	err := checkBlockHeaderSanity(header, chainParams, timeSource, BFNone)
+	if err != nil {
+		return err
+	}
+
+	return checkAuxPowHeight(header, height, chainParams)
 }
 
 // CountSigOps returns the number of signature operations for all transaction
@@ -539,12 +637,48 @@ func CountP2SHSigOps(tx *btcutil.Tx, isCoinBaseTx bool, txStore TxStore) (int, e
 	return totalSigOps, nil
 }
 
+// checkBlockHeaderSanity performs the context free checks on a block header.
+//
+// The flags do not modify the behavior of this function directly, however they
+// are needed to pass along to checkProofOfWork.
+func checkBlockHeaderSanity(header *wire.BlockHeader, chainParams *chaincfg.Params, timeSource MedianTimeSource, flags BehaviorFlags) error {
+	// Ensure the proof of work bits in the block header is in min/max range
+	// and the block hash is less than the target value described by the
+	// bits.
+	err := checkProofOfWork(header, chainParams, flags)
+	if err != nil {
+		return err
+	}
+
+	// A block timestamp must not have a greater precision than one second.
+	// This check is necessary because Go time.Time values support
+	// nanosecond precision whereas the consensus rules only apply to
+	// seconds and it's much nicer to deal with standard Go time values
+	// instead of converting to seconds everywhere.
+	if !header.Timestamp.Equal(time.Unix(header.Timestamp.Unix(), 0)) {
+		str := fmt.Sprintf("block timestamp of %v has a higher "+
+			"precision than one second", header.Timestamp)
+		return ruleError(ErrInvalidTime, str)
+	}
+
+	// Ensure the block time is not too far in the future.
+	maxTimestamp := timeSource.AdjustedTime().Add(time.Second *
+		MaxTimeOffsetSeconds)
+	if header.Timestamp.After(maxTimestamp) {
+		str := fmt.Sprintf("block timestamp of %v is too far in the "+
+			"future", header.Timestamp)
+		return ruleError(ErrTimeTooNew, str)
+	}
+
+	return nil
+}
+
 // checkBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
 //
 // The flags do not modify the behavior of this function directly, however they
 // are needed to pass along to checkProofOfWork.
-func checkBlockSanity(block *btcutil.Block, powLimit *big.Int, timeSource MedianTimeSource, flags BehaviorFlags) error {
+func checkBlockSanity(block *btcutil.Block, chainParams *chaincfg.Params, timeSource MedianTimeSource, flags BehaviorFlags) error {
 	// A block must have at least one transaction.
 	msgBlock := block.MsgBlock()
 	numTx := len(msgBlock.Transactions)
@@ -569,35 +703,12 @@ func checkBlockSanity(block *btcutil.Block, powLimit *big.Int, timeSource Median
 		return ruleError(ErrBlockTooBig, str)
 	}
 
-	// Ensure the proof of work bits in the block header is in min/max range
-	// and the block hash is less than the target value described by the
-	// bits.
-	err := checkProofOfWork(block, powLimit, flags)
+	header := &msgBlock.Header
+	err := checkBlockHeaderSanity(header, chainParams, timeSource, flags)
 	if err != nil {
 		return err
 	}
 
-	// A block timestamp must not have a greater precision than one second.
-	// This check is necessary because Go time.Time values support
-	// nanosecond precision whereas the consensus rules only apply to
-	// seconds and it's much nicer to deal with standard Go time values
-	// instead of converting to seconds everywhere.
-	header := &block.MsgBlock().Header
-	if !header.Timestamp.Equal(time.Unix(header.Timestamp.Unix(), 0)) {
-		str := fmt.Sprintf("block timestamp of %v has a higher "+
-			"precision than one second", header.Timestamp)
-		return ruleError(ErrInvalidTime, str)
-	}
-
-	// Ensure the block time is not too far in the future.
-	maxTimestamp := timeSource.AdjustedTime().Add(time.Second *
-		MaxTimeOffsetSeconds)
-	if header.Timestamp.After(maxTimestamp) {
-		str := fmt.Sprintf("block timestamp of %v is too far in the "+
-			"future", header.Timestamp)
-		return ruleError(ErrTimeTooNew, str)
-	}
-
 	// The first transaction in a block must be a coinbase.
 	transactions := block.Transactions()
 	if !IsCoinBase(transactions[0]) {
@@ -673,8 +784,8 @@ func checkBlockSanity(block *btcutil.Block, powLimit *big.Int, timeSource Median
 
 // CheckBlockSanity performs some preliminary checks on a block to ensure it is
 // sane before continuing with block processing.  These checks are context free.
-func CheckBlockSanity(block *btcutil.Block, powLimit *big.Int, timeSource MedianTimeSource) error {
-	return checkBlockSanity(block, powLimit, timeSource, BFNone)
+func CheckBlockSanity(block *btcutil.Block, chainParams *chaincfg.Params, timeSource MedianTimeSource) error {
+	return checkBlockSanity(block, chainParams, timeSource, BFNone)
 }
 
 // checkSerializedHeight checks if the signature script in the passed
@@ -982,8 +1093,21 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block) er
 		}
 	}
 
-	// Perform several checks on the inputs for each transaction.  Also
-	// accumulate the total fees.  This could technically be combined with
+	// Name operations are only checked from the height the network
+	// enforces the name rules at regardless of the checkpoints, since the
+	// historic blocks before it contain name operations that are invalid
+	// under the current rules.
+	checkNames := node.height >= b.chainParams.NameRulesHeight
+	var nameView NameView
+	if checkNames {
+		nameView, err = b.fetchBlockNameView(node, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Perform several checks on the inputs and name operations for each
+	// transaction.  Also accumulate the total fees.  This could technically be combined with
 	// the loop above instead of running another loop over the transactions,
 	// but by separating it we can avoid running the more expensive (though
 	// still relatively cheap as compared to running the scripts) checks
@@ -991,6 +1115,19 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block) er
 	// bounds.
 	var totalFees int64
 	for _, tx := range transactions {
+		if checkNames {
+			err := CheckTransactionNames(tx, node.height,
+				txInputStore, nameView, b.chainParams)
+			if err != nil {
+				return err
+			}
+
+			// Later transactions in the block see the names as
+			// updated by this one.
+			connectNameEntries(nameView, nameEntriesForTx(tx,
+				node.height, b.chainParams))
+		}
+
 		txFee, err := CheckTransactionInputs(tx, node.height, txInputStore)
 		if err != nil {
 			return err
@@ -44,10 +44,10 @@ func TestCheckConnectBlock(t *testing.T) {
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
-	powLimit := chaincfg.MainNetParams.PowLimit
+	params := &chaincfg.MainNetParams
 	block := btcutil.NewBlock(&Block100000)
 	timeSource := blockchain.NewMedianTime()
-	err := blockchain.CheckBlockSanity(block, powLimit, timeSource)
+	err := blockchain.CheckBlockSanity(block, params, timeSource)
 	if err != nil {
 		t.Errorf("CheckBlockSanity: %v", err)
 	}
@@ -56,7 +56,7 @@ func TestCheckBlockSanity(t *testing.T) {
 	// second fails.
 	timestamp := block.MsgBlock().Header.Timestamp
 	block.MsgBlock().Header.Timestamp = timestamp.Add(time.Nanosecond)
-	err = blockchain.CheckBlockSanity(block, powLimit, timeSource)
+	err = blockchain.CheckBlockSanity(block, params, timeSource)
 	if err == nil {
 		t.Errorf("CheckBlockSanity: error is nil when it shouldn't be")
 	}
@@ -128,6 +128,208 @@ func TestCheckSerializedHeight(t *testing.T) {
 	}
 }
 
+// TestCheckAuxPowProofOfWork ensures the AuxPow header of a merged mined block
+// is accepted when its parent block commits to the block and meets its target
+// difficulty and rejected otherwise.  It also ensures the chain IDs of the block
+// and its parent are enforced.
+func TestCheckAuxPowProofOfWork(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+
+	// Create the merged mined block and commit to its hash in the coinbase
+	// of the parent block.  The hash is stored in the byte order it is
+	// displayed in.
+	var msgBlock wire.MsgBlock
+	msgBlock.Header = wire.BlockHeader{
+		Version:   2,
+		Timestamp: time.Unix(0x55000000, 0),
+		Bits:      params.PowLimitBits,
+	}
+	msgBlock.Header.SetAuxPowVersion(params.AuxPowChainID)
+	blockHash, _ := msgBlock.Header.BlockSha()
+	sigScript := []byte{0x03, 0x01, 0x02, 0x03, 0x2c, 0xfa, 0xbe, 0x6d, 0x6d}
+	for i := wire.HashSize - 1; i >= 0; i-- {
+		sigScript = append(sigScript, blockHash[i])
+	}
+	sigScript = append(sigScript, 0x01, 0x00, 0x00, 0x00) // Merkle size
+	sigScript = append(sigScript, 0x00, 0x00, 0x00, 0x00) // Merkle nonce
+
+	coinbaseTx := wire.NewMsgTx()
+	coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&wire.ShaHash{},
+		math.MaxUint32), sigScript))
+	coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+	coinbaseHash, _ := coinbaseTx.TxSha()
+
+	// Solve the parent block against the target of the merged mined block.
+	parent := wire.BlockHeader{
+		Version:    1,
+		MerkleRoot: coinbaseHash,
+		Timestamp:  msgBlock.Header.Timestamp,
+		Bits:       0x1d00ffff,
+	}
+	target := blockchain.CompactToBig(msgBlock.Header.Bits)
+	for {
+		parentHash, _ := parent.BlockSha()
+		if blockchain.ShaHashToBig(&parentHash).Cmp(target) <= 0 {
+			break
+		}
+		parent.Nonce++
+	}
+	parentHash, _ := parent.BlockSha()
+	msgBlock.Header.AuxPowHeader = &wire.AuxPow{
+		CoinbaseTx:  coinbaseTx,
+		BlockHash:   parentHash,
+		ParentBlock: parent,
+	}
+
+	block := btcutil.NewBlock(&msgBlock)
+	err := blockchain.CheckAuxPowProofOfWork(block, params)
+	if err != nil {
+		t.Fatalf("CheckAuxPowProofOfWork: unexpected error: %v", err)
+	}
+	err = blockchain.CheckProofOfWork(block, params)
+	if err != nil {
+		t.Fatalf("CheckProofOfWork: unexpected error: %v", err)
+	}
+
+	// A block with the chain ID of another chain must be rejected.
+	version := msgBlock.Header.Version
+	msgBlock.Header.SetAuxPowVersion(params.AuxPowChainID + 1)
+	err = blockchain.CheckProofOfWork(btcutil.NewBlock(&msgBlock), params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrWrongChainID {
+		t.Errorf("CheckProofOfWork: did not receive expected error "+
+			"for wrong chain ID - got %v", err)
+	}
+	msgBlock.Header.Version = version
+
+	// A parent block with our own chain ID must be rejected.
+	msgBlock.Header.AuxPowHeader.ParentBlock.SetBaseVersion(1,
+		params.AuxPowChainID)
+	err = blockchain.CheckAuxPowProofOfWork(btcutil.NewBlock(&msgBlock),
+		params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrWrongChainID {
+		t.Errorf("CheckAuxPowProofOfWork: did not receive expected "+
+			"error for parent chain ID - got %v", err)
+	}
+	msgBlock.Header.AuxPowHeader.ParentBlock = parent
+
+	// A merged mined parent block must be rejected.
+	msgBlock.Header.AuxPowHeader.ParentBlock.SetAuxPowVersion(
+		params.AuxPowChainID + 1)
+	err = blockchain.CheckAuxPowProofOfWork(btcutil.NewBlock(&msgBlock),
+		params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("CheckAuxPowProofOfWork: did not receive expected "+
+			"error for merged mined parent - got %v", err)
+	}
+	msgBlock.Header.AuxPowHeader.ParentBlock = parent
+
+	// Changing the block after it was committed to must be rejected.
+	msgBlock.Header.Nonce++
+	err = blockchain.CheckAuxPowProofOfWork(btcutil.NewBlock(&msgBlock),
+		params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("CheckAuxPowProofOfWork: did not receive expected "+
+			"error for modified block - got %v", err)
+	}
+	msgBlock.Header.Nonce--
+
+	// A block without an AuxPow header must be rejected.
+	msgBlock.Header.AuxPowHeader = nil
+	err = blockchain.CheckAuxPowProofOfWork(btcutil.NewBlock(&msgBlock),
+		params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("CheckAuxPowProofOfWork: did not receive expected "+
+			"error for missing auxpow - got %v", err)
+	}
+
+	// A block signalling auxpow without an AuxPow header must be rejected.
+	err = blockchain.CheckProofOfWork(btcutil.NewBlock(&msgBlock), params)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrAuxPowValidation {
+		t.Errorf("CheckProofOfWork: did not receive expected error "+
+			"for missing auxpow - got %v", err)
+	}
+}
+
+// TestCheckBlockHeaderSanity ensures block headers are validated on their own,
+// including the rules which depend on their height.
+func TestCheckBlockHeaderSanity(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	timeSource := blockchain.NewMedianTime()
+
+	// solve returns the passed header with a nonce which satisfies its
+	// target difficulty.
+	solve := func(header wire.BlockHeader) *wire.BlockHeader {
+		target := blockchain.CompactToBig(header.Bits)
+		for {
+			hash, _ := header.BlockSha()
+			if blockchain.ShaHashToBig(&hash).Cmp(target) <= 0 {
+				return &header
+			}
+			header.Nonce++
+		}
+	}
+
+	header := wire.BlockHeader{
+		Timestamp: time.Unix(0x55000000, 0),
+		Bits:      params.PowLimitBits,
+	}
+	header.SetBaseVersion(2, params.AuxPowChainID)
+	legacy := header
+	legacy.Version = 1
+	future := header
+	future.Timestamp = timeSource.AdjustedTime().Add(3 * time.Hour)
+	auxPow := header
+	auxPow.SetAuxPowVersion(params.AuxPowChainID)
+
+	tests := []struct {
+		name   string
+		header *wire.BlockHeader
+		height int64
+		code   blockchain.ErrorCode
+		valid  bool
+	}{
+		{"valid", solve(header), 1, 0, true},
+		{"legacy genesis", solve(legacy), 0, 0, true},
+		{"legacy", solve(legacy), 1, blockchain.ErrLegacyBlockVersion, false},
+		{"high hash", &header, 1, blockchain.ErrHighHash, false},
+		{"future", solve(future), 1, blockchain.ErrTimeTooNew, false},
+		{"missing auxpow", solve(auxPow), 1,
+			blockchain.ErrAuxPowValidation, false},
+	}
+	for _, test := range tests {
+		// Ensure the unsolved header really is unsolved.
+		if test.code == blockchain.ErrHighHash {
+			hash, _ := test.header.BlockSha()
+			target := blockchain.CompactToBig(test.header.Bits)
+			if blockchain.ShaHashToBig(&hash).Cmp(target) <= 0 {
+				test.header.Nonce++
+			}
+		}
+
+		err := blockchain.CheckBlockHeaderSanity(test.header,
+			test.height, params, timeSource)
+		if test.valid {
+			if err != nil {
+				t.Errorf("CheckBlockHeaderSanity (%s): unexpected "+
+					"error: %v", test.name, err)
+			}
+			continue
+		}
+		if rerr, ok := err.(blockchain.RuleError); !ok ||
+			rerr.ErrorCode != test.code {
+			t.Errorf("CheckBlockHeaderSanity (%s): did not receive "+
+				"expected error %v - got %v", test.name,
+				test.code, err)
+		}
+	}
+}
+
 // Block100000 defines block 100,000 of the block chain.  It is used to
 // test Block operations.
 var Block100000 = wire.MsgBlock{
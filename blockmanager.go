@@ -6,6 +6,8 @@ package main
 
 import (
 	"container/list"
+	"fmt"
+	"math/big"
 	"net"
 	"os"
 	"path/filepath"
@@ -23,10 +25,36 @@ import (
 const (
 	chanBufferSize = 50
 
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
+	// maxBlocksInFlightPerPeer is the maximum number of blocks requested
+	// from a single peer at once in headers-first mode.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockDownloadWindow is how far ahead of the latest processed block
+	// blocks are requested in headers-first mode.  Blocks arriving out of
+	// order are held until all blocks before them are processed, so this
+	// limits the number of blocks held in memory.
+	blockDownloadWindow = 1024
+
+	// blockStallTimeout is the time a peer may take to deliver a requested
+	// block in headers-first mode before it is considered stalling.  The
+	// blocks requested from stalling peers are requested from other peers
+	// instead.
+	blockStallTimeout = time.Minute
+
+	// headersStallTimeout is the time the sync peer may take to respond to
+	// a request for block headers before it is considered stalling.
+	headersStallTimeout = 2 * time.Minute
+
+	// stallCheckInterval is the interval at which downloads are checked
+	// for stalls.
+	stallCheckInterval = 10 * time.Second
+
+	// maxHeadersBeyondCheckpoint is the maximum number of downloaded block
+	// headers after the final checkpoint whose blocks are not processed
+	// yet.  More headers are requested once their blocks are processed.
+	// Headers before the final checkpoint are limited by the next
+	// checkpoint instead.
+	maxHeadersBeyondCheckpoint = 2 * wire.MaxBlockHeadersPerMsg
 
 	// blockDbNamePrefix is the prefix for the block database name.  The
 	// database type is appended to this value to form the full block
@@ -118,6 +146,42 @@ type processBlockMsg struct {
 	reply chan processBlockResponse
 }
 
+// chainTipsMsg is a message type to be sent across the message channel for
+// requesting the tips of all known branches of the block chain.
+type chainTipsMsg struct {
+	reply chan []blockchain.ChainTip
+}
+
+// invalidateBlockMsg is a message type to be sent across the message channel
+// for requesting a block is marked invalid.
+type invalidateBlockMsg struct {
+	hash  *wire.ShaHash
+	reply chan error
+}
+
+// reconsiderBlockMsg is a message type to be sent across the message channel
+// for requesting the invalid mark of a block is removed.
+type reconsiderBlockMsg struct {
+	hash  *wire.ShaHash
+	reply chan error
+}
+
+// chainInfoResponse is a response sent to the reply channel of a chainInfoMsg
+// query.
+type chainInfoResponse struct {
+	chainWork  *big.Int
+	upgrades   []*blockchain.VersionUpgrade
+	syncHeight int64
+}
+
+// chainInfoMsg is a message type to be sent across the message channel for
+// requesting the work of the main chain, the upgrade status of the passed
+// block versions and the height of the best chain known to the sync peer.
+type chainInfoMsg struct {
+	versions []int32
+	reply    chan chainInfoResponse
+}
+
 // isCurrentMsg is a message type to be sent across the message channel for
 // requesting whether or not the block manager believes it is synced with
 // the currently connected peers.
@@ -126,10 +190,19 @@ type isCurrentMsg struct {
 }
 
 // headerNode is used as a node in a list of headers that are linked together
-// between checkpoints.
+// in headers-first mode.
 type headerNode struct {
-	height int64
-	sha    *wire.ShaHash
+	height    int64
+	sha       *wire.ShaHash
+	bits      uint32
+	timestamp time.Time
+}
+
+// blockRequest describes an outstanding request for a block in headers-first
+// mode.
+type blockRequest struct {
+	peer      *peer
+	requested time.Time
 }
 
 // chainState tracks the state of the best chain as blocks are inserted.  This
@@ -176,27 +249,55 @@ type blockManager struct {
 	wg                sync.WaitGroup
 	quit              chan struct{}
 
-	// The following fields are used for headers-first mode.
-	headersFirstMode bool
-	headerList       *list.List
-	startHeader      *list.Element
-	nextCheckpoint   *chaincfg.Checkpoint
+	// The following fields are used for headers-first mode.  The front of
+	// the header list is always the latest processed block, followed by
+	// the headers of the blocks still to be processed.  The headers build
+	// on the main chain block at the anchor height.  The recent headers
+	// are kept after their blocks are processed to calculate the
+	// difficulty of the following headers.
+	headersFirstMode     bool
+	headersAnchored      bool
+	headersSynced        bool
+	headersRequested     time.Time
+	headerList           *list.List
+	headerIndex          map[wire.ShaHash]struct{}
+	recentHeaders        map[int64]*headerNode
+	anchorHeight         int64
+	headersWork          *big.Int
+	requiredWork         *big.Int
+	blockRequests        map[wire.ShaHash]*blockRequest
+	pendingBlocks        map[wire.ShaHash]*blockMsg
+	lastCheckpointHeight int64
+	nextCheckpoint       *chaincfg.Checkpoint
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
-// syncing from a new peer.
+// syncing from a new peer.  Outstanding block requests are forgotten, so the
+// blocks can be requested again.
 func (b *blockManager) resetHeaderState(newestHash *wire.ShaHash, newestHeight int64) {
 	b.headersFirstMode = false
+	b.headersAnchored = false
+	b.headersSynced = false
+	b.headersRequested = time.Time{}
 	b.headerList.Init()
-	b.startHeader = nil
-
-	// When there is a next checkpoint, add an entry for the latest known
-	// block into the header pool.  This allows the next downloaded header
-	// to prove it links to the chain properly.
-	if b.nextCheckpoint != nil {
-		node := headerNode{height: newestHeight, sha: newestHash}
-		b.headerList.PushBack(&node)
-	}
+	b.headerIndex = make(map[wire.ShaHash]struct{})
+	b.recentHeaders = make(map[int64]*headerNode)
+	b.anchorHeight = newestHeight
+	b.headersWork = big.NewInt(0)
+	b.requiredWork = big.NewInt(0)
+	for hash := range b.blockRequests {
+		delete(b.requestedBlocks, hash)
+	}
+	b.blockRequests = make(map[wire.ShaHash]*blockRequest)
+	b.pendingBlocks = make(map[wire.ShaHash]*blockMsg)
+	b.lastCheckpointHeight = newestHeight
+	b.nextCheckpoint = b.findNextHeaderCheckpoint(newestHeight)
+
+	// Add an entry for the latest known block into the header pool.  This
+	// allows the next downloaded header to prove it links to the chain
+	// properly.
+	node := headerNode{height: newestHeight, sha: newestHash}
+	b.headerList.PushBack(&node)
 }
 
 // updateChainState updates the chain state associated with the block manager.
@@ -261,7 +362,7 @@ func (b *blockManager) startSync(peers *list.List) {
 	}
 
 	// Find the height of the current known best block.
-	_, height, err := b.server.db.NewestSha()
+	newestHash, height, err := b.server.db.NewestSha()
 	if err != nil {
 		bmgrLog.Errorf("%v", err)
 		return
@@ -301,31 +402,32 @@ func (b *blockManager) startSync(peers *list.List) {
 		bmgrLog.Infof("Syncing to block height %d from peer %v",
 			bestPeer.lastBlock, bestPeer.addr)
 
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
+		// When the peer is ahead, use block headers to learn about
+		// which blocks comprise the chain up to the tip of the peer.
+		// The headers are validated as they arrive, which includes
+		// their proof of work, so the blocks can then be downloaded
+		// from all sync candidates in parallel.  Since each header
+		// contains the hash of the previous header and a merkle root,
+		// the received headers linking together properly and matching
+		// the checkpoints proves the hashes of the blocks up to the
+		// last checkpoint are accurate, so those blocks get less
+		// validation.  Further, once the full blocks are downloaded,
+		// the merkle root is computed and compared against the value
+		// in the header which proves the full block hasn't been
+		// tampered with.
 		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if b.nextCheckpoint != nil && height < b.nextCheckpoint.Height &&
-			!cfg.RegressionTest && !cfg.DisableCheckpoints {
-
-			bestPeer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
+		// Regression test mode does not support the headers-first
+		// approach so do normal block downloads when in regression test
+		// mode.
+		if int64(bestPeer.lastBlock) > height && !cfg.RegressionTest {
+			b.resetHeaderState(newestHash, height)
 			b.headersFirstMode = true
+			b.headersRequested = time.Now()
+			b.progressLogger.SetLastLogTime(b.headersRequested)
+			bestPeer.PushGetHeadersMsg(locator, &zeroHash)
 			bmgrLog.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", height+1,
-				b.nextCheckpoint.Height, bestPeer.addr)
+				"%d from peer %s", height+1, bestPeer.lastBlock,
+				bestPeer.addr)
 		} else {
 			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		}
@@ -409,15 +511,20 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, p *peer) {
 
 	// Remove requested blocks from the global map so that they will be
 	// fetched from elsewhere next time we get an inv.
-	// TODO(oga) we could possibly here check which peers have these blocks
-	// and request them now to speed things up a little.
 	for k := range p.requestedBlocks {
 		delete(b.requestedBlocks, k)
 	}
+	for hash, req := range b.blockRequests {
+		if req.peer == p {
+			delete(b.blockRequests, hash)
+		}
+	}
 
 	// Attempt to find a new peer to sync from if the quitting peer is the
 	// sync peer.  Also, reset the headers-first state if in headers-first
-	// mode so
+	// mode so the headers are downloaded from the new sync peer.
+	// Otherwise, request the blocks the peer was downloading in
+	// headers-first mode from the remaining peers.
 	if b.syncPeer != nil && b.syncPeer == p {
 		b.syncPeer = nil
 		if b.headersFirstMode {
@@ -433,6 +540,10 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, p *peer) {
 			b.resetHeaderState(newestHash, height)
 		}
 		b.startSync(peers)
+		return
+	}
+	if b.headersFirstMode {
+		b.fetchHeaderBlocks(peers)
 	}
 }
 
@@ -448,8 +559,10 @@ func (b *blockManager) handleTxMsg(tmsg *txMsg) {
 	// interoperability.
 
 	// Process the transaction to include validation, insertion in the
-	// memory pool, orphan handling, etc.
-	err := tmsg.peer.server.txMemPool.ProcessTransaction(tmsg.tx, true, true)
+	// memory pool, orphan handling, etc.  Transactions from whitelisted
+	// peers are not subject to the free transaction rate limiting.
+	txMemPool := tmsg.peer.server.txMemPool
+	err := txMemPool.ProcessTransaction(tmsg.tx, true, !tmsg.peer.whitelisted)
 
 	// Remove transaction from request maps. Either the mempool/chain
 	// already knows about it and as such we shouldn't have any more
@@ -477,6 +590,20 @@ func (b *blockManager) handleTxMsg(tmsg *txMsg) {
 		code, reason := errToRejectErr(err)
 		tmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason, txHash,
 			false)
+
+		// Increase the ban score of the peer when the transaction is
+		// considered an attack, such as a flood of large orphans.
+		if banScore := extractBanScore(err); banScore > 0 {
+			tmsg.peer.addBanScore(0, banScore, reason)
+		}
+
+		// Whitelisted peers may rely on this server to get their
+		// transactions out, so always relay their transactions, even
+		// when they are in the memory pool already.
+		if tmsg.peer.whitelisted && txMemPool.IsTransactionInPool(txHash) {
+			iv := wire.NewInvVect(wire.InvTypeTx, txHash)
+			tmsg.peer.server.RelayInventory(iv, tmsg.tx)
+		}
 		return
 	}
 }
@@ -507,7 +634,7 @@ func (b *blockManager) current() bool {
 }
 
 // handleBlockMsg handles block messages from all peers.
-func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
+func (b *blockManager) handleBlockMsg(peers *list.List, bmsg *blockMsg) {
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockSha, _ := bmsg.block.Sha()
 	if _, ok := bmsg.peer.requestedBlocks[*blockSha]; !ok {
@@ -524,40 +651,45 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
-	if b.headersFirstMode {
-		firstNodeEl := b.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockSha.IsEqual(firstNode.sha) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.sha.IsEqual(b.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					b.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
-	}
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(bmsg.peer.requestedBlocks, *blockSha)
 	delete(b.requestedBlocks, *blockSha)
 
+	// When in headers-first mode, blocks for the downloaded headers are
+	// processed in the order of the headers once all blocks before them
+	// have arrived.  The same block might arrive more than once when it
+	// was requested from another peer after the first one stalled, so
+	// silently drop blocks which are known already.
+	if b.headersFirstMode {
+		if _, ok := b.headerIndex[*blockSha]; ok {
+			delete(b.blockRequests, *blockSha)
+			if _, ok := b.pendingBlocks[*blockSha]; !ok {
+				b.pendingBlocks[*blockSha] = bmsg
+			}
+			b.processHeaderBlocks(peers)
+			return
+		}
+		if haveBlock, _ := b.blockChain.HaveBlock(blockSha); haveBlock {
+			bmgrLog.Debugf("Ignoring duplicate block %v from %s",
+				blockSha, bmsg.peer)
+			return
+		}
+	}
+
+	b.processBlock(bmsg, blockchain.BFNone)
+}
+
+// processBlock processes a block received from a peer with the passed behavior
+// flags and updates the chain state accordingly.  It returns whether the block
+// was accepted.
+func (b *blockManager) processBlock(bmsg *blockMsg, flags blockchain.BehaviorFlags) bool {
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
+	blockSha, _ := bmsg.block.Sha()
 	isOrphan, err := b.blockChain.ProcessBlock(bmsg.block,
-		b.server.timeSource, behaviorFlags)
+		b.server.timeSource, flags)
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -576,7 +708,23 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		code, reason := errToRejectErr(err)
 		bmsg.peer.PushRejectMsg(wire.CmdBlock, code, reason,
 			blockSha, false)
-		return
+
+		// Ban peers which relay invalid blocks, including blocks with
+		// an invalid auxpow.  Duplicates, blocks from the future which
+		// might be due to clock differences and blocks building on top
+		// of blocks invalidated locally are no misbehavior.
+		if rerr, ok := err.(blockchain.RuleError); ok {
+			switch rerr.ErrorCode {
+			case blockchain.ErrDuplicateBlock,
+				blockchain.ErrTimeTooNew,
+				blockchain.ErrInvalidAncestorBlock:
+
+			default:
+				bmsg.peer.addBanScore(banScoreInvalidBlock, 0,
+					reason)
+			}
+		}
+		return false
 	}
 
 	// Request the parents for the orphan block from the peer that sent it.
@@ -612,80 +760,259 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 
 	// Sync the db to disk.
 	b.server.db.Sync()
+	return true
+}
 
-	// Nothing more to do if we aren't in headers-first mode.
-	if !b.headersFirstMode {
+// processHeaderBlocks processes the downloaded blocks for the headers in
+// headers-first mode in the order of the headers, stopping at the first block
+// which has not arrived yet.  Blocks up to the last verified checkpoint are
+// eligible for less validation since their headers have already been verified
+// to link together and match the checkpoint.  Once all headers are downloaded
+// and their blocks processed, it switches to normal mode.  Otherwise, it
+// requests more blocks.
+func (b *blockManager) processHeaderBlocks(peers *list.List) {
+	for {
+		e := b.headerList.Front().Next()
+		if e == nil {
+			break
+		}
+		node := e.Value.(*headerNode)
+
+		bmsg, ok := b.pendingBlocks[*node.sha]
+		if ok {
+			delete(b.pendingBlocks, *node.sha)
+			behaviorFlags := blockchain.BFNone
+			if node.height <= b.lastCheckpointHeight {
+				behaviorFlags |= blockchain.BFFastAdd
+			}
+			if !b.processBlock(bmsg, behaviorFlags) {
+				// The headers after an invalid block can't be
+				// used, so start over from the current best
+				// chain.
+				b.restartSync(peers)
+				return
+			}
+		} else {
+			// Blocks which are known already, for example from
+			// a side chain, are not downloaded again.
+			haveBlock, _ := b.blockChain.HaveBlock(node.sha)
+			if !haveBlock {
+				break
+			}
+		}
+
+		// The block becomes the latest processed block at the front of
+		// the list.
+		b.headerList.Remove(b.headerList.Front())
+		delete(b.headerIndex, *node.sha)
+	}
+
+	// Keep downloading headers and blocks until all headers are downloaded
+	// and their blocks processed.  The blocks are only downloaded once the
+	// headers have more work than our main chain, so give up on headers
+	// which don't when the peer has no more headers or no more headers
+	// can be kept.
+	haveWork := b.headersWork.Cmp(b.requiredWork) > 0
+	if (haveWork && b.headerList.Len() > 1) ||
+		(!b.headersSynced && !b.headersLimitReached()) {
+
+		b.fetchHeaders()
+		b.fetchHeaderBlocks(peers)
 		return
 	}
 
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if b.startHeader != nil &&
-			len(bmsg.peer.requestedBlocks) < minInFlightBlocks {
-			b.fetchHeaderBlocks()
-		}
+	// All blocks of the downloaded headers are processed, so switch to
+	// normal mode by requesting blocks from the tip of the chain up to the
+	// end of the chain (zero hash) to catch up with blocks announced in
+	// the meantime.
+	newestHash, newestHeight, err := b.server.db.NewestSha()
+	if err != nil {
+		bmgrLog.Warnf("Unable to obtain latest block information "+
+			"from the database: %v", err)
+		return
+	}
+	if haveWork {
+		bmgrLog.Infof("Processed the blocks of all downloaded headers "+
+			"-- switching to normal mode at height %d",
+			newestHeight)
+	} else {
+		bmgrLog.Infof("Downloaded block headers don't have more work "+
+			"than the main chain -- switching to normal mode at "+
+			"height %d", newestHeight)
+	}
+	b.resetHeaderState(newestHash, newestHeight)
+	if b.syncPeer == nil {
 		return
 	}
+	locator := blockchain.BlockLocator([]*wire.ShaHash{newestHash})
+	err = b.syncPeer.PushGetBlocksMsg(locator, &zeroHash)
+	if err != nil {
+		bmgrLog.Warnf("Failed to send getblocks message to peer %s: %v",
+			b.syncPeer.addr, err)
+	}
+}
 
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := b.nextCheckpoint.Height
-	prevHash := b.nextCheckpoint.Hash
-	b.nextCheckpoint = b.findNextHeaderCheckpoint(prevHeight)
+// chainTips returns the tips of all known branches of the block chain sorted by
+// descending height.  In headers-first mode, the last downloaded header whose
+// block has not been processed yet is included as the tip of a branch which
+// only has its headers available.  The branch starts after the latest
+// processed header when its block is on the main chain, and after the block
+// the headers were anchored at otherwise.
+func (b *blockManager) chainTips() []blockchain.ChainTip {
+	tips := b.blockChain.ChainTips()
+	if !b.headersFirstMode || b.headerList.Len() < 2 {
+		return tips
+	}
+	front := b.headerList.Front().Value.(*headerNode)
+	back := b.headerList.Back().Value.(*headerNode)
+	if haveBlock, _ := b.blockChain.HaveBlock(back.sha); haveBlock {
+		return tips
+	}
+
+	forkHeight := b.anchorHeight
+	height, err := b.server.db.FetchBlockHeightBySha(front.sha)
+	if err == nil && height == front.height {
+		forkHeight = height
+	}
+	tip := blockchain.ChainTip{
+		Height:    back.height,
+		Hash:      *back.sha,
+		BranchLen: back.height - forkHeight,
+		Status:    blockchain.StatusHeadersOnly,
+	}
+
+	// Keep the tips sorted by descending height.
+	i := 0
+	for i < len(tips) && tips[i].Height >= tip.Height {
+		i++
+	}
+	tips = append(tips, blockchain.ChainTip{})
+	copy(tips[i+1:], tips[i:])
+	tips[i] = tip
+	return tips
+}
+
+// headersLimitReached returns whether the maximum number of downloaded headers
+// after the final checkpoint whose blocks are not processed yet is reached.
+func (b *blockManager) headersLimitReached() bool {
 	if b.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*wire.ShaHash{prevHash})
-		err := bmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
-		if err != nil {
-			bmgrLog.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", bmsg.peer.addr, err)
-			return
-		}
-		bmgrLog.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, b.nextCheckpoint.Height,
-			b.syncPeer.addr)
+		return false
+	}
+	start := b.headerList.Front().Value.(*headerNode).height
+	if b.lastCheckpointHeight > start {
+		start = b.lastCheckpointHeight
+	}
+	tip := b.headerList.Back().Value.(*headerNode)
+	return tip.height-start >= maxHeadersBeyondCheckpoint
+}
+
+// fetchHeaders requests the next batch of headers from the sync peer in
+// headers-first mode, starting from the latest downloaded header up to the end
+// of the chain (zero hash).  Nothing is requested when the peer has no more
+// headers, a request is outstanding or no more headers can be kept.
+func (b *blockManager) fetchHeaders() {
+	if b.syncPeer == nil || b.headersSynced ||
+		!b.headersRequested.IsZero() || b.headersLimitReached() {
 		return
 	}
 
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	b.headersFirstMode = false
-	b.headerList.Init()
-	bmgrLog.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*wire.ShaHash{blockSha})
-	err = bmsg.peer.PushGetBlocksMsg(locator, &zeroHash)
+	tip := b.headerList.Back().Value.(*headerNode)
+	locator := blockchain.BlockLocator([]*wire.ShaHash{tip.sha})
+	b.headersRequested = time.Now()
+	err := b.syncPeer.PushGetHeadersMsg(locator, &zeroHash)
 	if err != nil {
-		bmgrLog.Warnf("Failed to send getblocks message to peer %s: %v",
-			bmsg.peer.addr, err)
+		bmgrLog.Warnf("Failed to send getheaders message to peer %s: %v",
+			b.syncPeer.addr, err)
+	}
+}
+
+// headerDifficulty returns the difficulty bits and timestamp of the block at
+// the passed height of the chain the downloaded headers build on.  It is used
+// to calculate the required difficulty of the downloaded headers.
+func (b *blockManager) headerDifficulty(height int64) (uint32, time.Time, error) {
+	if height > b.anchorHeight {
+		node, ok := b.recentHeaders[height]
+		if !ok {
+			return 0, time.Time{}, fmt.Errorf("no downloaded block "+
+				"header at height %d", height)
+		}
+		return node.bits, node.timestamp, nil
+	}
+
+	hash, err := b.server.db.FetchBlockShaByHeight(height)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	header, err := b.server.db.FetchBlockHeaderBySha(hash)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return header.Bits, header.Timestamp, nil
+}
+
+// restartSync abandons the current sync and starts syncing again from the
+// current best chain.
+func (b *blockManager) restartSync(peers *list.List) {
+	newestHash, height, err := b.server.db.NewestSha()
+	if err != nil {
+		bmgrLog.Warnf("Unable to obtain latest block information "+
+			"from the database: %v", err)
 		return
 	}
+	b.resetHeaderState(newestHash, height)
+	b.syncPeer = nil
+	b.startSync(peers)
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (b *blockManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if b.startHeader == nil {
-		bmgrLog.Warnf("fetchHeaderBlocks called with no start header")
+// fetchHeaderBlocks requests the blocks for the downloaded headers in
+// headers-first mode which are not requested yet.  The requests are spread
+// over all sync candidate peers which know about the blocks, limited by the
+// download window and the number of blocks in flight per peer.  Blocks after
+// the last verified checkpoint are only requested once all checkpoints are
+// verified.
+func (b *blockManager) fetchHeaderBlocks(peers *list.List) {
+	// Only download the blocks once the headers have more work than our
+	// main chain after the block they build on, so peers can't make us
+	// download the blocks of a weaker chain.
+	if b.headersWork.Cmp(b.requiredWork) <= 0 {
 		return
 	}
 
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(b.headerList.Len()))
-	numRequested := 0
-	for e := b.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			bmgrLog.Warn("Header list node type is not a headerNode")
-			continue
+	// Find the peers which can download more blocks.
+	inFlight := make(map[*peer]int)
+	for _, req := range b.blockRequests {
+		inFlight[req.peer]++
+	}
+	var fetchPeers []*peer
+	for e := peers.Front(); e != nil; e = e.Next() {
+		p := e.Value.(*peer)
+		if p.Connected() && inFlight[p] < maxBlocksInFlightPerPeer {
+			fetchPeers = append(fetchPeers, p)
 		}
+	}
 
+	// Build up getdata requests for the blocks within the download window
+	// which are neither requested nor known yet, assigning them to the
+	// peers in turn.  The size of each request is limited by the number of
+	// blocks in flight per peer, so no need to check it against
+	// wire.MaxInvPerMsg here.
+	now := time.Now()
+	requests := make(map[*peer]*wire.MsgGetData)
+	anchor := b.headerList.Front().Value.(*headerNode)
+	next := 0
+	for e := b.headerList.Front().Next(); e != nil && len(fetchPeers) > 0; e = e.Next() {
+		node := e.Value.(*headerNode)
+		if node.height > anchor.height+blockDownloadWindow ||
+			(node.height > b.lastCheckpointHeight &&
+				b.nextCheckpoint != nil) {
+			break
+		}
+		if _, ok := b.blockRequests[*node.sha]; ok {
+			continue
+		}
+		if _, ok := b.pendingBlocks[*node.sha]; ok {
+			continue
+		}
 		iv := wire.NewInvVect(wire.InvTypeBlock, node.sha)
 		haveInv, err := b.haveInventory(iv)
 		if err != nil {
@@ -693,43 +1020,148 @@ func (b *blockManager) fetchHeaderBlocks() {
 				"existing inventory during header block "+
 				"fetch: %v", err)
 		}
-		if !haveInv {
-			b.requestedBlocks[*node.sha] = struct{}{}
-			b.syncPeer.requestedBlocks[*node.sha] = struct{}{}
-			gdmsg.AddInvVect(iv)
-			numRequested++
+		if haveInv {
+			continue
 		}
-		b.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
+
+		// Find the next peer which knows about the block.  None of the
+		// peers know about the later blocks either when there is none.
+		i := 0
+		for ; i < len(fetchPeers); i++ {
+			p := fetchPeers[(next+i)%len(fetchPeers)]
+			if p == b.syncPeer || int64(p.lastBlock) >= node.height {
+				break
+			}
+		}
+		if i == len(fetchPeers) {
 			break
 		}
+		i = (next + i) % len(fetchPeers)
+		p := fetchPeers[i]
+
+		b.blockRequests[*node.sha] = &blockRequest{peer: p, requested: now}
+		b.requestedBlocks[*node.sha] = struct{}{}
+		p.requestedBlocks[*node.sha] = struct{}{}
+		gdmsg, ok := requests[p]
+		if !ok {
+			gdmsg = wire.NewMsgGetData()
+			requests[p] = gdmsg
+		}
+		gdmsg.AddInvVect(iv)
+
+		// Continue with the next peer, dropping this one once it has
+		// the maximum number of blocks in flight.
+		inFlight[p]++
+		if inFlight[p] >= maxBlocksInFlightPerPeer {
+			fetchPeers = append(fetchPeers[:i], fetchPeers[i+1:]...)
+			next = i
+		} else {
+			next = i + 1
+		}
+		if len(fetchPeers) > 0 {
+			next %= len(fetchPeers)
+		}
 	}
-	if len(gdmsg.InvList) > 0 {
-		b.syncPeer.QueueMessage(gdmsg, nil)
+	for p, gdmsg := range requests {
+		p.QueueMessage(gdmsg, nil)
 	}
 }
 
-// handleHeadersMsghandles headers messages from all peers.
-func (b *blockManager) handleHeadersMsg(hmsg *headersMsg) {
-	// The remote peer is misbehaving if we didn't request headers.
-	msg := hmsg.headers
-	numHeaders := len(msg.Headers)
+// handleStallCheck detects peers stalling the download in headers-first mode.
+// Peers which take too long to deliver requested blocks are disconnected and
+// the blocks are requested from the remaining peers.  The sync peer is
+// disconnected when it takes too long to respond to a request for headers,
+// which causes the sync to start over with another peer.
+func (b *blockManager) handleStallCheck(peers *list.List) {
 	if !b.headersFirstMode {
-		bmgrLog.Warnf("Got %d unrequested headers from %s -- "+
-			"disconnecting", numHeaders, hmsg.peer.addr)
-		hmsg.peer.Disconnect()
 		return
 	}
 
-	// Nothing to do for an empty headers message.
-	if numHeaders == 0 {
+	now := time.Now()
+	if b.syncPeer != nil && !b.headersRequested.IsZero() &&
+		now.Sub(b.headersRequested) > headersStallTimeout {
+
+		bmgrLog.Warnf("Sync peer %s stalled sending block headers -- "+
+			"disconnecting", b.syncPeer)
+		b.syncPeer.Disconnect()
+	}
+
+	stalled := make(map[*peer]struct{})
+	for _, req := range b.blockRequests {
+		if now.Sub(req.requested) > blockStallTimeout {
+			stalled[req.peer] = struct{}{}
+		}
+	}
+	if len(stalled) == 0 {
 		return
 	}
 
-	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
-	receivedCheckpoint := false
-	var finalHash *wire.ShaHash
+	// Forget all requests of the stalling peers so the blocks can be
+	// requested from other peers.  The blocks are left in the request map
+	// of the peers, so they are still accepted should they arrive late.
+	for hash, req := range b.blockRequests {
+		if _, ok := stalled[req.peer]; ok {
+			delete(b.blockRequests, hash)
+			delete(b.requestedBlocks, hash)
+		}
+	}
+	for p := range stalled {
+		bmgrLog.Warnf("Peer %s stalled downloading blocks -- "+
+			"disconnecting", p)
+		p.Disconnect()
+	}
+	b.fetchHeaderBlocks(peers)
+}
+
+// handleHeadersMsg handles headers messages from all peers.
+func (b *blockManager) handleHeadersMsg(peers *list.List, hmsg *headersMsg) {
+	// Headers which were not requested announce new blocks.  The sync peer
+	// might also announce a block before responding to the request, which
+	// is detected by the first header not connecting to the latest
+	// downloaded header.
+	msg := hmsg.headers
+	numHeaders := len(msg.Headers)
+	if !b.headersFirstMode || hmsg.peer != b.syncPeer ||
+		b.headersRequested.IsZero() {
+
+		b.handleHeadersAnnouncement(hmsg)
+		return
+	}
+	lastNode := b.headerList.Back().Value.(*headerNode)
+	if numHeaders > 0 && !lastNode.sha.IsEqual(&msg.Headers[0].PrevBlock) {
+		if b.headersAnchored {
+			b.handleHeadersAnnouncement(hmsg)
+			return
+		}
+
+		// The first headers connect to an earlier block than our tip
+		// when our tip is on a fork the peer doesn't know about, since
+		// the peer responds with the headers after the latest block of
+		// the locator on its chain.  Download the headers of the
+		// peer's branch starting from that block when it is part of
+		// the main chain.  Otherwise, fall back to downloading blocks.
+		prevHash := msg.Headers[0].PrevBlock
+		if !b.anchorHeaders(&prevHash) {
+			bmgrLog.Infof("Block headers from peer %s don't "+
+				"connect to the main chain -- falling back to "+
+				"downloading blocks", hmsg.peer.addr)
+			b.resetHeaderState(lastNode.sha, lastNode.height)
+			locator, err := b.blockChain.LatestBlockLocator()
+			if err != nil {
+				bmgrLog.Warnf("Failed to get block locator for "+
+					"the latest block: %v", err)
+				return
+			}
+			hmsg.peer.PushGetBlocksMsg(locator, &zeroHash)
+			return
+		}
+	}
+	b.headersAnchored = true
+	b.headersRequested = time.Time{}
+
+	// Process all of the received headers ensuring each one is valid,
+	// connects to the previous, has the required difficulty and that
+	// checkpoints match.
 	for _, blockHeader := range msg.Headers {
 		blockHash, err := blockHeader.BlockSha()
 		if err != nil {
@@ -739,43 +1171,48 @@ func (b *blockManager) handleHeadersMsg(hmsg *headersMsg) {
 			hmsg.peer.Disconnect()
 			return
 		}
-		finalHash = &blockHash
 
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := b.headerList.Back()
-		if prevNodeEl == nil {
-			bmgrLog.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
+		// Ensure the header properly connects to the previous one.
+		prevNode := b.headerList.Back().Value.(*headerNode)
+		if !prevNode.sha.IsEqual(&blockHeader.PrevBlock) {
+			bmgrLog.Warnf("Received block header that does not "+
+				"properly connect to the chain from peer %s "+
+				"-- disconnecting", hmsg.peer.addr)
 			hmsg.peer.Disconnect()
 			return
 		}
+		node := headerNode{
+			height:    prevNode.height + 1,
+			sha:       &blockHash,
+			bits:      blockHeader.Bits,
+			timestamp: blockHeader.Timestamp,
+		}
 
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{sha: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.sha.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := b.headerList.PushBack(&node)
-			if b.startHeader == nil {
-				b.startHeader = e
+		// Ensure the header is valid on its own, including the proof
+		// of work, which is proven by the AuxPow header for merged
+		// mined blocks.  Headers from the future might be due to clock
+		// differences, so they are no misbehavior.
+		err = blockchain.CheckBlockHeaderSanity(blockHeader,
+			node.height, b.server.chainParams, b.server.timeSource)
+		if err != nil {
+			bmgrLog.Warnf("Received invalid block header %v from "+
+				"peer %s: %v -- disconnecting", node.sha,
+				hmsg.peer.addr, err)
+			rerr, ok := err.(blockchain.RuleError)
+			if ok && rerr.ErrorCode != blockchain.ErrTimeTooNew {
+				hmsg.peer.addBanScore(banScoreInvalidBlock, 0,
+					fmt.Sprintf("sent invalid block header "+
+						"%v", node.sha))
 			}
-		} else {
-			bmgrLog.Warnf("Received block header that does not "+
-				"properly connect to the chain from peer %s "+
-				"-- disconnecting", hmsg.peer.addr)
 			hmsg.peer.Disconnect()
 			return
 		}
 
 		// Verify the header at the next checkpoint height matches.
-		if node.height == b.nextCheckpoint.Height {
-			if node.sha.IsEqual(b.nextCheckpoint.Hash) {
-				receivedCheckpoint = true
-				bmgrLog.Infof("Verified downloaded block "+
-					"header against checkpoint at height "+
-					"%d/hash %s", node.height, node.sha)
-			} else {
+		if b.nextCheckpoint != nil &&
+			node.height == b.nextCheckpoint.Height {
+
+			if !node.sha.IsEqual(b.nextCheckpoint.Hash) {
 				bmgrLog.Warnf("Block header at height %d/hash "+
 					"%s from peer %s does NOT match "+
 					"expected checkpoint hash of %s -- "+
@@ -785,35 +1222,194 @@ func (b *blockManager) handleHeadersMsg(hmsg *headersMsg) {
 				hmsg.peer.Disconnect()
 				return
 			}
-			break
+			bmgrLog.Infof("Verified downloaded block header "+
+				"against checkpoint at height %d/hash %s",
+				node.height, node.sha)
+			b.lastCheckpointHeight = node.height
+			b.nextCheckpoint = b.findNextHeaderCheckpoint(node.height)
 		}
+
+		// Ensure the difficulty of the header matches the retarget
+		// rules over the downloaded headers.  The headers up to the
+		// next checkpoint are proven by the checkpoint instead, just
+		// like their blocks are added with less validation.
+		if b.nextCheckpoint == nil {
+			requiredBits, err := blockchain.CalcNextRequiredHeaderDifficulty(
+				b.server.chainParams, prevNode.height,
+				blockHeader.Timestamp, b.headerDifficulty)
+			if err != nil {
+				bmgrLog.Warnf("Unable to calculate the difficulty "+
+					"of block header %v: %v", node.sha, err)
+				hmsg.peer.Disconnect()
+				return
+			}
+			if blockHeader.Bits != requiredBits {
+				bmgrLog.Warnf("Received block header %v with "+
+					"difficulty %08x instead of %08x from "+
+					"peer %s -- disconnecting", node.sha,
+					blockHeader.Bits, requiredBits,
+					hmsg.peer.addr)
+				hmsg.peer.addBanScore(banScoreInvalidBlock, 0,
+					fmt.Sprintf("sent block header %v with "+
+						"unexpected difficulty", node.sha))
+				hmsg.peer.Disconnect()
+				return
+			}
+		}
+
+		b.headerList.PushBack(&node)
+		b.headerIndex[blockHash] = struct{}{}
+		b.recentHeaders[node.height] = &node
+		delete(b.recentHeaders, node.height-blockchain.BlocksPerRetarget)
+		b.headersWork.Add(b.headersWork, blockchain.CalcWork(node.bits))
+	}
+
+	// A batch with less than the maximum number of headers means the peer
+	// has no more headers.  Otherwise, the next batch of headers is
+	// requested along with the blocks for the new headers.
+	if numHeaders < wire.MaxBlockHeadersPerMsg {
+		b.headersSynced = true
+		tip := b.headerList.Back().Value.(*headerNode)
+		bmgrLog.Infof("Downloaded block headers up to height %d from "+
+			"peer %s", tip.height, hmsg.peer.addr)
+	}
+	b.processHeaderBlocks(peers)
+}
+
+// anchorHeaders starts the header list at the passed block instead of our tip
+// when the first downloaded header connects to it, so blocks of a branch which
+// forks off the main chain before our tip can be downloaded.  The blocks of
+// that branch are only eligible for less validation once a later checkpoint
+// is verified.  It returns false when the block is not part of the main chain.
+func (b *blockManager) anchorHeaders(hash *wire.ShaHash) bool {
+	height, err := b.server.db.FetchBlockHeightBySha(hash)
+	if err != nil {
+		return false
 	}
 
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
-	if receivedCheckpoint {
-		// Since the first entry of the list is always the final block
-		// that is already in the database and is only used to ensure
-		// the next header links properly, it must be removed before
-		// fetching the blocks.
-		b.headerList.Remove(b.headerList.Front())
-		bmgrLog.Infof("Received %v block headers: Fetching blocks",
-			b.headerList.Len())
-		b.progressLogger.SetLastLogTime(time.Now())
-		b.fetchHeaderBlocks()
+	// The headers need more work than the blocks of our main chain after
+	// the block they build on.
+	requiredWork := big.NewInt(0)
+	for h := height + 1; h <= b.anchorHeight; h++ {
+		bits, _, err := b.headerDifficulty(h)
+		if err != nil {
+			bmgrLog.Warnf("Unable to obtain block header at height "+
+				"%d: %v", h, err)
+			return false
+		}
+		requiredWork.Add(requiredWork, blockchain.CalcWork(bits))
+	}
+
+	bmgrLog.Infof("Block headers from the sync peer fork off the main "+
+		"chain at height %d/hash %s", height, hash)
+	b.headerList.Init()
+	b.headerList.PushBack(&headerNode{height: height, sha: hash})
+	b.anchorHeight = height
+	b.requiredWork = requiredWork
+	b.lastCheckpointHeight = height
+	b.nextCheckpoint = b.findNextHeaderCheckpoint(height)
+	return true
+}
+
+// handleHeadersAnnouncement handles headers which announce new blocks instead
+// of inventory vectors (BIP0130).  The headers are validated, including their
+// AuxPow, and the announced blocks are requested from the peer.  When the
+// headers don't connect to the main chain, the missing blocks are requested
+// with a getblocks message instead.
+func (b *blockManager) handleHeadersAnnouncement(hmsg *headersMsg) {
+	p := hmsg.peer
+	headers := hmsg.headers.Headers
+	if len(headers) == 0 {
 		return
 	}
 
-	// This header is not a checkpoint, so request the next batch of
-	// headers starting from the latest known header and ending with the
-	// next checkpoint.
-	locator := blockchain.BlockLocator([]*wire.ShaHash{finalHash})
-	err := hmsg.peer.PushGetHeadersMsg(locator, b.nextCheckpoint.Hash)
+	// Add the announced blocks to the cache of known inventory for the
+	// peer, ensuring the headers connect to each other.
+	hashes := make([]wire.ShaHash, len(headers))
+	for i, blockHeader := range headers {
+		hash, err := blockHeader.BlockSha()
+		if err != nil {
+			bmgrLog.Warnf("Failed to compute hash of header "+
+				"received from peer %s -- disconnecting",
+				p.addr)
+			p.Disconnect()
+			return
+		}
+		if i > 0 && !blockHeader.PrevBlock.IsEqual(&hashes[i-1]) {
+			p.addBanScore(banScoreNonContinuousHeaders, 0,
+				"sent headers which don't connect to each other")
+			return
+		}
+		hashes[i] = hash
+		p.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, &hash))
+	}
+
+	// Ignore announcements from peers that aren't the sync peer if we are
+	// not current and while downloading the chain in headers-first mode,
+	// just like inventory announcements.
+	if b.headersFirstMode || (p != b.syncPeer && !b.current()) {
+		return
+	}
+
+	// Request the blocks from the latest known block up to the final
+	// announced block when the headers don't connect to the main chain.
+	lastHash := &hashes[len(hashes)-1]
+	prevHeight, err := b.server.db.FetchBlockHeightBySha(&headers[0].PrevBlock)
 	if err != nil {
-		bmgrLog.Warnf("Failed to send getheaders message to "+
-			"peer %s: %v", hmsg.peer.addr, err)
+		locator, err := b.blockChain.LatestBlockLocator()
+		if err != nil {
+			bmgrLog.Warnf("Failed to get block locator for the "+
+				"latest block: %v", err)
+			return
+		}
+		p.PushGetBlocksMsg(locator, lastHash)
 		return
 	}
+
+	// Ensure the headers are valid on their own, including the proof of
+	// work, which is proven by the AuxPow header for merged mined blocks.
+	for i, blockHeader := range headers {
+		err := blockchain.CheckBlockHeaderSanity(blockHeader,
+			prevHeight+int64(i)+1, b.server.chainParams,
+			b.server.timeSource)
+		if err != nil {
+			bmgrLog.Infof("Rejected block header %v from %s: %v",
+				&hashes[i], p, err)
+			rerr, ok := err.(blockchain.RuleError)
+			if ok && rerr.ErrorCode != blockchain.ErrTimeTooNew {
+				p.addBanScore(banScoreInvalidBlock, 0,
+					fmt.Sprintf("sent invalid block header "+
+						"%v", &hashes[i]))
+			}
+			return
+		}
+	}
+
+	// Request the announced blocks which are neither known nor requested
+	// yet.
+	gdmsg := wire.NewMsgGetData()
+	for i := range hashes {
+		iv := wire.NewInvVect(wire.InvTypeBlock, &hashes[i])
+		haveInv, err := b.haveInventory(iv)
+		if err != nil {
+			bmgrLog.Warnf("Unexpected failure when checking for "+
+				"existing inventory during headers "+
+				"announcement processing: %v", err)
+			continue
+		}
+		if haveInv {
+			continue
+		}
+		if _, exists := b.requestedBlocks[hashes[i]]; exists {
+			continue
+		}
+		b.requestedBlocks[hashes[i]] = struct{}{}
+		p.requestedBlocks[hashes[i]] = struct{}{}
+		gdmsg.AddInvVect(iv)
+	}
+	if len(gdmsg.InvList) > 0 {
+		p.QueueMessage(gdmsg, nil)
+	}
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -990,6 +1586,8 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 // the fetching should proceed.
 func (b *blockManager) blockHandler() {
 	candidatePeers := list.New()
+	stallTicker := time.NewTicker(stallCheckInterval)
+	defer stallTicker.Stop()
 out:
 	for {
 		select {
@@ -1003,14 +1601,14 @@ out:
 				msg.peer.txProcessed <- struct{}{}
 
 			case *blockMsg:
-				b.handleBlockMsg(msg)
+				b.handleBlockMsg(candidatePeers, msg)
 				msg.peer.blockProcessed <- struct{}{}
 
 			case *invMsg:
 				b.handleInvMsg(msg)
 
 			case *headersMsg:
-				b.handleHeadersMsg(msg)
+				b.handleHeadersMsg(candidatePeers, msg)
 
 			case *donePeerMsg:
 				b.handleDonePeerMsg(candidatePeers, msg.peer)
@@ -1056,11 +1654,49 @@ out:
 			case isCurrentMsg:
 				msg.reply <- b.current()
 
+			case chainTipsMsg:
+				msg.reply <- b.chainTips()
+
+			case invalidateBlockMsg:
+				err := b.blockChain.InvalidateBlock(msg.hash)
+
+				// Query the db for the latest best block since
+				// the chain might have been reorganized even
+				// when an error occurred.
+				newestSha, newestHeight, _ := b.server.db.NewestSha()
+				b.updateChainState(newestSha, newestHeight)
+				msg.reply <- err
+
+			case reconsiderBlockMsg:
+				err := b.blockChain.ReconsiderBlock(msg.hash)
+				newestSha, newestHeight, _ := b.server.db.NewestSha()
+				b.updateChainState(newestSha, newestHeight)
+				msg.reply <- err
+
+			case chainInfoMsg:
+				response := chainInfoResponse{
+					chainWork: b.blockChain.BestChainWork(),
+				}
+				for _, version := range msg.versions {
+					upgrade := b.blockChain.VersionUpgradeStatus(
+						version)
+					response.upgrades = append(
+						response.upgrades, upgrade)
+				}
+				if b.syncPeer != nil {
+					response.syncHeight = int64(
+						b.syncPeer.lastBlock)
+				}
+				msg.reply <- response
+
 			default:
 				bmgrLog.Warnf("Invalid message type in block "+
 					"handler: %T", msg)
 			}
 
+		case <-stallTicker.C:
+			b.handleStallCheck(candidatePeers)
+
 		case <-b.quit:
 			break out
 		}
@@ -1094,9 +1730,10 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 		// coming from the chain code which has already cached the hash.
 		hash, _ := block.Sha()
 
-		// Generate the inventory vector and relay it.
+		// Generate the inventory vector and relay it along with the
+		// block header for peers which prefer headers announcements.
 		iv := wire.NewInvVect(wire.InvTypeBlock, hash)
-		b.server.RelayInventory(iv, nil)
+		b.server.RelayInventory(iv, &block.MsgBlock().Header)
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -1106,16 +1743,22 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			break
 		}
 
+		// Record how long the transactions in the block took to be
+		// mined for fee estimation before they are removed from the
+		// transaction pool.
+		b.server.feeEstimator.RegisterBlock(block)
+
 		// Remove all of the transactions (except the coinbase) in the
 		// connected block from the transaction pool.  Secondly, remove any
-		// transactions which are now double spends as a result of these
-		// new transactions.  Finally, remove any transaction that is
-		// no longer an orphan.  Note that removing a transaction from
-		// pool also removes any transactions which depend on it,
-		// recursively.
+		// transactions which are now double spends or operate on the same
+		// names as a result of these new transactions.  Finally, remove
+		// any transaction that is no longer an orphan.  Note that removing
+		// a transaction from pool also removes any transactions which
+		// depend on it, recursively.
 		for _, tx := range block.Transactions()[1:] {
 			b.server.txMemPool.RemoveTransaction(tx)
 			b.server.txMemPool.RemoveDoubleSpends(tx)
+			b.server.txMemPool.RemoveNameConflicts(tx)
 			b.server.txMemPool.RemoveOrphan(tx.Sha())
 		}
 
@@ -1146,6 +1789,10 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			break
 		}
 
+		// Unwind the fee estimates recorded for the block so its
+		// transactions are tracked again once they are reinserted.
+		b.server.feeEstimator.Rollback(block)
+
 		// Reinsert all of the transactions (except the coinbase) into
 		// the transaction pool.
 		for _, tx := range block.Transactions()[1:] {
@@ -1163,6 +1810,34 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 		if r := b.server.rpcServer; r != nil {
 			r.ntfnMgr.NotifyBlockDisconnected(block)
 		}
+
+	// A name expired when a block was connected to the main block chain.
+	case blockchain.NTNameExpired:
+		entry, ok := notification.Data.(*database.NameEntry)
+		if !ok {
+			bmgrLog.Warnf("Name expired notification is not a name entry.")
+			break
+		}
+
+		// Notify registered websocket clients.
+		if r := b.server.rpcServer; r != nil {
+			r.ntfnMgr.NotifyNameExpired(entry)
+		}
+
+	// A name is active again because the block which expired it was
+	// disconnected from the main block chain.
+	case blockchain.NTNameExpiryReverted:
+		entry, ok := notification.Data.(*database.NameEntry)
+		if !ok {
+			bmgrLog.Warnf("Name expiry reverted notification is not a " +
+				"name entry.")
+			break
+		}
+
+		// Notify registered websocket clients.
+		if r := b.server.rpcServer; r != nil {
+			r.ntfnMgr.NotifyNameExpiryReverted(entry)
+		}
 	}
 }
 
@@ -1306,6 +1981,48 @@ func (b *blockManager) IsCurrent() bool {
 	return <-reply
 }
 
+// ChainTips returns the tips of all known branches of the block chain,
+// including the headers downloaded in headers-first mode whose blocks are not
+// available yet.  This function makes use of ChainTips on an internal instance
+// of a block chain.  It is funneled through the block manager since btcchain is
+// not safe for concurrent access.
+func (b *blockManager) ChainTips() []blockchain.ChainTip {
+	reply := make(chan []blockchain.ChainTip)
+	b.msgChan <- chainTipsMsg{reply: reply}
+	return <-reply
+}
+
+// InvalidateBlock marks the block with the passed hash invalid and reorganizes
+// the chain away from it.  This function makes use of InvalidateBlock on an
+// internal instance of a block chain.  It is funneled through the block manager
+// since btcchain is not safe for concurrent access.
+func (b *blockManager) InvalidateBlock(hash *wire.ShaHash) error {
+	reply := make(chan error)
+	b.msgChan <- invalidateBlockMsg{hash: hash, reply: reply}
+	return <-reply
+}
+
+// ReconsiderBlock removes the invalid mark of the block with the passed hash
+// and reorganizes the chain to the best valid branch.  This function makes use
+// of ReconsiderBlock on an internal instance of a block chain.  It is funneled
+// through the block manager since btcchain is not safe for concurrent access.
+func (b *blockManager) ReconsiderBlock(hash *wire.ShaHash) error {
+	reply := make(chan error)
+	b.msgChan <- reconsiderBlockMsg{hash: hash, reply: reply}
+	return <-reply
+}
+
+// ChainInfo returns the work of the main chain, the upgrade status of the
+// passed block versions and the height of the best chain known to the sync
+// peer.  This function makes use of an internal instance of a block chain.  It
+// is funneled through the block manager since btcchain is not safe for
+// concurrent access.
+func (b *blockManager) ChainInfo(versions []int32) chainInfoResponse {
+	reply := make(chan chainInfoResponse)
+	b.msgChan <- chainInfoMsg{versions: versions, reply: reply}
+	return <-reply
+}
+
 // newBlockManager returns a new bitcoin block manager.
 // Use Start to begin processing asynchronous block and inv updates.
 func newBlockManager(s *server) (*blockManager, error) {
@@ -1326,15 +2043,10 @@ func newBlockManager(s *server) (*blockManager, error) {
 	bm.progressLogger = newBlockProgressLogger("Processed", bmgrLog)
 	bm.blockChain = blockchain.New(s.db, s.chainParams, bm.handleNotifyMsg)
 	bm.blockChain.DisableCheckpoints(cfg.DisableCheckpoints)
-	if !cfg.DisableCheckpoints {
-		// Initialize the next checkpoint based on the current height.
-		bm.nextCheckpoint = bm.findNextHeaderCheckpoint(height)
-		if bm.nextCheckpoint != nil {
-			bm.resetHeaderState(newestHash, height)
-		}
-	} else {
+	if cfg.DisableCheckpoints {
 		bmgrLog.Info("Checkpoints are disabled")
 	}
+	bm.resetHeaderState(newestHash, height)
 
 	bmgrLog.Infof("Generating initial block node index.  This may " +
 		"take a while...")
@@ -1344,6 +2056,13 @@ func newBlockManager(s *server) (*blockManager, error) {
 	}
 	bmgrLog.Infof("Block index generation complete")
 
+	// Make sure the name index is built up for the blocks in the database,
+	// which isn't the case for databases created before it existed.
+	err = bm.blockChain.CheckNameIndex()
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize the chain state now that the intial block node index has
 	// been generated.
 	bm.updateChainState(newestHash, height)
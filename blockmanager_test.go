@@ -0,0 +1,363 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/chaincfg"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// solveTestBlock returns a block with the passed difficulty bits building on
+// the passed block which satisfies its proof of work.  The extra nonce makes
+// the hashes of blocks at the same height differ.
+func solveTestBlock(t *testing.T, prev *wire.MsgBlock, bits, extraNonce uint32) *wire.MsgBlock {
+	prevHash, err := prev.BlockSha()
+	if err != nil {
+		t.Fatalf("BlockSha: %v", err)
+	}
+	params := &chaincfg.RegressionNetParams
+	block := wire.NewMsgBlock(&wire.BlockHeader{
+		PrevBlock: prevHash,
+		Timestamp: prev.Header.Timestamp.Add(time.Minute),
+		Bits:      bits,
+		Nonce:     extraNonce << 16,
+	})
+	block.Header.SetBaseVersion(2, params.AuxPowChainID)
+
+	target := blockchain.CompactToBig(block.Header.Bits)
+	for {
+		hash, err := block.Header.BlockSha()
+		if err != nil {
+			t.Fatalf("BlockSha: %v", err)
+		}
+		if blockchain.ShaHashToBig(&hash).Cmp(target) <= 0 {
+			return block
+		}
+		block.Header.Nonce++
+	}
+}
+
+// newTestBlockManager returns a block manager for the regression test network
+// in headers-first mode with the passed peer as sync peer.  The main chain
+// consists of the genesis block followed by the passed blocks.
+func newTestBlockManager(t *testing.T, p *peer, blocks []*wire.MsgBlock) *blockManager {
+	db, err := database.CreateDB("memdb")
+	if err != nil {
+		t.Fatalf("CreateDB: %v", err)
+	}
+	params := &chaincfg.RegressionNetParams
+	blocks = append([]*wire.MsgBlock{params.GenesisBlock}, blocks...)
+	for _, block := range blocks {
+		if _, err := db.InsertBlock(btcutil.NewBlock(block)); err != nil {
+			t.Fatalf("InsertBlock: %v", err)
+		}
+	}
+	newestHash, height, err := db.NewestSha()
+	if err != nil {
+		t.Fatalf("NewestSha: %v", err)
+	}
+
+	s := &server{
+		chainParams: params,
+		db:          db,
+		timeSource:  blockchain.NewMedianTime(),
+	}
+	bm := &blockManager{
+		server:          s,
+		requestedBlocks: make(map[wire.ShaHash]struct{}),
+		headerList:      list.New(),
+	}
+	bm.blockChain = blockchain.New(db, params, nil)
+	bm.resetHeaderState(newestHash, height)
+	bm.headersFirstMode = true
+	bm.headersRequested = time.Now()
+	bm.syncPeer = p
+	return bm
+}
+
+// TestHeadersStaleFork ensures the header list is anchored at the block of the
+// main chain the first downloaded header connects to when our tip is on a
+// stale fork, and that headers which don't connect to the main chain make the
+// sync fall back to downloading blocks.
+func TestHeadersStaleFork(t *testing.T) {
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{}
+
+	// Our main chain is genesis -> a1 -> a2 while the peer either extends
+	// it with a3 or builds on a1 with b2 -> b3.
+	genesis := chaincfg.RegressionNetParams.GenesisBlock
+	bits := chaincfg.RegressionNetParams.PowLimitBits
+	a1 := solveTestBlock(t, genesis, bits, 0)
+	a2 := solveTestBlock(t, a1, bits, 0)
+	a3 := solveTestBlock(t, a2, bits, 0)
+	b2 := solveTestBlock(t, a1, bits, 1)
+	b3 := solveTestBlock(t, b2, bits, 1)
+	orphan := solveTestBlock(t, b3, bits, 1)
+	orphan.Header.PrevBlock = wire.ShaHash{0x01}
+
+	tests := []struct {
+		name         string
+		headers      []*wire.MsgBlock
+		headersFirst bool             // whether headers-first mode continues
+		anchor       *wire.MsgBlock   // expected front of the header list
+		height       int64            // expected height of the anchor
+		want         []*wire.MsgBlock // expected downloaded headers
+		lastCheck    int64            // expected last checkpoint height
+	}{
+		{
+			name:         "connects to tip",
+			headers:      []*wire.MsgBlock{a3},
+			headersFirst: true,
+			anchor:       a2,
+			height:       2,
+			want:         []*wire.MsgBlock{a3},
+			lastCheck:    2,
+		},
+		{
+			name:         "stale fork",
+			headers:      []*wire.MsgBlock{b2, b3},
+			headersFirst: true,
+			anchor:       a1,
+			height:       1,
+			want:         []*wire.MsgBlock{b2, b3},
+			lastCheck:    1,
+		},
+		{
+			name:         "not on main chain",
+			headers:      []*wire.MsgBlock{orphan},
+			headersFirst: false,
+			anchor:       a2,
+			height:       2,
+			lastCheck:    2,
+		},
+	}
+
+	for _, test := range tests {
+		p := &peer{addr: "10.1.2.3:8333"}
+		bm := newTestBlockManager(t, p, []*wire.MsgBlock{a1, a2})
+		peers := list.New()
+		peers.PushBack(p)
+
+		msg := wire.NewMsgHeaders()
+		for _, block := range test.headers {
+			msg.AddBlockHeader(&block.Header)
+		}
+		bm.handleHeadersMsg(peers, &headersMsg{headers: msg, peer: p})
+
+		if bm.headersFirstMode != test.headersFirst {
+			t.Errorf("%s: got headers-first mode %v, want %v",
+				test.name, bm.headersFirstMode,
+				test.headersFirst)
+		}
+		if bm.lastCheckpointHeight != test.lastCheck {
+			t.Errorf("%s: got last checkpoint height %d, want %d",
+				test.name, bm.lastCheckpointHeight,
+				test.lastCheck)
+		}
+
+		want := append([]*wire.MsgBlock{test.anchor}, test.want...)
+		if bm.headerList.Len() != len(want) {
+			t.Errorf("%s: got %d header nodes, want %d", test.name,
+				bm.headerList.Len(), len(want))
+			continue
+		}
+		e := bm.headerList.Front()
+		for i, block := range want {
+			node := e.Value.(*headerNode)
+			hash, _ := block.Header.BlockSha()
+			if !node.sha.IsEqual(&hash) ||
+				node.height != test.height+int64(i) {
+
+				t.Errorf("%s: header node %d: got %v at height "+
+					"%d, want %v at height %d", test.name, i,
+					node.sha, node.height, hash,
+					test.height+int64(i))
+			}
+			e = e.Next()
+		}
+	}
+}
+
+// TestHeadersWork ensures downloaded headers with a difficulty which doesn't
+// follow the retarget rules are rejected and that the sync falls back to
+// downloading blocks when the headers don't have more work than our chain.
+func TestHeadersWork(t *testing.T) {
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{BanThreshold: defaultBanThreshold}
+
+	// Our main chain is genesis -> a1 -> a2.  The peer either extends it
+	// with a header of the wrong difficulty or builds on a1 with b2, which
+	// has the same work as a2.
+	genesis := chaincfg.RegressionNetParams.GenesisBlock
+	bits := chaincfg.RegressionNetParams.PowLimitBits
+	a1 := solveTestBlock(t, genesis, bits, 0)
+	a2 := solveTestBlock(t, a1, bits, 0)
+	hard := solveTestBlock(t, a2, bits-1, 0)
+	b2 := solveTestBlock(t, a1, bits, 1)
+
+	tests := []struct {
+		name         string
+		headers      []*wire.MsgBlock
+		headersFirst bool // whether headers-first mode continues
+		disconnect   bool // whether the peer is disconnected
+	}{
+		{
+			name:         "wrong difficulty",
+			headers:      []*wire.MsgBlock{hard},
+			headersFirst: true,
+			disconnect:   true,
+		},
+		{
+			name:         "not more work",
+			headers:      []*wire.MsgBlock{b2},
+			headersFirst: false,
+		},
+	}
+
+	for _, test := range tests {
+		// Banning would require a server, so use a whitelisted peer.
+		p := &peer{
+			addr:        "10.1.2.3:8333",
+			whitelisted: true,
+			quit:        make(chan struct{}),
+		}
+		bm := newTestBlockManager(t, p, []*wire.MsgBlock{a1, a2})
+		peers := list.New()
+		peers.PushBack(p)
+
+		msg := wire.NewMsgHeaders()
+		for _, block := range test.headers {
+			msg.AddBlockHeader(&block.Header)
+		}
+		bm.handleHeadersMsg(peers, &headersMsg{headers: msg, peer: p})
+
+		if bm.headersFirstMode != test.headersFirst {
+			t.Errorf("%s: got headers-first mode %v, want %v",
+				test.name, bm.headersFirstMode,
+				test.headersFirst)
+		}
+		disconnected := atomic.LoadInt32(&p.disconnect) != 0
+		if disconnected != test.disconnect {
+			t.Errorf("%s: got disconnected %v, want %v", test.name,
+				disconnected, test.disconnect)
+		}
+		if test.disconnect && bm.headerList.Len() != 1 {
+			t.Errorf("%s: got %d header nodes, want 1", test.name,
+				bm.headerList.Len())
+		}
+	}
+}
+
+// TestHeadersLimit ensures no more headers are requested once the maximum
+// number of headers after the final checkpoint is downloaded, and that more
+// headers are requested once their blocks are processed.
+func TestHeadersLimit(t *testing.T) {
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{}
+
+	p := &peer{addr: "10.1.2.3:8333"}
+	bm := newTestBlockManager(t, p, nil)
+	bm.headersRequested = time.Time{}
+	for i := int64(1); i <= maxHeadersBeyondCheckpoint; i++ {
+		bm.headerList.PushBack(&headerNode{height: i, sha: &zeroHash})
+	}
+
+	bm.fetchHeaders()
+	if !bm.headersRequested.IsZero() {
+		t.Errorf("fetchHeaders: requested headers beyond the limit")
+	}
+
+	// Processing the block of the first header makes room for more.
+	bm.headerList.Remove(bm.headerList.Front())
+	bm.fetchHeaders()
+	if bm.headersRequested.IsZero() {
+		t.Errorf("fetchHeaders: didn't request headers below the limit")
+	}
+}
+
+// TestHeadersChainTips ensures the last downloaded header whose block is not
+// available yet is reported as the tip of a branch with only headers in
+// headers-first mode.
+func TestHeadersChainTips(t *testing.T) {
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{}
+
+	// Our main chain is genesis -> a1 -> a2 while the peer either extends
+	// it with a3 -> a4 or builds on a1 with b2 -> b3.
+	genesis := chaincfg.RegressionNetParams.GenesisBlock
+	bits := chaincfg.RegressionNetParams.PowLimitBits
+	a1 := solveTestBlock(t, genesis, bits, 0)
+	a2 := solveTestBlock(t, a1, bits, 0)
+	a3 := solveTestBlock(t, a2, bits, 0)
+	a4 := solveTestBlock(t, a3, bits, 0)
+	b2 := solveTestBlock(t, a1, bits, 1)
+	b3 := solveTestBlock(t, b2, bits, 1)
+	a2Hash, _ := a2.BlockSha()
+
+	tests := []struct {
+		name      string
+		headers   []*wire.MsgBlock
+		height    int64 // expected height of the headers tip
+		branchLen int64 // expected branch length of the headers tip
+	}{
+		{
+			name:      "extends main chain",
+			headers:   []*wire.MsgBlock{a3, a4},
+			height:    4,
+			branchLen: 2,
+		},
+		{
+			name:      "stale fork",
+			headers:   []*wire.MsgBlock{b2, b3},
+			height:    3,
+			branchLen: 2,
+		},
+	}
+
+	for _, test := range tests {
+		p := &peer{addr: "10.1.2.3:8333"}
+		bm := newTestBlockManager(t, p, []*wire.MsgBlock{a1, a2})
+		if err := bm.blockChain.GenerateInitialIndex(); err != nil {
+			t.Fatalf("GenerateInitialIndex: %v", err)
+		}
+		peers := list.New()
+		peers.PushBack(p)
+
+		msg := wire.NewMsgHeaders()
+		for _, block := range test.headers {
+			msg.AddBlockHeader(&block.Header)
+		}
+		bm.handleHeadersMsg(peers, &headersMsg{headers: msg, peer: p})
+
+		tipHash, _ := test.headers[len(test.headers)-1].BlockSha()
+		want := []blockchain.ChainTip{
+			{
+				Height:    test.height,
+				Hash:      tipHash,
+				BranchLen: test.branchLen,
+				Status:    blockchain.StatusHeadersOnly,
+			},
+			{
+				Height: 2,
+				Hash:   a2Hash,
+				Status: blockchain.StatusActive,
+			},
+		}
+		tips := bm.chainTips()
+		if !reflect.DeepEqual(tips, want) {
+			t.Errorf("%s: got tips %v, want %v", test.name, tips,
+				want)
+		}
+	}
+}
@@ -24,6 +24,12 @@ Create a new account with the given name.`
 
 	renameAccountHelp = `renameaccount "oldname" "newname"
 Rename an account to the given new name.`
+
+	notifyNamesHelp = `notifynames ["prefix",...]
+Send nameupdated and nameexpired notifications for names starting with any of
+the passed prefixes, such as "d/" or "id/", when blocks are connected, and
+nameupdatereverted and nameexpiryreverted notifications when they are
+disconnected again.  An empty prefix matches every name.`
 )
 
 func init() {
@@ -48,6 +54,8 @@ func init() {
 		parseListAllTransactionsCmd, nil, `TODO(jrick) fillmein`)
 	btcjson.RegisterCustomCmd("notifyblocks", parseNotifyBlocksCmd, nil,
 		`TODO(jrick) fillmein`)
+	btcjson.RegisterCustomCmd("notifynames", parseNotifyNamesCmd, nil,
+		notifyNamesHelp)
 	btcjson.RegisterCustomCmd("notifyreceived", parseNotifyReceivedCmd, nil,
 		`TODO(jrick) fillmein`)
 	btcjson.RegisterCustomCmd("notifynewtransactions",
@@ -921,6 +929,87 @@ func (cmd *NotifyBlocksCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// NotifyNamesCmd is a type handling custom marshaling and
+// unmarshaling of notifynames JSON websocket extension
+// commands.
+type NotifyNamesCmd struct {
+	id       interface{}
+	Prefixes []string
+}
+
+// Enforce that NotifyNamesCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &NotifyNamesCmd{}
+
+// NewNotifyNamesCmd creates a new NotifyNamesCmd.
+func NewNotifyNamesCmd(id interface{}, prefixes []string) *NotifyNamesCmd {
+	return &NotifyNamesCmd{
+		id:       id,
+		Prefixes: prefixes,
+	}
+}
+
+// parseNotifyNamesCmd parses a NotifyNamesCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseNotifyNamesCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var prefixes []string
+	if err := json.Unmarshal(r.Params[0], &prefixes); err != nil {
+		return nil, errors.New("first parameter 'prefixes' must be " +
+			"an array of strings: " + err.Error())
+	}
+
+	return NewNotifyNamesCmd(r.Id, prefixes), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *NotifyNamesCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the RPC method.
+func (cmd *NotifyNamesCmd) Method() string {
+	return "notifynames"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *NotifyNamesCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Prefixes,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *NotifyNamesCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseNotifyNamesCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*NotifyNamesCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
 // NotifyReceivedCmd is a type handling custom marshaling and
 // unmarshaling of notifyreceived JSON websocket extension
 // commands.
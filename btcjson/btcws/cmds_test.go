@@ -142,6 +142,18 @@ var cmdtests = []struct {
 			Account: &testAccount,
 		},
 	},
+	{
+		name: "notifynames",
+		f: func() (btcjson.Cmd, error) {
+			return NewNotifyNamesCmd(
+				float64(1),
+				[]string{"d/", "id/"}), nil
+		},
+		result: &NotifyNamesCmd{
+			id:       float64(1),
+			Prefixes: []string{"d/", "id/"},
+		},
+	},
 	{
 		name: "notifyreceived",
 		f: func() (btcjson.Cmd, error) {
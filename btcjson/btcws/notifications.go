@@ -39,6 +39,22 @@ const (
 	// blockdisconnected notification.
 	BlockDisconnectedNtfnMethod = "blockdisconnected"
 
+	// NameUpdatedNtfnMethod is the method of the btcd nameupdated
+	// notification.
+	NameUpdatedNtfnMethod = "nameupdated"
+
+	// NameUpdateRevertedNtfnMethod is the method of the btcd
+	// nameupdatereverted notification.
+	NameUpdateRevertedNtfnMethod = "nameupdatereverted"
+
+	// NameExpiredNtfnMethod is the method of the btcd nameexpired
+	// notification.
+	NameExpiredNtfnMethod = "nameexpired"
+
+	// NameExpiryRevertedNtfnMethod is the method of the btcd
+	// nameexpiryreverted notification.
+	NameExpiryRevertedNtfnMethod = "nameexpiryreverted"
+
 	// BtcdConnectedNtfnMethod is the method of the btcwallet
 	// btcdconnected notification.
 	BtcdConnectedNtfnMethod = "btcdconnected"
@@ -77,6 +93,14 @@ func init() {
 		parseBlockDisconnectedNtfn, nil, `TODO(jrick) fillmein`)
 	btcjson.RegisterCustomCmd(BtcdConnectedNtfnMethod,
 		parseBtcdConnectedNtfn, nil, `TODO(jrick) fillmein`)
+	btcjson.RegisterCustomCmd(NameExpiredNtfnMethod,
+		parseNameExpiredNtfn, nil, `TODO(jrick) fillmein`)
+	btcjson.RegisterCustomCmd(NameExpiryRevertedNtfnMethod,
+		parseNameExpiryRevertedNtfn, nil, `TODO(jrick) fillmein`)
+	btcjson.RegisterCustomCmd(NameUpdatedNtfnMethod,
+		parseNameUpdatedNtfn, nil, `TODO(jrick) fillmein`)
+	btcjson.RegisterCustomCmd(NameUpdateRevertedNtfnMethod,
+		parseNameUpdateRevertedNtfn, nil, `TODO(jrick) fillmein`)
 	btcjson.RegisterCustomCmd(RecvTxNtfnMethod,
 		parseRecvTxNtfn, nil, `TODO(jrick) fillmein`)
 	btcjson.RegisterCustomCmd(RescanFinishedNtfnMethod,
@@ -476,6 +500,388 @@ func (n *BtcdConnectedNtfn) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// parseNameNtfnParams parses the parameters shared by all name notifications,
+// which are the name, its value, the transaction which last updated it and the
+// height of the block holding that transaction.
+func parseNameNtfnParams(r *btcjson.RawCmd) (name, value, txID string, height int32, err error) {
+	if r.Id != nil {
+		return "", "", "", 0, ErrNotANtfn
+	}
+
+	if len(r.Params) != 4 {
+		return "", "", "", 0, btcjson.ErrWrongNumberOfParams
+	}
+
+	if err = json.Unmarshal(r.Params[0], &name); err != nil {
+		err = errors.New("first parameter 'name' must be a string: " + err.Error())
+		return
+	}
+	if err = json.Unmarshal(r.Params[1], &value); err != nil {
+		err = errors.New("second parameter 'value' must be a string: " + err.Error())
+		return
+	}
+	if err = json.Unmarshal(r.Params[2], &txID); err != nil {
+		err = errors.New("third parameter 'txid' must be a string: " + err.Error())
+		return
+	}
+	if err = json.Unmarshal(r.Params[3], &height); err != nil {
+		err = errors.New("fourth parameter 'height' must be a 32-bit integer: " + err.Error())
+		return
+	}
+
+	return name, value, txID, height, nil
+}
+
+// NameExpiredNtfn is a type handling custom marshaling and
+// unmarshaling of nameexpired JSON websocket notifications.
+// It describes the last update of a name which expired when the block at
+// Height plus the expiration depth was connected.
+type NameExpiredNtfn struct {
+	Name   string
+	Value  string
+	TxID   string
+	Height int32
+}
+
+// Enforce that NameExpiredNtfn satisfies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &NameExpiredNtfn{}
+
+// NewNameExpiredNtfn creates a new NameExpiredNtfn.
+func NewNameExpiredNtfn(name, value, txID string, height int32) *NameExpiredNtfn {
+	return &NameExpiredNtfn{
+		Name:   name,
+		Value:  value,
+		TxID:   txID,
+		Height: height,
+	}
+}
+
+// parseNameExpiredNtfn parses a RawCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the notification
+// with the btcjson parser.
+func parseNameExpiredNtfn(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	name, value, txID, height, err := parseNameNtfnParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNameExpiredNtfn(name, value, txID, height), nil
+}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *NameExpiredNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method
+// of the notification.
+func (n *NameExpiredNtfn) Method() string {
+	return NameExpiredNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Part of the btcjson.Cmd
+// interface.
+func (n *NameExpiredNtfn) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		n.Name,
+		n.Value,
+		n.TxID,
+		n.Height,
+	}
+
+	// No ID for notifications.
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of
+// the btcjson.Cmd interface.
+func (n *NameExpiredNtfn) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newNtfn, err := parseNameExpiredNtfn(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteNtfn, ok := newNtfn.(*NameExpiredNtfn)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*n = *concreteNtfn
+	return nil
+}
+
+// NameExpiryRevertedNtfn is a type handling custom marshaling and
+// unmarshaling of nameexpiryreverted JSON websocket notifications.
+// It describes the last update of a name which is active again because the
+// block which expired it was disconnected.
+type NameExpiryRevertedNtfn struct {
+	Name   string
+	Value  string
+	TxID   string
+	Height int32
+}
+
+// Enforce that NameExpiryRevertedNtfn satisfies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &NameExpiryRevertedNtfn{}
+
+// NewNameExpiryRevertedNtfn creates a new NameExpiryRevertedNtfn.
+func NewNameExpiryRevertedNtfn(name, value, txID string, height int32) *NameExpiryRevertedNtfn {
+	return &NameExpiryRevertedNtfn{
+		Name:   name,
+		Value:  value,
+		TxID:   txID,
+		Height: height,
+	}
+}
+
+// parseNameExpiryRevertedNtfn parses a RawCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the notification
+// with the btcjson parser.
+func parseNameExpiryRevertedNtfn(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	name, value, txID, height, err := parseNameNtfnParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNameExpiryRevertedNtfn(name, value, txID, height), nil
+}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *NameExpiryRevertedNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method
+// of the notification.
+func (n *NameExpiryRevertedNtfn) Method() string {
+	return NameExpiryRevertedNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Part of the btcjson.Cmd
+// interface.
+func (n *NameExpiryRevertedNtfn) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		n.Name,
+		n.Value,
+		n.TxID,
+		n.Height,
+	}
+
+	// No ID for notifications.
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of
+// the btcjson.Cmd interface.
+func (n *NameExpiryRevertedNtfn) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newNtfn, err := parseNameExpiryRevertedNtfn(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteNtfn, ok := newNtfn.(*NameExpiryRevertedNtfn)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*n = *concreteNtfn
+	return nil
+}
+
+// NameUpdatedNtfn is a type handling custom marshaling and
+// unmarshaling of nameupdated JSON websocket notifications.
+// It describes a name operation in a block connected to the main chain.
+type NameUpdatedNtfn struct {
+	Name   string
+	Value  string
+	TxID   string
+	Height int32
+}
+
+// Enforce that NameUpdatedNtfn satisfies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &NameUpdatedNtfn{}
+
+// NewNameUpdatedNtfn creates a new NameUpdatedNtfn.
+func NewNameUpdatedNtfn(name, value, txID string, height int32) *NameUpdatedNtfn {
+	return &NameUpdatedNtfn{
+		Name:   name,
+		Value:  value,
+		TxID:   txID,
+		Height: height,
+	}
+}
+
+// parseNameUpdatedNtfn parses a RawCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the notification
+// with the btcjson parser.
+func parseNameUpdatedNtfn(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	name, value, txID, height, err := parseNameNtfnParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNameUpdatedNtfn(name, value, txID, height), nil
+}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *NameUpdatedNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method
+// of the notification.
+func (n *NameUpdatedNtfn) Method() string {
+	return NameUpdatedNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Part of the btcjson.Cmd
+// interface.
+func (n *NameUpdatedNtfn) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		n.Name,
+		n.Value,
+		n.TxID,
+		n.Height,
+	}
+
+	// No ID for notifications.
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of
+// the btcjson.Cmd interface.
+func (n *NameUpdatedNtfn) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newNtfn, err := parseNameUpdatedNtfn(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteNtfn, ok := newNtfn.(*NameUpdatedNtfn)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*n = *concreteNtfn
+	return nil
+}
+
+// NameUpdateRevertedNtfn is a type handling custom marshaling and
+// unmarshaling of nameupdatereverted JSON websocket notifications.
+// It describes a name operation in a block disconnected from the main chain.
+type NameUpdateRevertedNtfn struct {
+	Name   string
+	Value  string
+	TxID   string
+	Height int32
+}
+
+// Enforce that NameUpdateRevertedNtfn satisfies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &NameUpdateRevertedNtfn{}
+
+// NewNameUpdateRevertedNtfn creates a new NameUpdateRevertedNtfn.
+func NewNameUpdateRevertedNtfn(name, value, txID string, height int32) *NameUpdateRevertedNtfn {
+	return &NameUpdateRevertedNtfn{
+		Name:   name,
+		Value:  value,
+		TxID:   txID,
+		Height: height,
+	}
+}
+
+// parseNameUpdateRevertedNtfn parses a RawCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the notification
+// with the btcjson parser.
+func parseNameUpdateRevertedNtfn(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	name, value, txID, height, err := parseNameNtfnParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNameUpdateRevertedNtfn(name, value, txID, height), nil
+}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *NameUpdateRevertedNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method
+// of the notification.
+func (n *NameUpdateRevertedNtfn) Method() string {
+	return NameUpdateRevertedNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Part of the btcjson.Cmd
+// interface.
+func (n *NameUpdateRevertedNtfn) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		n.Name,
+		n.Value,
+		n.TxID,
+		n.Height,
+	}
+
+	// No ID for notifications.
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of
+// the btcjson.Cmd interface.
+func (n *NameUpdateRevertedNtfn) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newNtfn, err := parseNameUpdateRevertedNtfn(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteNtfn, ok := newNtfn.(*NameUpdateRevertedNtfn)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*n = *concreteNtfn
+	return nil
+}
+
 // RecvTxNtfn is a type handling custom marshaling and unmarshaling
 // of recvtx JSON websocket notifications.
 type RecvTxNtfn struct {
@@ -62,6 +62,62 @@ var ntfntests = []struct {
 			Connected: true,
 		},
 	},
+	{
+		name: "nameexpired",
+		f: func() btcjson.Cmd {
+			return btcws.NewNameExpiredNtfn("d/example", `{"ip":"192.0.2.1"}`,
+				"b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+				153469)
+		},
+		result: &btcws.NameExpiredNtfn{
+			Name:   "d/example",
+			Value:  `{"ip":"192.0.2.1"}`,
+			TxID:   "b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+			Height: 153469,
+		},
+	},
+	{
+		name: "nameexpiryreverted",
+		f: func() btcjson.Cmd {
+			return btcws.NewNameExpiryRevertedNtfn("d/example", `{"ip":"192.0.2.1"}`,
+				"b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+				153469)
+		},
+		result: &btcws.NameExpiryRevertedNtfn{
+			Name:   "d/example",
+			Value:  `{"ip":"192.0.2.1"}`,
+			TxID:   "b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+			Height: 153469,
+		},
+	},
+	{
+		name: "nameupdated",
+		f: func() btcjson.Cmd {
+			return btcws.NewNameUpdatedNtfn("d/example", `{"ip":"192.0.2.1"}`,
+				"b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+				153469)
+		},
+		result: &btcws.NameUpdatedNtfn{
+			Name:   "d/example",
+			Value:  `{"ip":"192.0.2.1"}`,
+			TxID:   "b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+			Height: 153469,
+		},
+	},
+	{
+		name: "nameupdatereverted",
+		f: func() btcjson.Cmd {
+			return btcws.NewNameUpdateRevertedNtfn("d/example", `{"ip":"192.0.2.1"}`,
+				"b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+				153469)
+		},
+		result: &btcws.NameUpdateRevertedNtfn{
+			Name:   "d/example",
+			Value:  `{"ip":"192.0.2.1"}`,
+			TxID:   "b0bbd5a9bdb8c44af3d7d7a5e8d0e3f0f5c3f0bfa54a0b1e0d7fbc4a3b1b0e51",
+			Height: 153469,
+		},
+	},
 	{
 		name: "recvtx no block",
 		f: func() btcjson.Cmd {
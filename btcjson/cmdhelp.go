@@ -25,6 +25,22 @@ upon disconnection.`,
 Safely copies the wallet file to the destination provided, either a directory or
 a filename.`,
 
+	"clearbanned": `clearbanned
+Lifts the bans of all banned IP addresses and subnets.`,
+
+	"createauxblock": `createauxblock "address"
+Creates a block paying to "address" for merged mining and returns the following
+object describing it:
+{
+	"hash":"hash",			# The hash of the block to merge mine.
+	"chainid":n,			# The chain ID of the auxiliary chain.
+	"previousblockhash":"hash",	# The hash of the previous block.
+	"coinbasevalue":n,		# The value of the coinbase in satoshi.
+	"bits":"xxx",			# The compressed difficulty as a hex string.
+	"height":n,			# The height of the block.
+	"_target":"xxx",		# The byte reversed hash target.
+}`,
+
 	"createmultisig": `createmultisig nrequired ["key", ...]
 Creates a multi-signature address with m keys where "nrequired" signatures are
 required from those m. A JSON object is returned containing the address and
@@ -133,11 +149,13 @@ should use walletpassphrasechange.`,
 
 	"estimatefee": `estimatefee "numblocks"
 Estimates the approximate fee per kilobyte needed for a transaction to
-get confirmed within 'numblocks' blocks.`,
+get confirmed within 'numblocks' blocks, or -1 if not enough
+transactions have been observed.`,
 
 	"estimatepriority": `estimatepriority "numblocks"
 Estimates the approximate priority a zero-fee transaction needs to get
-confirmed within 'numblocks' blocks.`,
+confirmed within 'numblocks' blocks, or -1 if not enough transactions
+have been observed.`,
 
 	"getaccount": `getaccount "address"
 Returns the account associated with the given "address" as a string.`,
@@ -170,6 +188,22 @@ Returns the list of addresses for the given account:
 	...
 ]`,
 
+	"getauxblock": `getauxblock ( "hash" "auxpow" )
+If "hash" and "auxpow" are present, "auxpow" is the hex encoded auxpow solving
+the block with the given hash previously returned by getauxblock.  The server
+will then try to submit the block and return true upon success, false upon
+failure.  Otherwise a block paying to one of the configured mining addresses
+is created and the following object describing it is returned:
+{
+	"hash":"hash",			# The hash of the block to merge mine.
+	"chainid":n,			# The chain ID of the auxiliary chain.
+	"previousblockhash":"hash",	# The hash of the previous block.
+	"coinbasevalue":n,		# The value of the coinbase in satoshi.
+	"bits":"xxx",			# The compressed difficulty as a hex string.
+	"height":n,			# The height of the block.
+	"_target":"xxx",		# The byte reversed hash target.
+}`,
+
 	"getbalance": `getbalance ("account" "minconf")
 Returns the balance for an account. If "account" is not specified this is the
 total balance for the server. if "minconf" is provided then only transactions
@@ -207,6 +241,40 @@ instead of the "tx" member; It  will contain objects representing the
 transactions in the block in format used by getrawtransaction.
 Please note that verbosetx is a btcd/btcjson extension.`,
 
+	"getblockchaininfo": `getblockchaininfo
+Returns a JSON object containing information about the state of the block
+chain of the following format:
+{
+	"chain":"name",			# Name of the network.
+	"blocks":n,			# Numeric height of the best block.
+	"bestblockhash":"hash",		# Hex encoded hash of the best block.
+	"difficulty":n,			# Numeric difficulty of the best block.
+	"mediantime":t,			# Median timestamp of the last blocks in seconds since the epoch.
+	"verificationprogress":n,	# Numeric estimate of the fraction of the chain verified.
+	"chainwork":"hex",		# Hex encoded total work of the best chain.
+	"pruned":false,			# Whether blocks are pruned, always false.
+	"softforks":[			# Array of block version upgrades.
+		{
+			"id":"name",		# Name of the upgrade.
+			"version":n,		# Numeric block version being upgraded to.
+			"enforce":{		# Progress towards enforcing the new rules.
+				"status":true|false,	# Whether the threshold is reached.
+				"found":n,		# Numeric number of upgraded blocks found in the window.
+				"required":n,		# Numeric number of upgraded blocks required.
+				"window":n		# Numeric number of blocks checked.
+			},
+			"reject":{...}		# Progress towards rejecting older versions, same format as enforce.
+		},
+		...
+	],
+	"auxpow":{			# Merged mining status.
+		"active":true|false,	# Whether blocks building on the best block may be merged mined.
+		"chainid":n,		# Numeric chain ID of the network.
+		"startheight":n,	# Numeric height from which blocks may be merged mined.
+		"strictchainid":true|false	# Whether the chain ID is enforced.
+	}
+}`,
+
 	"getblockcount": `getblockcount
 Returns a numeric for the number of blocks in the longest block chain.`,
 
@@ -259,6 +327,20 @@ The result object is of the following format:
 	"height":n,		# Numeric height of the next block.
 }`,
 
+	"getchaintips": `getchaintips
+Returns information about all known tips in the block tree, including the
+main chain as well as orphaned branches.
+The result is a JSON array of objects of the following format:
+[
+	{
+		"height":n,		# Numeric height of the chain tip.
+		"hash":"hash",		# Hex encoded hash of the chain tip.
+		"branchlen":n,		# Numeric length of the branch connecting the tip to the main chain, zero for the main chain.
+		"status":"status"	# One of "active", "valid-fork", "headers-only" or "invalid".
+	},
+	...
+]`,
+
 	"getconnectioncount": `getconnectioncount
 Returns the number of connections to other nodes currently active as a JSON
 number.`,
@@ -290,9 +372,44 @@ Returns a JSON object containing state information about the service:
 	"keypoolsize":n,	# Numeric size of the wallet keypool.
 	"paytxfee":n,		# Numeric transaction fee that has been set.
 	"unlocked_until":t,	# Numeric time the wallet is unlocked for in seconds since epoch.
+	"relayfee":n,		# Minimum fee per kilobyte for transactions to be relayed in btc.
+	"mempoolbytes":n,	# Numeric size in bytes of the transactions in the memory pool.
+	"maxmempool":n,		# Numeric maximum size in bytes of the memory pool.
+	"mempoolminfee":n,	# Minimum fee per kilobyte for transactions to enter the full memory pool in btc.
 	"errors":"..."		# Any error messages as a string.
 }`,
 
+	"getmempoolentry": `getmempoolentry "txid"
+Returns a JSON object with information about the transaction "txid" in the
+memory pool:
+{
+	"size":n,		# Numeric transaction size in bytes.
+	"fee":n,		# Numeric transaction fee in btc.
+	"time":t,		# Time transaction entered pool in seconds since the epoch.
+	"height":n,		# Numeric block height when the transaction entered pool.
+	"startingpriority":n,	# Numeric transaction priority when it entered the pool.
+	"currentpriority":n,	# Numeric transaction priority.
+	"descendantcount":n,	# Numeric number of transactions in the pool depending on this one, including itself.
+	"descendantsize":n,	# Numeric size in bytes of this transaction and its descendants.
+	"descendantfees":n,	# Numeric fees in satoshi of this transaction and its descendants.
+	"ancestorcount":n,	# Numeric number of transactions in the pool this one depends on, including itself.
+	"ancestorsize":n,	# Numeric size in bytes of this transaction and its ancestors.
+	"ancestorfees":n,	# Numeric fees in satoshi of this transaction and its ancestors.
+	"depends":[		# Unconfirmed transactions used as inputs for this one.  As an array of strings.
+		"transactionid",	# Parent transaction id.
+	]
+}`,
+
+	"getmempoolinfo": `getmempoolinfo
+Returns a JSON object containing information about the transaction memory pool:
+{
+	"size":n,		# Numeric number of transactions in the pool.
+	"bytes":n,		# Numeric size in bytes of the transactions in the pool.
+	"usage":n,		# Numeric size in bytes of the transactions in the pool and the orphan pool.
+	"maxmempool":n,		# Numeric maximum size in bytes of the pool.
+	"mempoolminfee":n	# Minimum fee per kilobyte for transactions to enter the full pool in btc.
+}`,
+
 	"getmininginfo": `getmininginfo
 Returns a JSON object containing information related to mining:
 {
@@ -319,6 +436,35 @@ calculation will be carried out for the given block height instead of
 the block tip. A JSON number is returned with the hashes per second
 estimate.`,
 
+	"getnetworkinfo": `getnetworkinfo
+Returns a JSON object containing information about the P2P network of the
+following format:
+{
+	"version":n,			# Numeric server version.
+	"protocolversion":n,		# Numeric protocol version.
+	"localservices":"services",	# Hex bitfield of the services offered to peers.
+	"timeoffset":n,			# Numeric time offset to the network in seconds.
+	"connections":n,		# Numeric number of connected peers.
+	"networks":[			# Array of networks.
+		{
+			"name":"name",		# Network name, ipv4, ipv6 or onion.
+			"limited":true|false,	# Whether connections to the network are disabled.
+			"reachable":true|false,	# Whether peers on the network can be connected to.
+			"proxy":"host:port"	# Proxy used to connect to the network, if any.
+		},
+		...
+	],
+	"relayfee":n,			# Current minimum fee in BTC/kB to relay transactions.
+	"localaddresses":[		# Array of addresses advertised to peers.
+		{
+			"address":"address",	# Network address.
+			"port":n,		# Numeric port.
+			"score":n		# Numeric score of how the address was discovered.
+		},
+		...
+	]
+}`,
+
 	"getnewaddress": `getnewaddress ( "account" )
 Returns a string for a new Bitcoin address for receiving payments. In the case
 that "account" is specified then the address will be for "account", else the
@@ -345,6 +491,7 @@ network node. The objects have the following format:
 		"startingheight":n,	# Numeric block heght of peer at connect time.
 		"banscore":n,		# The numeric ban score.
 		"syncnode":true|false,	# Boolean if the peer is the current sync node.
+		"whitelisted":true|false,	# Boolean if the peer is whitelisted.
 	}
 ]`,
 	"getrawchangeaddress": `getrawchangeaddress
@@ -363,6 +510,9 @@ If it is true then objects in the following format will be returned:
 		"height":n,		# Numeric block height when the transaction entered pool.
 		"startingpriority:n,	# Numeric transaction priority when it entered the pool.
 		"currentpriority":n,	# Numeric transaction priority.
+		"descendantcount":n,	# Numeric number of transactions in the pool depending on this one, including itself.
+		"descendantsize":n,	# Numeric size in bytes of this transaction and its descendants.
+		"descendantfees":n,	# Numeric fees in satoshi of this transaction and its descendants.
 		"depends":[		# Unconfirmed transactions used as inputs for this one.  As an array of strings.
 			"transactionid",	# Parent transaction id.
 		]
@@ -474,6 +624,8 @@ output set:
 	"bytes_serialized":n		# Numeric serialized size.
 	"hash_serialized"		# String of serialized hash.
 	"total_amount":n,		# Numeric total amount in BTC.
+	"name_txouts":n,		# Numeric count of outputs holding active names.
+	"name_amount":n			# Numeric total amount held by active names in BTC.
 }`,
 
 	"getwork": `getwork ( "data" )
@@ -502,7 +654,9 @@ blockchain will be scanned for transaction.`,
 Imports keys from the wallet dump file in "filename".`,
 
 	"invalidateblock": `invalidateblock "hash"
-Mark block specified by "hash" as invalid.`,
+Mark block specified by "hash" and all of its descendants as invalid and
+reorganize the chain to the best valid branch. The mark is kept across
+restarts until the block is reconsidered.`,
 
 	"keypoolrefill": `keypoolrefill ( newsize=100 )
 Refills the wallet pregenerated key pool to a size of "newsize"`,
@@ -530,6 +684,18 @@ takes the following format:
 	...
 ]`,
 
+	"listbanned": `listbanned
+Returns a JSON array of objects describing the banned IP addresses and subnets:
+[
+	{
+		"address":"subnet",	# The banned subnet in CIDR notation.
+		"banned_until":n,	# Unix time at which the ban ends.
+		"ban_created":n,	# Unix time at which the ban was added.
+		"ban_reason":"reason"	# Why the subnet was banned.
+	},
+	...
+]`,
+
 	"listlockunspent": `listlockunspent
 Returns a JSON array of objects detailing transaction outputs that are
 temporarily unspendable due to being processed by the lockunspent call.
@@ -645,12 +811,64 @@ with minconf confirmations are used. If comment is present this comment
 will be stored in the wallet with the transaction. Boolean is returned
 to denode success.`,
 
+	"name_filter": `name_filter ("regexp" maxage=36000 from=0 nb=0 "stat")
+Returns the names matching "regexp" which were updated in the last "maxage"
+blocks. An empty "regexp" matches every name and a "maxage" of 0 matches names
+of any age. The first "from" matches are skipped and at most "nb" are returned,
+where 0 means no limit. Each result is a JSON object:
+{
+	"name":"name",		# the name.
+	"value":"value",	# the current value of the name.
+	"registered_at":n,	# the height of the last update of the name.
+	"expires_in":n,		# the number of blocks until the name expires.
+}
+Expired names only hold the name and "expired":1. If "stat" is passed instead
+a JSON object with the current block count as "blocks" and the number of
+matches as "count" is returned.`,
+
+	"name_history": `name_history "name"
+Returns every value "name" was given, oldest first, as a list of objects in the
+format returned by name_show.`,
+
+	"name_pending": `name_pending
+Returns the name operations waiting in the memory pool to be mined, ordered by
+name. Each result is a JSON object:
+{
+	"name":"name",		# the name.
+	"txid":"hash",		# the transaction performing the operation.
+	"op":"name_update",	# name_firstupdate or name_update.
+	"value":"value",	# the value the name is given.
+}`,
+
+	"name_scan": `name_scan ("startname" maxreturned=500)
+Returns up to "maxreturned" names in ascending order, starting with the first
+name which is not less than "startname". Each result is a JSON object:
+{
+	"name":"name",		# the name.
+	"value":"value",	# the current value of the name.
+	"expires_in":n,		# the number of blocks until the name expires.
+}
+Expired names only hold the name and "expired":1.`,
+
+	"name_show": `name_show "name"
+Returns a JSON object describing the current state of "name":
+{
+	"name":"name",		# the name.
+	"value":"value",	# the current value of the name.
+	"txid":"hash",		# the transaction of the last update of the name.
+	"address":"address",	# the address owning the name.
+	"expires_in":n,		# the number of blocks until the name expires.
+	"expired":1,		# only present once the name has expired.
+}`,
+
 	"ping": `ping
 Queues a ping to be sent to each connected peer. Ping times are provided in
 getpeerinfo.`,
 
 	"reconsiderblock": `reconsiderblock "hash"
-Remove invalid mark from block specified by "hash" so it is considered again.`,
+Remove invalid mark from block specified by "hash", its ancestors and its
+descendants so they are considered again and reorganize the chain to the best
+valid branch.`,
 
 	"searchrawtransactions": `searchrawtransactions "address" (verbose=1 skip=0 count=100)
 Returns raw tx data related to credits or debits to "address". Skip indicates
@@ -725,6 +943,12 @@ returned if successful.`,
 	"setaccount": `setaccount "address" "account"
 Sets the account associated with "address" to "account".`,
 
+	"setban": `setban "subnet" "{add|remove}" ( bantime )
+Adds or removes the ban of an IP address or a subnet in CIDR notation, such as
+192.168.0.0/16.  Banned addresses are disconnected and no connections are made
+to or accepted from them.  The ban lasts "bantime" seconds, or the configured
+ban duration when it is omitted or 0.`,
+
 	"setgenerate": `setgenerate generate ( genproclimit )
 Sets the current mining state to "generate". Up to "genproclimit" processors
 will be used, if genproclimit is -1 then it is unlimited.`,
@@ -768,6 +992,11 @@ The return value takes the following format:
 	"stop": `stop
 Stop the server.`,
 
+	"submitauxblock": `submitauxblock "hash" "auxpow"
+Will attempt to submit the block with the given hash previously returned by
+createauxblock, solved by the hex encoded "auxpow", to the network.  Returns
+true upon success, false upon failure.`,
+
 	"submitblock": `submitblock "data" ( optionalparameterobject )
 Will attempt to submit the block serialized in "data" to the bitcoin network.
 optionalparametersobject takes the following format:
@@ -106,7 +106,8 @@ func CreateMessageWithId(message string, id interface{}, args ...interface{}) ([
 		"getmininginfo", "getpeerinfo", "getrawmempool",
 		"keypoolrefill", "listaddressgroupings", "listlockunspent",
 		"stop", "walletlock", "getbestblockhash", "getblockchaininfo",
-		"getnetworkinfo":
+		"getnetworkinfo", "getchaintips", "getmempoolinfo",
+		"listbanned", "clearbanned":
 		if len(args) > 0 {
 			err = fmt.Errorf("too many arguments for %s", message)
 			return finalMessage, err
@@ -170,7 +171,7 @@ func CreateMessageWithId(message string, id interface{}, args ...interface{}) ([
 		"encryptwallet", "getaccount", "getaccountaddress",
 		"getaddressesbyaccount", "getblock",
 		"gettransaction", "sendrawtransaction", "validateaddress",
-		"invalidateblock", "reconsiderblock":
+		"invalidateblock", "reconsiderblock", "getmempoolentry":
 		if len(args) != 1 {
 			err = fmt.Errorf("%s requires one argument", message)
 			return finalMessage, err
@@ -194,6 +195,23 @@ func CreateMessageWithId(message string, id interface{}, args ...interface{}) ([
 			return finalMessage, err
 		}
 		finalMessage, err = jsonWithArgs(message, id, args)
+	// Two required strings, one optional int
+	case "setban":
+		if len(args) < 2 || len(args) > 3 {
+			err = fmt.Errorf("wrong number of arguments for %s", message)
+			return finalMessage, err
+		}
+		_, ok1 := args[0].(string)
+		_, ok2 := args[1].(string)
+		ok3 := true
+		if len(args) == 3 {
+			_, ok3 = args[2].(int)
+		}
+		if !ok1 || !ok2 || !ok3 {
+			err = fmt.Errorf("arguments must be string, string and optionally int for %s", message)
+			return finalMessage, err
+		}
+		finalMessage, err = jsonWithArgs(message, id, args)
 	// One required string, one required int
 	case "walletpassphrase":
 		if len(args) != 2 {
@@ -61,6 +61,9 @@ var cmdtests = []struct {
 	{"invalidateblock", []interface{}{1, 2}, false},
 	{"invalidateblock", []interface{}{1}, false},
 	{"invalidateblock", []interface{}{"testhash"}, true},
+	{"getmempoolentry", nil, false},
+	{"getmempoolentry", []interface{}{1}, false},
+	{"getmempoolentry", []interface{}{"testtxid"}, true},
 	{"reconsiderblock", nil, false},
 	{"reconsiderblock", []interface{}{1, 2}, false},
 	{"reconsiderblock", []interface{}{1}, false},
@@ -100,6 +103,13 @@ var cmdtests = []struct {
 	{"addnode", []interface{}{1}, false},
 	{"addnode", []interface{}{"test", 1}, false},
 	{"addnode", []interface{}{"test", 1.0}, false},
+	{"setban", nil, false},
+	{"setban", []interface{}{"test"}, false},
+	{"setban", []interface{}{"test", "test"}, true},
+	{"setban", []interface{}{"test", "test", 1}, true},
+	{"setban", []interface{}{"test", "test", 1.0}, false},
+	{"setban", []interface{}{"test", 1}, false},
+	{"setban", []interface{}{"test", "test", 1, 2}, false},
 	{"listreceivedbyaccount", nil, true},
 	{"listreceivedbyaccount", []interface{}{1, 2, 3}, false},
 	{"listreceivedbyaccount", []interface{}{1}, true},
@@ -202,8 +212,16 @@ var cmdtests = []struct {
 	{"getbestblockhash", []interface{}{"something"}, false},
 	{"getblockchaininfo", []interface{}{}, true},
 	{"getblockchaininfo", []interface{}{"something"}, false},
+	{"getchaintips", []interface{}{}, true},
+	{"getchaintips", []interface{}{"something"}, false},
 	{"getnetworkinfo", []interface{}{}, true},
 	{"getnetworkinfo", []interface{}{"something"}, false},
+	{"getmempoolinfo", []interface{}{}, true},
+	{"getmempoolinfo", []interface{}{"something"}, false},
+	{"listbanned", []interface{}{}, true},
+	{"listbanned", []interface{}{"something"}, false},
+	{"clearbanned", []interface{}{}, true},
+	{"clearbanned", []interface{}{"something"}, false},
 	{"submitblock", []interface{}{}, false},
 	{"submitblock", []interface{}{"something"}, true},
 	{"submitblock", []interface{}{"something", "something else"}, true},
@@ -112,6 +112,12 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "backupwallet":
 		cmd = new(BackupWalletCmd)
 
+	case "clearbanned":
+		cmd = new(ClearBannedCmd)
+
+	case "createauxblock":
+		cmd = new(CreateAuxBlockCmd)
+
 	case "createmultisig":
 		cmd = new(CreateMultisigCmd)
 
@@ -154,6 +160,9 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "getaddressesbyaccount":
 		cmd = new(GetAddressesByAccountCmd)
 
+	case "getauxblock":
+		cmd = new(GetAuxBlockCmd)
+
 	case "getbalance":
 		cmd = new(GetBalanceCmd)
 
@@ -175,6 +184,9 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "getblocktemplate":
 		cmd = new(GetBlockTemplateCmd)
 
+	case "getchaintips":
+		cmd = new(GetChainTipsCmd)
+
 	case "getconnectioncount":
 		cmd = new(GetConnectionCountCmd)
 
@@ -190,6 +202,12 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "getinfo":
 		cmd = new(GetInfoCmd)
 
+	case "getmempoolentry":
+		cmd = new(GetMempoolEntryCmd)
+
+	case "getmempoolinfo":
+		cmd = new(GetMempoolInfoCmd)
+
 	case "getmininginfo":
 		cmd = new(GetMiningInfoCmd)
 
@@ -262,6 +280,9 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "listaddressgroupings":
 		cmd = new(ListAddressGroupingsCmd)
 
+	case "listbanned":
+		cmd = new(ListBannedCmd)
+
 	case "listlockunspent":
 		cmd = new(ListLockUnspentCmd)
 
@@ -286,6 +307,21 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "move":
 		cmd = new(MoveCmd)
 
+	case "name_filter":
+		cmd = new(NameFilterCmd)
+
+	case "name_history":
+		cmd = new(NameHistoryCmd)
+
+	case "name_pending":
+		cmd = new(NamePendingCmd)
+
+	case "name_scan":
+		cmd = new(NameScanCmd)
+
+	case "name_show":
+		cmd = new(NameShowCmd)
+
 	case "ping":
 		cmd = new(PingCmd)
 
@@ -310,6 +346,9 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "setaccount":
 		cmd = new(SetAccountCmd)
 
+	case "setban":
+		cmd = new(SetBanCmd)
+
 	case "setgenerate":
 		cmd = new(SetGenerateCmd)
 
@@ -325,6 +364,9 @@ func ParseMarshaledCmd(b []byte) (Cmd, error) {
 	case "stop":
 		cmd = new(StopCmd)
 
+	case "submitauxblock":
+		cmd = new(SubmitAuxBlockCmd)
+
 	case "submitblock":
 		cmd = new(SubmitBlockCmd)
 
@@ -685,6 +727,133 @@ func (cmd *BackupWalletCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// ClearBannedCmd is a type handling custom marshaling and
+// unmarshaling of clearbanned JSON RPC commands.
+type ClearBannedCmd struct {
+	id interface{}
+}
+
+// Enforce that ClearBannedCmd satisifies the Cmd interface.
+var _ Cmd = &ClearBannedCmd{}
+
+// NewClearBannedCmd creates a new ClearBannedCmd.
+func NewClearBannedCmd(id interface{}) (*ClearBannedCmd, error) {
+	return &ClearBannedCmd{
+		id: id,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *ClearBannedCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *ClearBannedCmd) Method() string {
+	return "clearbanned"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ClearBannedCmd) MarshalJSON() ([]byte, error) {
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ClearBannedCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) > 0 {
+		return ErrWrongNumberOfParams
+	}
+
+	newCmd, err := NewClearBannedCmd(r.Id)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
+// CreateAuxBlockCmd is a type handling custom marshaling and
+// unmarshaling of createauxblock JSON RPC commands.
+type CreateAuxBlockCmd struct {
+	id      interface{}
+	Address string
+}
+
+// Enforce that CreateAuxBlockCmd satisifies the Cmd interface.
+var _ Cmd = &CreateAuxBlockCmd{}
+
+// NewCreateAuxBlockCmd creates a new CreateAuxBlockCmd.
+func NewCreateAuxBlockCmd(id interface{}, address string) (*CreateAuxBlockCmd, error) {
+	return &CreateAuxBlockCmd{
+		id:      id,
+		Address: address,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *CreateAuxBlockCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *CreateAuxBlockCmd) Method() string {
+	return "createauxblock"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *CreateAuxBlockCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Address,
+	}
+
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *CreateAuxBlockCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) != 1 {
+		return ErrWrongNumberOfParams
+	}
+
+	var address string
+	if err := json.Unmarshal(r.Params[0], &address); err != nil {
+		return fmt.Errorf("first parameter 'address' must be a string: %v", err)
+	}
+
+	newCmd, err := NewCreateAuxBlockCmd(r.Id, address)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // CreateMultisigCmd is a type handling custom marshaling and
 // unmarshaling of createmultisig JSON RPC commands.
 type CreateMultisigCmd struct {
@@ -1714,6 +1883,100 @@ func (cmd *GetAddressesByAccountCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// GetAuxBlockCmd is a type handling custom marshaling and
+// unmarshaling of getauxblock JSON RPC commands.  The hash and auxpow are
+// either both set for a submission or both empty for a work request.
+type GetAuxBlockCmd struct {
+	id     interface{}
+	Hash   string
+	AuxPow string
+}
+
+// Enforce that GetAuxBlockCmd satisifies the Cmd interface.
+var _ Cmd = &GetAuxBlockCmd{}
+
+// NewGetAuxBlockCmd creates a new GetAuxBlockCmd.  Optionally the hash of a
+// previously returned block and the hex-encoded auxpow solving it may be
+// provided to submit the block.
+func NewGetAuxBlockCmd(id interface{}, optArgs ...string) (*GetAuxBlockCmd, error) {
+	var hash, auxPow string
+	switch len(optArgs) {
+	case 0:
+	case 2:
+		hash = optArgs[0]
+		auxPow = optArgs[1]
+	default:
+		return nil, ErrWrongNumberOfParams
+	}
+
+	return &GetAuxBlockCmd{
+		id:     id,
+		Hash:   hash,
+		AuxPow: auxPow,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *GetAuxBlockCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *GetAuxBlockCmd) Method() string {
+	return "getauxblock"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetAuxBlockCmd) MarshalJSON() ([]byte, error) {
+	params := make([]interface{}, 0, 2)
+	if cmd.Hash != "" || cmd.AuxPow != "" {
+		params = append(params, cmd.Hash, cmd.AuxPow)
+	}
+
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetAuxBlockCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) != 0 && len(r.Params) != 2 {
+		return ErrWrongNumberOfParams
+	}
+
+	optArgs := make([]string, 0, 2)
+	if len(r.Params) == 2 {
+		var hash string
+		if err := json.Unmarshal(r.Params[0], &hash); err != nil {
+			return fmt.Errorf("first optional parameter 'hash' must be a string: %v", err)
+		}
+
+		var auxPow string
+		if err := json.Unmarshal(r.Params[1], &auxPow); err != nil {
+			return fmt.Errorf("second optional parameter 'auxpow' must be a string: %v", err)
+		}
+		optArgs = append(optArgs, hash, auxPow)
+	}
+
+	newCmd, err := NewGetAuxBlockCmd(r.Id, optArgs...)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // GetBalanceCmd is a type handling custom marshaling and
 // unmarshaling of getbalance JSON RPC commands.
 type GetBalanceCmd struct {
@@ -2327,6 +2590,64 @@ func (cmd *GetBlockTemplateCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// GetChainTipsCmd is a type handling custom marshaling and
+// unmarshaling of getchaintips JSON RPC commands.
+type GetChainTipsCmd struct {
+	id interface{}
+}
+
+// Enforce that GetChainTipsCmd satisifies the Cmd interface.
+var _ Cmd = &GetChainTipsCmd{}
+
+// NewGetChainTipsCmd creates a new GetChainTipsCmd.
+func NewGetChainTipsCmd(id interface{}) (*GetChainTipsCmd, error) {
+	return &GetChainTipsCmd{
+		id: id,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *GetChainTipsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *GetChainTipsCmd) Method() string {
+	return "getchaintips"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetChainTipsCmd) MarshalJSON() ([]byte, error) {
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetChainTipsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) > 0 {
+		return ErrWrongNumberOfParams
+	}
+
+	newCmd, err := NewGetChainTipsCmd(r.Id)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // GetConnectionCountCmd is a type handling custom marshaling and
 // unmarshaling of getconnectioncount JSON RPC commands.
 type GetConnectionCountCmd struct {
@@ -2617,6 +2938,133 @@ func (cmd *GetInfoCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// GetMempoolEntryCmd is a type handling custom marshaling and
+// unmarshaling of getmempoolentry JSON RPC commands.
+type GetMempoolEntryCmd struct {
+	id   interface{}
+	Txid string
+}
+
+// Enforce that GetMempoolEntryCmd satisifies the Cmd interface.
+var _ Cmd = &GetMempoolEntryCmd{}
+
+// NewGetMempoolEntryCmd creates a new GetMempoolEntryCmd.
+func NewGetMempoolEntryCmd(id interface{}, txid string) (*GetMempoolEntryCmd, error) {
+	return &GetMempoolEntryCmd{
+		id:   id,
+		Txid: txid,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *GetMempoolEntryCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *GetMempoolEntryCmd) Method() string {
+	return "getmempoolentry"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetMempoolEntryCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Txid,
+	}
+
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetMempoolEntryCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) != 1 {
+		return ErrWrongNumberOfParams
+	}
+
+	var txid string
+	if err := json.Unmarshal(r.Params[0], &txid); err != nil {
+		return fmt.Errorf("first parameter 'txid' must be a string: %v", err)
+	}
+
+	newCmd, err := NewGetMempoolEntryCmd(r.Id, txid)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
+// GetMempoolInfoCmd is a type handling custom marshaling and
+// unmarshaling of getmempoolinfo JSON RPC commands.
+type GetMempoolInfoCmd struct {
+	id interface{}
+}
+
+// Enforce that GetMempoolInfoCmd satisifies the Cmd interface.
+var _ Cmd = &GetMempoolInfoCmd{}
+
+// NewGetMempoolInfoCmd creates a new GetMempoolInfoCmd.
+func NewGetMempoolInfoCmd(id interface{}) (*GetMempoolInfoCmd, error) {
+	return &GetMempoolInfoCmd{
+		id: id,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *GetMempoolInfoCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *GetMempoolInfoCmd) Method() string {
+	return "getmempoolinfo"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetMempoolInfoCmd) MarshalJSON() ([]byte, error) {
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetMempoolInfoCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) > 0 {
+		return ErrWrongNumberOfParams
+	}
+
+	newCmd, err := NewGetMempoolInfoCmd(r.Id)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // GetMiningInfoCmd is a type handling custom marshaling and
 // unmarshaling of getmininginfo JSON RPC commands.
 type GetMiningInfoCmd struct {
@@ -4516,6 +4964,64 @@ func (cmd *ListAddressGroupingsCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// ListBannedCmd is a type handling custom marshaling and
+// unmarshaling of listbanned JSON RPC commands.
+type ListBannedCmd struct {
+	id interface{}
+}
+
+// Enforce that ListBannedCmd satisifies the Cmd interface.
+var _ Cmd = &ListBannedCmd{}
+
+// NewListBannedCmd creates a new ListBannedCmd.
+func NewListBannedCmd(id interface{}) (*ListBannedCmd, error) {
+	return &ListBannedCmd{
+		id: id,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *ListBannedCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *ListBannedCmd) Method() string {
+	return "listbanned"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ListBannedCmd) MarshalJSON() ([]byte, error) {
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ListBannedCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) > 0 {
+		return ErrWrongNumberOfParams
+	}
+
+	newCmd, err := NewListBannedCmd(r.Id)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // ListLockUnspentCmd is a type handling custom marshaling and
 // unmarshaling of listlockunspent JSON RPC commands.
 type ListLockUnspentCmd struct {
@@ -6252,6 +6758,116 @@ func (cmd *SetAccountCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// SetBanCmd is a type handling custom marshaling and
+// unmarshaling of setban JSON RPC commands.
+type SetBanCmd struct {
+	id      interface{}
+	Subnet  string
+	SubCmd  string // One of "add","remove".
+	BanTime int64  // Seconds, 0 for the default ban duration.
+}
+
+// Enforce that SetBanCmd satisifies the Cmd interface.
+var _ Cmd = &SetBanCmd{}
+
+// NewSetBanCmd creates a new SetBanCmd for the given subnet and subcommand.
+// The optional argument is the number of seconds the subnet is banned for.
+func NewSetBanCmd(id interface{}, subnet string, subcmd string,
+	optArgs ...int64) (*SetBanCmd, error) {
+
+	switch subcmd {
+	case "add":
+		// fine
+	case "remove":
+		// fine
+	default:
+		return nil, errors.New("invalid subcommand for setban")
+	}
+
+	var banTime int64
+	if len(optArgs) > 0 {
+		if len(optArgs) > 1 {
+			return nil, ErrTooManyOptArgs
+		}
+		banTime = optArgs[0]
+	}
+
+	return &SetBanCmd{
+		id:      id,
+		Subnet:  subnet,
+		SubCmd:  subcmd,
+		BanTime: banTime,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *SetBanCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *SetBanCmd) Method() string {
+	return "setban"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SetBanCmd) MarshalJSON() ([]byte, error) {
+	params := make([]interface{}, 2, 3)
+	params[0] = cmd.Subnet
+	params[1] = cmd.SubCmd
+	if cmd.BanTime != 0 {
+		params = append(params, cmd.BanTime)
+	}
+
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SetBanCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) < 2 || len(r.Params) > 3 {
+		return ErrWrongNumberOfParams
+	}
+
+	var subnet string
+	if err := json.Unmarshal(r.Params[0], &subnet); err != nil {
+		return fmt.Errorf("first parameter 'subnet' must be a string: %v", err)
+	}
+
+	var subcmd string
+	if err := json.Unmarshal(r.Params[1], &subcmd); err != nil {
+		return fmt.Errorf("second parameter 'subcmd' must be a string: %v", err)
+	}
+
+	optArgs := make([]int64, 0, 1)
+	if len(r.Params) > 2 {
+		var banTime int64
+		if err := json.Unmarshal(r.Params[2], &banTime); err != nil {
+			return fmt.Errorf("third optional parameter 'bantime' must be an integer: %v", err)
+		}
+		optArgs = append(optArgs, banTime)
+	}
+
+	newCmd, err := NewSetBanCmd(r.Id, subnet, subcmd, optArgs...)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // SetGenerateCmd is a type handling custom marshaling and
 // unmarshaling of setgenerate JSON RPC commands.
 type SetGenerateCmd struct {
@@ -6709,6 +7325,83 @@ type SubmitBlockOptions struct {
 	WorkID string `json:"workid,omitempty"`
 }
 
+// SubmitAuxBlockCmd is a type handling custom marshaling and
+// unmarshaling of submitauxblock JSON RPC commands.
+type SubmitAuxBlockCmd struct {
+	id     interface{}
+	Hash   string
+	AuxPow string
+}
+
+// Enforce that SubmitAuxBlockCmd satisifies the Cmd interface.
+var _ Cmd = &SubmitAuxBlockCmd{}
+
+// NewSubmitAuxBlockCmd creates a new SubmitAuxBlockCmd.
+func NewSubmitAuxBlockCmd(id interface{}, hash, auxPow string) (*SubmitAuxBlockCmd, error) {
+	return &SubmitAuxBlockCmd{
+		id:     id,
+		Hash:   hash,
+		AuxPow: auxPow,
+	}, nil
+}
+
+// Id satisfies the Cmd interface by returning the id of the command.
+func (cmd *SubmitAuxBlockCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisfies the Cmd interface by returning the json method.
+func (cmd *SubmitAuxBlockCmd) Method() string {
+	return "submitauxblock"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SubmitAuxBlockCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Hash,
+		cmd.AuxPow,
+	}
+
+	// Fill and marshal a RawCmd.
+	raw, err := NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SubmitAuxBlockCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd
+	var r RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	if len(r.Params) != 2 {
+		return ErrWrongNumberOfParams
+	}
+
+	var hash string
+	if err := json.Unmarshal(r.Params[0], &hash); err != nil {
+		return fmt.Errorf("first parameter 'hash' must be a string: %v", err)
+	}
+
+	var auxPow string
+	if err := json.Unmarshal(r.Params[1], &auxPow); err != nil {
+		return fmt.Errorf("second parameter 'auxpow' must be a string: %v", err)
+	}
+
+	newCmd, err := NewSubmitAuxBlockCmd(r.Id, hash, auxPow)
+	if err != nil {
+		return err
+	}
+
+	*cmd = *newCmd
+	return nil
+}
+
 // SubmitBlockCmd is a type handling custom marshaling and
 // unmarshaling of submitblock JSON RPC commands.
 type SubmitBlockCmd struct {
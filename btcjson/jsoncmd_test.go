@@ -105,6 +105,27 @@ var jsoncmdtests = []struct {
 			Destination: "destination",
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "clearbanned",
+		f: func() (Cmd, error) {
+			return NewClearBannedCmd(testID)
+		},
+		result: &ClearBannedCmd{
+			id: testID,
+		},
+	},
+	{
+		name: "basic",
+		cmd:  "createauxblock",
+		f: func() (Cmd, error) {
+			return NewCreateAuxBlockCmd(testID, "someaddress")
+		},
+		result: &CreateAuxBlockCmd{
+			id:      testID,
+			Address: "someaddress",
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "createmultisig",
@@ -304,6 +325,29 @@ var jsoncmdtests = []struct {
 			Account: testAccount,
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "getauxblock",
+		f: func() (Cmd, error) {
+			return NewGetAuxBlockCmd(testID)
+		},
+		result: &GetAuxBlockCmd{
+			id: testID,
+		},
+	},
+	{
+		name: "+ submission",
+		cmd:  "getauxblock",
+		f: func() (Cmd, error) {
+			return NewGetAuxBlockCmd(testID, "somehash",
+				"lotsofhex")
+		},
+		result: &GetAuxBlockCmd{
+			id:     testID,
+			Hash:   "somehash",
+			AuxPow: "lotsofhex",
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "getbalance",
@@ -454,6 +498,16 @@ var jsoncmdtests = []struct {
 			},
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "getchaintips",
+		f: func() (Cmd, error) {
+			return NewGetChainTipsCmd(testID)
+		},
+		result: &GetChainTipsCmd{
+			id: testID,
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "getconnectioncount",
@@ -504,6 +558,27 @@ var jsoncmdtests = []struct {
 			id: testID,
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "getmempoolentry",
+		f: func() (Cmd, error) {
+			return NewGetMempoolEntryCmd(testID, "thisisatxid")
+		},
+		result: &GetMempoolEntryCmd{
+			id:   testID,
+			Txid: "thisisatxid",
+		},
+	},
+	{
+		name: "basic",
+		cmd:  "getmempoolinfo",
+		f: func() (Cmd, error) {
+			return NewGetMempoolInfoCmd(testID)
+		},
+		result: &GetMempoolInfoCmd{
+			id: testID,
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "getmininginfo",
@@ -985,6 +1060,16 @@ var jsoncmdtests = []struct {
 			id: testID,
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "listbanned",
+		f: func() (Cmd, error) {
+			return NewListBannedCmd(testID)
+		},
+		result: &ListBannedCmd{
+			id: testID,
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "listlockunspent",
@@ -1238,6 +1323,99 @@ var jsoncmdtests = []struct {
 			Comment:     "some comment",
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "name_filter",
+		f: func() (Cmd, error) {
+			return NewNameFilterCmd(testID, "", DefaultNameFilterMaxAge,
+				0, 0, false)
+		},
+		result: &NameFilterCmd{
+			id:     testID,
+			MaxAge: DefaultNameFilterMaxAge,
+		},
+	},
+	{
+		name: "default regexp + maxage",
+		cmd:  "name_filter",
+		f: func() (Cmd, error) {
+			return NewNameFilterCmd(testID, "", 5, 0, 0, false)
+		},
+		result: &NameFilterCmd{
+			id:     testID,
+			MaxAge: 5,
+		},
+	},
+	{
+		name: "all + stat",
+		cmd:  "name_filter",
+		f: func() (Cmd, error) {
+			return NewNameFilterCmd(testID, "^id/", 36000, 10, 20,
+				true)
+		},
+		result: &NameFilterCmd{
+			id:     testID,
+			Regexp: "^id/",
+			MaxAge: 36000,
+			From:   10,
+			NB:     20,
+			Stat:   true,
+		},
+	},
+	{
+		name: "basic",
+		cmd:  "name_history",
+		f: func() (Cmd, error) {
+			return NewNameHistoryCmd(testID, "d/example")
+		},
+		result: &NameHistoryCmd{
+			id:   testID,
+			Name: "d/example",
+		},
+	},
+	{
+		name: "basic",
+		cmd:  "name_pending",
+		f: func() (Cmd, error) {
+			return NewNamePendingCmd(testID)
+		},
+		result: &NamePendingCmd{
+			id: testID,
+		},
+	},
+	{
+		name: "basic",
+		cmd:  "name_scan",
+		f: func() (Cmd, error) {
+			return NewNameScanCmd(testID, "", DefaultNameScanMaxReturned)
+		},
+		result: &NameScanCmd{
+			id:          testID,
+			MaxReturned: DefaultNameScanMaxReturned,
+		},
+	},
+	{
+		name: "default start + max",
+		cmd:  "name_scan",
+		f: func() (Cmd, error) {
+			return NewNameScanCmd(testID, "", 10)
+		},
+		result: &NameScanCmd{
+			id:          testID,
+			MaxReturned: 10,
+		},
+	},
+	{
+		name: "basic",
+		cmd:  "name_show",
+		f: func() (Cmd, error) {
+			return NewNameShowCmd(testID, "d/example")
+		},
+		result: &NameShowCmd{
+			id:   testID,
+			Name: "d/example",
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "ping",
@@ -1449,6 +1627,43 @@ var jsoncmdtests = []struct {
 			Account: "account name",
 		},
 	},
+	{
+		name: "basic add",
+		cmd:  "setban",
+		f: func() (Cmd, error) {
+			return NewSetBanCmd(testID, "10.0.0.0/24", "add")
+		},
+		result: &SetBanCmd{
+			id:     testID,
+			Subnet: "10.0.0.0/24",
+			SubCmd: "add",
+		},
+	},
+	{
+		name: "basic add + optional",
+		cmd:  "setban",
+		f: func() (Cmd, error) {
+			return NewSetBanCmd(testID, "10.0.0.0/24", "add", 3600)
+		},
+		result: &SetBanCmd{
+			id:      testID,
+			Subnet:  "10.0.0.0/24",
+			SubCmd:  "add",
+			BanTime: 3600,
+		},
+	},
+	{
+		name: "basic remove",
+		cmd:  "setban",
+		f: func() (Cmd, error) {
+			return NewSetBanCmd(testID, "10.0.0.1", "remove")
+		},
+		result: &SetBanCmd{
+			id:     testID,
+			Subnet: "10.0.0.1",
+			SubCmd: "remove",
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "setgenerate",
@@ -1552,6 +1767,19 @@ var jsoncmdtests = []struct {
 			id: testID,
 		},
 	},
+	{
+		name: "basic",
+		cmd:  "submitauxblock",
+		f: func() (Cmd, error) {
+			return NewSubmitAuxBlockCmd(testID, "somehash",
+				"lotsofhex")
+		},
+		result: &SubmitAuxBlockCmd{
+			id:     testID,
+			Hash:   "somehash",
+			AuxPow: "lotsofhex",
+		},
+	},
 	{
 		name: "basic",
 		cmd:  "submitblock",
@@ -1721,6 +1949,8 @@ func TestHelps(t *testing.T) {
 		"addmultisigaddress",
 		"addnode",
 		"backupwallet",
+		"clearbanned",
+		"createauxblock",
 		"createmultisig",
 		"createrawtransaction",
 		"debuglevel",
@@ -1735,20 +1965,26 @@ func TestHelps(t *testing.T) {
 		"getaccountaddress",
 		"getaddednodeinfo",
 		"getaddressesbyaccount",
+		"getauxblock",
 		"getbalance",
 		"getbestblockhash",
 		"getblock",
+		"getblockchaininfo",
 		"getblockcount",
 		"getblockhash",
 		"getblocktemplate",
+		"getchaintips",
 		"getconnectioncount",
 		"getdifficulty",
 		"getgenerate",
 		"gethashespersec",
 		"getinfo",
+		"getmempoolentry",
+		"getmempoolinfo",
 		"getmininginfo",
 		"getnettotals",
 		"getnetworkhashps",
+		"getnetworkinfo",
 		"getnewaddress",
 		"getpeerinfo",
 		"getrawchangeaddress",
@@ -1767,6 +2003,7 @@ func TestHelps(t *testing.T) {
 		"keypoolrefill",
 		"listaccounts",
 		"listaddressgroupings",
+		"listbanned",
 		"listlockunspent",
 		"listreceivedbyaccount",
 		"listreceivedbyaddress",
@@ -1775,6 +2012,11 @@ func TestHelps(t *testing.T) {
 		"listunspent",
 		"lockunspent",
 		"move",
+		"name_filter",
+		"name_history",
+		"name_pending",
+		"name_scan",
+		"name_show",
 		"ping",
 		"reconsiderblock",
 		"searchrawtransactions",
@@ -1783,11 +2025,13 @@ func TestHelps(t *testing.T) {
 		"sendrawtransaction",
 		"sendtoaddress",
 		"setaccount",
+		"setban",
 		"setgenerate",
 		"settxfee",
 		"signmessage",
 		"signrawtransaction",
 		"stop",
+		"submitauxblock",
 		"submitblock",
 		"validateaddress",
 		"verifychain",
@@ -59,15 +59,57 @@ type GetAddedNodeInfoResult struct {
 	Addresses *[]GetAddedNodeInfoResultAddr `json:"addresses,omitempty"`
 }
 
+// GetAuxBlockResult models the data from the getauxblock and createauxblock
+// commands.
+type GetAuxBlockResult struct {
+	Hash              string `json:"hash"`
+	ChainID           int32  `json:"chainid"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	CoinbaseValue     int64  `json:"coinbasevalue"`
+	Bits              string `json:"bits"`
+	Height            int64  `json:"height"`
+	Target            string `json:"_target"`
+}
+
 // GetBlockChainInfoResult models the data returned from the getblockchaininfo
 // command.
 type GetBlockChainInfoResult struct {
-	Chain                string  `json:"chain"`
-	Blocks               int32   `json:"blocks"`
-	BestBlockHash        string  `json:"bestblockhash"`
-	Difficulty           float64 `json:"difficulty"`
-	VerificationProgress float64 `json:"verificationprogress"`
-	ChainWork            string  `json:"chainwork"`
+	Chain                string                `json:"chain"`
+	Blocks               int32                 `json:"blocks"`
+	BestBlockHash        string                `json:"bestblockhash"`
+	Difficulty           float64               `json:"difficulty"`
+	MedianTime           int64                 `json:"mediantime"`
+	VerificationProgress float64               `json:"verificationprogress"`
+	ChainWork            string                `json:"chainwork"`
+	Pruned               bool                  `json:"pruned"`
+	SoftForks            []SoftForkDescription `json:"softforks,omitempty"`
+	AuxPow               *AuxPowDescription    `json:"auxpow,omitempty"`
+}
+
+// SoftForkDescription models the progress of a block version upgrade in the
+// softforks field of the getblockchaininfo command.
+type SoftForkDescription struct {
+	ID      string           `json:"id"`
+	Version int32            `json:"version"`
+	Enforce SoftForkMajority `json:"enforce"`
+	Reject  SoftForkMajority `json:"reject"`
+}
+
+// SoftForkMajority models the enforce and reject fields of a
+// SoftForkDescription.
+type SoftForkMajority struct {
+	Status   bool   `json:"status"`
+	Found    uint64 `json:"found"`
+	Required uint64 `json:"required"`
+	Window   uint64 `json:"window"`
+}
+
+// AuxPowDescription models the auxpow field of the getblockchaininfo command.
+type AuxPowDescription struct {
+	Active        bool  `json:"active"`
+	ChainID       int32 `json:"chainid"`
+	StartHeight   int64 `json:"startheight"`
+	StrictChainID bool  `json:"strictchainid"`
 }
 
 // GetBlockTemplateResultTx models the transactions field of the
@@ -124,10 +166,19 @@ type GetBlockTemplateResult struct {
 	RejectReasion string   `json:"reject-reason,omitempty"`
 }
 
+// GetChainTipsResult models the data returned from the getchaintips command.
+type GetChainTipsResult struct {
+	Height    int64  `json:"height"`
+	Hash      string `json:"hash"`
+	BranchLen int64  `json:"branchlen"`
+	Status    string `json:"status"`
+}
+
 // GetNetworkInfoResult models the data returned from the getnetworkinfo command.
 type GetNetworkInfoResult struct {
 	Version         int32                  `json:"version"`
 	ProtocolVersion int32                  `json:"protocolversion"`
+	LocalServices   string                 `json:"localservices"`
 	TimeOffset      int64                  `json:"timeoffset"`
 	Connections     int32                  `json:"connections"`
 	Networks        []NetworksResult       `json:"networks"`
@@ -154,6 +205,35 @@ type GetPeerInfoResult struct {
 	CurrentHeight  int32   `json:"currentheight,omitempty"`
 	BanScore       int32   `json:"banscore,omitempty"`
 	SyncNode       bool    `json:"syncnode"`
+	Whitelisted    bool    `json:"whitelisted"`
+}
+
+// GetMempoolEntryResult models the data returned from the getmempoolentry
+// command.
+type GetMempoolEntryResult struct {
+	Size             int32    `json:"size"`
+	Fee              float64  `json:"fee"`
+	Time             int64    `json:"time"`
+	Height           int64    `json:"height"`
+	StartingPriority float64  `json:"startingpriority"`
+	CurrentPriority  float64  `json:"currentpriority"`
+	DescendantCount  int64    `json:"descendantcount"`
+	DescendantSize   int64    `json:"descendantsize"`
+	DescendantFees   int64    `json:"descendantfees"`
+	AncestorCount    int64    `json:"ancestorcount"`
+	AncestorSize     int64    `json:"ancestorsize"`
+	AncestorFees     int64    `json:"ancestorfees"`
+	Depends          []string `json:"depends"`
+}
+
+// GetMempoolInfoResult models the data returned from the getmempoolinfo
+// command.
+type GetMempoolInfoResult struct {
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	Usage         int64   `json:"usage"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
 }
 
 // GetRawMempoolResult models the data returned from the getrawmempool command.
@@ -164,6 +244,9 @@ type GetRawMempoolResult struct {
 	Height           int64    `json:"height"`
 	StartingPriority float64  `json:"startingpriority"`
 	CurrentPriority  float64  `json:"currentpriority"`
+	DescendantCount  int64    `json:"descendantcount"`
+	DescendantSize   int64    `json:"descendantsize"`
+	DescendantFees   int64    `json:"descendantfees"`
 	Depends          []string `json:"depends"`
 }
 
@@ -202,6 +285,19 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
+type GetTxOutSetInfoResult struct {
+	Height          int64   `json:"height"`
+	BestBlock       string  `json:"bestblock"`
+	Transactions    int64   `json:"transactions"`
+	TxOuts          int64   `json:"txouts"`
+	BytesSerialized int64   `json:"bytes_serialized"`
+	HashSerialized  string  `json:"hash_serialized"`
+	TotalAmount     float64 `json:"total_amount"`
+	NameTxOuts      int64   `json:"name_txouts"`
+	NameAmount      float64 `json:"name_amount"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
 	TotalBytesRecv uint64 `json:"totalbytesrecv"`
@@ -319,6 +415,9 @@ type InfoResult struct {
 	UnlockedUntil   int64   `json:"unlocked_until,omitempty"`
 	PaytxFee        float64 `json:"paytxfee,omitempty"`
 	RelayFee        float64 `json:"relayfee"`
+	MempoolBytes    int64   `json:"mempoolbytes"`
+	MaxMempool      int64   `json:"maxmempool"`
+	MempoolMinFee   float64 `json:"mempoolminfee"`
 	Errors          string  `json:"errors"`
 }
 
@@ -349,6 +448,14 @@ type LocalAddressesResult struct {
 	Score   int32  `json:"score"`
 }
 
+// ListBannedResult models the data from the listbanned command.
+type ListBannedResult struct {
+	Address     string `json:"address"`
+	BannedUntil int64  `json:"banned_until"`
+	BanCreated  int64  `json:"ban_created"`
+	BanReason   string `json:"ban_reason"`
+}
+
 // ListReceivedByAccountResult models the data from the listreceivedbyaccount
 // command.
 type ListReceivedByAccountResult struct {
@@ -470,6 +577,12 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 	// generate put the results in the proper structure.
 	// We handle the error condition after the switch statement.
 	switch cmd {
+	case "createauxblock":
+		var res *GetAuxBlockResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
 	case "createmultisig":
 		var res *CreateMultiSigResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -499,6 +612,23 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 				result.Result = res
 			}
 		}
+	case "getauxblock":
+		// getauxblock can either return a JSON object or a boolean
+		// depending on whether or not an auxpow was provided.  Choose
+		// the right form accordingly.
+		if bytes.IndexByte(objmap["result"], '{') > -1 {
+			var res *GetAuxBlockResult
+			err = json.Unmarshal(objmap["result"], &res)
+			if err == nil {
+				result.Result = res
+			}
+		} else {
+			var res bool
+			err = json.Unmarshal(objmap["result"], &res)
+			if err == nil {
+				result.Result = res
+			}
+		}
 	case "getinfo":
 		var res *InfoResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -528,6 +658,12 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 		if err == nil {
 			result.Result = res
 		}
+	case "getchaintips":
+		var res []GetChainTipsResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
 	case "getnettotals":
 		var res *GetNetTotalsResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -575,6 +711,18 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 		if err == nil {
 			result.Result = res
 		}
+	case "getmempoolentry":
+		var res *GetMempoolEntryResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
+	case "getmempoolinfo":
+		var res *GetMempoolInfoResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
 	case "getmininginfo":
 		var res *GetMiningInfoResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -622,6 +770,12 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 			}
 			result.Result = res
 		}
+	case "gettxoutsetinfo":
+		var res *GetTxOutSetInfoResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
 	case "getwork":
 		// getwork can either return a JSON object or a boolean
 		// depending on whether or not data was provided.  Choose the
@@ -657,6 +811,12 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 		if err == nil {
 			result.Result = res
 		}
+	case "listbanned":
+		var res []ListBannedResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
 	case "listreceivedbyaccount":
 		var res []ListReceivedByAccountResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -713,6 +873,24 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 	// BEGIN NAMECOIN RESULTS
 	// ----------------------
 
+	case "name_filter":
+		// name_filter either returns a list of names or a JSON object
+		// with stats depending on its stat argument.  Choose the right
+		// form accordingly.
+		if bytes.HasPrefix(bytes.TrimSpace(objmap["result"]), []byte("{")) {
+			var res *NameFilterStatResult
+			err = json.Unmarshal(objmap["result"], &res)
+			if err == nil {
+				result.Result = res
+			}
+		} else {
+			var res []*NameFilterResult
+			err = json.Unmarshal(objmap["result"], &res)
+			if err == nil {
+				result.Result = res
+			}
+		}
+
 	case "name_history":
 		var res []NameInfoResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -727,6 +905,13 @@ func ReadResultCmd(cmd string, message []byte) (Reply, error) {
 			result.Result = res
 		}
 
+	case "name_pending":
+		var res []*NamePendingResult
+		err = json.Unmarshal(objmap["result"], &res)
+		if err == nil {
+			result.Result = res
+		}
+
 	case "name_scan":
 		var res []*NameScanResult
 		err = json.Unmarshal(objmap["result"], &res)
@@ -27,34 +27,59 @@ var resulttests = []struct {
 	{"anycommand", []byte(`{"result":"test","id":1}`), false, false},
 	{"anycommand", []byte(`{some junk}`), false, false},
 	{"anycommand", []byte(`{"error":null,"result":null,"id":"test"}`), false, true},
+	{"createauxblock", []byte(`{"error":null,"id":1,"result":{"hash":"hash","chainid":1,"height":2}}`), false, true},
+	{"createauxblock", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"createmultisig", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"createmultisig", []byte(`{"error":null,"id":1,"result":{"address":"something","redeemScript":"else"}}`), false, true},
 	{"decodescript", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"decodescript", []byte(`{"error":null,"id":1,"result":{"Asm":"something"}}`), false, true},
+	{"getauxblock", []byte(`{"error":null,"id":1,"result":{"hash":"hash","chainid":1,"_target":"target"}}`), false, true},
+	{"getauxblock", []byte(`{"error":null,"id":1,"result":true}`), false, true},
+	{"getauxblock", []byte(`{"error":null,"id":1,"result":"junk"}`), false, false},
 	{"getinfo", []byte(`{"error":null,"result":null,"id":"test"}`), false, true},
 	{"getinfo", []byte(`{"error":null,"result":null}`), false, false},
 	{"getinfo", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getblock", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getblock", []byte(`{"result":{"hash":"000000","confirmations":16007,"size":325648},"error":null,"id":1}`), false, true},
 	{"getblockchaininfo", []byte(`{"result":{"chain":"hex","blocks":250000,"bestblockhash":"hash"},"error":null,"id":1}`), false, true},
+	{"getblockchaininfo", []byte(`{"result":{"chain":"mainnet","blocks":250000,"bestblockhash":"hash","mediantime":1430000000,"pruned":false,"softforks":[{"id":"bip34","version":2,"enforce":{"status":true,"found":1000,"required":750,"window":1000},"reject":{"status":true,"found":1000,"required":950,"window":1000}}],"auxpow":{"active":true,"chainid":1,"startheight":19200,"strictchainid":true}},"error":null,"id":1}`), false, true},
 	{"getblockchaininfo", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
+	{"getchaintips", []byte(`{"result":[{"height":250000,"hash":"hash","branchlen":0,"status":"active"}],"error":null,"id":1}`), false, true},
+	{"getchaintips", []byte(`{"error":null,"id":1,"result":{"a":"b"}}`), false, false},
 	{"getnetworkinfo", []byte(`{"result":{"version":100,"protocolversion":70002},"error":null,"id":1}`), false, true},
+	{"getnetworkinfo", []byte(`{"result":{"version":100,"protocolversion":70002,"localservices":"00000001","timeoffset":0,"connections":8,"networks":[{"name":"ipv4","limited":false,"reachable":true,"proxy":""},{"name":"onion","limited":true,"reachable":false,"proxy":""}],"relayfee":0.00001,"localaddresses":[{"address":"204.124.1.1","port":8334,"score":1}]},"error":null,"id":1}`), false, true},
 	{"getnetworkinfo", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getrawtransaction", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getrawtransaction", []byte(`{"error":null,"id":1,"result":{"hex":"somejunk","version":1}}`), false, true},
 	{"gettransaction", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
+	{"gettxoutsetinfo", []byte(`{"result":{"height":250000,"bestblock":"hash","transactions":1000,"txouts":2000,"bytes_serialized":100000,"hash_serialized":"hash","total_amount":10000000,"name_txouts":100,"name_amount":1},"error":null,"id":1}`), false, true},
+	{"gettxoutsetinfo", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"gettransaction", []byte(`{"error":null,"id":1,"result":{"Amount":0.0}}`), false, true},
 	{"decoderawtransaction", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"decoderawtransaction", []byte(`{"error":null,"id":1,"result":{"Txid":"something"}}`), false, true},
 	{"getaddressesbyaccount", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getaddressesbyaccount", []byte(`{"error":null,"id":1,"result":["test"]}`), false, true},
+	{"getmempoolentry", []byte(`{"result":{"size":226,"fee":0.0001,"time":1387992789,"height":276836,"startingpriority":0,"currentpriority":0,"descendantcount":1,"descendantsize":226,"descendantfees":10000,"ancestorcount":2,"ancestorsize":451,"ancestorfees":20000,"depends":["aa96f672fcc5a1ec6a08a94aa46d6b789799c87bd6542967da25a96b2dee0afb"]},"error":null,"id":1}`), false, true},
+	{"getmempoolentry", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
+	{"getmempoolinfo", []byte(`{"result":{"size":2,"bytes":451,"usage":451,"maxmempool":300000000,"mempoolminfee":0},"error":null,"id":1}`), false, true},
+	{"getmempoolinfo", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getmininginfo", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"getmininginfo", []byte(`{"error":null,"id":1,"result":{"generate":true}}`), false, true},
 	{"gettxout", []byte(`{"error":null,"id":1,"result":{"bestblock":"a","value":1.0}}`), false, true},
+	{"listbanned", []byte(`{"result":[{"address":"10.0.0.0/24","banned_until":1445000000,"ban_created":1444900000,"ban_reason":"manually added"}],"error":null,"id":1}`), false, true},
+	{"listbanned", []byte(`{"error":null,"id":1,"result":{"a":"b"}}`), false, false},
 	{"listreceivedbyaddress", []byte(`{"error":null,"id":1,"result":[{"a"}]}`), false, false},
 	{"listreceivedbyaddress", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, true},
 	{"listsinceblock", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"listsinceblock", []byte(`{"error":null,"id":1,"result":{"lastblock":"something"}}`), false, true},
+	{"name_filter", []byte(`{"error":null,"id":1,"result":[{"name":"d/a","value":"v","registered_at":1,"expires_in":2}]}`), false, true},
+	{"name_filter", []byte(`{"error":null,"id":1,"result":{"blocks":100,"count":2}}`), false, true},
+	{"name_filter", []byte(`{"error":null,"id":1,"result":"junk"}`), false, false},
+	{"name_pending", []byte(`{"error":null,"id":1,"result":[{"name":"d/a","txid":"hash","op":"name_update","value":"v"}]}`), false, true},
+	{"name_pending", []byte(`{"error":null,"id":1,"result":{"name":"d/a"}}`), false, false},
+	{"name_scan", []byte(`{"error":null,"id":1,"result":[{"name":"d/a","expired":1}]}`), false, true},
+	{"name_show", []byte(`{"error":null,"id":1,"result":{"name":"d/a","value":"v","txid":"hash","address":"addr","expires_in":2}}`), false, true},
+	{"name_show", []byte(`{"error":null,"id":1,"result":[{"a":"b"}]}`), false, false},
 	{"validateaddress", []byte(`{"error":null,"id":1,"result":{"isvalid":false}}`), false, true},
 	{"validateaddress", []byte(`{"error":null,"id":1,"result":{false}}`), false, false},
 	{"signrawtransaction", []byte(`{"error":null,"id":1,"result":{"hex":"something","complete":false}}`), false, true},
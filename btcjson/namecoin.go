@@ -183,6 +183,10 @@ func (n *NameDebug1Cmd) UnmarshalJSON(b []byte) error {
 
 var _ Cmd = &NameFilterCmd{}
 
+// DefaultNameFilterMaxAge is the number of blocks name_filter looks back when
+// no maxage is given.
+const DefaultNameFilterMaxAge = 36000
+
 // NameFilterCmd implements "name_filter"
 //
 // name_filter [[[[[regexp] maxage=36000] from=0] nb=0] stat]
@@ -227,28 +231,23 @@ func (n *NameFilterCmd) Method() string {
 
 // MarshalJSON return the JSON encoding of cmd. Part of the Cmd interface.
 func (n *NameFilterCmd) MarshalJSON() ([]byte, error) {
-	params := []interface{}{}
-
-	if n.Regexp != "" {
-		params = append(params, n.Regexp)
-
-		if n.MaxAge != 0 {
-			params = append(params, n.MaxAge)
-
-			if n.From != 0 {
-				params = append(params, n.From)
-
-				if n.NB != 0 {
-					params = append(params, n.NB)
-
-					if n.Stat {
-						// Based on the documentation, it looks like we pass
-						// the literal string "stat".
-						params = append(params, "stat")
-					}
-				}
-			}
-		}
+	// Every parameter is positional, so trailing parameters are only left
+	// out while they hold their default values.
+	params := []interface{}{n.Regexp, n.MaxAge, n.From, n.NB}
+	switch {
+	case n.Stat:
+		// Based on the documentation, we pass the literal string
+		// "stat".
+		params = append(params, "stat")
+	case n.NB != 0:
+	case n.From != 0:
+		params = params[:3]
+	case n.MaxAge != DefaultNameFilterMaxAge:
+		params = params[:2]
+	case n.Regexp != "":
+		params = params[:1]
+	default:
+		params = params[:0]
 	}
 
 	raw, err := NewRawCmd(n.id, n.Method(), params)
@@ -272,7 +271,7 @@ func (n *NameFilterCmd) UnmarshalJSON(b []byte) error {
 
 	var (
 		regexp string
-		maxage int
+		maxage = DefaultNameFilterMaxAge
 		from   int
 		nb     int
 		stat   string
@@ -298,7 +297,7 @@ func (n *NameFilterCmd) UnmarshalJSON(b []byte) error {
 					}
 
 					if len(r.Params) > 4 {
-						if err := json.Unmarshal(r.Params[0], &stat); err != nil {
+						if err := json.Unmarshal(r.Params[4], &stat); err != nil {
 							return fmt.Errorf("fifth parameter 'stat' must be a string: %v", err)
 						}
 					}
@@ -307,7 +306,8 @@ func (n *NameFilterCmd) UnmarshalJSON(b []byte) error {
 		}
 	}
 
-	statBool := stat != ""
+	// Like namecoind, only the literal string "stat" selects the stats.
+	statBool := stat == "stat"
 
 	newCmd, err := NewNameFilterCmd(r.Id, regexp, maxage, from, nb, statBool)
 	if err != nil {
@@ -318,6 +318,23 @@ func (n *NameFilterCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// NameFilterResult models a single name returned by name_filter.  Expired
+// names only hold the name and Expired.
+type NameFilterResult struct {
+	Name         string `json:"name"`
+	Value        string `json:"value,omitempty"`
+	RegisteredAt int    `json:"registered_at,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	Expired      int    `json:"expired,omitempty"`
+}
+
+// NameFilterStatResult models the data returned by name_filter when stats are
+// requested.
+type NameFilterStatResult struct {
+	Blocks int32 `json:"blocks"`
+	Count  int   `json:"count"`
+}
+
 var _ Cmd = &NameFirstUpdateCmd{}
 
 // NameFirstUpdateCmd implements "name_firstupdate"
@@ -503,13 +520,17 @@ func (n *NameHistoryCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// NameScanResult models a single name returned by name_scan.  Expired names
+// only hold the name and Expired.
 type NameScanResult struct {
 	Name      string `json:"name"`
-	Value     string `json:"value"`
-	Expired   int    `json:"expired"`
-	ExpiresIn int    `json:"expires_in"`
+	Value     string `json:"value,omitempty"`
+	Expired   int    `json:"expired,omitempty"`
+	ExpiresIn int    `json:"expires_in,omitempty"`
 }
 
+// NameInfoResult models the data returned by name_show and the entries
+// returned by name_history.
 type NameInfoResult struct {
 	Name      string
 	Value     string
@@ -527,9 +548,27 @@ type nameInfoResult struct {
 	TX        string `json:"txid"`
 	Address   string `json:"address"`
 	ExpiresIn int    `json:"expires_in"`
-	Expired   int    `json:"expired"`
+	Expired   int    `json:"expired,omitempty"`
+}
+
+// MarshalJSON returns the JSON encoding of n.  Like namecoind, the expired
+// field is only present once the name has expired.
+func (n NameInfoResult) MarshalJSON() ([]byte, error) {
+	res := nameInfoResult{
+		Name:      n.Name,
+		Value:     n.Value,
+		TX:        n.TX,
+		Address:   n.Address,
+		ExpiresIn: n.ExpiresIn,
+	}
+	if n.Expired {
+		res.Expired = 1
+	}
+
+	return json.Marshal(&res)
 }
 
+// UnmarshalJSON unmarshals the JSON encoding of a name_show result into n.
 func (n *NameInfoResult) UnmarshalJSON(b []byte) error {
 	var res *nameInfoResult
 	err := json.Unmarshal(b, &res)
@@ -737,8 +776,21 @@ func (n *NamePendingCmd) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// NamePendingResult models a single pending name operation returned by
+// name_pending.
+type NamePendingResult struct {
+	Name  string `json:"name"`
+	TX    string `json:"txid"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
 var _ Cmd = &NameScanCmd{}
 
+// DefaultNameScanMaxReturned is the number of names name_scan returns when no
+// maximum is given.
+const DefaultNameScanMaxReturned = 500
+
 // NameScanCmd implements "name_scan"
 //
 // name_scan [<start-name>] [<max-returned>]
@@ -771,10 +823,10 @@ func (n *NameScanCmd) Method() string {
 func (n *NameScanCmd) MarshalJSON() ([]byte, error) {
 	params := []interface{}{}
 
-	if n.StartName != "" {
+	if n.StartName != "" || n.MaxReturned != DefaultNameScanMaxReturned {
 		params = append(params, n.StartName)
 
-		if n.MaxReturned != 0 {
+		if n.MaxReturned != DefaultNameScanMaxReturned {
 			params = append(params, n.MaxReturned)
 		}
 	}
@@ -799,7 +851,7 @@ func (n *NameScanCmd) UnmarshalJSON(b []byte) error {
 	}
 
 	var startName string
-	var maxReturned int
+	maxReturned := DefaultNameScanMaxReturned
 	if len(r.Params) > 0 {
 		if err := json.Unmarshal(r.Params[0], &startName); err != nil {
 			return fmt.Errorf("first parameter 'startname' must be a string: %v", err)
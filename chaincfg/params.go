@@ -78,6 +78,38 @@ type Params struct {
 	// The number of nodes to check.  This is part of BIP0034.
 	BlockUpgradeNumToCheck uint64
 
+	// AuxPowChainID is the merged mining chain ID blocks of the network
+	// encode in their version.
+	AuxPowChainID int32
+
+	// StrictChainID rejects blocks which do not encode AuxPowChainID in
+	// their version unless they use the legacy version 1, merged mined
+	// blocks whose parent block encodes it, and legacy blocks from
+	// AuxPowStartHeight on.
+	StrictChainID bool
+
+	// AuxPowStartHeight is the height of the first block which is allowed
+	// to be merged mined.
+	AuxPowStartHeight int64
+
+	// Height from which outputs holding a name must lock at least the
+	// minimum name amount.  Older blocks contain "greedy" names which
+	// lock less.
+	MinNameAmountHeight int64
+
+	// Height of the first block whose name operations are validated.
+	// Older blocks contain name operations which are invalid under the
+	// current rules, so they are applied to the names without being
+	// checked.
+	NameRulesHeight int64
+
+	// Number of blocks after its last update at which a name expires.
+	// The depth starts out at InitialNameExpirationDepth and, from twice
+	// that height on, rises by one block per block until it reaches
+	// NameExpirationDepth.
+	InitialNameExpirationDepth int64
+	NameExpirationDepth        int64
+
 	// Mempool parameters
 	RelayNonStdTxs bool
 
@@ -132,6 +164,17 @@ var MainNetParams = Params{
 	BlockRejectNumRequired:  950,
 	BlockUpgradeNumToCheck:  1000,
 
+	// AuxPow parameters
+	AuxPowChainID:     1,
+	StrictChainID:     true,
+	AuxPowStartHeight: 19200,
+
+	// Name parameters
+	MinNameAmountHeight:        212500,
+	NameRulesHeight:            216117,
+	InitialNameExpirationDepth: 12000,
+	NameExpirationDepth:        36000,
+
 	// Mempool parameters
 	RelayNonStdTxs: false,
 
@@ -178,6 +221,17 @@ var RegressionNetParams = Params{
 	BlockRejectNumRequired:  950,
 	BlockUpgradeNumToCheck:  1000,
 
+	// AuxPow parameters
+	AuxPowChainID:     1,
+	StrictChainID:     true,
+	AuxPowStartHeight: 0,
+
+	// Name parameters
+	MinNameAmountHeight:        0,
+	NameRulesHeight:            0,
+	InitialNameExpirationDepth: 30,
+	NameExpirationDepth:        30,
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -226,6 +280,17 @@ var TestNet3Params = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// AuxPow parameters
+	AuxPowChainID:     1,
+	StrictChainID:     false,
+	AuxPowStartHeight: 0,
+
+	// Name parameters
+	MinNameAmountHeight:        0,
+	NameRulesHeight:            0,
+	InitialNameExpirationDepth: 12000,
+	NameExpirationDepth:        36000,
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -276,6 +341,17 @@ var SimNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// AuxPow parameters
+	AuxPowChainID:     1,
+	StrictChainID:     true,
+	AuxPowStartHeight: 0,
+
+	// Name parameters
+	MinNameAmountHeight:        0,
+	NameRulesHeight:            0,
+	InitialNameExpirationDepth: 30,
+	NameExpirationDepth:        30,
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -50,6 +50,7 @@ const outpointArrayStr = `"[{"txid":"id","vout":n},...]"`
 var commandHandlers = map[string]*handlerData{
 	"addmultisigaddress":    {2, 1, displayGeneric, []conversionHandler{toInt, nil, nil}, makeAddMultiSigAddress, "<numrequired> <[\"pubkey\",...]> [account]"},
 	"addnode":               {2, 0, displayJSONDump, nil, makeAddNode, "<ip> <add/remove/onetry>"},
+	"clearbanned":           {0, 0, displayGeneric, nil, makeClearBanned, ""},
 	"createencryptedwallet": {1, 0, displayGeneric, nil, makeCreateEncryptedWallet, "<passphrase>"},
 	"createnewaccount":      {1, 0, displayGeneric, nil, makeCreateNewAccount, "<account>"},
 	"createrawtransaction":  {2, 0, displayGeneric, nil, makeCreateRawTransaction, outpointArrayStr + " " + "\"{\"address\":amount,...}\""},
@@ -75,6 +76,8 @@ var commandHandlers = map[string]*handlerData{
 	"getgenerate":           {0, 0, displayGeneric, nil, makeGetGenerate, ""},
 	"gethashespersec":       {0, 0, displayGeneric, nil, makeGetHashesPerSec, ""},
 	"getinfo":               {0, 0, displayJSONDump, nil, makeGetInfo, ""},
+	"getmempoolentry":       {1, 0, displayJSONDump, nil, makeGetMempoolEntry, "<txid>"},
+	"getmempoolinfo":        {0, 0, displayJSONDump, nil, makeGetMempoolInfo, ""},
 	"getmininginfo":         {0, 0, displayJSONDump, nil, makeGetMiningInfo, ""},
 	"getnetworkhashps":      {0, 2, displayGeneric, []conversionHandler{toInt, toInt}, makeGetNetworkHashPS, "[blocks height]"},
 	"getnettotals":          {0, 0, displayJSONDump, nil, makeGetNetTotals, ""},
@@ -100,6 +103,7 @@ var commandHandlers = map[string]*handlerData{
 	"listaddressgroupings":  {0, 0, displayJSONDump, nil, makeListAddressGroupings, ""},
 	"listreceivedbyaccount": {0, 2, displayJSONDump, []conversionHandler{toInt, toBool}, makeListReceivedByAccount, "[minconf] [includeempty]"},
 	"listreceivedbyaddress": {0, 2, displayJSONDump, []conversionHandler{toInt, toBool}, makeListReceivedByAddress, "[minconf] [includeempty]"},
+	"listbanned":            {0, 0, displayJSONDump, nil, makeListBanned, ""},
 	"listlockunspent":       {0, 0, displayJSONDump, nil, makeListLockUnspent, ""},
 	"listsinceblock":        {0, 2, displayJSONDump, []conversionHandler{nil, toInt}, makeListSinceBlock, "[blockhash] [minconf=10]"},
 	"listtransactions":      {0, 3, displayJSONDump, []conversionHandler{nil, toInt, toInt}, makeListTransactions, "[account] [count=10] [from=0]"},
@@ -113,6 +117,7 @@ var commandHandlers = map[string]*handlerData{
 	"sendmany":               {2, 2, displayGeneric, []conversionHandler{nil, nil, toInt, nil}, makeSendMany, "<account> <{\"address\":amount,...}> [minconf=1] [comment]"},
 	"sendrawtransaction":     {1, 0, displayGeneric, nil, makeSendRawTransaction, "<hextx>"},
 	"sendtoaddress":          {2, 2, displayGeneric, []conversionHandler{nil, toSatoshi, nil, nil}, makeSendToAddress, "<address> <amount> [comment] [comment-to]"},
+	"setban":                 {2, 1, displayGeneric, []conversionHandler{nil, nil, toInt64}, makeSetBan, "<subnet> <add/remove> [bantime]"},
 	"setgenerate":            {1, 1, displayGeneric, []conversionHandler{toBool, toInt}, makeSetGenerate, "<generate> [genproclimit]"},
 	"settxfee":               {1, 0, displayGeneric, []conversionHandler{toSatoshi}, makeSetTxFee, "<amount>"},
 	"signmessage":            {2, 2, displayGeneric, nil, makeSignMessage, "<address> <message>"},
@@ -240,6 +245,11 @@ func makeAddNode(args []interface{}) (btcjson.Cmd, error) {
 		args[1].(string))
 }
 
+// makeClearBanned generates the cmd structure for clearbanned commands.
+func makeClearBanned(args []interface{}) (btcjson.Cmd, error) {
+	return btcjson.NewClearBannedCmd("btcctl")
+}
+
 // makeCreateEncryptedWallet generates the cmd structure for
 // createencryptedwallet commands.
 func makeCreateEncryptedWallet(args []interface{}) (btcjson.Cmd, error) {
@@ -445,6 +455,16 @@ func makeGetInfo(args []interface{}) (btcjson.Cmd, error) {
 	return btcjson.NewGetInfoCmd("btcctl")
 }
 
+// makeGetMempoolEntry generates the cmd structure for getmempoolentry commands.
+func makeGetMempoolEntry(args []interface{}) (btcjson.Cmd, error) {
+	return btcjson.NewGetMempoolEntryCmd("btcctl", args[0].(string))
+}
+
+// makeGetMempoolInfo generates the cmd structure for getmempoolinfo commands.
+func makeGetMempoolInfo(args []interface{}) (btcjson.Cmd, error) {
+	return btcjson.NewGetMempoolInfoCmd("btcctl")
+}
+
 // makeGetMiningInfo generates the cmd structure for getmininginfo commands.
 func makeGetMiningInfo(args []interface{}) (btcjson.Cmd, error) {
 	return btcjson.NewGetMiningInfoCmd("btcctl")
@@ -672,6 +692,11 @@ func makeListReceivedByAddress(args []interface{}) (btcjson.Cmd, error) {
 	return btcjson.NewListReceivedByAddressCmd("btcctl", optargs...)
 }
 
+// makeListBanned generates the cmd structure for listbanned commands.
+func makeListBanned(args []interface{}) (btcjson.Cmd, error) {
+	return btcjson.NewListBannedCmd("btcctl")
+}
+
 // makeListLockUnspent generates the cmd structure for listlockunspent commands.
 func makeListLockUnspent(args []interface{}) (btcjson.Cmd, error) {
 	return btcjson.NewListLockUnspentCmd("btcctl")
@@ -822,6 +847,16 @@ func makeSendToAddress(args []interface{}) (btcjson.Cmd, error) {
 	return btcjson.NewSendToAddressCmd("btcctl", args[0].(string), args[1].(int64), args[2:]...)
 }
 
+// makeSetBan generates the cmd structure for setban commands.
+func makeSetBan(args []interface{}) (btcjson.Cmd, error) {
+	var optargs = make([]int64, 0, 1)
+	if len(args) > 2 {
+		optargs = append(optargs, args[2].(int64))
+	}
+	return btcjson.NewSetBanCmd("btcctl", args[0].(string),
+		args[1].(string), optargs...)
+}
+
 //  makeSetGenerate generates the cmd structure for setgenerate commands.
 func makeSetGenerate(args []interface{}) (btcjson.Cmd, error) {
 	var optargs = make([]int, 0, 1)
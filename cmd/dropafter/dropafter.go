@@ -130,6 +130,21 @@ func main() {
 		return
 	}
 
+	keepHeight, err := db.FetchBlockHeightBySha(&sha)
+	if err != nil {
+		log.Warnf("failed %v", err)
+		return
+	}
+
+	// Roll back the name database along with the dropped blocks.
+	for h := height; h > keepHeight; h-- {
+		err = db.DropNamesForBlock(h)
+		if err != nil {
+			log.Warnf("failed to drop names for block %v: %v", h, err)
+			return
+		}
+	}
+
 	err = db.DropAfterBlockBySha(&sha)
 	if err != nil {
 		log.Warnf("failed %v", err)
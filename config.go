@@ -33,11 +33,15 @@ const (
 	defaultLogFilename       = "nmcd.log"
 	defaultMaxPeers          = 125
 	defaultBanDuration       = time.Hour * 24
+	defaultBanThreshold      = 100
 	defaultMaxRPCClients     = 10
 	defaultMaxRPCWebsockets  = 25
 	defaultVerifyEnabled     = false
 	defaultDbType            = "leveldb"
 	defaultFreeTxRelayLimit  = 15.0
+	defaultMaxMempool        = 300000000
+	defaultMempoolExpiry     = time.Hour * 24 * 14
+	defaultOrphanTTL         = time.Minute * 20
 	defaultBlockMinSize      = 0
 	defaultBlockMaxSize      = 750000
 	blockMaxSizeMin          = 1000
@@ -75,6 +79,8 @@ type config struct {
 	Listeners          []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	MaxPeers           int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
 	BanDuration        time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
+	BanThreshold       uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers"`
+	Whitelists         []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned or rate limited (eg. 192.168.1.0/24 or ::1)"`
 	RPCUser            string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass            string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCListeners       []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
@@ -103,6 +109,9 @@ type config struct {
 	DebugLevel         string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp               bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	FreeTxRelayLimit   float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
+	MaxMempool         uint64        `long:"maxmempool" description:"Maximum size in bytes of the transaction memory pool -- The transactions paying the lowest fee rates are evicted when it is full"`
+	MempoolExpiry      time.Duration `long:"mempoolexpiry" description:"How long transactions are kept in the memory pool without being mined.  Valid time units are {s, m, h}.  Minimum 1 minute"`
+	OrphanTTL          time.Duration `long:"orphanttl" description:"How long orphan transactions are kept while waiting for the transactions they spend.  Valid time units are {s, m, h}.  Minimum 1 minute"`
 	Generate           bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	MiningAddrs        []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	BlockMinSize       uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
@@ -116,6 +125,7 @@ type config struct {
 	oniondial          func(string, string) (net.Conn, error)
 	dial               func(string, string) (net.Conn, error)
 	miningAddrs        []btcutil.Address
+	whitelists         []*net.IPNet
 }
 
 // serviceOptions defines the configuration options for btcd as a service on
@@ -305,6 +315,7 @@ func loadConfig() (*config, []string, error) {
 		DebugLevel:        defaultLogLevel,
 		MaxPeers:          defaultMaxPeers,
 		BanDuration:       defaultBanDuration,
+		BanThreshold:      defaultBanThreshold,
 		RPCMaxClients:     defaultMaxRPCClients,
 		RPCMaxWebsockets:  defaultMaxRPCWebsockets,
 		DataDir:           defaultDataDir,
@@ -313,6 +324,9 @@ func loadConfig() (*config, []string, error) {
 		RPCKey:            defaultRPCKeyFile,
 		RPCCert:           defaultRPCCertFile,
 		FreeTxRelayLimit:  defaultFreeTxRelayLimit,
+		MaxMempool:        defaultMaxMempool,
+		MempoolExpiry:     defaultMempoolExpiry,
+		OrphanTTL:         defaultOrphanTTL,
 		BlockMinSize:      defaultBlockMinSize,
 		BlockMaxSize:      defaultBlockMaxSize,
 		BlockPrioritySize: defaultBlockPrioritySize,
@@ -515,6 +529,22 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate any given whitelisted IP addresses and networks.
+	if len(cfg.Whitelists) > 0 {
+		cfg.whitelists = make([]*net.IPNet, 0, len(cfg.Whitelists))
+		for _, addr := range cfg.Whitelists {
+			ipnet, err := parseSubnet(addr)
+			if err != nil {
+				str := "%s: The whitelist value of '%s' is invalid"
+				err = fmt.Errorf(str, funcName, addr)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			cfg.whitelists = append(cfg.whitelists, ipnet)
+		}
+	}
+
 	// --addPeer and --connect do not mix.
 	if len(cfg.AddPeers) > 0 && len(cfg.ConnectPeers) > 0 {
 		str := "%s: the --addpeer and --connect options can not be " +
@@ -576,6 +606,34 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The memory pool must be able to hold at least a full block worth of
+	// transactions.
+	if cfg.MaxMempool < wire.MaxBlockPayload {
+		str := "%s: The maxmempool option must be at least %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, wire.MaxBlockPayload,
+			cfg.MaxMempool)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Don't allow memory pool expiry durations that are too short.
+	if cfg.MempoolExpiry < time.Minute {
+		str := "%s: The mempoolexpiry option may not be less than 1m -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.OrphanTTL < time.Minute {
+		str := "%s: The orphanttl option may not be less than 1m -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.OrphanTTL)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -24,6 +24,11 @@ var (
 	ErrDbDoesNotExist  = errors.New("non-existent database")
 	ErrDbUnknownType   = errors.New("non-existent database type")
 	ErrNotImplemented  = errors.New("method has not yet been implemented")
+	ErrNameMissing     = errors.New("requested name does not exist")
+
+	ErrNameIndexDoesNotExist = errors.New("name index hasn't been built up yet")
+	ErrNameIndexOutOfSync    = errors.New("name index doesn't match the " +
+		"newest block")
 )
 
 // AllShas is a special value that can be used as the final sha when requesting
@@ -99,6 +104,22 @@ type Db interface {
 	// which can be used to detect errors.
 	FetchUnSpentTxByShaList(txShaList []*wire.ShaHash) []*TxListReply
 
+	// ForEachUnspentTx calls the passed function with every transaction
+	// which has at least one unspent output, in the order the
+	// transactions appear in the block chain, and returns the hash and
+	// height of the most recent block the set of unspent transactions
+	// corresponds to.  Unless it is nil, the names function is called
+	// first with the current state of every name as of the same block.
+	// Blocks and their names are stored separately, so the walk waits a
+	// short while for the name index to catch up with the newest block and
+	// returns ErrNameIndexOutOfSync if it doesn't.
+	// Only the Sha, Tx, BlkSha, Height and TxSpent fields of the replies
+	// are set.  Iteration stops at the first error returned by either
+	// function, which is returned.  The database must not be modified
+	// from the passed functions.
+	ForEachUnspentTx(names func([]*NameEntry) error,
+		fn func(*TxListReply) error) (sha *wire.ShaHash, height int64, err error)
+
 	// InsertBlock inserts raw block and transaction data from a block
 	// into the database.  The first block inserted into the database
 	// will be treated as the genesis block.  Every subsequent block insert
@@ -139,6 +160,72 @@ type Db interface {
 	// DeleteAddrIndex deletes the entire addrindex stored within the DB.
 	DeleteAddrIndex() error
 
+	// FetchName returns the current state of the passed name.  It returns
+	// ErrNameMissing if the name has never been registered in the main
+	// chain.
+	FetchName(name []byte) (*NameEntry, error)
+
+	// FetchNames returns the current state of up to max names in
+	// ascending byte order, starting with the first name which is not less
+	// than start.  A max of zero or less returns every remaining name.
+	FetchNames(start []byte, max int) ([]*NameEntry, error)
+
+	// FetchNameHistory returns every state the passed name was given by a
+	// name_firstupdate or name_update operation in the main chain, oldest
+	// first.  Entries which only mark the name as expired are not part of
+	// its history.
+	FetchNameHistory(name []byte) ([]*NameEntry, error)
+
+	// FetchNamesBeforeBlock returns the state of every name updated by
+	// the block at the given height as it was before that block, keyed by
+	// name.  Names which did not exist before the block map to nil.
+	FetchNamesBeforeBlock(height int64) (map[string]*NameEntry, error)
+
+	// UpdateNamesForBlock stores the passed entries as the new state of
+	// their names as of the block with the given hash and height, which
+	// becomes the tip of the name index.  The previous state of each name
+	// is remembered so DropNamesForBlock can restore it.  The operations
+	// are performed in an atomic transaction which is commited before the
+	// function returns.
+	UpdateNamesForBlock(sha *wire.ShaHash, height int64,
+		names []*NameEntry) error
+
+	// DropNamesForBlock restores every name updated by the block at the
+	// given height to the state it had before that block and makes the
+	// block indexed before it the tip of the name index.  It must be
+	// called for blocks in the reverse order of UpdateNamesForBlock.
+	DropNamesForBlock(height int64) error
+
+	// FetchNameIndexTip returns the hash and height of the last block the
+	// name index was updated for.  It returns ErrNameIndexDoesNotExist if
+	// no block has been indexed.
+	FetchNameIndexTip() (sha *wire.ShaHash, height int64, err error)
+
+	// FetchNameIndexVersion returns the version of the name index.  It
+	// returns ErrNameIndexDoesNotExist if the name index hasn't been
+	// completely built up from the block chain.
+	FetchNameIndexVersion() (version uint32, err error)
+
+	// SetNameIndexVersion records that the name index has been built up
+	// from the block chain with the passed version.
+	SetNameIndexVersion(version uint32) error
+
+	// DeleteNameIndex deletes the entire name index, including its
+	// version, stored within the DB.
+	DeleteNameIndex() error
+
+	// FetchInvalidBlocks returns the hashes of all blocks which are marked
+	// invalid in no particular order.
+	FetchInvalidBlocks() ([]wire.ShaHash, error)
+
+	// MarkBlockInvalid persistently marks the block with the passed hash as
+	// invalid.  The block itself does not need to be in the database.
+	MarkBlockInvalid(sha *wire.ShaHash) error
+
+	// UnmarkBlockInvalid removes the invalid mark of the block with the
+	// passed hash.  It is not an error if the block is not marked.
+	UnmarkBlockInvalid(sha *wire.ShaHash) error
+
 	// RollbackClose discards the recent database changes to the previously
 	// saved data at last Sync and closes the database.
 	RollbackClose() (err error)
@@ -167,6 +254,28 @@ type TxListReply struct {
 	Err     error
 }
 
+// NameEntry describes the state of a Namecoin name as of the last
+// name_firstupdate or name_update operation on it.  TxSha and TxOutIndex
+// locate the output holding the name, Height is the height of the block it
+// was included in and Script is the ownership script that follows the name
+// operation in that output.  Expired is set once the block at ExpireHeight
+// is connected, after which the name is no longer active.
+type NameEntry struct {
+	Name         []byte
+	Value        []byte
+	TxSha        wire.ShaHash
+	TxOutIndex   uint32
+	Height       int64
+	ExpireHeight int64
+	Expired      bool
+	Script       []byte
+}
+
+// NameIndexVersion is the current version of the name index.  It must be
+// increased whenever the entries stored for names change, so a name index built
+// by an older version is rebuilt from the block chain.
+const NameIndexVersion uint32 = 3
+
 // AddrIndexKeySize is the number of bytes used by keys into the BlockAddrIndex.
 const AddrIndexKeySize = ripemd160.Size
 
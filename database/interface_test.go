@@ -5,6 +5,7 @@
 package database_test
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
@@ -462,6 +463,141 @@ func testFetchUnSpentTxByShaList(tc *testContext) bool {
 	return testFetchTxByShaListCommon(tc, false)
 }
 
+// testForEachUnspentTx ensures ForEachUnspentTx conforms to the interface
+// contract once all of the passed blocks have been inserted.  The name index
+// must not have been updated for the newest block yet.
+func testForEachUnspentTx(tc *testContext, blocks []*btcutil.Block) bool {
+	// The names must not be passed before the name index has caught up
+	// with the newest block.
+	newestSha, newestHeight, err := tc.db.NewestSha()
+	if err != nil {
+		tc.t.Errorf("NewestSha (%s): %v", tc.dbType, err)
+		return false
+	}
+	_, _, err = tc.db.ForEachUnspentTx(
+		func([]*database.NameEntry) error { return nil },
+		func(*database.TxListReply) error { return nil })
+	if err != database.ErrNameIndexOutOfSync {
+		tc.t.Errorf("ForEachUnspentTx (%s): got %v before updating the "+
+			"name index, want %v", tc.dbType, err,
+			database.ErrNameIndexOutOfSync)
+		return false
+	}
+	err = tc.db.UpdateNamesForBlock(newestSha, newestHeight, nil)
+	if err != nil {
+		tc.t.Errorf("UpdateNamesForBlock (%s): %v", tc.dbType, err)
+		return false
+	}
+
+	// Every transaction with unspent outputs must be visited in the order
+	// of the block chain with the same data FetchUnSpentTxByShaList
+	// returns for it.
+	var want []*database.TxListReply
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			txShas := []*wire.ShaHash{tx.Sha()}
+			reply := tc.db.FetchUnSpentTxByShaList(txShas)[0]
+			if reply.Err == nil {
+				want = append(want, reply)
+			}
+		}
+	}
+
+	// The names must be the same FetchNames returns and be passed before
+	// any transaction.
+	wantNames, err := tc.db.FetchNames(nil, 0)
+	if err != nil {
+		tc.t.Errorf("FetchNames (%s): %v", tc.dbType, err)
+		return false
+	}
+
+	var got []*database.TxListReply
+	var gotNames []*database.NameEntry
+	namesCalls := 0
+	namesFunc := func(names []*database.NameEntry) error {
+		namesCalls++
+		if len(got) != 0 {
+			tc.t.Errorf("ForEachUnspentTx (%s): names passed after "+
+				"%d transactions", tc.dbType, len(got))
+		}
+		gotNames = names
+		return nil
+	}
+	walkFunc := func(reply *database.TxListReply) error {
+		got = append(got, reply)
+		return nil
+	}
+	sha, height, err := tc.db.ForEachUnspentTx(namesFunc, walkFunc)
+	if err != nil {
+		tc.t.Errorf("ForEachUnspentTx (%s): %v", tc.dbType, err)
+		return false
+	}
+	if namesCalls != 1 || len(gotNames) != len(wantNames) ||
+		(len(wantNames) > 0 && !reflect.DeepEqual(gotNames, wantNames)) {
+
+		tc.t.Errorf("ForEachUnspentTx (%s): got names %s after %d "+
+			"calls, want %s after 1 call", tc.dbType,
+			spew.Sdump(gotNames), namesCalls, spew.Sdump(wantNames))
+		return false
+	}
+	if !sha.IsEqual(newestSha) || height != newestHeight {
+		tc.t.Errorf("ForEachUnspentTx (%s): got newest block %v (%d), "+
+			"want %v (%d)", tc.dbType, sha, height, newestSha,
+			newestHeight)
+		return false
+	}
+	if len(got) != len(want) {
+		tc.t.Errorf("ForEachUnspentTx (%s): got %d transactions, "+
+			"want %d", tc.dbType, len(got), len(want))
+		return false
+	}
+	for i := range want {
+		if !got[i].Sha.IsEqual(want[i].Sha) ||
+			!got[i].BlkSha.IsEqual(want[i].BlkSha) ||
+			got[i].Height != want[i].Height ||
+			!reflect.DeepEqual(got[i].Tx, want[i].Tx) ||
+			!reflect.DeepEqual(got[i].TxSpent, want[i].TxSpent) {
+
+			tc.t.Errorf("ForEachUnspentTx (%s): wrong reply #%d - "+
+				"got %v, want %v", tc.dbType, i,
+				spew.Sdump(got[i]), spew.Sdump(want[i]))
+			return false
+		}
+	}
+
+	// Iteration must stop at the first error returned by the function.
+	errStop := errors.New("stop")
+	calls := 0
+	_, _, err = tc.db.ForEachUnspentTx(nil, func(*database.TxListReply) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		tc.t.Errorf("ForEachUnspentTx (%s): got error %v after %d "+
+			"calls, want %v after 1 call", tc.dbType, err, calls,
+			errStop)
+		return false
+	}
+
+	// An error returned by the names function stops the walk before any
+	// transaction.
+	calls = 0
+	_, _, err = tc.db.ForEachUnspentTx(func([]*database.NameEntry) error {
+		return errStop
+	}, func(*database.TxListReply) error {
+		calls++
+		return nil
+	})
+	if err != errStop || calls != 0 {
+		tc.t.Errorf("ForEachUnspentTx (%s): got error %v after %d "+
+			"calls, want %v after no calls", tc.dbType, err, calls,
+			errStop)
+		return false
+	}
+
+	return true
+}
+
 // testIntegrity performs a series of tests against the interface functions
 // which fetch and check for data existence.
 func testIntegrity(tc *testContext) bool {
@@ -602,6 +738,10 @@ func testInterface(t *testing.T, dbType string) {
 		testIntegrity(&context)
 	}
 
+	// The transactions with unspent outputs must be walked in the order of
+	// the block chain.
+	testForEachUnspentTx(&context, blocks)
+
 	// TODO(davec): Need to figure out how to handle the special checks
 	// required for the duplicate transactions allowed by blocks 91842 and
 	// 91880 on the main network due to the old miner + Satoshi client bug.
@@ -626,11 +766,15 @@ func testInterface(t *testing.T, dbType string) {
 	   x FetchBlockShaByHeight(height int64) (sha *wire.ShaHash, err error)
 	   - FetchHeightRange(startHeight, endHeight int64) (rshalist []wire.ShaHash, err error)
 	   x ExistsTxSha(sha *wire.ShaHash) (exists bool)
+	   x ForEachUnspentTx(names func([]*NameEntry) error, fn func(*TxListReply) error) (sha *wire.ShaHash, height int64, err error)
 	   x FetchTxBySha(txsha *wire.ShaHash) ([]*TxListReply, error)
 	   x FetchTxByShaList(txShaList []*wire.ShaHash) []*TxListReply
 	   x FetchUnSpentTxByShaList(txShaList []*wire.ShaHash) []*TxListReply
 	   x InsertBlock(block *btcutil.Block) (height int64, err error)
 	   x NewestSha() (sha *wire.ShaHash, height int64, err error)
+	   x FetchInvalidBlocks() ([]wire.ShaHash, error)
+	   x MarkBlockInvalid(sha *wire.ShaHash) error
+	   x UnmarkBlockInvalid(sha *wire.ShaHash) error
 	   - RollbackClose()
 	   - Sync()
 	*/
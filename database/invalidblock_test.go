@@ -0,0 +1,86 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// TestInvalidBlocks ensures every supported database stores and removes the
+// invalid marks of blocks and that the marks survive reopening the database
+// for the databases which persist their data.
+func TestInvalidBlocks(t *testing.T) {
+	hash1 := wire.ShaHash{0x01}
+	hash2 := wire.ShaHash{0x02}
+
+	// checkMarks ensures the database holds exactly the passed marks.
+	checkMarks := func(desc, dbType string, db database.Db, want ...wire.ShaHash) {
+		hashes, err := db.FetchInvalidBlocks()
+		if err != nil {
+			t.Errorf("FetchInvalidBlocks (%s, %s): %v", desc, dbType,
+				err)
+			return
+		}
+		got := make(map[wire.ShaHash]struct{})
+		for _, hash := range hashes {
+			got[hash] = struct{}{}
+		}
+		wantMap := make(map[wire.ShaHash]struct{})
+		for _, hash := range want {
+			wantMap[hash] = struct{}{}
+		}
+		if len(hashes) != len(got) || !reflect.DeepEqual(got, wantMap) {
+			t.Errorf("FetchInvalidBlocks (%s, %s): got %v, want %v",
+				desc, dbType, hashes, want)
+		}
+	}
+
+	for _, dbType := range []string{"leveldb", "memdb"} {
+		db, teardown, err := createDB(dbType, "invalidblocks", false)
+		if err != nil {
+			t.Errorf("Failed to create test database (%s) %v",
+				dbType, err)
+			continue
+		}
+
+		checkMarks("empty", dbType, db)
+		for _, hash := range []wire.ShaHash{hash1, hash2, hash1} {
+			if err := db.MarkBlockInvalid(&hash); err != nil {
+				t.Errorf("MarkBlockInvalid (%s): %v", dbType, err)
+			}
+		}
+		checkMarks("marked", dbType, db, hash1, hash2)
+
+		// Removing the mark of a block which is not marked is not an
+		// error.
+		for _, hash := range []wire.ShaHash{hash1, hash1} {
+			if err := db.UnmarkBlockInvalid(&hash); err != nil {
+				t.Errorf("UnmarkBlockInvalid (%s): %v", dbType,
+					err)
+			}
+		}
+		checkMarks("unmarked", dbType, db, hash2)
+
+		// The marks of the memory database do not outlive it.
+		if dbType != "memdb" {
+			db.Close()
+			db, err = openDB(dbType, "invalidblocks")
+			if err != nil {
+				t.Errorf("Failed to open test database (%s) %v",
+					dbType, err)
+				teardown()
+				continue
+			}
+			checkMarks("reopened", dbType, db, hash2)
+		}
+
+		db.Close()
+		teardown()
+	}
+}
@@ -6,10 +6,15 @@ package ldb
 
 import (
 	"bytes"
-
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/wire"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -62,3 +67,169 @@ func TestBytesPrefix(t *testing.T) {
 			[]byte("b"))
 	}
 }
+
+// TestNameKeySerialization ensures the keys of the name index keep names in
+// ascending order, can be told apart from the keys of transactions, and give
+// back the names they were made from.
+func TestNameKeySerialization(t *testing.T) {
+	names := [][]byte{
+		[]byte("d/example"),
+		[]byte("d/ex"),
+		[]byte("d/ex\x00"),
+		[]byte("d/ex\x00\x00"),
+		[]byte("d/ex\x00\x01"),
+		[]byte("d/ex\x01"),
+		[]byte("d/ex\xff"),
+		{},
+		bytes.Repeat([]byte{0x00}, 40),
+		bytes.Repeat([]byte{0xff}, 255),
+	}
+
+	var keys [][]byte
+	for _, name := range names {
+		key := nameToKey(name)
+		got, ok := keyToName(key)
+		if !ok || !bytes.Equal(got, name) {
+			t.Errorf("keyToName: got %x, %v for %x, want %x", got,
+				ok, key, name)
+		}
+		undoKey := nameUndoToKey(1, name)
+		got, ok = undoKeyToName(undoKey)
+		if !ok || !bytes.Equal(got, name) {
+			t.Errorf("undoKeyToName: got %x, %v for %x, want %x",
+				got, ok, undoKey, name)
+		}
+		historyKey := nameHistoryToKey(name, 1, 0)
+		if !isNameHistoryKey(historyKey) {
+			t.Errorf("isNameHistoryKey: %x is not a history key",
+				historyKey)
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Sort(nameSorter(names))
+	sort.Sort(nameSorter(keys))
+	for i, key := range keys {
+		if !bytes.Equal(key, nameToKey(names[i])) {
+			t.Errorf("key %d: got %x, want key of %x", i, key,
+				names[i])
+		}
+	}
+
+	// The keys of transactions and spent transactions whose hashes start
+	// like keys of the name index are not part of it.
+	for _, prefix := range [][]byte{nameKeyPrefix, nameUndoKeyPrefix,
+		nameHistoryKeyPrefix, nameBlockKeyPrefix} {
+
+		var sha wire.ShaHash
+		copy(sha[:], prefix)
+		copy(sha[len(prefix):], []byte("d/ex"))
+		for _, key := range [][]byte{shaTxToKey(&sha),
+			shaSpentTxToKey(&sha)} {
+
+			if _, ok := keyToName(key); ok {
+				t.Errorf("keyToName: accepted key %x", key)
+			}
+			if _, ok := undoKeyToName(key); ok {
+				t.Errorf("undoKeyToName: accepted key %x", key)
+			}
+			if isNameHistoryKey(key) {
+				t.Errorf("isNameHistoryKey: accepted key %x",
+					key)
+			}
+			if isNameIndexKey(key, prefix) {
+				t.Errorf("isNameIndexKey: accepted key %x",
+					key)
+			}
+		}
+	}
+}
+
+// nameSorter implements sort.Interface to sort byte slices in ascending order.
+type nameSorter [][]byte
+
+func (s nameSorter) Len() int           { return len(s) }
+func (s nameSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s nameSorter) Less(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 }
+
+// TestNameIndexForeignKeys ensures records of transactions whose hashes start
+// like the keys of the name index are neither read as names nor deleted along
+// with the name index.
+func TestNameIndexForeignKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldbnames")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := CreateDB(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatalf("CreateDB: %v", err)
+	}
+	defer db.Close()
+	ldb := db.(*LevelDb)
+
+	var foreignKeys [][]byte
+	for _, prefix := range [][]byte{nameKeyPrefix, nameUndoKeyPrefix,
+		nameHistoryKeyPrefix, nameBlockKeyPrefix} {
+
+		var sha wire.ShaHash
+		copy(sha[:], prefix)
+		copy(sha[len(prefix):], appendKeyName(nil, []byte("d/a")))
+		foreignKeys = append(foreignKeys, shaTxToKey(&sha),
+			shaSpentTxToKey(&sha))
+	}
+	for _, key := range foreignKeys {
+		if err := ldb.lDb.Put(key, []byte("record"), ldb.wo); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	entry := &database.NameEntry{
+		Name:   []byte("d/a"),
+		Value:  []byte("value"),
+		Height: 1,
+	}
+	err = db.UpdateNamesForBlock(&wire.ShaHash{0x11}, 1,
+		[]*database.NameEntry{entry})
+	if err != nil {
+		t.Fatalf("UpdateNamesForBlock: %v", err)
+	}
+	names, err := db.FetchNames(nil, 0)
+	if err != nil {
+		t.Fatalf("FetchNames: %v", err)
+	}
+	if len(names) != 1 || !bytes.Equal(names[0].Name, entry.Name) {
+		t.Errorf("FetchNames: got %d names, want only %q", len(names),
+			entry.Name)
+	}
+	history, err := db.FetchNameHistory(entry.Name)
+	if err != nil {
+		t.Fatalf("FetchNameHistory: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("FetchNameHistory: got %d entries, want 1",
+			len(history))
+	}
+	undo, err := db.FetchNamesBeforeBlock(1)
+	if err != nil {
+		t.Fatalf("FetchNamesBeforeBlock: %v", err)
+	}
+	if len(undo) != 1 {
+		t.Errorf("FetchNamesBeforeBlock: got %d names, want 1",
+			len(undo))
+	}
+
+	if err := db.DeleteNameIndex(); err != nil {
+		t.Fatalf("DeleteNameIndex: %v", err)
+	}
+	if _, err := db.FetchName(entry.Name); err != database.ErrNameMissing {
+		t.Errorf("FetchName: got %v after deleting the index, want %v",
+			err, database.ErrNameMissing)
+	}
+	for _, key := range foreignKeys {
+		if _, err := ldb.lDb.Get(key, ldb.ro); err != nil {
+			t.Errorf("Get: record %x is gone: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ldb
+
+import (
+	"github.com/melange-app/nmcd/wire"
+)
+
+// All entries marking a block as invalid share this prefix.  The value is
+// empty.  Unlike the two byte prefixes of the other indexes, the prefix is long
+// enough that the keys can not be confused with the 34 byte transaction keys.
+// -----------------------------
+// | Prefix   | BlkSha         | -> nothing
+// -----------------------------
+// | 12 bytes |  32 bytes      |
+// -----------------------------
+var invalidBlockKeyPrefix = []byte("invalidblock")
+
+// invalidBlockToKey returns the key of the invalid mark of the passed block.
+func invalidBlockToKey(sha *wire.ShaHash) []byte {
+	key := make([]byte, 0, len(invalidBlockKeyPrefix)+wire.HashSize)
+	key = append(key, invalidBlockKeyPrefix...)
+	return append(key, sha[:]...)
+}
+
+// FetchInvalidBlocks returns the hashes of all blocks which are marked invalid.
+// This is part of the database.Db interface implementation.
+func (db *LevelDb) FetchInvalidBlocks() ([]wire.ShaHash, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	var hashes []wire.ShaHash
+	iter := db.lDb.NewIterator(bytesPrefix(invalidBlockKeyPrefix), db.ro)
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(invalidBlockKeyPrefix)+wire.HashSize {
+			continue
+		}
+
+		var hash wire.ShaHash
+		copy(hash[:], key[len(invalidBlockKeyPrefix):])
+		hashes = append(hashes, hash)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// MarkBlockInvalid marks the block with the passed hash as invalid.  This is
+// part of the database.Db interface implementation.
+func (db *LevelDb) MarkBlockInvalid(sha *wire.ShaHash) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.lDb.Put(invalidBlockToKey(sha), nil, db.wo)
+}
+
+// UnmarkBlockInvalid removes the invalid mark of the block with the passed
+// hash.  This is part of the database.Db interface implementation.
+func (db *LevelDb) UnmarkBlockInvalid(sha *wire.ShaHash) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.lDb.Delete(invalidBlockToKey(sha), db.wo)
+}
@@ -0,0 +1,657 @@
+// Copyright (c) 2013-2014 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ldb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// Every key of the name index starts with a byte which neither the height keys
+// nor the metadata keys start with.  Block, transaction and address index keys
+// start with a hash and so may start with any byte, but none of them is longer
+// than 34 bytes, so the keys of the name index are padded with zeros up to
+// minNameKeyLen bytes.  A key of any other shape is never part of the name
+// index.
+//
+// Names are escaped within keys, turning every zero byte into 0x00 0xff, and
+// terminated by 0x00 0x01.  This keeps names in ascending byte order and never
+// makes the key of a name the prefix of the key of a longer name.
+const (
+	nameIndexKeyByte = 0xff
+	minNameKeyLen    = 35
+)
+
+// All entries holding the current state of a name share this prefix.
+// ---------------------------------------
+// | Prefix  | Name          | Padding   | -> serialized NameEntry
+// ---------------------------------------
+// | 2 bytes | escaped       | as needed |
+// ---------------------------------------
+var nameKeyPrefix = []byte{nameIndexKeyByte, 'n'}
+
+// All entries holding the state of a name before it was updated by a block
+// share this prefix.  An empty value means the name did not exist before.
+// ---------------------------------------------------
+// | Prefix  | BlkHeight | Name          | Padding   | -> serialized NameEntry
+// ---------------------------------------------------
+// | 2 bytes |  8 bytes  | escaped       | as needed |
+// ---------------------------------------------------
+var nameUndoKeyPrefix = []byte{nameIndexKeyByte, 'u'}
+
+// All entries holding the states a name was given by name operations share
+// this prefix.
+// -------------------------------------------------------------
+// | Prefix  | Name          | BlkHeight | Index   | Padding   | -> serialized NameEntry
+// -------------------------------------------------------------
+// | 2 bytes | escaped       |  8 bytes  | 4 bytes | as needed |
+// -------------------------------------------------------------
+var nameHistoryKeyPrefix = []byte{nameIndexKeyByte, 'h'}
+
+// All entries holding the hash of the block the name index was updated for at
+// a height share this prefix.
+// -----------------------------------------
+// | Prefix  | BlkHeight | Padding         | -> block sha
+// -----------------------------------------
+// | 2 bytes |  8 bytes  | 25 bytes        |
+// -----------------------------------------
+var nameBlockKeyPrefix = []byte{nameIndexKeyByte, 'b'}
+
+// The version of the name index is stored under this key once the index has
+// been built up from the block chain.
+var nameIndexVersionKey = []byte("nameindex")
+
+// The hash and height of the last block the name index was updated for are
+// stored under this key.
+//
+// -----------------------------
+// | BlkSha   | BlkHeight      |
+// -----------------------------
+// | 32 bytes | 8 bytes        |
+// -----------------------------
+var nameIndexTipKey = []byte("nameindextip")
+
+var (
+	errBadNameEntry        = errors.New("malformed name entry")
+	errBadNameIndexVersion = errors.New("malformed name index version")
+	errBadNameIndexTip     = errors.New("malformed name index tip")
+)
+
+// appendKeyName appends the escaped and terminated passed name to a key.
+func appendKeyName(key, name []byte) []byte {
+	for _, b := range name {
+		key = append(key, b)
+		if b == 0x00 {
+			key = append(key, 0xff)
+		}
+	}
+	return append(key, 0x00, 0x01)
+}
+
+// cutKeyName returns the name escaped at the start of the passed part of a key
+// along with the rest of the key.  It returns false when the part doesn't
+// start with an escaped and terminated name.
+func cutKeyName(raw []byte) ([]byte, []byte, bool) {
+	name := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != 0x00 {
+			name = append(name, raw[i])
+			continue
+		}
+		if i+1 == len(raw) {
+			break
+		}
+		switch raw[i+1] {
+		case 0xff:
+			name = append(name, 0x00)
+			i++
+		case 0x01:
+			return name, raw[i+2:], true
+		default:
+			return nil, nil, false
+		}
+	}
+
+	return nil, nil, false
+}
+
+// padNameKey pads the passed key with zeros up to the minimum length of the
+// keys of the name index.
+func padNameKey(key []byte) []byte {
+	for len(key) < minNameKeyLen {
+		key = append(key, 0x00)
+	}
+	return key
+}
+
+// isNameKeyPadding returns whether the passed rest of the given key, following
+// its fields, is exactly the padding the key needs.
+func isNameKeyPadding(key, rest []byte) bool {
+	if len(rest) != 0 && len(key) != minNameKeyLen {
+		return false
+	}
+	for _, b := range rest {
+		if b != 0x00 {
+			return false
+		}
+	}
+	return true
+}
+
+// isNameIndexKey returns whether the passed key which starts with the given
+// prefix of the name index may be part of the name index.  Keys of other
+// records sharing the prefix are all too short.
+func isNameIndexKey(key, prefix []byte) bool {
+	return len(key) >= minNameKeyLen && bytes.HasPrefix(key, prefix)
+}
+
+// nameToKey returns the key of the current state of the passed name.
+func nameToKey(name []byte) []byte {
+	key := make([]byte, 0, minNameKeyLen+2*len(name))
+	key = append(key, nameKeyPrefix...)
+	return padNameKey(appendKeyName(key, name))
+}
+
+// keyToName returns the name of the passed key of the current state of a name.
+// It returns false when the key isn't one.
+func keyToName(key []byte) ([]byte, bool) {
+	if !isNameIndexKey(key, nameKeyPrefix) {
+		return nil, false
+	}
+	name, rest, ok := cutKeyName(key[len(nameKeyPrefix):])
+	if !ok || !isNameKeyPadding(key, rest) {
+		return nil, false
+	}
+	return name, true
+}
+
+// nameUndoHeightPrefix returns the key prefix of all undo entries for the
+// block at the given height.  The height is big endian so the entries are
+// ordered by height.
+func nameUndoHeightPrefix(height int64) []byte {
+	key := make([]byte, len(nameUndoKeyPrefix)+8)
+	copy(key, nameUndoKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(nameUndoKeyPrefix):], uint64(height))
+	return key
+}
+
+// nameUndoToKey returns the key of the undo entry of the passed name for the
+// block at the given height.
+func nameUndoToKey(height int64, name []byte) []byte {
+	return padNameKey(appendKeyName(nameUndoHeightPrefix(height), name))
+}
+
+// undoKeyToName returns the name of the passed key of an undo entry.  It
+// returns false when the key isn't one.
+func undoKeyToName(key []byte) ([]byte, bool) {
+	if !isNameIndexKey(key, nameUndoKeyPrefix) {
+		return nil, false
+	}
+	name, rest, ok := cutKeyName(key[len(nameUndoKeyPrefix)+8:])
+	if !ok || !isNameKeyPadding(key, rest) {
+		return nil, false
+	}
+	return name, true
+}
+
+// nameBlockToKey returns the key of the hash of the block the name index was
+// updated for at the given height.
+func nameBlockToKey(height int64) []byte {
+	key := make([]byte, minNameKeyLen)
+	copy(key, nameBlockKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(nameBlockKeyPrefix):], uint64(height))
+	return key
+}
+
+// formatNameIndexTip generates the value buffer for the tip of the name index.
+func formatNameIndexTip(sha *wire.ShaHash, height int64) []byte {
+	data := make([]byte, wire.HashSize+8)
+	copy(data, sha[:])
+	binary.LittleEndian.PutUint64(data[wire.HashSize:], uint64(height))
+	return data
+}
+
+// nameHistoryPrefix returns the key prefix of all history entries of the passed
+// name.
+func nameHistoryPrefix(name []byte) []byte {
+	key := make([]byte, 0, minNameKeyLen+2*len(name)+12)
+	key = append(key, nameHistoryKeyPrefix...)
+	return appendKeyName(key, name)
+}
+
+// nameHistoryHeightPrefix returns the key prefix of the history entries of the
+// passed name which were added by the block at the given height.
+func nameHistoryHeightPrefix(name []byte, height int64) []byte {
+	var scratch [8]byte
+	binary.BigEndian.PutUint64(scratch[:], uint64(height))
+	return append(nameHistoryPrefix(name), scratch[:]...)
+}
+
+// nameHistoryToKey returns the key of the history entry at the given index of
+// the entries added by the block at the given height.
+func nameHistoryToKey(name []byte, height int64, index int) []byte {
+	var scratch [4]byte
+	binary.BigEndian.PutUint32(scratch[:], uint32(index))
+	return padNameKey(append(nameHistoryHeightPrefix(name, height),
+		scratch[:]...))
+}
+
+// isNameHistoryKey returns whether the passed key is the key of a history
+// entry.
+func isNameHistoryKey(key []byte) bool {
+	if !isNameIndexKey(key, nameHistoryKeyPrefix) {
+		return false
+	}
+	_, rest, ok := cutKeyName(key[len(nameHistoryKeyPrefix):])
+	return ok && len(rest) >= 12 && isNameKeyPadding(key, rest[12:])
+}
+
+// formatNameEntry generates the value buffer for a name entry.
+//
+// --------------------------------------------------------------------------------------
+// | Height | ExpireHeight | TxSha    | TxOutIndex | Expired | Name | Value | Script    |
+// --------------------------------------------------------------------------------------
+// | 8 bytes| 8 bytes      | 32 bytes | 4 bytes    | 1 byte  | varbytes each            |
+// --------------------------------------------------------------------------------------
+func formatNameEntry(entry *database.NameEntry) []byte {
+	var buf bytes.Buffer
+	var scratch [8]byte
+
+	binary.LittleEndian.PutUint64(scratch[:], uint64(entry.Height))
+	buf.Write(scratch[:])
+	binary.LittleEndian.PutUint64(scratch[:], uint64(entry.ExpireHeight))
+	buf.Write(scratch[:])
+	buf.Write(entry.TxSha[:])
+	binary.LittleEndian.PutUint32(scratch[:4], entry.TxOutIndex)
+	buf.Write(scratch[:4])
+	if entry.Expired {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+
+	for _, field := range [][]byte{entry.Name, entry.Value, entry.Script} {
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(len(field)))
+		buf.Write(scratch[:4])
+		buf.Write(field)
+	}
+
+	return buf.Bytes()
+}
+
+// unpackNameEntry deserializes the raw bytes of a name entry.
+func unpackNameEntry(raw []byte) (*database.NameEntry, error) {
+	if len(raw) < 53 {
+		return nil, errBadNameEntry
+	}
+
+	entry := &database.NameEntry{
+		Height:       int64(binary.LittleEndian.Uint64(raw[0:8])),
+		ExpireHeight: int64(binary.LittleEndian.Uint64(raw[8:16])),
+		TxOutIndex:   binary.LittleEndian.Uint32(raw[48:52]),
+		Expired:      raw[52] != 0,
+	}
+	copy(entry.TxSha[:], raw[16:48])
+
+	raw = raw[53:]
+	fields := []*[]byte{&entry.Name, &entry.Value, &entry.Script}
+	for _, field := range fields {
+		if len(raw) < 4 {
+			return nil, errBadNameEntry
+		}
+		fieldLen := binary.LittleEndian.Uint32(raw[0:4])
+		raw = raw[4:]
+		if uint64(len(raw)) < uint64(fieldLen) {
+			return nil, errBadNameEntry
+		}
+		*field = make([]byte, fieldLen)
+		copy(*field, raw[:fieldLen])
+		raw = raw[fieldLen:]
+	}
+
+	return entry, nil
+}
+
+// FetchName returns the current state of the passed name.  This is part of
+// the database.Db interface implementation.
+func (db *LevelDb) FetchName(name []byte) (*database.NameEntry, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.fetchName(name)
+}
+
+// fetchName returns the current state of the passed name.
+// Must be called with the db lock held.
+func (db *LevelDb) fetchName(name []byte) (*database.NameEntry, error) {
+	raw, err := db.lDb.Get(nameToKey(name), db.ro)
+	if err == leveldb.ErrNotFound {
+		return nil, database.ErrNameMissing
+	} else if err != nil {
+		return nil, err
+	}
+
+	return unpackNameEntry(raw)
+}
+
+// FetchNames returns the current state of up to max names in ascending order,
+// starting with the first name which is not less than start.  This is part of
+// the database.Db interface implementation.
+func (db *LevelDb) FetchNames(start []byte, max int) ([]*database.NameEntry, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	keyRange := bytesPrefix(nameKeyPrefix)
+	keyRange.Start = nameToKey(start)
+	return readNames(db.lDb.NewIterator(keyRange, db.ro), max)
+}
+
+// readNames returns up to max entries of names read from the passed iterator
+// over current name states, and releases it.  A max of zero or less reads
+// every remaining entry.
+func readNames(iter iterator.Iterator, max int) ([]*database.NameEntry, error) {
+	var names []*database.NameEntry
+	for (max <= 0 || len(names) < max) && iter.Next() {
+		if _, ok := keyToName(iter.Key()); !ok {
+			continue
+		}
+		entry, err := unpackNameEntry(iter.Value())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		names = append(names, entry)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// FetchNameHistory returns every state the passed name was given by a name
+// operation in the main chain, oldest first.  This is part of the database.Db
+// interface implementation.
+func (db *LevelDb) FetchNameHistory(name []byte) ([]*database.NameEntry, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	var history []*database.NameEntry
+	iter := db.lDb.NewIterator(bytesPrefix(nameHistoryPrefix(name)), db.ro)
+	for iter.Next() {
+		if !isNameHistoryKey(iter.Key()) {
+			continue
+		}
+		entry, err := unpackNameEntry(iter.Value())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// FetchNamesBeforeBlock returns the state of every name updated by the block
+// at the given height as it was before that block.  This is part of the
+// database.Db interface implementation.
+func (db *LevelDb) FetchNamesBeforeBlock(height int64) (map[string]*database.NameEntry, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	names := make(map[string]*database.NameEntry)
+	prefix := nameUndoHeightPrefix(height)
+	iter := db.lDb.NewIterator(bytesPrefix(prefix), db.ro)
+	for iter.Next() {
+		name, ok := undoKeyToName(iter.Key())
+		if !ok {
+			continue
+		}
+		if len(iter.Value()) == 0 {
+			names[string(name)] = nil
+			continue
+		}
+
+		entry, err := unpackNameEntry(iter.Value())
+		if err != nil {
+			iter.Release()
+			return nil, err
+		}
+		names[string(name)] = entry
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// UpdateNamesForBlock stores the passed entries as the new state of their
+// names as of the passed block along with undo entries holding their previous
+// states, and makes the block the tip of the name index.  This is part of the
+// database.Db interface implementation.
+func (db *LevelDb) UpdateNamesForBlock(sha *wire.ShaHash, height int64, names []*database.NameEntry) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	batch := db.lBatch()
+	defer batch.Reset()
+
+	// A name may be updated more than once in the same block, in which case
+	// only the state before the first update must be remembered.
+	seen := make(map[string]struct{}, len(names))
+	for i, entry := range names {
+		if _, ok := seen[string(entry.Name)]; !ok {
+			seen[string(entry.Name)] = struct{}{}
+
+			var undo []byte
+			prev, err := db.fetchName(entry.Name)
+			switch err {
+			case nil:
+				undo = formatNameEntry(prev)
+			case database.ErrNameMissing:
+			default:
+				return err
+			}
+			batch.Put(nameUndoToKey(height, entry.Name), undo)
+		}
+
+		serializedEntry := formatNameEntry(entry)
+		batch.Put(nameToKey(entry.Name), serializedEntry)
+
+		// Entries which only mark a name as expired are not the result
+		// of a name operation and so are not part of its history.
+		if !entry.Expired {
+			batch.Put(nameHistoryToKey(entry.Name, height, i),
+				serializedEntry)
+		}
+	}
+
+	batch.Put(nameBlockToKey(height), sha[:])
+	batch.Put(nameIndexTipKey, formatNameIndexTip(sha, height))
+
+	return db.lDb.Write(batch, db.wo)
+}
+
+// DropNamesForBlock restores every name updated by the block at the given
+// height to its previous state and makes the block indexed before it the tip
+// of the name index.  This is part of the database.Db interface
+// implementation.
+func (db *LevelDb) DropNamesForBlock(height int64) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	batch := db.lBatch()
+	defer batch.Reset()
+
+	prefix := nameUndoHeightPrefix(height)
+	iter := db.lDb.NewIterator(bytesPrefix(prefix), db.ro)
+	for iter.Next() {
+		name, ok := undoKeyToName(iter.Key())
+		if !ok {
+			continue
+		}
+		if len(iter.Value()) == 0 {
+			batch.Delete(nameToKey(name))
+		} else {
+			batch.Put(nameToKey(name), iter.Value())
+		}
+		batch.Delete(iter.Key())
+
+		historyPrefix := nameHistoryHeightPrefix(name, height)
+		historyIter := db.lDb.NewIterator(bytesPrefix(historyPrefix),
+			db.ro)
+		for historyIter.Next() {
+			if !isNameHistoryKey(historyIter.Key()) {
+				continue
+			}
+			batch.Delete(historyIter.Key())
+		}
+		historyIter.Release()
+		if err := historyIter.Error(); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	batch.Delete(nameBlockToKey(height))
+	prev, err := db.lDb.Get(nameBlockToKey(height-1), db.ro)
+	switch {
+	case err == leveldb.ErrNotFound || height <= 0:
+		batch.Delete(nameIndexTipKey)
+	case err != nil:
+		return err
+	case len(prev) != wire.HashSize:
+		return errBadNameIndexTip
+	default:
+		var prevSha wire.ShaHash
+		copy(prevSha[:], prev)
+		batch.Put(nameIndexTipKey, formatNameIndexTip(&prevSha,
+			height-1))
+	}
+
+	return db.lDb.Write(batch, db.wo)
+}
+
+// FetchNameIndexTip returns the hash and height of the last block the name
+// index was updated for.  This is part of the database.Db interface
+// implementation.
+func (db *LevelDb) FetchNameIndexTip() (*wire.ShaHash, int64, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	data, err := db.lDb.Get(nameIndexTipKey, db.ro)
+	if err == leveldb.ErrNotFound {
+		return nil, 0, database.ErrNameIndexDoesNotExist
+	} else if err != nil {
+		return nil, 0, err
+	}
+	if len(data) != wire.HashSize+8 {
+		return nil, 0, errBadNameIndexTip
+	}
+
+	var sha wire.ShaHash
+	copy(sha[:], data[:wire.HashSize])
+	height := int64(binary.LittleEndian.Uint64(data[wire.HashSize:]))
+	return &sha, height, nil
+}
+
+// FetchNameIndexVersion returns the version of the name index.  This is part of
+// the database.Db interface implementation.
+func (db *LevelDb) FetchNameIndexVersion() (uint32, error) {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	data, err := db.lDb.Get(nameIndexVersionKey, db.ro)
+	if err == leveldb.ErrNotFound {
+		return 0, database.ErrNameIndexDoesNotExist
+	} else if err != nil {
+		return 0, err
+	}
+	if len(data) != 4 {
+		return 0, errBadNameIndexVersion
+	}
+
+	return binary.LittleEndian.Uint32(data), nil
+}
+
+// SetNameIndexVersion records the version of the name index.  This is part of
+// the database.Db interface implementation.
+func (db *LevelDb) SetNameIndexVersion(version uint32) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	var data [4]byte
+	binary.LittleEndian.PutUint32(data[:], version)
+	return db.lDb.Put(nameIndexVersionKey, data[:], db.wo)
+}
+
+// DeleteNameIndex deletes the entire name index along with its version and
+// tip.  This is part of the database.Db interface implementation.
+func (db *LevelDb) DeleteNameIndex() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	// Delete the version first so an interrupted deletion still leaves
+	// the index marked as not built up.
+	if err := db.lDb.Delete(nameIndexVersionKey, db.wo); err != nil {
+		return err
+	}
+	if err := db.lDb.Delete(nameIndexTipKey, db.wo); err != nil {
+		return err
+	}
+
+	batch := db.lBatch()
+	defer batch.Reset()
+
+	prefixes := [][]byte{nameKeyPrefix, nameUndoKeyPrefix,
+		nameHistoryKeyPrefix, nameBlockKeyPrefix}
+	for _, prefix := range prefixes {
+		iter := db.lDb.NewIterator(bytesPrefix(prefix), db.ro)
+		numInBatch := 0
+		for iter.Next() {
+			if !isNameIndexKey(iter.Key(), prefix) {
+				continue
+			}
+			batch.Delete(iter.Key())
+
+			numInBatch++
+
+			// Delete in chunks to potentially avoid very large
+			// batches.
+			if numInBatch >= batchDeleteThreshold {
+				if err := db.lDb.Write(batch, db.wo); err != nil {
+					iter.Release()
+					return err
+				}
+				batch.Reset()
+				numInBatch = 0
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return db.lDb.Write(batch, db.wo)
+}
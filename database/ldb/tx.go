@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"sort"
+	"time"
 
 	"github.com/melange-app/nmcd/database"
 	"github.com/melange-app/nmcd/wire"
@@ -28,6 +30,12 @@ const (
 	addrIndexKeyLength = 2 + ripemd160.Size + 4 + 4 + 4
 
 	batchDeleteThreshold = 10000
+
+	// unspentSnapshotAttempts is the number of snapshots ForEachUnspentTx
+	// takes at most while waiting for the name index to catch up with the
+	// newest block, waiting unspentSnapshotRetryInterval between them.
+	unspentSnapshotAttempts      = 10
+	unspentSnapshotRetryInterval = 50 * time.Millisecond
 )
 
 var addrIndexMetaDataKey = []byte("addrindex")
@@ -372,6 +380,210 @@ func (db *LevelDb) FetchTxBySha(txsha *wire.ShaHash) ([]*database.TxListReply, e
 	return replies, nil
 }
 
+// unspentTxLoc houses the location and spent data of a transaction record
+// found while walking the transactions with unspent outputs.
+type unspentTxLoc struct {
+	txSha     wire.ShaHash
+	blkHeight int64
+	txoff     int
+	txlen     int
+	spentBuf  []byte
+}
+
+// unspentSnapshot returns a snapshot of the database along with the hash and
+// height of its newest block.  A block is inserted in a single write while
+// holding the lock, so the snapshot taken with it held matches the newest block
+// height.  The names of the block are written separately afterwards though, so
+// when checkNames is set, snapshots are taken until the tip of the name index
+// matches the newest block, up to unspentSnapshotAttempts times.
+func (db *LevelDb) unspentSnapshot(checkNames bool) (*leveldb.Snapshot, *wire.ShaHash, int64, error) {
+	for attempt := 1; ; attempt++ {
+		db.dbLock.Lock()
+		snap, err := db.lDb.GetSnapshot()
+		bestHeight := db.nextBlock - 1
+		db.dbLock.Unlock()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		bestSha := &wire.ShaHash{}
+		if bestHeight == -1 {
+			return snap, bestSha, bestHeight, nil
+		}
+		blkVal, err := snap.Get(int64ToKey(bestHeight), db.ro)
+		if err != nil {
+			snap.Release()
+			return nil, nil, 0, err
+		}
+		bestSha.SetBytes(blkVal[0:32])
+		if !checkNames {
+			return snap, bestSha, bestHeight, nil
+		}
+
+		tip, err := snap.Get(nameIndexTipKey, db.ro)
+		if err != nil && err != leveldb.ErrNotFound {
+			snap.Release()
+			return nil, nil, 0, err
+		}
+		if len(tip) == wire.HashSize+8 &&
+			bytes.Equal(tip[:wire.HashSize], bestSha[:]) {
+
+			return snap, bestSha, bestHeight, nil
+		}
+		snap.Release()
+
+		if attempt == unspentSnapshotAttempts {
+			return nil, nil, 0, database.ErrNameIndexOutOfSync
+		}
+		time.Sleep(unspentSnapshotRetryInterval)
+	}
+}
+
+// ForEachUnspentTx calls the passed function with every transaction which has
+// unspent outputs, in the order the transactions appear in the block chain.
+// The walk reads a snapshot of the database, so it doesn't block other
+// operations while still seeing the state as of a single block.  This is part
+// of the database.Db interface implementation.
+func (db *LevelDb) ForEachUnspentTx(names func([]*database.NameEntry) error,
+	fn func(*database.TxListReply) error) (*wire.ShaHash, int64, error) {
+
+	snap, bestSha, bestHeight, err := db.unspentSnapshot(names != nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer snap.Release()
+
+	if names != nil {
+		iter := snap.NewIterator(bytesPrefix(nameKeyPrefix), db.ro)
+		entries, err := readNames(iter, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := names(entries); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Transaction records are keyed by the transaction hash followed by
+	// a suffix, so collect their locations first in order to load every
+	// block only once afterwards.
+	var locs []*unspentTxLoc
+	iter := snap.NewIterator(nil, db.ro)
+	for iter.Next() {
+		key := iter.Key()
+		value := iter.Value()
+		if len(key) != wire.HashSize+2 ||
+			!bytes.HasSuffix(key, []byte("tx")) || len(value) < 16 {
+
+			continue
+		}
+
+		loc := &unspentTxLoc{
+			blkHeight: int64(binary.LittleEndian.Uint64(value[0:8])),
+			txoff:     int(binary.LittleEndian.Uint32(value[8:12])),
+			txlen:     int(binary.LittleEndian.Uint32(value[12:16])),
+			spentBuf:  make([]byte, len(value)-16),
+		}
+		loc.txSha.SetBytes(key[:wire.HashSize])
+		copy(loc.spentBuf, value[16:])
+		locs = append(locs, loc)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.Sort(unspentTxLocSorter(locs))
+
+	var blkHeight int64 = -1
+	var blkSha *wire.ShaHash
+	var blkBuf []byte
+	for _, loc := range locs {
+		if loc.blkHeight != blkHeight {
+			blkVal, err := snap.Get(int64ToKey(loc.blkHeight), db.ro)
+			if err != nil {
+				return nil, 0, err
+			}
+			blkSha = &wire.ShaHash{}
+			blkSha.SetBytes(blkVal[0:32])
+			blkBuf = blkVal[32:]
+			blkHeight = loc.blkHeight
+		}
+		if len(blkBuf) < loc.txoff+loc.txlen {
+			return nil, 0, database.ErrTxShaMissing
+		}
+
+		var tx wire.MsgTx
+		err := tx.Deserialize(bytes.NewReader(blkBuf[loc.txoff : loc.txoff+loc.txlen]))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Keys of other records may happen to look like the key of a
+		// transaction record, so make sure the transaction really has
+		// the hash of the key.
+		txSha, err := tx.TxSha()
+		if err != nil {
+			return nil, 0, err
+		}
+		if !txSha.IsEqual(&loc.txSha) ||
+			len(loc.spentBuf) < (len(tx.TxOut)+7)/8 {
+
+			continue
+		}
+
+		txSpent := make([]bool, len(tx.TxOut))
+		unspent := false
+		for idx := range tx.TxOut {
+			byteidx := idx / 8
+			byteoff := uint(idx % 8)
+			txSpent[idx] = (loc.spentBuf[byteidx] & (byte(1) << byteoff)) != 0
+			unspent = unspent || !txSpent[idx]
+		}
+		if !unspent {
+			continue
+		}
+
+		err = fn(&database.TxListReply{
+			Sha:     &loc.txSha,
+			Tx:      &tx,
+			BlkSha:  blkSha,
+			Height:  loc.blkHeight,
+			TxSpent: txSpent,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return bestSha, bestHeight, nil
+}
+
+// unspentTxLocSorter implements sort.Interface to allow a slice of transaction
+// locations to be sorted by their position in the block chain.
+type unspentTxLocSorter []*unspentTxLoc
+
+// Len returns the number of locations in the slice.  It is part of the
+// sort.Interface implementation.
+func (s unspentTxLocSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the locations at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s unspentTxLocSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the location with index i should sort before the
+// location with index j.  It is part of the sort.Interface implementation.
+func (s unspentTxLocSorter) Less(i, j int) bool {
+	if s[i].blkHeight != s[j].blkHeight {
+		return s[i].blkHeight < s[j].blkHeight
+	}
+	return s[i].txoff < s[j].txoff
+}
+
 // addrIndexToKey serializes the passed txAddrIndex for storage within the DB.
 func addrIndexToKey(index *txAddrIndex) []byte {
 	record := make([]byte, addrIndexKeyLength, addrIndexKeyLength)
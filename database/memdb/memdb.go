@@ -8,11 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/melange-app/nmcd/btcutil"
 	"github.com/melange-app/nmcd/database"
 	"github.com/melange-app/nmcd/wire"
-	"github.com/melange-app/nmcd/btcutil"
 )
 
 // Errors that the various database functions may return.
@@ -20,6 +22,14 @@ var (
 	ErrDbClosed = errors.New("database is closed")
 )
 
+const (
+	// unspentWalkAttempts is the number of times ForEachUnspentTx checks
+	// at most whether the name index has caught up with the newest block,
+	// waiting unspentWalkRetryInterval between the checks.
+	unspentWalkAttempts      = 10
+	unspentWalkRetryInterval = 50 * time.Millisecond
+)
+
 var (
 	zeroHash = wire.ShaHash{}
 
@@ -92,6 +102,32 @@ type MemDb struct {
 	// block height and spent status of all their outputs.
 	txns map[wire.ShaHash][]*tTxInsertData
 
+	// names holds the current state of every name keyed by the name.
+	names map[string]*database.NameEntry
+
+	// nameUndo holds the state of the names updated by the block at each
+	// height before that block.  A nil entry means the name did not exist.
+	nameUndo map[int64]map[string]*database.NameEntry
+
+	// nameHistory holds every state each name was given by a name
+	// operation, oldest first.
+	nameHistory map[string][]*database.NameEntry
+
+	// nameBlocks holds the hash of the block the name index was updated
+	// for at each height.
+	nameBlocks map[int64]wire.ShaHash
+
+	// nameTipHeight is the height of the last block the name index was
+	// updated for, or -1 when no block has been indexed.
+	nameTipHeight int64
+
+	// nameIndexVersion is the version of the name index once it has been
+	// built up, or zero before.
+	nameIndexVersion uint32
+
+	// invalidBlocks holds the hashes of the blocks marked invalid.
+	invalidBlocks map[wire.ShaHash]struct{}
+
 	// closed indicates whether or not the database has been closed and is
 	// therefore invalidated.
 	closed bool
@@ -149,6 +185,11 @@ func (db *MemDb) Close() error {
 	db.blocks = nil
 	db.blocksBySha = nil
 	db.txns = nil
+	db.names = nil
+	db.nameUndo = nil
+	db.nameHistory = nil
+	db.nameBlocks = nil
+	db.invalidBlocks = nil
 	db.closed = true
 	return nil
 }
@@ -524,6 +565,141 @@ func (db *MemDb) FetchUnSpentTxByShaList(txShaList []*wire.ShaHash) []*database.
 	return db.fetchTxByShaList(txShaList, false)
 }
 
+// nameIndexAtNewestBlock returns whether the tip of the name index is the
+// newest block.  This function MUST be called with the database lock held.
+func (db *MemDb) nameIndexAtNewestBlock() (bool, error) {
+	numBlocks := len(db.blocks)
+	if numBlocks == 0 {
+		return true, nil
+	}
+	if db.nameTipHeight != int64(numBlocks-1) {
+		return false, nil
+	}
+	blockSha, err := db.blocks[numBlocks-1].BlockSha()
+	if err != nil {
+		return false, err
+	}
+	return db.nameBlocks[db.nameTipHeight] == blockSha, nil
+}
+
+// ForEachUnspentTx calls the passed function with every transaction which has
+// unspent outputs, in the order the transactions appear in the block chain.
+// The names of a block are updated separately after inserting it, so when the
+// names are requested the walk waits for the name index to catch up with the
+// newest block, checking up to unspentWalkAttempts times.  This is part of the
+// database.Db interface implementation.
+func (db *MemDb) ForEachUnspentTx(names func([]*database.NameEntry) error,
+	fn func(*database.TxListReply) error) (*wire.ShaHash, int64, error) {
+
+	for attempt := 1; ; attempt++ {
+		db.Lock()
+		if db.closed {
+			db.Unlock()
+			return nil, 0, ErrDbClosed
+		}
+		if names == nil {
+			break
+		}
+		synced, err := db.nameIndexAtNewestBlock()
+		if err != nil {
+			db.Unlock()
+			return nil, 0, err
+		}
+		if synced {
+			break
+		}
+		db.Unlock()
+
+		if attempt == unspentWalkAttempts {
+			return nil, 0, database.ErrNameIndexOutOfSync
+		}
+		time.Sleep(unspentWalkRetryInterval)
+	}
+	defer db.Unlock()
+
+	if names != nil {
+		if err := names(db.fetchNames(nil, 0)); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Only the most recent version of a transaction can have unspent
+	// outputs.
+	var txDs []*tTxInsertData
+	for _, txns := range db.txns {
+		txD := txns[len(txns)-1]
+		if !isFullySpent(txD) {
+			txDs = append(txDs, txD)
+		}
+	}
+	sort.Sort(txInsertDataSorter(txDs))
+
+	for _, txD := range txDs {
+		msgBlock := db.blocks[txD.blockHeight]
+		blockSha, err := msgBlock.BlockSha()
+		if err != nil {
+			return nil, 0, err
+		}
+		msgTx := msgBlock.Transactions[txD.offset]
+		txSha, err := msgTx.TxSha()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Make a copy of the spent buf to return so the caller can't
+		// accidentally modify it.
+		spentBuf := make([]bool, len(txD.spentBuf))
+		copy(spentBuf, txD.spentBuf)
+
+		err = fn(&database.TxListReply{
+			Sha:     &txSha,
+			Tx:      msgTx,
+			BlkSha:  &blockSha,
+			Height:  txD.blockHeight,
+			TxSpent: spentBuf,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	numBlocks := len(db.blocks)
+	if numBlocks == 0 {
+		return &zeroHash, -1, nil
+	}
+	blockSha, err := db.blocks[numBlocks-1].BlockSha()
+	if err != nil {
+		return nil, 0, err
+	}
+	return &blockSha, int64(numBlocks - 1), nil
+}
+
+// txInsertDataSorter implements sort.Interface to allow a slice of transaction
+// insert data to be sorted by the position of the transactions in the block
+// chain.
+type txInsertDataSorter []*tTxInsertData
+
+// Len returns the number of transactions in the slice.  It is part of the
+// sort.Interface implementation.
+func (s txInsertDataSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the transactions at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s txInsertDataSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the transaction with index i should sort before the
+// transaction with index j.  It is part of the sort.Interface implementation.
+func (s txInsertDataSorter) Less(i, j int) bool {
+	if s[i].blockHeight != s[j].blockHeight {
+		return s[i].blockHeight < s[j].blockHeight
+	}
+	return s[i].offset < s[j].offset
+}
+
 // InsertBlock inserts raw block and transaction data from a block into the
 // database.  The first block inserted into the database will be treated as the
 // genesis block.  Every subsequent block insert requires the referenced parent
@@ -723,6 +899,283 @@ func (db *MemDb) DeleteAddrIndex() error {
 	return database.ErrNotImplemented
 }
 
+// FetchName returns the current state of the passed name.  This is part of
+// the database.Db interface implementation.
+func (db *MemDb) FetchName(name []byte) (*database.NameEntry, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return nil, ErrDbClosed
+	}
+
+	entry, exists := db.names[string(name)]
+	if !exists {
+		return nil, database.ErrNameMissing
+	}
+
+	return entry, nil
+}
+
+// FetchNames returns the current state of up to max names in ascending order,
+// starting with the first name which is not less than start.  This is part of
+// the database.Db interface implementation.
+func (db *MemDb) FetchNames(start []byte, max int) ([]*database.NameEntry, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return nil, ErrDbClosed
+	}
+
+	return db.fetchNames(start, max), nil
+}
+
+// fetchNames returns the current state of up to max names in ascending order,
+// starting with the first name which is not less than start.
+//
+// This function must be called with the db lock held.
+func (db *MemDb) fetchNames(start []byte, max int) []*database.NameEntry {
+	keys := make([]string, 0, len(db.names))
+	for name := range db.names {
+		if name >= string(start) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	if max > 0 && len(keys) > max {
+		keys = keys[:max]
+	}
+
+	names := make([]*database.NameEntry, 0, len(keys))
+	for _, name := range keys {
+		names = append(names, db.names[name])
+	}
+
+	return names
+}
+
+// FetchNameHistory returns every state the passed name was given by a name
+// operation in the main chain, oldest first.  This is part of the database.Db
+// interface implementation.
+func (db *MemDb) FetchNameHistory(name []byte) ([]*database.NameEntry, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return nil, ErrDbClosed
+	}
+
+	history := db.nameHistory[string(name)]
+	return append([]*database.NameEntry(nil), history...), nil
+}
+
+// FetchNamesBeforeBlock returns the state of every name updated by the block
+// at the given height as it was before that block.  This is part of the
+// database.Db interface implementation.
+func (db *MemDb) FetchNamesBeforeBlock(height int64) (map[string]*database.NameEntry, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return nil, ErrDbClosed
+	}
+
+	names := make(map[string]*database.NameEntry, len(db.nameUndo[height]))
+	for name, prev := range db.nameUndo[height] {
+		names[name] = prev
+	}
+
+	return names, nil
+}
+
+// UpdateNamesForBlock stores the passed entries as the new state of their
+// names as of the passed block, which becomes the tip of the name index.  This
+// is part of the database.Db interface implementation.
+func (db *MemDb) UpdateNamesForBlock(sha *wire.ShaHash, height int64, names []*database.NameEntry) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return ErrDbClosed
+	}
+
+	undo := make(map[string]*database.NameEntry, len(names))
+	for _, entry := range names {
+		// Only the state before the first update of a name in the block
+		// must be remembered.
+		name := string(entry.Name)
+		if _, exists := undo[name]; !exists {
+			undo[name] = db.names[name]
+		}
+
+		db.names[name] = entry
+
+		// Entries which only mark a name as expired are not the result
+		// of a name operation and so are not part of its history.
+		if !entry.Expired {
+			db.nameHistory[name] = append(db.nameHistory[name],
+				entry)
+		}
+	}
+	db.nameUndo[height] = undo
+	db.nameBlocks[height] = *sha
+	db.nameTipHeight = height
+
+	return nil
+}
+
+// DropNamesForBlock restores every name updated by the block at the given
+// height to its previous state and makes the block indexed before it the tip
+// of the name index.  This is part of the database.Db interface
+// implementation.
+func (db *MemDb) DropNamesForBlock(height int64) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return ErrDbClosed
+	}
+
+	for name, prev := range db.nameUndo[height] {
+		history := db.nameHistory[name]
+		for len(history) > 0 && history[len(history)-1].Height == height {
+			history = history[:len(history)-1]
+		}
+		if len(history) == 0 {
+			delete(db.nameHistory, name)
+		} else {
+			db.nameHistory[name] = history
+		}
+
+		if prev == nil {
+			delete(db.names, name)
+			continue
+		}
+		db.names[name] = prev
+	}
+	delete(db.nameUndo, height)
+	delete(db.nameBlocks, height)
+	db.nameTipHeight = -1
+	if _, ok := db.nameBlocks[height-1]; ok && height > 0 {
+		db.nameTipHeight = height - 1
+	}
+
+	return nil
+}
+
+// FetchNameIndexTip returns the hash and height of the last block the name
+// index was updated for.  This is part of the database.Db interface
+// implementation.
+func (db *MemDb) FetchNameIndexTip() (*wire.ShaHash, int64, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return nil, 0, ErrDbClosed
+	}
+
+	if db.nameTipHeight < 0 {
+		return nil, 0, database.ErrNameIndexDoesNotExist
+	}
+	sha := db.nameBlocks[db.nameTipHeight]
+	return &sha, db.nameTipHeight, nil
+}
+
+// FetchNameIndexVersion returns the version of the name index.  This is part of
+// the database.Db interface implementation.
+func (db *MemDb) FetchNameIndexVersion() (uint32, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return 0, ErrDbClosed
+	}
+
+	if db.nameIndexVersion == 0 {
+		return 0, database.ErrNameIndexDoesNotExist
+	}
+	return db.nameIndexVersion, nil
+}
+
+// SetNameIndexVersion records the version of the name index.  This is part of
+// the database.Db interface implementation.
+func (db *MemDb) SetNameIndexVersion(version uint32) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return ErrDbClosed
+	}
+
+	db.nameIndexVersion = version
+	return nil
+}
+
+// DeleteNameIndex deletes the entire name index along with its version and
+// tip.  This is part of the database.Db interface implementation.
+func (db *MemDb) DeleteNameIndex() error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return ErrDbClosed
+	}
+
+	db.names = make(map[string]*database.NameEntry)
+	db.nameUndo = make(map[int64]map[string]*database.NameEntry)
+	db.nameHistory = make(map[string][]*database.NameEntry)
+	db.nameBlocks = make(map[int64]wire.ShaHash)
+	db.nameTipHeight = -1
+	db.nameIndexVersion = 0
+	return nil
+}
+
+// FetchInvalidBlocks returns the hashes of all blocks which are marked invalid.
+// This is part of the database.Db interface implementation.
+func (db *MemDb) FetchInvalidBlocks() ([]wire.ShaHash, error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return nil, ErrDbClosed
+	}
+
+	hashes := make([]wire.ShaHash, 0, len(db.invalidBlocks))
+	for hash := range db.invalidBlocks {
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// MarkBlockInvalid marks the block with the passed hash as invalid.  This is
+// part of the database.Db interface implementation.
+func (db *MemDb) MarkBlockInvalid(sha *wire.ShaHash) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return ErrDbClosed
+	}
+
+	db.invalidBlocks[*sha] = struct{}{}
+	return nil
+}
+
+// UnmarkBlockInvalid removes the invalid mark of the block with the passed
+// hash.  This is part of the database.Db interface implementation.
+func (db *MemDb) UnmarkBlockInvalid(sha *wire.ShaHash) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.closed {
+		return ErrDbClosed
+	}
+
+	delete(db.invalidBlocks, *sha)
+	return nil
+}
+
 // RollbackClose discards the recent database changes to the previously saved
 // data at last Sync and closes the database.  This is part of the database.Db
 // interface implementation.
@@ -759,9 +1212,15 @@ func (db *MemDb) Sync() error {
 // newMemDb returns a new memory-only database ready for block inserts.
 func newMemDb() *MemDb {
 	db := MemDb{
-		blocks:      make([]*wire.MsgBlock, 0, 200000),
-		blocksBySha: make(map[wire.ShaHash]int64),
-		txns:        make(map[wire.ShaHash][]*tTxInsertData),
+		blocks:        make([]*wire.MsgBlock, 0, 200000),
+		blocksBySha:   make(map[wire.ShaHash]int64),
+		txns:          make(map[wire.ShaHash][]*tTxInsertData),
+		names:         make(map[string]*database.NameEntry),
+		nameUndo:      make(map[int64]map[string]*database.NameEntry),
+		nameHistory:   make(map[string][]*database.NameEntry),
+		nameBlocks:    make(map[int64]wire.ShaHash),
+		nameTipHeight: -1,
+		invalidBlocks: make(map[wire.ShaHash]struct{}),
 	}
 	return &db
 }
@@ -0,0 +1,351 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// testFetchName ensures FetchName returns the expected entry for the passed
+// name, or ErrNameMissing when want is nil.
+func testFetchName(t *testing.T, dbType string, db database.Db, name string,
+	want *database.NameEntry) bool {
+
+	entry, err := db.FetchName([]byte(name))
+	if want == nil {
+		if err != database.ErrNameMissing {
+			t.Errorf("FetchName (%s): unexpected result for %q - "+
+				"got %v, want %v", dbType, name, err,
+				database.ErrNameMissing)
+			return false
+		}
+		return true
+	}
+	if err != nil {
+		t.Errorf("FetchName (%s): %q: %v", dbType, name, err)
+		return false
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("FetchName (%s): wrong entry for %q - got %s, want %s",
+			dbType, name, spew.Sdump(entry), spew.Sdump(want))
+		return false
+	}
+	return true
+}
+
+// testFetchNames ensures FetchNames returns the expected entries for the passed
+// start name and limit.
+func testFetchNames(t *testing.T, dbType string, db database.Db, start string,
+	max int, want []*database.NameEntry) bool {
+
+	names, err := db.FetchNames([]byte(start), max)
+	if err != nil {
+		t.Errorf("FetchNames (%s): %q %d: %v", dbType, start, max, err)
+		return false
+	}
+	if len(names) != len(want) || (len(want) > 0 &&
+		!reflect.DeepEqual(names, want)) {
+		t.Errorf("FetchNames (%s): wrong entries for %q %d - got %s, "+
+			"want %s", dbType, start, max, spew.Sdump(names),
+			spew.Sdump(want))
+		return false
+	}
+	return true
+}
+
+// testFetchNameHistory ensures FetchNameHistory returns the expected entries
+// for the passed name.
+func testFetchNameHistory(t *testing.T, dbType string, db database.Db,
+	name string, want []*database.NameEntry) bool {
+
+	history, err := db.FetchNameHistory([]byte(name))
+	if err != nil {
+		t.Errorf("FetchNameHistory (%s): %q: %v", dbType, name, err)
+		return false
+	}
+	if len(history) != len(want) || (len(want) > 0 &&
+		!reflect.DeepEqual(history, want)) {
+		t.Errorf("FetchNameHistory (%s): wrong history for %q - got "+
+			"%s, want %s", dbType, name, spew.Sdump(history),
+			spew.Sdump(want))
+		return false
+	}
+	return true
+}
+
+// testFetchNameIndexTip ensures FetchNameIndexTip returns the expected block,
+// or ErrNameIndexDoesNotExist when wantSha is nil.
+func testFetchNameIndexTip(t *testing.T, dbType string, db database.Db,
+	wantSha *wire.ShaHash, wantHeight int64) bool {
+
+	sha, height, err := db.FetchNameIndexTip()
+	if wantSha == nil {
+		if err != database.ErrNameIndexDoesNotExist {
+			t.Errorf("FetchNameIndexTip (%s): unexpected result - "+
+				"got %v, want %v", dbType, err,
+				database.ErrNameIndexDoesNotExist)
+			return false
+		}
+		return true
+	}
+	if err != nil {
+		t.Errorf("FetchNameIndexTip (%s): %v", dbType, err)
+		return false
+	}
+	if !sha.IsEqual(wantSha) || height != wantHeight {
+		t.Errorf("FetchNameIndexTip (%s): wrong tip - got %v (height "+
+			"%d), want %v (height %d)", dbType, sha, height,
+			wantSha, wantHeight)
+		return false
+	}
+	return true
+}
+
+// TestNameIndex ensures the name index of every supported database tracks the
+// current state of names and restores earlier states when blocks are dropped.
+func TestNameIndex(t *testing.T) {
+	first := &database.NameEntry{
+		Name:         []byte("d/example"),
+		Value:        []byte(`{"ip":"192.0.2.1"}`),
+		TxOutIndex:   1,
+		Height:       1,
+		ExpireHeight: 36001,
+		Script:       []byte{0x76, 0xa9, 0x14},
+	}
+	first.TxSha[0] = 0x01
+	other := &database.NameEntry{
+		Name:         []byte("id/example"),
+		Value:        []byte(`{"email":"someone@example.com"}`),
+		Height:       1,
+		ExpireHeight: 36001,
+		Script:       []byte{0xa9, 0x14},
+	}
+	other.TxSha[0] = 0x02
+	second := &database.NameEntry{
+		Name:         []byte("d/example"),
+		Value:        []byte(`{"ip":"192.0.2.2"}`),
+		Height:       2,
+		ExpireHeight: 36002,
+		Script:       []byte{0x76, 0xa9, 0x14},
+	}
+	second.TxSha[0] = 0x03
+	third := &database.NameEntry{
+		Name:         []byte("d/example"),
+		Value:        []byte{},
+		Height:       2,
+		ExpireHeight: 36002,
+		Expired:      true,
+		Script:       []byte{},
+	}
+	third.TxSha[0] = 0x04
+	block1Sha := &wire.ShaHash{0x11}
+	block2Sha := &wire.ShaHash{0x22}
+
+	for _, dbType := range database.SupportedDBs() {
+		if _, exists := ignoreDbTypes[dbType]; exists {
+			continue
+		}
+
+		db, teardown, err := createDB(dbType, "names", true)
+		if err != nil {
+			t.Errorf("Failed to create test database (%s) %v",
+				dbType, err)
+			continue
+		}
+
+		if !testFetchName(t, dbType, db, "d/example", nil) ||
+			!testFetchNameIndexTip(t, dbType, db, nil, 0) {
+			teardown()
+			continue
+		}
+
+		// Register two names in the first block.
+		err = db.UpdateNamesForBlock(block1Sha, 1,
+			[]*database.NameEntry{first, other})
+		if err != nil {
+			t.Errorf("UpdateNamesForBlock (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		if !testFetchName(t, dbType, db, "d/example", first) ||
+			!testFetchName(t, dbType, db, "id/example", other) ||
+			!testFetchNameIndexTip(t, dbType, db, block1Sha, 1) {
+			teardown()
+			continue
+		}
+
+		// Names are returned in order from the start name on.
+		all := []*database.NameEntry{first, other}
+		if !testFetchNames(t, dbType, db, "", 0, all) ||
+			!testFetchNames(t, dbType, db, "", 1, all[:1]) ||
+			!testFetchNames(t, dbType, db, "d/f", 0, all[1:]) ||
+			!testFetchNames(t, dbType, db, "id/example", 5, all[1:]) ||
+			!testFetchNames(t, dbType, db, "z", 0, nil) {
+			teardown()
+			continue
+		}
+
+		// Update one of them twice in the second block.  The final
+		// update wins.
+		err = db.UpdateNamesForBlock(block2Sha, 2,
+			[]*database.NameEntry{second, third})
+		if err != nil {
+			t.Errorf("UpdateNamesForBlock (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		if !testFetchName(t, dbType, db, "d/example", third) ||
+			!testFetchNameIndexTip(t, dbType, db, block2Sha, 2) {
+			teardown()
+			continue
+		}
+
+		// Only name operations are part of the history, so the entry
+		// marking the name as expired must not show up.  The history of
+		// a name must not include that of longer names sharing its
+		// bytes.
+		if !testFetchNameHistory(t, dbType, db, "d/example",
+			[]*database.NameEntry{first, second}) ||
+			!testFetchNameHistory(t, dbType, db, "d/exampl", nil) ||
+			!testFetchNameHistory(t, dbType, db, "id/example",
+				[]*database.NameEntry{other}) {
+			teardown()
+			continue
+		}
+
+		// The state before each block must be available until the
+		// block is dropped.
+		before, err := db.FetchNamesBeforeBlock(1)
+		wantBefore := map[string]*database.NameEntry{
+			"d/example":  nil,
+			"id/example": nil,
+		}
+		if err != nil || !reflect.DeepEqual(before, wantBefore) {
+			t.Errorf("FetchNamesBeforeBlock (%s): got %s (err %v), "+
+				"want %s", dbType, spew.Sdump(before), err,
+				spew.Sdump(wantBefore))
+			teardown()
+			continue
+		}
+		before, err = db.FetchNamesBeforeBlock(2)
+		wantBefore = map[string]*database.NameEntry{"d/example": first}
+		if err != nil || !reflect.DeepEqual(before, wantBefore) {
+			t.Errorf("FetchNamesBeforeBlock (%s): got %s (err %v), "+
+				"want %s", dbType, spew.Sdump(before), err,
+				spew.Sdump(wantBefore))
+			teardown()
+			continue
+		}
+
+		// Dropping the second block must restore the state before it
+		// rather than the intermediate update, and make the first
+		// block the tip again.
+		if err := db.DropNamesForBlock(2); err != nil {
+			t.Errorf("DropNamesForBlock (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		if !testFetchName(t, dbType, db, "d/example", first) ||
+			!testFetchName(t, dbType, db, "id/example", other) ||
+			!testFetchNameHistory(t, dbType, db, "d/example",
+				[]*database.NameEntry{first}) ||
+			!testFetchNameIndexTip(t, dbType, db, block1Sha, 1) {
+			teardown()
+			continue
+		}
+
+		// Dropping the first block must remove the names entirely.
+		if err := db.DropNamesForBlock(1); err != nil {
+			t.Errorf("DropNamesForBlock (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		testFetchName(t, dbType, db, "d/example", nil)
+		testFetchName(t, dbType, db, "id/example", nil)
+		testFetchNames(t, dbType, db, "", 0, nil)
+		testFetchNameHistory(t, dbType, db, "d/example", nil)
+		testFetchNameIndexTip(t, dbType, db, nil, 0)
+
+		teardown()
+	}
+}
+
+// TestNameIndexVersion ensures the name index of every supported database
+// reports its version once set, and that deleting the index removes every name
+// along with the version.
+func TestNameIndexVersion(t *testing.T) {
+	entry := &database.NameEntry{
+		Name:         []byte("d/example"),
+		Value:        []byte(`{"ip":"192.0.2.1"}`),
+		Height:       1,
+		ExpireHeight: 36001,
+		Script:       []byte{},
+	}
+
+	for _, dbType := range database.SupportedDBs() {
+		if _, exists := ignoreDbTypes[dbType]; exists {
+			continue
+		}
+
+		db, teardown, err := createDB(dbType, "nameindexversion", true)
+		if err != nil {
+			t.Errorf("Failed to create test database (%s) %v",
+				dbType, err)
+			continue
+		}
+
+		_, err = db.FetchNameIndexVersion()
+		if err != database.ErrNameIndexDoesNotExist {
+			t.Errorf("FetchNameIndexVersion (%s): got %v, want %v",
+				dbType, err, database.ErrNameIndexDoesNotExist)
+		}
+
+		err = db.UpdateNamesForBlock(&wire.ShaHash{0x11}, 1,
+			[]*database.NameEntry{entry})
+		if err != nil {
+			t.Errorf("UpdateNamesForBlock (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		if err := db.SetNameIndexVersion(3); err != nil {
+			t.Errorf("SetNameIndexVersion (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		version, err := db.FetchNameIndexVersion()
+		if err != nil || version != 3 {
+			t.Errorf("FetchNameIndexVersion (%s): got %d (err %v), "+
+				"want 3", dbType, version, err)
+		}
+
+		if err := db.DeleteNameIndex(); err != nil {
+			t.Errorf("DeleteNameIndex (%s): %v", dbType, err)
+			teardown()
+			continue
+		}
+		_, err = db.FetchNameIndexVersion()
+		if err != database.ErrNameIndexDoesNotExist {
+			t.Errorf("FetchNameIndexVersion (%s): got %v after "+
+				"delete, want %v", dbType, err,
+				database.ErrNameIndexDoesNotExist)
+		}
+		testFetchName(t, dbType, db, "d/example", nil)
+		testFetchNameHistory(t, dbType, db, "d/example", nil)
+		testFetchNameIndexTip(t, dbType, db, nil, 0)
+		before, err := db.FetchNamesBeforeBlock(1)
+		if err != nil || len(before) != 0 {
+			t.Errorf("FetchNamesBeforeBlock (%s): got %s (err %v) "+
+				"after delete, want none", dbType,
+				spew.Sdump(before), err)
+		}
+
+		teardown()
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package database_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// unspentTestBlocks returns a short chain of blocks whose transactions spend
+// some outputs of earlier blocks.  The first output of the first coinbase and
+// all outputs of the transaction in the second block end up spent.
+func unspentTestBlocks() []*btcutil.Block {
+	var prevHash wire.ShaHash
+	newBlock := func(height int64, txns ...*wire.MsgTx) *btcutil.Block {
+		coinbase := wire.NewMsgTx()
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&wire.ShaHash{},
+			math.MaxUint32), []byte{byte(height)}))
+		coinbase.AddTxOut(wire.NewTxOut(25e8, []byte{0x51}))
+		coinbase.AddTxOut(wire.NewTxOut(25e8, []byte{0x51}))
+
+		msgBlock := wire.NewMsgBlock(wire.NewBlockHeader(&prevHash,
+			&wire.ShaHash{}, 0, uint32(height)))
+		msgBlock.AddTransaction(coinbase)
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		prevHash, _ = msgBlock.BlockSha()
+
+		block := btcutil.NewBlock(msgBlock)
+		block.SetHeight(height)
+		return block
+	}
+	spend := func(tx *wire.MsgTx, index uint32) *wire.MsgTx {
+		txSha, _ := tx.TxSha()
+		spendTx := wire.NewMsgTx()
+		spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txSha, index), nil))
+		spendTx.AddTxOut(wire.NewTxOut(tx.TxOut[index].Value, []byte{0x51}))
+		return spendTx
+	}
+
+	block0 := newBlock(0)
+	tx1 := spend(block0.MsgBlock().Transactions[0], 0)
+	block1 := newBlock(1, tx1)
+	block2 := newBlock(2, spend(tx1, 0))
+	return []*btcutil.Block{block0, block1, block2}
+}
+
+// TestForEachUnspentTx ensures every supported database walks the names and the
+// transactions with unspent outputs in the order of the block chain, and that
+// the leveldb walk isn't affected by blocks inserted while it runs.
+func TestForEachUnspentTx(t *testing.T) {
+	blocks := unspentTestBlocks()
+	entry := &database.NameEntry{
+		Name:         []byte("d/example"),
+		Value:        []byte(`{"ip":"192.0.2.1"}`),
+		Height:       1,
+		ExpireHeight: 36001,
+		Script:       []byte{},
+	}
+	entry.TxSha = *blocks[1].Transactions()[1].Sha()
+	for _, dbType := range []string{"leveldb", "memdb"} {
+		db, teardown, err := createDB(dbType, "unspent", true)
+		if err != nil {
+			t.Errorf("Failed to create test database (%s) %v",
+				dbType, err)
+			continue
+		}
+
+		for _, block := range blocks[:len(blocks)-1] {
+			if _, err := db.InsertBlock(block); err != nil {
+				t.Errorf("InsertBlock (%s): %v", dbType, err)
+				break
+			}
+		}
+		blockSha, _ := blocks[1].Sha()
+		err = db.UpdateNamesForBlock(blockSha, 1,
+			[]*database.NameEntry{entry})
+		if err != nil {
+			t.Errorf("UpdateNamesForBlock (%s): %v", dbType, err)
+		}
+
+		// The leveldb walk reads a snapshot, so inserting the last
+		// block while it runs doesn't change what it sees.  The other
+		// databases hold their lock for the walk, so insert it first.
+		last := blocks[len(blocks)-1]
+		if dbType != "leveldb" {
+			if _, err := db.InsertBlock(last); err != nil {
+				t.Errorf("InsertBlock (%s): %v", dbType, err)
+			}
+		} else {
+			calls := 0
+			sha, height, err := db.ForEachUnspentTx(nil,
+				func(*database.TxListReply) error {
+					calls++
+					if calls > 1 {
+						return nil
+					}
+					_, err := db.InsertBlock(last)
+					return err
+				})
+			wantSha, _ := blocks[len(blocks)-2].Sha()
+			if err != nil || calls != 3 || !sha.IsEqual(wantSha) ||
+				height != int64(len(blocks)-2) {
+
+				t.Errorf("ForEachUnspentTx (%s): got %d "+
+					"transactions up to block %v (%d) (err "+
+					"%v), want 3 up to %v (%d)", dbType,
+					calls, sha, height, err, wantSha,
+					len(blocks)-2)
+			}
+		}
+
+		tc := &testContext{t: t, dbType: dbType, db: db}
+		testForEachUnspentTx(tc, blocks)
+		teardown()
+	}
+}
@@ -35,6 +35,10 @@ Application Options:
       --maxpeers=          Max number of inbound and outbound peers (125)
       --banduration=       How long to ban misbehaving peers.  Valid time units
                            are {s, m, h}.  Minimum 1 second (24h0m0s)
+      --banthreshold=      Maximum allowed ban score before disconnecting and
+                           banning misbehaving peers (100)
+      --whitelist=         Add an IP network or IP that will not be banned or
+                           rate limited (eg. 192.168.1.0/24 or ::1)
   -u, --rpcuser=           Username for RPC connections
   -P, --rpcpass=           Password for RPC connections
       --rpclisten=         Add an interface/port to listen for RPC connections
@@ -79,6 +83,15 @@ Application Options:
       --limitfreerelay=    Limit relay of transactions with no transaction fee
                            to the given amount in thousands of bytes per minute
                            (15)
+      --maxmempool=        Maximum size in bytes of the transaction memory pool
+                           -- The transactions paying the lowest fee rates are
+                           evicted when it is full (300000000)
+      --mempoolexpiry=     How long transactions are kept in the memory pool
+                           without being mined.  Valid time units are {s, m,
+                           h}.  Minimum 1 minute (336h0m0s)
+      --orphanttl=         How long orphan transactions are kept while waiting
+                           for the transactions they spend.  Valid time units
+                           are {s, m, h}.  Minimum 1 minute (20m0s)
 
       --generate=          Generate (mine) bitcoins using the CPU
       --miningaddr=        Add the specified payment address to the list of
@@ -0,0 +1,584 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/wire"
+)
+
+const (
+	// feeEstimatorMaxConfirms is the maximum number of blocks a fee or
+	// priority estimate can be requested for.
+	feeEstimatorMaxConfirms = 25
+
+	// feeEstimatorDecay is the factor the recorded statistics are scaled
+	// by for every connected block so that recent blocks carry more
+	// weight than old ones.  It gives the statistics a half-life of about
+	// 350 blocks.
+	feeEstimatorDecay = 0.998
+
+	// feeEstimatorSuccessPct is the minimum fraction of the transactions
+	// of a bucket which must have confirmed within the requested number
+	// of blocks for the bucket to be considered sufficient.
+	feeEstimatorSuccessPct = 0.85
+
+	// feeEstimatorMinSamples is the minimum decayed number of
+	// transactions a range of buckets must hold before an estimate is
+	// derived from it.
+	feeEstimatorMinSamples = 5
+
+	// feeEstimatorMaxRollback is the maximum number of recently connected
+	// blocks the estimator can unwind when they are disconnected.
+	feeEstimatorMaxRollback = 100
+
+	// feeEstimatorFileName is the name of the file the fee estimator
+	// state is saved to in the data directory.
+	feeEstimatorFileName = "feeestimates.json"
+
+	// feeEstimatorVersion is the version of the saved fee estimator state.
+	feeEstimatorVersion = 1
+)
+
+// feeRateBuckets and priorityBuckets are the lower bounds of the buckets the
+// fee rates in satoshi per kilobyte and priorities of confirmed transactions
+// are grouped in.  Transactions paying less than the minimum relay fee are
+// considered to rely on their priority and are only tracked by priority.
+var (
+	feeRateBuckets  = expBuckets(minTxRelayFee, 1e7, 1.1)
+	priorityBuckets = expBuckets(1e5, 1e13, 2)
+)
+
+// expBuckets returns exponentially spaced bucket bounds starting at start and
+// growing by the passed factor until max is reached.
+func expBuckets(start, max, factor float64) []float64 {
+	var bounds []float64
+	for bound := start; bound <= max; bound *= factor {
+		bounds = append(bounds, bound)
+	}
+	return bounds
+}
+
+// feeEstimatorStats houses the decayed confirmation statistics of either fee
+// rates or priorities, grouped into buckets.  The fields are exported so they
+// can be saved as JSON.
+type feeEstimatorStats struct {
+	// Bounds are the lower bounds of the buckets.  The last bucket has no
+	// upper bound.
+	Bounds []float64
+
+	// Confirmed holds the number of transactions of each bucket which
+	// confirmed within i+1 blocks at index i.
+	Confirmed [][]float64
+
+	// Total holds the number of confirmed transactions of each bucket.
+	Total []float64
+
+	// Sum holds the sum of the fee rates or priorities of the confirmed
+	// transactions of each bucket.
+	Sum []float64
+}
+
+// newFeeEstimatorStats returns empty statistics for the buckets with the
+// passed lower bounds.
+func newFeeEstimatorStats(bounds []float64) *feeEstimatorStats {
+	stats := &feeEstimatorStats{
+		Bounds:    bounds,
+		Confirmed: make([][]float64, feeEstimatorMaxConfirms),
+		Total:     make([]float64, len(bounds)),
+		Sum:       make([]float64, len(bounds)),
+	}
+	for i := range stats.Confirmed {
+		stats.Confirmed[i] = make([]float64, len(bounds))
+	}
+	return stats
+}
+
+// bucket returns the index of the bucket the passed value falls in.
+func (s *feeEstimatorStats) bucket(value float64) int {
+	i := sort.Search(len(s.Bounds), func(i int) bool {
+		return s.Bounds[i] > value
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+// record adds a transaction with the passed value which confirmed after the
+// passed number of blocks to the statistics with the passed weight.  A
+// negative weight removes a previously recorded transaction.
+func (s *feeEstimatorStats) record(value float64, blocks int64, weight float64) {
+	i := s.bucket(value)
+	for c := blocks - 1; c < feeEstimatorMaxConfirms; c++ {
+		s.Confirmed[c][i] += weight
+	}
+	s.Total[i] += weight
+	s.Sum[i] += weight * value
+}
+
+// scale multiplies all statistics with the passed factor.
+func (s *feeEstimatorStats) scale(factor float64) {
+	for _, confirmed := range s.Confirmed {
+		for i := range confirmed {
+			confirmed[i] *= factor
+		}
+	}
+	for i := range s.Total {
+		s.Total[i] *= factor
+		s.Sum[i] *= factor
+	}
+}
+
+// estimate returns the average value of the transactions in the lowest range
+// of buckets for which at least feeEstimatorSuccessPct of the transactions
+// confirmed within the passed number of blocks, or -1 when there is not enough
+// data.  Buckets are combined from the top down until they hold enough samples
+// and the search stops at the first range which fails.  The passed pending
+// counts are the transactions of each bucket which are still unconfirmed after
+// the passed number of blocks and count as failures.
+func (s *feeEstimatorStats) estimate(blocks int64, pending []float64) float64 {
+	var confirmed, total, failed, sum float64
+	result := -1.0
+	for i := len(s.Bounds) - 1; i >= 0; i-- {
+		confirmed += s.Confirmed[blocks-1][i]
+		total += s.Total[i]
+		failed += pending[i]
+		sum += s.Sum[i]
+		if total+failed < feeEstimatorMinSamples {
+			continue
+		}
+		if confirmed/(total+failed) < feeEstimatorSuccessPct {
+			break
+		}
+		result = sum / total
+		confirmed, total, failed, sum = 0, 0, 0, 0
+	}
+	return result
+}
+
+// observedTx houses the details of a transaction in the memory pool which are
+// needed to record its confirmation.
+type observedTx struct {
+	hash     wire.ShaHash
+	feeRate  float64 // Fee in satoshi per kilobyte.
+	priority float64 // Priority when added to the memory pool.
+	height   int64   // Best chain height when added to the memory pool.
+}
+
+// byFee returns whether the transaction relies on its fee rather than its
+// priority to get mined.
+func (o *observedTx) byFee() bool {
+	return o.feeRate >= minTxRelayFee
+}
+
+// registeredBlock houses the transactions which were recorded as confirmed by
+// a connected block so they can be unwound when it is disconnected.
+type registeredBlock struct {
+	hash      wire.ShaHash
+	height    int64
+	confirmed []*observedTx
+}
+
+// feeEstimator records how many blocks it takes for memory pool transactions
+// to be mined depending on their fee rate and priority in order to estimate
+// the fee rate or priority a transaction needs to be mined within a number of
+// blocks.
+type feeEstimator struct {
+	sync.Mutex
+	file          string
+	lastHeight    int64
+	observed      map[wire.ShaHash]*observedTx
+	feeStats      *feeEstimatorStats
+	priorityStats *feeEstimatorStats
+	blocks        []*registeredBlock
+}
+
+// newFeeEstimator returns a new fee estimator which saves its state to the
+// passed file.  Any previously saved state is loaded from it.
+func newFeeEstimator(file string) *feeEstimator {
+	e := &feeEstimator{file: file}
+	e.reset()
+	if err := e.load(); err != nil {
+		txmpLog.Errorf("Failed to load fee estimates from %s: %v",
+			file, err)
+		e.reset()
+	}
+	return e
+}
+
+// reset clears all recorded statistics and observed transactions.
+func (e *feeEstimator) reset() {
+	e.lastHeight = -1
+	e.observed = make(map[wire.ShaHash]*observedTx)
+	e.feeStats = newFeeEstimatorStats(feeRateBuckets)
+	e.priorityStats = newFeeEstimatorStats(priorityBuckets)
+	e.blocks = nil
+}
+
+// record adds or, with a negative weight, removes the passed transaction which
+// was confirmed after the passed number of blocks to the statistics.
+func (e *feeEstimator) record(o *observedTx, blocks int64, weight float64) {
+	if blocks < 1 {
+		blocks = 1
+	}
+	if o.byFee() {
+		e.feeStats.record(o.feeRate, blocks, weight)
+	} else {
+		e.priorityStats.record(o.priority, blocks, weight)
+	}
+}
+
+// ObserveTransaction starts tracking the passed memory pool transaction with
+// the passed starting priority until it is mined or removed from the pool.
+// Transactions which are already being tracked, such as those returning to
+// the pool after a reorganize, keep their original details.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) ObserveTransaction(txD *TxDesc, priority float64) {
+	e.Lock()
+	defer e.Unlock()
+
+	hash := txD.Tx.Sha()
+	if _, exists := e.observed[*hash]; exists {
+		return
+	}
+	size := txD.Tx.MsgTx().SerializeSize()
+	e.observed[*hash] = &observedTx{
+		hash:     *hash,
+		feeRate:  float64(txD.Fee) * 1000 / float64(size),
+		priority: priority,
+		height:   txD.Height,
+	}
+}
+
+// RemoveTransaction stops tracking the transaction with the passed hash
+// without recording it as confirmed.  It is called when a transaction leaves
+// the memory pool for any reason other than being mined.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) RemoveTransaction(hash *wire.ShaHash) {
+	e.Lock()
+	defer e.Unlock()
+
+	delete(e.observed, *hash)
+}
+
+// IsObserved returns whether the transaction with the passed hash is tracked.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) IsObserved(hash *wire.ShaHash) bool {
+	e.Lock()
+	defer e.Unlock()
+
+	_, exists := e.observed[*hash]
+	return exists
+}
+
+// RegisterBlock records the confirmation of all tracked transactions mined by
+// the passed block, which must have just been connected to the main chain.
+// It must be called before the transactions of the block are removed from the
+// memory pool.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) RegisterBlock(block *btcutil.Block) {
+	e.Lock()
+	defer e.Unlock()
+
+	// Blocks which were already registered before a restart are
+	// connected again while the chain catches up.
+	height := block.Height()
+	if height <= e.lastHeight {
+		return
+	}
+	e.lastHeight = height
+
+	e.feeStats.scale(feeEstimatorDecay)
+	e.priorityStats.scale(feeEstimatorDecay)
+
+	hash, _ := block.Sha()
+	rb := &registeredBlock{hash: *hash, height: height}
+	for _, tx := range block.Transactions()[1:] {
+		o, exists := e.observed[*tx.Sha()]
+		if !exists {
+			continue
+		}
+		delete(e.observed, o.hash)
+		e.record(o, height-o.height, 1)
+		rb.confirmed = append(rb.confirmed, o)
+	}
+
+	e.blocks = append(e.blocks, rb)
+	if len(e.blocks) > feeEstimatorMaxRollback {
+		e.blocks = e.blocks[len(e.blocks)-feeEstimatorMaxRollback:]
+	}
+}
+
+// Rollback unwinds the statistics recorded for the passed block, which must
+// have just been disconnected from the main chain, and tracks its transactions
+// again.  It must be called before the transactions of the block are returned
+// to the memory pool.  Only the most recently registered blocks, up to
+// feeEstimatorMaxRollback of them, can be unwound.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) Rollback(block *btcutil.Block) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.lastHeight = block.Height() - 1
+
+	hash, _ := block.Sha()
+	n := len(e.blocks)
+	if n == 0 || !e.blocks[n-1].hash.IsEqual(hash) {
+		txmpLog.Debugf("Unable to roll back fee estimates for block %v "+
+			"which is not the last registered block", hash)
+		return
+	}
+	rb := e.blocks[n-1]
+	e.blocks = e.blocks[:n-1]
+
+	for _, o := range rb.confirmed {
+		e.record(o, rb.height-o.height, -1)
+		e.observed[o.hash] = o
+	}
+	e.feeStats.scale(1 / feeEstimatorDecay)
+	e.priorityStats.scale(1 / feeEstimatorDecay)
+}
+
+// pending returns the number of tracked transactions per bucket of the passed
+// statistics which have been waiting for more than the passed number of
+// blocks.
+func (e *feeEstimator) pending(stats *feeEstimatorStats, byFee bool, blocks int64) []float64 {
+	pending := make([]float64, len(stats.Bounds))
+	for _, o := range e.observed {
+		if o.byFee() != byFee || e.lastHeight-o.height < blocks {
+			continue
+		}
+		value := o.priority
+		if byFee {
+			value = o.feeRate
+		}
+		pending[stats.bucket(value)]++
+	}
+	return pending
+}
+
+// clampBlocks limits the passed number of blocks to the range estimates are
+// available for.
+func clampBlocks(blocks int64) int64 {
+	if blocks < 1 {
+		return 1
+	}
+	if blocks > feeEstimatorMaxConfirms {
+		return feeEstimatorMaxConfirms
+	}
+	return blocks
+}
+
+// EstimateFee returns the fee in satoshi per kilobyte a transaction needs to
+// pay in order to be mined within the passed number of blocks, or -1 when not
+// enough transactions have been observed.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) EstimateFee(blocks int64) float64 {
+	e.Lock()
+	defer e.Unlock()
+
+	blocks = clampBlocks(blocks)
+	return e.feeStats.estimate(blocks, e.pending(e.feeStats, true, blocks))
+}
+
+// EstimatePriority returns the priority a transaction which does not pay the
+// minimum relay fee needs in order to be mined within the passed number of
+// blocks, or -1 when not enough transactions have been observed.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) EstimatePriority(blocks int64) float64 {
+	e.Lock()
+	defer e.Unlock()
+
+	blocks = clampBlocks(blocks)
+	return e.priorityStats.estimate(blocks,
+		e.pending(e.priorityStats, false, blocks))
+}
+
+// serializedObservedTx is the saved form of an observed transaction.
+type serializedObservedTx struct {
+	Hash     string
+	FeeRate  float64
+	Priority float64
+	Height   int64
+}
+
+// serializedRegisteredBlock is the saved form of a registered block.
+type serializedRegisteredBlock struct {
+	Hash      string
+	Height    int64
+	Confirmed []*serializedObservedTx
+}
+
+// serializedFeeEstimator is the saved form of the fee estimator state.
+type serializedFeeEstimator struct {
+	Version       int
+	LastHeight    int64
+	Observed      []*serializedObservedTx
+	FeeStats      *feeEstimatorStats
+	PriorityStats *feeEstimatorStats
+	Blocks        []*serializedRegisteredBlock
+}
+
+// serializeObservedTx returns the saved form of the passed transaction.
+func serializeObservedTx(o *observedTx) *serializedObservedTx {
+	return &serializedObservedTx{
+		Hash:     o.hash.String(),
+		FeeRate:  o.feeRate,
+		Priority: o.priority,
+		Height:   o.height,
+	}
+}
+
+// deserializeObservedTx returns the transaction of the passed saved form.
+func deserializeObservedTx(so *serializedObservedTx) (*observedTx, error) {
+	hash, err := wire.NewShaHashFromStr(so.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &observedTx{
+		hash:     *hash,
+		feeRate:  so.FeeRate,
+		priority: so.Priority,
+		height:   so.Height,
+	}, nil
+}
+
+// Save writes the fee estimator state to its file so it can be loaded at the
+// next start.
+//
+// This function is safe for concurrent access.
+func (e *feeEstimator) Save() {
+	e.Lock()
+	defer e.Unlock()
+
+	sfe := &serializedFeeEstimator{
+		Version:       feeEstimatorVersion,
+		LastHeight:    e.lastHeight,
+		Observed:      make([]*serializedObservedTx, 0, len(e.observed)),
+		FeeStats:      e.feeStats,
+		PriorityStats: e.priorityStats,
+		Blocks:        make([]*serializedRegisteredBlock, 0, len(e.blocks)),
+	}
+	for _, o := range e.observed {
+		sfe.Observed = append(sfe.Observed, serializeObservedTx(o))
+	}
+	for _, rb := range e.blocks {
+		srb := &serializedRegisteredBlock{
+			Hash:   rb.hash.String(),
+			Height: rb.height,
+		}
+		for _, o := range rb.confirmed {
+			srb.Confirmed = append(srb.Confirmed,
+				serializeObservedTx(o))
+		}
+		sfe.Blocks = append(sfe.Blocks, srb)
+	}
+
+	w, err := os.Create(e.file)
+	if err != nil {
+		txmpLog.Errorf("Error opening file %s: %v", e.file, err)
+		return
+	}
+	defer w.Close()
+	if err := json.NewEncoder(w).Encode(sfe); err != nil {
+		txmpLog.Errorf("Failed to encode file %s: %v", e.file, err)
+		return
+	}
+}
+
+// validStats returns whether the passed saved statistics match the buckets
+// with the passed bounds.
+func validStats(stats *feeEstimatorStats, bounds []float64) bool {
+	if stats == nil || len(stats.Bounds) != len(bounds) ||
+		len(stats.Total) != len(bounds) ||
+		len(stats.Sum) != len(bounds) ||
+		len(stats.Confirmed) != feeEstimatorMaxConfirms {
+
+		return false
+	}
+	for i := range bounds {
+		if math.Abs(stats.Bounds[i]-bounds[i]) > bounds[i]*1e-9 {
+			return false
+		}
+	}
+	for _, confirmed := range stats.Confirmed {
+		if len(confirmed) != len(bounds) {
+			return false
+		}
+	}
+	return true
+}
+
+// load reads the fee estimator state from its file.  A missing file leaves the
+// estimator empty.
+func (e *feeEstimator) load() error {
+	r, err := os.Open(e.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var sfe serializedFeeEstimator
+	if err := json.NewDecoder(r).Decode(&sfe); err != nil {
+		return err
+	}
+	if sfe.Version != feeEstimatorVersion {
+		return fmt.Errorf("unknown version %v in saved fee estimates",
+			sfe.Version)
+	}
+	if !validStats(sfe.FeeStats, feeRateBuckets) ||
+		!validStats(sfe.PriorityStats, priorityBuckets) {
+
+		return fmt.Errorf("saved fee estimates use different buckets")
+	}
+
+	e.lastHeight = sfe.LastHeight
+	e.feeStats = sfe.FeeStats
+	e.priorityStats = sfe.PriorityStats
+	for _, so := range sfe.Observed {
+		o, err := deserializeObservedTx(so)
+		if err != nil {
+			return err
+		}
+		e.observed[o.hash] = o
+	}
+	for _, srb := range sfe.Blocks {
+		hash, err := wire.NewShaHashFromStr(srb.Hash)
+		if err != nil {
+			return err
+		}
+		rb := &registeredBlock{hash: *hash, height: srb.Height}
+		for _, so := range srb.Confirmed {
+			o, err := deserializeObservedTx(so)
+			if err != nil {
+				return err
+			}
+			rb.confirmed = append(rb.confirmed, o)
+		}
+		e.blocks = append(e.blocks, rb)
+	}
+
+	txmpLog.Infof("Loaded fee estimates up to height %d from file '%s'",
+		e.lastHeight, e.file)
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// TestFeeEstimator ensures the fee estimator records the confirmation of
+// observed transactions, derives fee and priority estimates from them, unwinds
+// disconnected blocks and survives being saved and loaded again.
+func TestFeeEstimator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feeestimator")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, feeEstimatorFileName)
+	e := newFeeEstimator(file)
+
+	// newTxs returns the passed number of unique memory pool transactions
+	// added at the passed height which pay the passed fee rate.
+	lockTime := uint32(0)
+	newTxs := func(n int, height int64, feeRate int64, priority float64) []*btcutil.Tx {
+		var txs []*btcutil.Tx
+		for i := 0; i < n; i++ {
+			lockTime++
+			msgTx := wire.NewMsgTx()
+			msgTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+			msgTx.LockTime = lockTime
+			tx := btcutil.NewTx(msgTx)
+			size := int64(msgTx.SerializeSize())
+			e.ObserveTransaction(&TxDesc{
+				Tx:     tx,
+				Height: height,
+				Fee:    feeRate * size / 1000,
+			}, priority)
+			txs = append(txs, tx)
+		}
+		return txs
+	}
+
+	// connect registers blocks up to the passed height, the last of which
+	// mines the passed transactions.
+	var blocks []*btcutil.Block
+	connect := func(height int64, txs []*btcutil.Tx) {
+		for h := e.lastHeight + 1; h <= height; h++ {
+			msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Nonce: uint32(h)})
+			msgBlock.AddTransaction(wire.NewMsgTx())
+			if h == height {
+				for _, tx := range txs {
+					msgBlock.AddTransaction(tx.MsgTx())
+				}
+			}
+			block := btcutil.NewBlock(msgBlock)
+			block.SetHeight(h)
+			e.RegisterBlock(block)
+			blocks = append(blocks, block)
+		}
+	}
+	disconnect := func() {
+		e.Rollback(blocks[len(blocks)-1])
+		blocks = blocks[:len(blocks)-1]
+	}
+
+	check := func(desc string, estimate func(int64) float64, blocks int64, want float64) {
+		got := estimate(blocks)
+		if math.Abs(got-want) > 1e-6*math.Abs(want) {
+			t.Errorf("%s (%d blocks): got %v, want %v", desc, blocks,
+				got, want)
+		}
+	}
+
+	// Nothing is known about an empty estimator.
+	connect(100, nil)
+	check("empty", e.EstimateFee, 1, -1)
+	check("empty", e.EstimatePriority, 1, -1)
+
+	// Transactions paying 10000 satoshi per kilobyte confirm within a
+	// block, those paying 2000 take four blocks.
+	connect(101, newTxs(10, 100, 10000, 0))
+	check("fast", e.EstimateFee, 1, 10000)
+	total := e.feeStats.Total[e.feeStats.bucket(10000)]
+	connect(105, newTxs(10, 101, 2000, 0))
+	check("slow", e.EstimateFee, 1, 10000)
+	check("slow", e.EstimateFee, 4, 2000)
+	check("out of range", e.EstimateFee, 100, 2000)
+
+	// Free transactions are only tracked by priority.
+	connect(106, newTxs(10, 105, 0, 1e9))
+	check("priority", e.EstimatePriority, 1, 1e9)
+
+	// Transactions paying 5000 satoshi per kilobyte which remain
+	// unconfirmed count as failures once they waited long enough.
+	newTxs(10, 106, 5000, 0)
+	connect(107, nil)
+	check("pending", e.EstimateFee, 4, 2000)
+	connect(110, nil)
+	check("pending", e.EstimateFee, 4, 10000)
+
+	// Save and load the estimator.
+	e.Save()
+	e = newFeeEstimator(file)
+	check("loaded", e.EstimateFee, 1, 10000)
+	check("loaded", e.EstimatePriority, 1, 1e9)
+	if len(e.observed) != 10 {
+		t.Errorf("loaded: got %d observed transactions, want 10",
+			len(e.observed))
+	}
+
+	// Disconnect all blocks back to the first mined transactions which
+	// leaves them as the only recorded transactions again.
+	for len(blocks) > 0 && blocks[len(blocks)-1].Height() > 101 {
+		disconnect()
+	}
+	if len(e.observed) != 30 {
+		t.Errorf("rollback: got %d observed transactions, want 30",
+			len(e.observed))
+	}
+	check("rollback", e.EstimateFee, 1, 10000)
+	check("rollback", e.EstimateFee, 4, 10000)
+	check("rollback", e.EstimatePriority, 1, -1)
+	got := e.feeStats.Total[e.feeStats.bucket(10000)]
+	if math.Abs(got-total) > 1e-9 {
+		t.Errorf("rollback: got total %v, want %v", got, total)
+	}
+}
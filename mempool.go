@@ -5,11 +5,16 @@
 package main
 
 import (
+	"bufio"
+	"container/heap"
 	"container/list"
 	"crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
+	"os"
 	"sync"
 	"time"
 
@@ -36,6 +41,10 @@ const (
 	// of big orphans.
 	maxOrphanTxSize = 5000
 
+	// mempoolExpireScanInterval is the interval at which the memory pool
+	// and the orphan pool are scanned for expired transactions.
+	mempoolExpireScanInterval = time.Minute
+
 	// maxSigOpsPerTx is the maximum number of signature operations
 	// in a single transaction we will relay or mine.  It is a fraction
 	// of the max signature operations for a block.
@@ -76,6 +85,20 @@ const (
 	// and as a base for calculating minimum required fees for larger
 	// transactions.  This value is in Satoshi/1000 bytes.
 	minTxRelayFee = 1000
+
+	// mempoolFileName is the name of the file the memory pool is saved to
+	// in the data directory on shutdown.
+	mempoolFileName = "mempool.dat"
+
+	// mempoolFileVersion is the version of the saved memory pool format.
+	mempoolFileVersion = 2
+
+	// rollingMinFeeHalfLife is the time it takes the minimum fee rate,
+	// which is raised when transactions are evicted from a full memory
+	// pool, to fall to half its value.  It falls two and four times as
+	// fast while the pool uses less than a half and a quarter of its
+	// maximum size respectively.
+	rollingMinFeeHalfLife = 12 * time.Hour
 )
 
 // TxDesc is a descriptor containing a transaction in the mempool and the
@@ -86,6 +109,72 @@ type TxDesc struct {
 	Height           int64       // Blockheight when added to pool.
 	Fee              int64       // Transaction fees.
 	startingPriority float64     // Priority when added to the pool.
+	descendantCount  int         // Number of txns including descendants.
+	descendantSize   int64       // Size of txns including descendants.
+	descendantFees   int64       // Fees of txns including descendants.
+	evictionIndex    int         // Index in the eviction queue.
+}
+
+// evictionFeeRate returns the fee rate in satoshi per kilobyte which decides
+// when the transaction is evicted from a full pool.  It is the higher one of
+// the fee rate of the transaction alone and the one of the transaction
+// together with its descendants, so transactions are neither evicted before
+// their children nor kept because of children paying low fees.
+func (txD *TxDesc) evictionFeeRate() float64 {
+	feeRate := float64(txD.Fee) * 1000 /
+		float64(txD.Tx.MsgTx().SerializeSize())
+	packageRate := float64(txD.descendantFees) * 1000 /
+		float64(txD.descendantSize)
+	if packageRate > feeRate {
+		return packageRate
+	}
+	return feeRate
+}
+
+// txEvictionQueue is a min-heap of the transactions in the memory pool ordered
+// by their eviction fee rates, so the next transaction to evict from a full
+// pool is always at the front.  It implements heap.Interface and keeps the
+// evictionIndex of the transactions up to date.
+type txEvictionQueue []*TxDesc
+
+// Len returns the number of transactions in the queue.  It is part of the
+// heap.Interface implementation.
+func (q txEvictionQueue) Len() int {
+	return len(q)
+}
+
+// Less returns whether the transaction with index i should be evicted before
+// the one with index j.  It is part of the heap.Interface implementation.
+func (q txEvictionQueue) Less(i, j int) bool {
+	return q[i].evictionFeeRate() < q[j].evictionFeeRate()
+}
+
+// Swap swaps the transactions at the passed indices in the queue.  It is part
+// of the heap.Interface implementation.
+func (q txEvictionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].evictionIndex = i
+	q[j].evictionIndex = j
+}
+
+// Push pushes the passed transaction onto the queue.  It is part of the
+// heap.Interface implementation.
+func (q *txEvictionQueue) Push(x interface{}) {
+	txD := x.(*TxDesc)
+	txD.evictionIndex = len(*q)
+	*q = append(*q, txD)
+}
+
+// Pop removes the last transaction from the queue and returns it.  It is part
+// of the heap.Interface implementation.
+func (q *txEvictionQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	txD := old[n-1]
+	old[n-1] = nil
+	txD.evictionIndex = -1
+	*q = old[0 : n-1]
+	return txD
 }
 
 // txMemPool is used as a source of transactions that need to be mined into
@@ -95,13 +184,27 @@ type txMemPool struct {
 	sync.RWMutex
 	server        *server
 	pool          map[wire.ShaHash]*TxDesc
-	orphans       map[wire.ShaHash]*btcutil.Tx
+	orphans       map[wire.ShaHash]*orphanTx
 	orphansByPrev map[wire.ShaHash]*list.List
 	addrindex     map[string]map[*btcutil.Tx]struct{} // maps address to txs
 	outpoints     map[wire.OutPoint]*btcutil.Tx
+	names         map[string]*btcutil.Tx // pending name operations by name
 	lastUpdated   time.Time // last time pool was updated
 	pennyTotal    float64   // exponentially decaying total for penny spends.
 	lastPennyUnix int64     // unix time of last ``penny spend''
+
+	// totalSize is the serialized size of all transactions in the pool.
+	totalSize int64
+
+	// evictionQueue holds the transactions of the pool ordered by their
+	// eviction fee rates.
+	evictionQueue txEvictionQueue
+
+	// rollingMinFee is the minimum fee rate in satoshi per kilobyte
+	// transactions must pay since transactions were evicted from the
+	// full pool.  It decays over time and is zero when not in effect.
+	rollingMinFee     float64
+	rollingMinFeeTime time.Time
 }
 
 // isDust returns whether or not the passed transaction output amount is
@@ -210,6 +313,16 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 			return txRuleError(wire.RejectNonstandard, str)
 		}
 
+	case txscript.NameTransactionTy:
+		// The script controlling a name must be standard itself.
+		ns := txscript.ExtractNameScript(pkScript)
+		addrScriptClass := txscript.GetScriptClass(ns.AddressScript)
+		if addrScriptClass == txscript.NameTransactionTy {
+			return txRuleError(wire.RejectNonstandard,
+				"name script with nested name operation")
+		}
+		return checkPkScriptStandard(ns.AddressScript, addrScriptClass)
+
 	case txscript.NonStandardTy:
 		return txRuleError(wire.RejectNonstandard,
 			"non-standard script form")
@@ -228,11 +341,14 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 func checkTransactionStandard(tx *btcutil.Tx, height int64) error {
 	msgTx := tx.MsgTx()
 
-	// The transaction must be a currently supported version.
-	if msgTx.Version > wire.TxVersion || msgTx.Version < 1 {
+	// The transaction must be a currently supported version or a Namecoin
+	// transaction.
+	if (msgTx.Version > wire.TxVersion || msgTx.Version < 1) &&
+		msgTx.Version != wire.NamecoinTxVersion {
+
 		str := fmt.Sprintf("transaction version %d is not in the "+
-			"valid range of %d-%d", msgTx.Version, 1,
-			wire.TxVersion)
+			"valid range of %d-%d or %d", msgTx.Version, 1,
+			wire.TxVersion, wire.NamecoinTxVersion)
 		return txRuleError(wire.RejectNonstandard, str)
 	}
 
@@ -398,15 +514,24 @@ func calcMinRequiredTxRelayFee(serializedSize int64) int64 {
 	return minFee
 }
 
+// orphanTx represents a transaction which spends outputs of unknown
+// transactions.  It is a normal transaction plus an expiration time to prevent
+// keeping the orphan forever.
+type orphanTx struct {
+	tx         *btcutil.Tx
+	expiration time.Time
+}
+
 // removeOrphan is the internal function which implements the public
 // RemoveOrphan.  See the comment for RemoveOrphan for more details.
 // This function MUST be called with the mempool lock held (for writes).
 func (mp *txMemPool) removeOrphan(txHash *wire.ShaHash) {
 	// Nothing to do if passed tx is not an orphan.
-	tx, exists := mp.orphans[*txHash]
+	otx, exists := mp.orphans[*txHash]
 	if !exists {
 		return
 	}
+	tx := otx.tx
 
 	// Remove the reference from the previous orphan index.
 	for _, txIn := range tx.MsgTx().TxIn {
@@ -485,7 +610,10 @@ func (mp *txMemPool) addOrphan(tx *btcutil.Tx) {
 	// random orphan is evicted to make room if needed.
 	mp.limitNumOrphans()
 
-	mp.orphans[*tx.Sha()] = tx
+	mp.orphans[*tx.Sha()] = &orphanTx{
+		tx:         tx,
+		expiration: time.Now().Add(cfg.OrphanTTL),
+	}
 	for _, txIn := range tx.MsgTx().TxIn {
 		originTxHash := txIn.PreviousOutPoint.Hash
 		if mp.orphansByPrev[originTxHash] == nil {
@@ -517,7 +645,11 @@ func (mp *txMemPool) maybeAddOrphan(tx *btcutil.Tx) error {
 		str := fmt.Sprintf("orphan transaction size of %d bytes is "+
 			"larger than max allowed size of %d bytes",
 			serializedLen, maxOrphanTxSize)
-		return txRuleError(wire.RejectNonstandard, str)
+		return RuleError{Err: TxRuleError{
+			RejectCode:  wire.RejectNonstandard,
+			Description: str,
+			BanScore:    banScoreOversizedOrphan,
+		}}
 	}
 
 	// Add the orphan if the none of the above disqualified it.
@@ -618,10 +750,29 @@ func (mp *txMemPool) removeTransaction(tx *btcutil.Tx) {
 		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
+		for _, ns := range nameOperations(tx) {
+			delete(mp.names, string(ns.Name))
+		}
+
+		// The transactions depending on this one are gone already, so
+		// it only needs to be dropped from the descendant totals of
+		// the transactions it depends on.
+		txSize := int64(tx.MsgTx().SerializeSize())
+		mp.updateAncestors(txDesc, func(ancestor *TxDesc) {
+			ancestor.descendantCount--
+			ancestor.descendantSize -= txSize
+			ancestor.descendantFees -= txDesc.Fee
+		})
+		heap.Remove(&mp.evictionQueue, txDesc.evictionIndex)
 		delete(mp.pool, *txHash)
+		mp.totalSize -= txSize
 		mp.lastUpdated = time.Now()
 	}
 
+	// Stop tracking the confirmation of the transaction for fee
+	// estimation.  Transactions which are mined have already been
+	// recorded by the time they are removed.
+	mp.server.feeEstimator.RemoveTransaction(txHash)
 }
 
 // removeTransactionFromAddrIndex removes the passed transaction from our
@@ -699,6 +850,28 @@ func (mp *txMemPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 	}
 }
 
+// RemoveNameConflicts removes all transactions which operate on names the
+// passed transaction operates on from the memory pool.  Removing those
+// transactions then leads to removing all transactions which rely on them,
+// recursively.  This is necessary when a block is connected to the main chain
+// because a name operation in the block invalidates any other pending
+// operation on the same name, even when it does not spend the same output.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) RemoveNameConflicts(tx *btcutil.Tx) {
+	// Protect concurrent access.
+	mp.Lock()
+	defer mp.Unlock()
+
+	for _, ns := range nameOperations(tx) {
+		if pendingTx, ok := mp.names[string(ns.Name)]; ok {
+			if !pendingTx.Sha().IsEqual(tx.Sha()) {
+				mp.removeTransaction(pendingTx)
+			}
+		}
+	}
+}
+
 // addTransaction adds the passed transaction to the memory pool.  It should
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
@@ -707,15 +880,53 @@ func (mp *txMemPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 func (mp *txMemPool) addTransaction(tx *btcutil.Tx, height, fee int64) {
 	// Add the transaction to the pool and mark the referenced outpoints
 	// as spent by the pool.
-	mp.pool[*tx.Sha()] = &TxDesc{
-		Tx:     tx,
-		Added:  time.Now(),
-		Height: height,
-		Fee:    fee,
-	}
+	txSize := int64(tx.MsgTx().SerializeSize())
+	txD := &TxDesc{
+		Tx:              tx,
+		Added:           time.Now(),
+		Height:          height,
+		Fee:             fee,
+		descendantCount: 1,
+		descendantSize:  txSize,
+		descendantFees:  fee,
+	}
+	mp.pool[*tx.Sha()] = txD
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+	for _, ns := range nameOperations(tx) {
+		mp.names[string(ns.Name)] = tx
+	}
+	mp.totalSize += txSize
+
+	// Add the transaction to the descendant totals of the transactions it
+	// depends on.  Transactions of a disconnected block are added back
+	// while transactions spending them might be in the pool already, in
+	// which case the totals of the transaction and the ones it depends on
+	// are calculated from scratch since the descendants might overlap.
+	hasChildren := false
+	for i := range tx.MsgTx().TxOut {
+		outpoint := wire.NewOutPoint(tx.Sha(), uint32(i))
+		if _, ok := mp.outpoints[*outpoint]; ok {
+			hasChildren = true
+			break
+		}
+	}
+	if hasChildren {
+		txD.descendantCount, txD.descendantSize, txD.descendantFees =
+			mp.descendants(txD)
+		mp.updateAncestors(txD, func(ancestor *TxDesc) {
+			ancestor.descendantCount, ancestor.descendantSize,
+				ancestor.descendantFees = mp.descendants(ancestor)
+		})
+	} else {
+		mp.updateAncestors(txD, func(ancestor *TxDesc) {
+			ancestor.descendantCount++
+			ancestor.descendantSize += txSize
+			ancestor.descendantFees += fee
+		})
+	}
+	heap.Push(&mp.evictionQueue, txD)
 	mp.lastUpdated = time.Now()
 
 	if cfg.AddrIndex {
@@ -872,6 +1083,41 @@ func (mp *txMemPool) checkPoolDoubleSpend(tx *btcutil.Tx) error {
 	return nil
 }
 
+// nameOperations returns the name scripts of the name_firstupdate and
+// name_update outputs of the passed transaction.  A name_new does not reveal
+// the name it reserves, so it never conflicts with other operations.
+func nameOperations(tx *btcutil.Tx) []*txscript.NameScript {
+	var nameScripts []*txscript.NameScript
+	for _, txOut := range tx.MsgTx().TxOut {
+		ns := txscript.ExtractNameScript(txOut.PkScript)
+		if ns == nil || ns.Op == txscript.OP_NAME_NEW {
+			continue
+		}
+		nameScripts = append(nameScripts, ns)
+	}
+
+	return nameScripts
+}
+
+// checkPoolNameConflict checks whether or not the passed transaction is
+// attempting to operate on a name which already has a pending operation in the
+// pool.  Only one operation on a name can be included in a block, so accepting
+// a second one would ultimately result in a conflict.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *txMemPool) checkPoolNameConflict(tx *btcutil.Tx) error {
+	for _, ns := range nameOperations(tx) {
+		if pendingTx, exists := mp.names[string(ns.Name)]; exists {
+			str := fmt.Sprintf("name %q already has a pending "+
+				"operation in transaction %v in the memory pool",
+				ns.Name, pendingTx.Sha())
+			return txRuleError(wire.RejectDuplicate, str)
+		}
+	}
+
+	return nil
+}
+
 // fetchInputTransactions fetches the input transactions referenced by the
 // passed transaction.  First, it fetches from the main chain, then it tries to
 // fetch any missing inputs from the transaction pool.
@@ -1021,6 +1267,13 @@ func (mp *txMemPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit boo
 		return nil, err
 	}
 
+	// The transaction may not operate on a name which already has a
+	// pending operation in the pool since only one of them could be mined.
+	err = mp.checkPoolNameConflict(tx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Fetch all of the transactions referenced by the inputs to this
 	// transaction.  This function also attempts to fetch the transaction
 	// itself to be used for detecting a duplicate transaction without
@@ -1059,6 +1312,25 @@ func (mp *txMemPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit boo
 		return missingParents, nil
 	}
 
+	// Perform several checks on the name operations of the transaction
+	// using the invariant rules in btcchain for what transactions are
+	// allowed into blocks.  The checks are performed as of the next block
+	// height, so a name_firstupdate is only accepted once the name_new it
+	// reveals is mature enough to be mined in the next block.  A name_new
+	// which is still in the pool is never mature.
+	nameView, err := mp.server.blockManager.blockChain.FetchNameView(tx)
+	if err != nil {
+		return nil, err
+	}
+	err = blockchain.CheckTransactionNames(tx, nextBlockHeight, txStore,
+		nameView, activeNetParams.Params)
+	if err != nil {
+		if cerr, ok := err.(blockchain.RuleError); ok {
+			return nil, chainRuleError(cerr)
+		}
+		return nil, err
+	}
+
 	// Perform several checks on the transaction inputs using the invariant
 	// rules in btcchain for what transactions are allowed into blocks.
 	// Also returns the fees associated with the transaction which will be
@@ -1132,6 +1404,19 @@ func (mp *txMemPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit boo
 		return nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
+	// Once transactions were evicted because the pool was full, require
+	// transactions to pay more than the evicted ones regardless of their
+	// priority.
+	if rollingMinFee := mp.decayRollingMinFee(); rollingMinFee > 0 {
+		minPoolFee := int64(rollingMinFee) * serializedSize / 1000
+		if txFee < minPoolFee {
+			str := fmt.Sprintf("transaction %v has %d fees which "+
+				"is under the amount of %d required while the "+
+				"memory pool is full", txHash, txFee, minPoolFee)
+			return nil, txRuleError(wire.RejectInsufficientFee, str)
+		}
+	}
+
 	// Free-to-relay transactions are rate limited here to prevent
 	// penny-flooding with tiny transactions as a form of attack.
 	if rateLimit && txFee < minFee {
@@ -1167,8 +1452,22 @@ func (mp *txMemPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit boo
 		return nil, err
 	}
 
-	// Add to transaction pool.
+	// Add to transaction pool and make room for it by evicting the
+	// transactions paying the lowest fee rates when the pool is full.
+	// That might be the transaction itself.
 	mp.addTransaction(tx, curHeight, txFee)
+	mp.trimToSize()
+	if !mp.isTransactionInPool(txHash) {
+		str := fmt.Sprintf("transaction %v does not pay enough fees "+
+			"to enter the full memory pool", txHash)
+		return nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	// Track the transaction until it is mined so the fee estimator can
+	// learn from the time it takes.
+	txD := mp.pool[*txHash]
+	mp.server.feeEstimator.ObserveTransaction(txD,
+		txD.StartingPriority(txStore))
 
 	txmpLog.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
@@ -1384,6 +1683,22 @@ func (mp *txMemPool) TxShas() []*wire.ShaHash {
 	return hashes
 }
 
+// PendingNames returns the transactions in the pool which operate on a name
+// keyed by the name.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) PendingNames() map[string]*btcutil.Tx {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	names := make(map[string]*btcutil.Tx, len(mp.names))
+	for name, tx := range mp.names {
+		names[name] = tx
+	}
+
+	return names
+}
+
 // TxDescs returns a slice of descriptors for all the transactions in the pool.
 // The descriptors are to be treated as read only.
 //
@@ -1402,6 +1717,99 @@ func (mp *txMemPool) TxDescs() []*TxDesc {
 	return descs
 }
 
+// Size returns the serialized size of all transactions in the main pool.  It
+// does not include the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) Size() int64 {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	return mp.totalSize
+}
+
+// Usage returns the serialized size of all transactions held in the main pool
+// and the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) Usage() int64 {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	usage := mp.totalSize
+	for _, otx := range mp.orphans {
+		usage += int64(otx.tx.MsgTx().SerializeSize())
+	}
+	return usage
+}
+
+// MinFeeRate returns the minimum fee rate in satoshi per kilobyte transactions
+// currently need to pay to enter the pool regardless of their priority.  It is
+// zero unless transactions were recently evicted from the full pool.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) MinFeeRate() float64 {
+	mp.Lock()
+	defer mp.Unlock()
+
+	return mp.decayRollingMinFee()
+}
+
+// expireTransactions removes the transactions which entered the pool longer
+// than the configured expiry ago, along with the transactions depending on
+// them, as well as the orphans whose time to live has passed.  Removing them
+// also drops them from the address index.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *txMemPool) expireTransactions() {
+	now := time.Now()
+	numExpired := 0
+	for _, txD := range mp.pool {
+		if now.Sub(txD.Added) <= cfg.MempoolExpiry {
+			continue
+		}
+
+		// The transaction might already have been removed as the
+		// descendant of another expired transaction.
+		if !mp.isTransactionInPool(txD.Tx.Sha()) {
+			continue
+		}
+		txmpLog.Debugf("Expired transaction %v which entered the "+
+			"memory pool at %v", txD.Tx.Sha(), txD.Added)
+		numExpired += txD.descendantCount
+		mp.removeTransaction(txD.Tx)
+	}
+
+	numOrphans := 0
+	for txHash, otx := range mp.orphans {
+		if now.Before(otx.expiration) {
+			continue
+		}
+
+		txmpLog.Debugf("Expired orphan transaction %v", txHash)
+		mp.removeOrphan(&txHash)
+		numOrphans++
+	}
+
+	if numExpired > 0 || numOrphans > 0 {
+		txmpLog.Infof("Expired %d transactions and %d orphans from "+
+			"the memory pool", numExpired, numOrphans)
+	}
+}
+
+// ExpireTransactions removes the transactions which stayed in the memory pool
+// for longer than the configured expiry without being mined, along with the
+// transactions depending on them, and the orphans whose time to live has
+// passed.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) ExpireTransactions() {
+	mp.Lock()
+	defer mp.Unlock()
+
+	mp.expireTransactions()
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1413,15 +1821,388 @@ func (mp *txMemPool) LastUpdated() time.Time {
 	return mp.lastUpdated
 }
 
+// descendants returns the number, serialized size and fees of the passed
+// transaction together with all transactions in the pool which depend on it.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *txMemPool) descendants(txD *TxDesc) (count int, size, fees int64) {
+	visited := make(map[wire.ShaHash]struct{})
+	queue := []*TxDesc{txD}
+	for len(queue) > 0 {
+		txD := queue[0]
+		queue = queue[1:]
+		txHash := txD.Tx.Sha()
+		if _, ok := visited[*txHash]; ok {
+			continue
+		}
+		visited[*txHash] = struct{}{}
+
+		count++
+		size += int64(txD.Tx.MsgTx().SerializeSize())
+		fees += txD.Fee
+		for i := range txD.Tx.MsgTx().TxOut {
+			outpoint := wire.NewOutPoint(txHash, uint32(i))
+			if txRedeemer, ok := mp.outpoints[*outpoint]; ok {
+				queue = append(queue, mp.pool[*txRedeemer.Sha()])
+			}
+		}
+	}
+	return count, size, fees
+}
+
+// ancestors returns the number, serialized size and fees of the passed
+// transaction together with all transactions in the pool it depends on.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *txMemPool) ancestors(txD *TxDesc) (count int, size, fees int64) {
+	visited := make(map[wire.ShaHash]struct{})
+	queue := []*TxDesc{txD}
+	for len(queue) > 0 {
+		txD := queue[0]
+		queue = queue[1:]
+		txHash := txD.Tx.Sha()
+		if _, ok := visited[*txHash]; ok {
+			continue
+		}
+		visited[*txHash] = struct{}{}
+
+		count++
+		size += int64(txD.Tx.MsgTx().SerializeSize())
+		fees += txD.Fee
+		for _, txIn := range txD.Tx.MsgTx().TxIn {
+			if parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]; ok {
+				queue = append(queue, parent)
+			}
+		}
+	}
+	return count, size, fees
+}
+
+// updateAncestors calls the passed function for every transaction in the pool
+// the passed transaction depends on, updating the position of each of them in
+// the eviction queue afterwards.  It is used to keep their descendant totals
+// up to date.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *txMemPool) updateAncestors(txD *TxDesc, update func(*TxDesc)) {
+	visited := make(map[wire.ShaHash]struct{})
+	queue := []*TxDesc{txD}
+	for len(queue) > 0 {
+		txD := queue[0]
+		queue = queue[1:]
+		for _, txIn := range txD.Tx.MsgTx().TxIn {
+			parentHash := txIn.PreviousOutPoint.Hash
+			if _, ok := visited[parentHash]; ok {
+				continue
+			}
+			parent, ok := mp.pool[parentHash]
+			if !ok {
+				continue
+			}
+			visited[parentHash] = struct{}{}
+
+			update(parent)
+			heap.Fix(&mp.evictionQueue, parent.evictionIndex)
+			queue = append(queue, parent)
+		}
+	}
+}
+
+// trimToSize evicts the transactions with the lowest eviction fee rates along
+// with the transactions which depend on them until the pool no longer exceeds
+// its maximum size.  The minimum fee rate required to enter the pool is raised
+// above the fee rate of every evicted transaction.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *txMemPool) trimToSize() {
+	for mp.totalSize > int64(cfg.MaxMempool) {
+		evict := mp.evictionQueue[0]
+		evictRate := evict.evictionFeeRate()
+
+		txmpLog.Debugf("Evicting transaction %v with a fee rate of "+
+			"%.0f satoshi/kB from the full memory pool",
+			evict.Tx.Sha(), evictRate)
+		mp.removeTransaction(evict.Tx)
+
+		rollingMinFee := evictRate + minTxRelayFee
+		if rollingMinFee > mp.decayRollingMinFee() {
+			mp.rollingMinFee = rollingMinFee
+			mp.rollingMinFeeTime = time.Now()
+		}
+	}
+}
+
+// decayRollingMinFee decays the minimum fee rate raised by evicting
+// transactions according to the time passed since it was last updated and
+// returns it.  It is dropped altogether once it falls below half the minimum
+// relay fee.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *txMemPool) decayRollingMinFee() float64 {
+	if mp.rollingMinFee == 0 {
+		return 0
+	}
+
+	halfLife := rollingMinFeeHalfLife
+	if mp.totalSize < int64(cfg.MaxMempool)/4 {
+		halfLife /= 4
+	} else if mp.totalSize < int64(cfg.MaxMempool)/2 {
+		halfLife /= 2
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(mp.rollingMinFeeTime)
+	mp.rollingMinFee *= math.Pow(0.5, float64(elapsed)/float64(halfLife))
+	mp.rollingMinFeeTime = now
+	if mp.rollingMinFee < minTxRelayFee/2 {
+		mp.rollingMinFee = 0
+	}
+	return mp.rollingMinFee
+}
+
+// writeSavedTxns writes the number of passed transactions followed by the
+// transactions themselves to w.  Unless added is nil, each transaction is
+// preceded by the time it entered the memory pool from added as a unix
+// timestamp.
+func writeSavedTxns(w io.Writer, txns []*btcutil.Tx, added []time.Time) error {
+	err := binary.Write(w, binary.LittleEndian, uint32(len(txns)))
+	if err != nil {
+		return err
+	}
+	for i, tx := range txns {
+		if added != nil {
+			err := binary.Write(w, binary.LittleEndian,
+				added[i].Unix())
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.MsgTx().Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSavedTxns reads transactions written by writeSavedTxns from r.  The times
+// the transactions entered the memory pool are only read and returned when
+// withAdded is set.
+func readSavedTxns(r io.Reader, withAdded bool) ([]*btcutil.Tx, []time.Time, error) {
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, nil, err
+	}
+
+	// Don't trust the count to preallocate the slices since the file
+	// might be corrupted.
+	var txns []*btcutil.Tx
+	var added []time.Time
+	for i := uint32(0); i < count; i++ {
+		if withAdded {
+			var timestamp int64
+			err := binary.Read(r, binary.LittleEndian, &timestamp)
+			if err != nil {
+				return nil, nil, err
+			}
+			added = append(added, time.Unix(timestamp, 0))
+		}
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(r); err != nil {
+			return nil, nil, err
+		}
+		txns = append(txns, btcutil.NewTx(&msgTx))
+	}
+	return txns, added, nil
+}
+
+// Save writes the transactions of the memory pool and the orphan pool to the
+// passed file so they can be reloaded with Load.  The file holds the version
+// of the format followed by the transactions of the memory pool and then the
+// orphans, each preceded by their number.  Transactions of the memory pool are
+// written after the transactions they spend so they can be added back in the
+// same order, and along with the time they entered the pool so they still
+// expire on time once reloaded.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) Save(file string) {
+	mp.RLock()
+	txns := make([]*btcutil.Tx, 0, len(mp.pool))
+	added := make([]time.Time, 0, len(mp.pool))
+	written := make(map[wire.ShaHash]struct{}, len(mp.pool))
+	var addTx func(txD *TxDesc)
+	addTx = func(txD *TxDesc) {
+		if _, ok := written[*txD.Tx.Sha()]; ok {
+			return
+		}
+		written[*txD.Tx.Sha()] = struct{}{}
+		for _, txIn := range txD.Tx.MsgTx().TxIn {
+			if prevTxD, ok := mp.pool[txIn.PreviousOutPoint.Hash]; ok {
+				addTx(prevTxD)
+			}
+		}
+		txns = append(txns, txD.Tx)
+		added = append(added, txD.Added)
+	}
+	for _, txD := range mp.pool {
+		addTx(txD)
+	}
+	orphans := make([]*btcutil.Tx, 0, len(mp.orphans))
+	for _, otx := range mp.orphans {
+		orphans = append(orphans, otx.tx)
+	}
+	mp.RUnlock()
+
+	// Write to a temporary file first so a failure doesn't destroy the
+	// previously saved memory pool.
+	tmpFile := file + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		txmpLog.Errorf("Error opening file %s: %v", tmpFile, err)
+		return
+	}
+	w := bufio.NewWriter(f)
+	err = binary.Write(w, binary.LittleEndian, uint32(mempoolFileVersion))
+	if err == nil {
+		err = writeSavedTxns(w, txns, added)
+	}
+	if err == nil {
+		err = writeSavedTxns(w, orphans, nil)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		txmpLog.Errorf("Failed to write file %s: %v", tmpFile, err)
+		os.Remove(tmpFile)
+		return
+	}
+	if err := os.Rename(tmpFile, file); err != nil {
+		txmpLog.Errorf("Failed to rename file %s: %v", tmpFile, err)
+		return
+	}
+
+	txmpLog.Infof("Saved %d transactions and %d orphans of the memory "+
+		"pool", len(txns), len(orphans))
+}
+
+// Load adds the transactions saved to the passed file by Save back to the
+// memory pool.  They are fully validated again, so transactions which were
+// mined, double spent or otherwise became invalid in the meantime are dropped.
+// The reloaded transactions keep the time they originally entered the pool, so
+// the ones which stayed longer than the configured expiry are expired once
+// loaded.  Loading stops early when the passed quit channel is closed, in which
+// case false is returned.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) Load(file string, quit <-chan struct{}) bool {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		txmpLog.Errorf("Error opening file %s: %v", file, err)
+		return true
+	}
+	r := bufio.NewReader(f)
+	var version uint32
+	err = binary.Read(r, binary.LittleEndian, &version)
+	if err == nil && version != mempoolFileVersion {
+		err = fmt.Errorf("unknown version %v", version)
+	}
+	var txns, orphans []*btcutil.Tx
+	var added []time.Time
+	if err == nil {
+		txns, added, err = readSavedTxns(r, true)
+	}
+	if err == nil {
+		orphans, _, err = readSavedTxns(r, false)
+	}
+	f.Close()
+	if err != nil {
+		txmpLog.Errorf("Failed to read file %s: %v", file, err)
+		return true
+	}
+
+	// Orphans are processed last so they can find their parents among
+	// the reloaded transactions.
+	accepted := 0
+	for i, tx := range append(txns, orphans...) {
+		select {
+		case <-quit:
+			return false
+		default:
+		}
+
+		var txAdded time.Time
+		if i < len(added) {
+			txAdded = added[i]
+		}
+		err := mp.acceptSavedTransaction(tx, txAdded)
+		if err != nil {
+			txmpLog.Debugf("Dropped saved transaction %v: %v",
+				tx.Sha(), err)
+			continue
+		}
+		accepted++
+	}
+
+	txmpLog.Infof("Reloaded %d of %d saved transactions into the memory "+
+		"pool", accepted, len(txns)+len(orphans))
+
+	// Transactions which stayed in the pool for too long before it was
+	// saved expire right away.
+	mp.ExpireTransactions()
+	return true
+}
+
+// acceptSavedTransaction validates the passed transaction saved by Save again
+// and adds it back to the memory pool, or to the orphan pool when its inputs
+// are still missing.  Unlike ProcessTransaction, the transaction isn't relayed
+// since the peers have already seen it before the restart.  Unless the passed
+// time is zero, it is restored as the time the transaction originally entered
+// the pool.
+//
+// The fee estimator saves the transactions it tracks along with the height at
+// which they entered the pool, so those are still tracked from that height.
+// Tracking any other reloaded transaction from the current height would make
+// it look like it was confirmed faster than it was, so they aren't tracked.
+//
+// This function is safe for concurrent access.
+func (mp *txMemPool) acceptSavedTransaction(tx *btcutil.Tx, added time.Time) error {
+	mp.Lock()
+	defer mp.Unlock()
+
+	observed := mp.server.feeEstimator.IsObserved(tx.Sha())
+	missingParents, err := mp.maybeAcceptTransaction(tx, false, false)
+	if err != nil {
+		return err
+	}
+	if len(missingParents) != 0 {
+		return mp.maybeAddOrphan(tx)
+	}
+
+	if !observed {
+		mp.server.feeEstimator.RemoveTransaction(tx.Sha())
+	}
+	if !added.IsZero() {
+		mp.pool[*tx.Sha()].Added = added
+	}
+	return nil
+}
+
 // newTxMemPool returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func newTxMemPool(server *server) *txMemPool {
 	memPool := &txMemPool{
 		server:        server,
 		pool:          make(map[wire.ShaHash]*TxDesc),
-		orphans:       make(map[wire.ShaHash]*btcutil.Tx),
+		orphans:       make(map[wire.ShaHash]*orphanTx),
 		orphansByPrev: make(map[wire.ShaHash]*list.List),
 		outpoints:     make(map[wire.OutPoint]*btcutil.Tx),
+		names:         make(map[string]*btcutil.Tx),
 	}
 	if cfg.AddrIndex {
 		memPool.addrindex = make(map[string]map[*btcutil.Tx]struct{})
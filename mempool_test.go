@@ -0,0 +1,722 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/melange-app/nmcd/blockchain"
+	"github.com/melange-app/nmcd/btcutil"
+	"github.com/melange-app/nmcd/database"
+	"github.com/melange-app/nmcd/txscript"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// TestMempoolSave ensures the memory pool is saved with every transaction
+// following the transactions it spends along with the time it entered the pool
+// and the orphans at the end.
+func TestMempoolSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, mempoolFileName)
+
+	// spend returns a transaction spending the first output of each of the
+	// passed transactions.
+	lockTime := uint32(0)
+	spend := func(parents ...*btcutil.Tx) *btcutil.Tx {
+		lockTime++
+		msgTx := wire.NewMsgTx()
+		for _, parent := range parents {
+			prevOut := wire.NewOutPoint(parent.Sha(), 0)
+			msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+		}
+		msgTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+		msgTx.LockTime = lockTime
+		return btcutil.NewTx(msgTx)
+	}
+
+	// Build a chain of transactions where the last one also spends the
+	// first, so it must be written after both.
+	mined := spend()
+	tx1 := spend(mined)
+	tx2 := spend(tx1)
+	tx3 := spend(tx2, tx1)
+	orphan := spend(spend())
+	mp := &txMemPool{
+		pool:    make(map[wire.ShaHash]*TxDesc),
+		orphans: make(map[wire.ShaHash]*orphanTx),
+	}
+	now := time.Unix(time.Now().Unix(), 0)
+	for i, tx := range []*btcutil.Tx{tx1, tx2, tx3} {
+		mp.pool[*tx.Sha()] = &TxDesc{
+			Tx:    tx,
+			Added: now.Add(time.Duration(i-3) * time.Hour),
+		}
+	}
+	mp.orphans[*orphan.Sha()] = &orphanTx{tx: orphan}
+	mp.Save(file)
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if version != mempoolFileVersion {
+		t.Fatalf("got version %d, want %d", version, mempoolFileVersion)
+	}
+	txns, added, err := readSavedTxns(r, true)
+	if err != nil {
+		t.Fatalf("readSavedTxns: %v", err)
+	}
+	orphans, _, err := readSavedTxns(r, false)
+	if err != nil {
+		t.Fatalf("readSavedTxns: %v", err)
+	}
+
+	want := []*btcutil.Tx{tx1, tx2, tx3}
+	if len(txns) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(txns), len(want))
+	}
+	for i, tx := range txns {
+		if !tx.Sha().IsEqual(want[i].Sha()) {
+			t.Errorf("transaction %d: got %v, want %v", i, tx.Sha(),
+				want[i].Sha())
+		}
+		wantAdded := mp.pool[*want[i].Sha()].Added
+		if !added[i].Equal(wantAdded) {
+			t.Errorf("transaction %d: got added %v, want %v", i,
+				added[i], wantAdded)
+		}
+	}
+	if len(orphans) != 1 || !orphans[0].Sha().IsEqual(orphan.Sha()) {
+		t.Errorf("got orphans %v, want %v", orphans, orphan.Sha())
+	}
+}
+
+// TestMempoolLoad ensures saved transactions are validated again and added
+// back to the memory pool with the time they originally entered it, without
+// being relayed, and that the fee estimator only keeps tracking the ones it
+// already tracked before they were saved.
+func TestMempoolLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, mempoolFileName)
+
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{MaxMempool: 1000000, MempoolExpiry: 24 * time.Hour}
+	defer func(oldParams *params) { activeNetParams = oldParams }(activeNetParams)
+	activeNetParams = &regressionNetParams
+
+	// The block after the genesis block has a transaction with two
+	// outputs which are spent by the saved transactions.
+	addrScript := []byte{txscript.OP_TRUE}
+	coinbaseTx := wire.NewMsgTx()
+	coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&wire.ShaHash{},
+		wire.MaxPrevOutIndex), []byte{0x51, 0x51}))
+	coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, addrScript))
+	coinbaseHash, err := coinbaseTx.TxSha()
+	if err != nil {
+		t.Fatalf("TxSha: %v", err)
+	}
+	fundTx := wire.NewMsgTx()
+	fundTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&coinbaseHash, 0), nil))
+	fundTx.AddTxOut(wire.NewTxOut(100000000, addrScript))
+	fundTx.AddTxOut(wire.NewTxOut(100000000, addrScript))
+	fundHash, err := fundTx.TxSha()
+	if err != nil {
+		t.Fatalf("TxSha: %v", err)
+	}
+	db, err := database.CreateDB("memdb")
+	if err != nil {
+		t.Fatalf("CreateDB: %v", err)
+	}
+	defer db.Close()
+	chainParams := activeNetParams.Params
+	genesis := btcutil.NewBlock(chainParams.GenesisBlock)
+	genesisHash, err := genesis.Sha()
+	if err != nil {
+		t.Fatalf("Sha: %v", err)
+	}
+	block := wire.NewMsgBlock(&wire.BlockHeader{PrevBlock: *genesisHash})
+	block.AddTransaction(coinbaseTx)
+	block.AddTransaction(fundTx)
+	for _, b := range []*btcutil.Block{genesis, btcutil.NewBlock(block)} {
+		if _, err := db.InsertBlock(b); err != nil {
+			t.Fatalf("InsertBlock: %v", err)
+		}
+	}
+
+	spend := func(index uint32) *btcutil.Tx {
+		msgTx := wire.NewMsgTx()
+		prevOut := wire.NewOutPoint(&fundHash, index)
+		msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+		msgTx.AddTxOut(wire.NewTxOut(99990000, addrScript))
+		return btcutil.NewTx(msgTx)
+	}
+	trackedTx := spend(0)
+	untrackedTx := spend(1)
+	now := time.Unix(time.Now().Unix(), 0)
+	added := map[wire.ShaHash]time.Time{
+		*trackedTx.Sha():   now.Add(-2 * time.Hour),
+		*untrackedTx.Sha(): now.Add(-time.Hour),
+	}
+	saved := &txMemPool{
+		pool:    make(map[wire.ShaHash]*TxDesc),
+		orphans: make(map[wire.ShaHash]*orphanTx),
+	}
+	for _, tx := range []*btcutil.Tx{trackedTx, untrackedTx} {
+		saved.pool[*tx.Sha()] = &TxDesc{Tx: tx, Added: added[*tx.Sha()]}
+	}
+	saved.Save(file)
+
+	// The fee estimator tracked one of the transactions since before the
+	// chain reached its current height.
+	s := &server{
+		db: db,
+		feeEstimator: newFeeEstimator(filepath.Join(dir,
+			feeEstimatorFileName)),
+		relayInv: make(chan relayMsg, 2),
+	}
+	s.feeEstimator.ObserveTransaction(&TxDesc{Tx: trackedTx, Fee: 10000},
+		0)
+	s.blockManager = &blockManager{
+		server:     s,
+		blockChain: blockchain.New(db, chainParams, nil),
+	}
+	mp := &txMemPool{
+		server:        s,
+		pool:          make(map[wire.ShaHash]*TxDesc),
+		orphans:       make(map[wire.ShaHash]*orphanTx),
+		orphansByPrev: make(map[wire.ShaHash]*list.List),
+		outpoints:     make(map[wire.OutPoint]*btcutil.Tx),
+		names:         make(map[string]*btcutil.Tx),
+	}
+	if !mp.Load(file, nil) {
+		t.Fatalf("Load: loading was stopped")
+	}
+
+	for _, tx := range []*btcutil.Tx{trackedTx, untrackedTx} {
+		txD, ok := mp.pool[*tx.Sha()]
+		if !ok {
+			t.Errorf("Load: transaction %v was not reloaded",
+				tx.Sha())
+			continue
+		}
+		if !txD.Added.Equal(added[*tx.Sha()]) {
+			t.Errorf("Load: transaction %v got added %v, want %v",
+				tx.Sha(), txD.Added, added[*tx.Sha()])
+		}
+	}
+	if len(s.relayInv) != 0 {
+		t.Errorf("Load: relayed %d reloaded transactions",
+			len(s.relayInv))
+	}
+	o, ok := s.feeEstimator.observed[*trackedTx.Sha()]
+	if !ok || o.height != 0 {
+		t.Errorf("Load: tracked transaction %v is no longer tracked "+
+			"from height 0", trackedTx.Sha())
+	}
+	if s.feeEstimator.IsObserved(untrackedTx.Sha()) {
+		t.Errorf("Load: reloaded transaction %v is tracked by the fee "+
+			"estimator", untrackedTx.Sha())
+	}
+}
+
+// TestMempoolTrimToSize ensures the transactions with the lowest fee rates are
+// evicted from a full pool along with their descendants, that transactions
+// paying for their parents protect them, and that the minimum fee rate is
+// raised above the fee rate of the evicted transactions.
+func TestMempoolTrimToSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{}
+
+	mp := &txMemPool{
+		server: &server{
+			feeEstimator: newFeeEstimator(filepath.Join(dir,
+				feeEstimatorFileName)),
+		},
+		pool:      make(map[wire.ShaHash]*TxDesc),
+		outpoints: make(map[wire.OutPoint]*btcutil.Tx),
+		names:     make(map[string]*btcutil.Tx),
+	}
+
+	// add adds a transaction spending the first output of the passed
+	// transaction which pays the passed fee to the pool.  All transactions
+	// have the same size.
+	lockTime := uint32(0)
+	add := func(parent *btcutil.Tx, fee int64) *btcutil.Tx {
+		lockTime++
+		msgTx := wire.NewMsgTx()
+		var prevOut *wire.OutPoint
+		if parent != nil {
+			prevOut = wire.NewOutPoint(parent.Sha(), 0)
+		} else {
+			prevOut = wire.NewOutPoint(&wire.ShaHash{}, lockTime)
+		}
+		msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+		msgTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+		msgTx.LockTime = lockTime
+		tx := btcutil.NewTx(msgTx)
+		mp.addTransaction(tx, 1, fee)
+		return tx
+	}
+
+	// The child pays enough fees for its parent to be kept longer than the
+	// transaction paying the second highest fees alone.
+	high := add(nil, 6000)
+	parent := add(nil, 1000)
+	child := add(parent, 9000)
+	low := add(nil, 2000)
+	txSize := int64(high.MsgTx().SerializeSize())
+	if mp.totalSize != 4*txSize {
+		t.Fatalf("got size %d, want %d", mp.totalSize, 4*txSize)
+	}
+
+	count, size, fees := mp.descendants(mp.pool[*parent.Sha()])
+	if count != 2 || size != 2*txSize || fees != 10000 {
+		t.Fatalf("descendants: got %d/%d/%d, want 2/%d/10000", count,
+			size, fees, 2*txSize)
+	}
+	count, size, fees = mp.ancestors(mp.pool[*child.Sha()])
+	if count != 2 || size != 2*txSize || fees != 10000 {
+		t.Fatalf("ancestors: got %d/%d/%d, want 2/%d/10000", count,
+			size, fees, 2*txSize)
+	}
+
+	// check ensures exactly the passed transactions are left in the pool
+	// and the minimum fee rate was raised above the passed one.
+	check := func(desc string, evictedFee int64, want ...*btcutil.Tx) {
+		if len(mp.pool) != len(want) {
+			t.Fatalf("%s: got %d transactions, want %d", desc,
+				len(mp.pool), len(want))
+		}
+		for _, tx := range want {
+			if !mp.isTransactionInPool(tx.Sha()) {
+				t.Fatalf("%s: transaction %v was evicted", desc,
+					tx.Sha())
+			}
+		}
+		if mp.totalSize != int64(len(want))*txSize {
+			t.Fatalf("%s: got size %d, want %d", desc, mp.totalSize,
+				int64(len(want))*txSize)
+		}
+		evictedRate := float64(evictedFee) * 1000 / float64(txSize)
+		if mp.rollingMinFee <= evictedRate {
+			t.Fatalf("%s: got minimum fee rate %v, want more than %v",
+				desc, mp.rollingMinFee, evictedRate)
+		}
+	}
+
+	cfg.MaxMempool = uint64(3 * txSize)
+	mp.trimToSize()
+	check("low", 2000, high, parent, child)
+	if mp.isTransactionInPool(low.Sha()) {
+		t.Fatalf("low: transaction %v was not evicted", low.Sha())
+	}
+
+	cfg.MaxMempool = uint64(2 * txSize)
+	mp.trimToSize()
+	check("package", 5000, high)
+}
+
+// TestMempoolDescendantTotals ensures the descendant totals of the
+// transactions in the pool are kept up to date as transactions are added and
+// removed, including transactions depending on each other in several ways and
+// transactions added back before transactions spending them, and that the
+// eviction queue stays ordered by the eviction fee rates.
+func TestMempoolDescendantTotals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{}
+
+	mp := &txMemPool{
+		server: &server{
+			feeEstimator: newFeeEstimator(filepath.Join(dir,
+				feeEstimatorFileName)),
+		},
+		pool:      make(map[wire.ShaHash]*TxDesc),
+		outpoints: make(map[wire.OutPoint]*btcutil.Tx),
+		names:     make(map[string]*btcutil.Tx),
+	}
+
+	// newTx returns a transaction with two outputs spending the passed
+	// outputs of the passed transactions or an unknown output if there
+	// are none.  All transactions have the same size when they spend the
+	// same number of outputs.
+	lockTime := uint32(0)
+	newTx := func(prevOuts ...*wire.OutPoint) *btcutil.Tx {
+		lockTime++
+		msgTx := wire.NewMsgTx()
+		if len(prevOuts) == 0 {
+			prevOuts = append(prevOuts,
+				wire.NewOutPoint(&wire.ShaHash{}, lockTime))
+		}
+		for _, prevOut := range prevOuts {
+			msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+		}
+		msgTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+		msgTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+		msgTx.LockTime = lockTime
+		return btcutil.NewTx(msgTx)
+	}
+	out := func(tx *btcutil.Tx, index uint32) *wire.OutPoint {
+		return wire.NewOutPoint(tx.Sha(), index)
+	}
+
+	// check ensures the descendant totals of the passed transaction match
+	// the passed transactions and the ones calculated from scratch.
+	check := func(desc string, tx *btcutil.Tx, want ...*btcutil.Tx) {
+		txD := mp.pool[*tx.Sha()]
+		var wantSize, wantFees int64
+		for _, tx := range want {
+			wantSize += int64(tx.MsgTx().SerializeSize())
+			wantFees += mp.pool[*tx.Sha()].Fee
+		}
+		if txD.descendantCount != len(want) ||
+			txD.descendantSize != wantSize ||
+			txD.descendantFees != wantFees {
+
+			t.Errorf("%s: got totals %d/%d/%d, want %d/%d/%d", desc,
+				txD.descendantCount, txD.descendantSize,
+				txD.descendantFees, len(want), wantSize,
+				wantFees)
+		}
+		count, size, fees := mp.descendants(txD)
+		if count != len(want) || size != wantSize || fees != wantFees {
+			t.Errorf("%s: descendants got %d/%d/%d, want %d/%d/%d",
+				desc, count, size, fees, len(want), wantSize,
+				wantFees)
+		}
+	}
+
+	// The child spends both outputs of the root, once directly and once
+	// through the middle transaction.
+	root := newTx()
+	middle := newTx(out(root, 0))
+	child := newTx(out(root, 1), out(middle, 0))
+	mp.addTransaction(root, 1, 1000)
+	mp.addTransaction(middle, 1, 3000)
+	mp.addTransaction(child, 1, 5000)
+	check("root", root, root, middle, child)
+	check("middle", middle, middle, child)
+	check("child", child, child)
+
+	// Adding a transaction back before the one spending it, as happens
+	// when a block is disconnected, counts the transaction in the pool.
+	mined := newTx()
+	spender := newTx(out(mined, 0), out(child, 0))
+	mp.addTransaction(spender, 1, 100)
+	mp.addTransaction(mined, 1, 200)
+	check("readded", mined, mined, spender)
+	check("root with readded", root, root, middle, child, spender)
+	check("middle with readded", middle, middle, child, spender)
+
+	// The transaction with the lowest eviction fee rate is at the front of
+	// the eviction queue.
+	front := mp.evictionQueue[0]
+	if !front.Tx.Sha().IsEqual(spender.Sha()) {
+		t.Errorf("got %v at the front of the eviction queue, want %v",
+			front.Tx.Sha(), spender.Sha())
+	}
+	for i, txD := range mp.evictionQueue {
+		if txD.evictionIndex != i {
+			t.Errorf("got eviction index %d, want %d",
+				txD.evictionIndex, i)
+		}
+	}
+
+	// Removing the child removes the transaction spending it and drops
+	// both from the totals of the transactions they depend on.
+	mp.removeTransaction(child)
+	check("root after removal", root, root, middle)
+	check("middle after removal", middle, middle)
+	check("readded after removal", mined, mined)
+	if len(mp.evictionQueue) != len(mp.pool) {
+		t.Errorf("got %d transactions in the eviction queue, want %d",
+			len(mp.evictionQueue), len(mp.pool))
+	}
+}
+
+// TestMempoolExpire ensures transactions which stayed in the pool for too long
+// are removed along with their descendants and orphans are removed once their
+// time to live passed.
+func TestMempoolExpire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{MempoolExpiry: time.Hour, OrphanTTL: time.Minute}
+
+	mp := &txMemPool{
+		server: &server{
+			feeEstimator: newFeeEstimator(filepath.Join(dir,
+				feeEstimatorFileName)),
+		},
+		pool:          make(map[wire.ShaHash]*TxDesc),
+		orphans:       make(map[wire.ShaHash]*orphanTx),
+		orphansByPrev: make(map[wire.ShaHash]*list.List),
+		outpoints:     make(map[wire.OutPoint]*btcutil.Tx),
+		names:         make(map[string]*btcutil.Tx),
+	}
+
+	// newTx returns a transaction spending the first output of the passed
+	// transaction or an unknown output if it is nil.
+	lockTime := uint32(0)
+	newTx := func(parent *btcutil.Tx) *btcutil.Tx {
+		lockTime++
+		msgTx := wire.NewMsgTx()
+		prevOut := wire.NewOutPoint(&wire.ShaHash{}, lockTime)
+		if parent != nil {
+			prevOut = wire.NewOutPoint(parent.Sha(), 0)
+		}
+		msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+		msgTx.AddTxOut(wire.NewTxOut(1, []byte{0x51}))
+		msgTx.LockTime = lockTime
+		return btcutil.NewTx(msgTx)
+	}
+
+	// The child of the expired transaction entered the pool recently, but
+	// can't be mined without its parent.
+	expired := newTx(nil)
+	child := newTx(expired)
+	fresh := newTx(nil)
+	for _, tx := range []*btcutil.Tx{expired, child, fresh} {
+		mp.addTransaction(tx, 1, 0)
+	}
+	mp.pool[*expired.Sha()].Added = time.Now().Add(-2 * time.Hour)
+
+	expiredOrphan := newTx(nil)
+	freshOrphan := newTx(nil)
+	mp.addOrphan(expiredOrphan)
+	mp.addOrphan(freshOrphan)
+	mp.orphans[*expiredOrphan.Sha()].expiration = time.Now().Add(-time.Second)
+
+	mp.ExpireTransactions()
+	if len(mp.pool) != 1 || !mp.isTransactionInPool(fresh.Sha()) {
+		t.Errorf("got %d transactions, want only %v", len(mp.pool),
+			fresh.Sha())
+	}
+	if len(mp.orphans) != 1 || !mp.isOrphanInPool(freshOrphan.Sha()) {
+		t.Errorf("got %d orphans, want only %v", len(mp.orphans),
+			freshOrphan.Sha())
+	}
+	if len(mp.orphansByPrev) != 1 {
+		t.Errorf("got %d orphan index entries, want 1",
+			len(mp.orphansByPrev))
+	}
+}
+
+// TestMempoolNameConflicts ensures only one pending operation per name is
+// accepted into the pool, that a name_firstupdate revealing an immature
+// name_new is rejected, and that a name is no longer pending once the
+// transaction operating on it is mined or removed.
+func TestMempoolNameConflicts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{}
+	defer func(oldParams *params) { activeNetParams = oldParams }(activeNetParams)
+	activeNetParams = &regressionNetParams
+
+	// nameTx returns a Namecoin transaction spending the passed output
+	// which pays to the passed name script followed by an address script.
+	addrScript := []byte{txscript.OP_TRUE}
+	nameTx := func(prevOut *wire.OutPoint,
+		builder *txscript.ScriptBuilder) *btcutil.Tx {
+
+		script, err := builder.Script()
+		if err != nil {
+			t.Fatalf("Script: %v", err)
+		}
+		msgTx := wire.NewMsgTx()
+		msgTx.Version = wire.NamecoinTxVersion
+		msgTx.AddTxIn(wire.NewTxIn(prevOut, nil))
+		msgTx.AddTxOut(wire.NewTxOut(1000000,
+			append(script, addrScript...)))
+		return btcutil.NewTx(msgTx)
+	}
+	name := []byte("d/example")
+	rand := []byte("salt")
+	commitment := btcutil.Hash160(append(append([]byte(nil), rand...),
+		name...))
+	newScript := func() *txscript.ScriptBuilder {
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_NAME_NEW).
+			AddData(commitment).AddOp(txscript.OP_2DROP)
+	}
+	firstUpdateScript := func(value string) *txscript.ScriptBuilder {
+		return txscript.NewScriptBuilder().
+			AddOp(txscript.OP_NAME_FIRSTUPDATE).AddData(name).
+			AddData(rand).AddData([]byte(value)).
+			AddOp(txscript.OP_2DROP).AddOp(txscript.OP_2DROP)
+	}
+	updateScript := func(value string) *txscript.ScriptBuilder {
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_NAME_UPDATE).
+			AddData(name).AddData([]byte(value)).
+			AddOp(txscript.OP_2DROP).AddOp(txscript.OP_DROP)
+	}
+	outPoint := func(tx *btcutil.Tx) *wire.OutPoint {
+		return wire.NewOutPoint(tx.Sha(), 0)
+	}
+
+	// The main chain has a name_new in the block after the genesis block,
+	// which is too recent to be revealed in the next block.
+	coinbaseTx := wire.NewMsgTx()
+	coinbaseTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&wire.ShaHash{},
+		wire.MaxPrevOutIndex), []byte{0x51, 0x51}))
+	coinbaseTx.AddTxOut(wire.NewTxOut(5000000000, addrScript))
+	minedNewTx := nameTx(outPoint(btcutil.NewTx(coinbaseTx)), newScript())
+	db, err := database.CreateDB("memdb")
+	if err != nil {
+		t.Fatalf("CreateDB: %v", err)
+	}
+	defer db.Close()
+	chainParams := activeNetParams.Params
+	genesis := btcutil.NewBlock(chainParams.GenesisBlock)
+	genesisHash, err := genesis.Sha()
+	if err != nil {
+		t.Fatalf("Sha: %v", err)
+	}
+	block := wire.NewMsgBlock(&wire.BlockHeader{PrevBlock: *genesisHash})
+	block.AddTransaction(coinbaseTx)
+	block.AddTransaction(minedNewTx.MsgTx())
+	for _, b := range []*btcutil.Block{genesis, btcutil.NewBlock(block)} {
+		if _, err := db.InsertBlock(b); err != nil {
+			t.Fatalf("InsertBlock: %v", err)
+		}
+	}
+
+	s := &server{
+		db: db,
+		feeEstimator: newFeeEstimator(filepath.Join(dir,
+			feeEstimatorFileName)),
+	}
+	s.blockManager = &blockManager{
+		server:     s,
+		blockChain: blockchain.New(db, chainParams, nil),
+	}
+	mp := &txMemPool{
+		server:        s,
+		pool:          make(map[wire.ShaHash]*TxDesc),
+		orphans:       make(map[wire.ShaHash]*orphanTx),
+		orphansByPrev: make(map[wire.ShaHash]*list.List),
+		outpoints:     make(map[wire.OutPoint]*btcutil.Tx),
+		names:         make(map[string]*btcutil.Tx),
+	}
+
+	// isNameRuleError returns whether the passed error is a rule error of
+	// the block chain for violating the name rules.
+	isNameRuleError := func(err error) bool {
+		rerr, ok := err.(RuleError)
+		if !ok {
+			return false
+		}
+		cerr, ok := rerr.Err.(blockchain.RuleError)
+		return ok && cerr.ErrorCode == blockchain.ErrNameValidation
+	}
+
+	// A name_firstupdate revealing a name_new which is either in the pool
+	// or mined in the latest block is immature.
+	poolNewTx := nameTx(wire.NewOutPoint(&wire.ShaHash{0x02}, 0),
+		newScript())
+	mp.addTransaction(poolNewTx, 1, 0)
+	for _, newTx := range []*btcutil.Tx{minedNewTx, poolNewTx} {
+		regTx := nameTx(outPoint(newTx), firstUpdateScript("immature"))
+		_, err := mp.maybeAcceptTransaction(regTx, true, false)
+		if !isNameRuleError(err) {
+			t.Errorf("maybeAcceptTransaction: got %v for immature "+
+				"name_new %v, want name rule error", err,
+				newTx.Sha())
+		}
+	}
+	if len(mp.names) != 0 {
+		t.Fatalf("got %d pending names, want none", len(mp.names))
+	}
+
+	// Once a name has a pending operation, other operations on it are
+	// rejected as duplicates.
+	pendingTx := nameTx(wire.NewOutPoint(&wire.ShaHash{0x03}, 0),
+		firstUpdateScript("pending"))
+	mp.addTransaction(pendingTx, 1, 0)
+	conflicts := []*btcutil.Tx{
+		nameTx(wire.NewOutPoint(&wire.ShaHash{0x04}, 0),
+			firstUpdateScript("other")),
+		nameTx(outPoint(pendingTx), updateScript("update")),
+	}
+	for _, tx := range conflicts {
+		_, err := mp.maybeAcceptTransaction(tx, true, false)
+		code, found := extractRejectCode(err)
+		if !found || code != wire.RejectDuplicate {
+			t.Errorf("maybeAcceptTransaction: got %v for conflicting "+
+				"transaction %v, want reject code %v", err,
+				tx.Sha(), wire.RejectDuplicate)
+		}
+	}
+
+	// Removing the transaction, such as when it is mined, clears the
+	// pending name.
+	mp.RemoveTransaction(pendingTx)
+	if pending, ok := mp.names[string(name)]; ok {
+		t.Errorf("RemoveTransaction: name still pending in %v",
+			pending.Sha())
+	}
+
+	// A mined transaction operating on a pending name removes the pending
+	// transaction along with the transactions spending it.
+	mp.addTransaction(pendingTx, 1, 0)
+	childTx := nameTx(outPoint(pendingTx), newScript())
+	mp.addTransaction(childTx, 1, 0)
+	minedTx := conflicts[0]
+	mp.RemoveNameConflicts(minedTx)
+	if pending, ok := mp.names[string(name)]; ok {
+		t.Errorf("RemoveNameConflicts: name still pending in %v",
+			pending.Sha())
+	}
+	for _, tx := range []*btcutil.Tx{pendingTx, childTx} {
+		if mp.isTransactionInPool(tx.Sha()) {
+			t.Errorf("RemoveNameConflicts: transaction %v still "+
+				"in pool", tx.Sha())
+		}
+	}
+	if !mp.isTransactionInPool(poolNewTx.Sha()) {
+		t.Errorf("RemoveNameConflicts: removed unrelated transaction %v",
+			poolNewTx.Sha())
+	}
+}
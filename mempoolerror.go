@@ -31,10 +31,13 @@ func (e RuleError) Error() string {
 // processing of a transaction failed due to one of the many validation
 // rules.  The caller can use type assertions to determine if a failure was
 // specifically due to a rule violation and access the ErrorCode field to
-// ascertain the specific reason for the rule violation.
+// ascertain the specific reason for the rule violation.  Violations which are
+// considered an attack also carry the transient ban score the peer which
+// relayed the transaction earns.
 type TxRuleError struct {
 	RejectCode  wire.RejectCode // The code to send with reject messages
 	Description string          // Human readable description of the issue
+	BanScore    uint32          // Transient ban score of the relaying peer
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -50,6 +53,18 @@ func txRuleError(c wire.RejectCode, desc string) RuleError {
 	}
 }
 
+// extractBanScore returns the transient ban score the peer which relayed a
+// transaction earns for the passed error, which is zero unless the error is a
+// rule violation considered an attack.
+func extractBanScore(err error) uint32 {
+	if rerr, ok := err.(RuleError); ok {
+		if txErr, ok := rerr.Err.(TxRuleError); ok {
+			return txErr.BanScore
+		}
+	}
+	return 0
+}
+
 // chainRuleError returns a RuleError that encapsulates the given
 // blockchain.RuleError.
 func chainRuleError(chainErr blockchain.RuleError) RuleError {
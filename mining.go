@@ -474,6 +474,11 @@ func NewBlockTemplate(mempool *txMemPool, payToAddress btcutil.Address) (*BlockT
 	blockTxns = append(blockTxns, coinbaseTx)
 	blockTxStore := make(blockchain.TxStore)
 
+	// blockNames tracks the names operated on by the transactions selected
+	// for the block.  Only one operation per name is included in a block
+	// which avoids conflicting registrations of the same name.
+	blockNames := make(map[string]struct{})
+
 	// dependers is used to track transactions which depend on another
 	// transaction in the memory pool.  This, in conjunction with the
 	// dependsOn map kept with each dependent transaction helps quickly
@@ -701,6 +706,46 @@ mempoolLoop:
 			}
 		}
 
+		// Ensure the name operations of the transaction are valid and
+		// don't operate on a name another transaction in the block
+		// already operates on.
+		var txNames []string
+		for _, txOut := range tx.MsgTx().TxOut {
+			ns := txscript.ExtractNameScript(txOut.PkScript)
+			if ns != nil && ns.Op != txscript.OP_NAME_NEW {
+				txNames = append(txNames, string(ns.Name))
+			}
+		}
+		nameConflict := false
+		for _, name := range txNames {
+			if _, exists := blockNames[name]; exists {
+				minrLog.Tracef("Skipping tx %s since name %q is "+
+					"already updated in the block", tx.Sha(),
+					name)
+				nameConflict = true
+				break
+			}
+		}
+		if nameConflict {
+			logSkippedDeps(tx, deps)
+			continue
+		}
+		nameView, err := chain.FetchNameView(tx)
+		if err != nil {
+			minrLog.Warnf("Unable to fetch name view for tx %s: %v",
+				tx.Sha(), err)
+			logSkippedDeps(tx, deps)
+			continue
+		}
+		err = blockchain.CheckTransactionNames(tx, nextBlockHeight,
+			blockTxStore, nameView, activeNetParams.Params)
+		if err != nil {
+			minrLog.Tracef("Skipping tx %s due to error in "+
+				"CheckTransactionNames: %v", tx.Sha(), err)
+			logSkippedDeps(tx, deps)
+			continue
+		}
+
 		// Ensure the transaction inputs pass all of the necessary
 		// preconditions before allowing it to be added to the block.
 		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
@@ -725,6 +770,9 @@ mempoolLoop:
 		// reference this one have it available as an input and can
 		// ensure they aren't double spending.
 		spendTransaction(blockTxStore, tx, nextBlockHeight)
+		for _, name := range txNames {
+			blockNames[name] = struct{}{}
+		}
 
 		// Add the transaction to the block, increment counters, and
 		// save the fees and signature operation counts to the block
@@ -786,6 +834,8 @@ mempoolLoop:
 		Timestamp:  ts,
 		Bits:       requiredDifficulty,
 	}
+	msgBlock.Header.SetBaseVersion(generatedBlockVersion,
+		activeNetParams.AuxPowChainID)
 	for _, tx := range blockTxns {
 		if err := msgBlock.AddTransaction(tx.MsgTx()); err != nil {
 			return nil, err
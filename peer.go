@@ -9,9 +9,11 @@ import (
 	"container/list"
 	"fmt"
 	"io"
+	"math"
 	prand "math/rand"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,7 +30,7 @@ import (
 
 const (
 	// maxProtocolVersion is the max protocol version the peer supports.
-	maxProtocolVersion = 70002
+	maxProtocolVersion = 70012
 
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
@@ -53,6 +55,31 @@ const (
 	// pingTimeoutMinutes is the number of minutes since we last sent a
 	// message requiring a reply before we will ping a host.
 	pingTimeoutMinutes = 2
+
+	// banScoreHalfLife is the time it takes the transient part of the ban
+	// score of a peer to decay to half its value.
+	banScoreHalfLife = time.Minute
+
+	// banScoreInvalidBlock is the ban score of a peer which sent a block,
+	// including its auxpow, which violates the consensus rules.
+	banScoreInvalidBlock = 100
+
+	// banScoreMalformedMsg is the ban score of a peer which sent a message
+	// that can't be decoded.
+	banScoreMalformedMsg = 20
+
+	// banScoreNonContinuousHeaders is the ban score of a peer which sent
+	// headers that don't connect to each other.
+	banScoreNonContinuousHeaders = 20
+
+	// banScoreOversizedOrphan is the transient ban score of a peer which
+	// sent an orphan transaction too large to be kept in the orphan pool.
+	// It decays, so only peers flooding the node with them are banned.
+	banScoreOversizedOrphan = 10
+
+	// banScoreFilterAbuse is the ban score of a peer which sent a bloom
+	// filter message without loading a filter first.
+	banScoreFilterAbuse = 100
 )
 
 var (
@@ -116,6 +143,48 @@ func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress,
 	return na, nil
 }
 
+// banScore tracks the misbehavior of a peer.  It consists of a persistent part
+// which never decays and a transient part which decays exponentially with a
+// half-life of banScoreHalfLife, so occasional minor misbehavior is forgiven.
+// It is safe for concurrent access.
+type banScore struct {
+	sync.Mutex
+	persistent uint32
+	transient  float64
+	lastDecay  time.Time
+}
+
+// decay decays the transient part of the score according to the time passed
+// since it was last decayed.
+//
+// This function MUST be called with the score lock held.
+func (s *banScore) decay(now time.Time) {
+	elapsed := now.Sub(s.lastDecay)
+	s.transient *= math.Pow(0.5, float64(elapsed)/float64(banScoreHalfLife))
+	s.lastDecay = now
+}
+
+// Int returns the current score.
+func (s *banScore) Int() uint32 {
+	s.Lock()
+	defer s.Unlock()
+
+	s.decay(time.Now())
+	return s.persistent + uint32(s.transient)
+}
+
+// Increase adds the passed persistent and transient amounts to the score and
+// returns the resulting score.
+func (s *banScore) Increase(persistent, transient uint32) uint32 {
+	s.Lock()
+	defer s.Unlock()
+
+	s.decay(time.Now())
+	s.persistent += persistent
+	s.transient += float64(transient)
+	return s.persistent + uint32(s.transient)
+}
+
 // outMsg is used to house a message to be sent along with a channel to signal
 // when the message has been sent (or won't be sent due to things such as
 // shutdown)
@@ -152,6 +221,7 @@ type peer struct {
 	na                 *wire.NetAddress
 	inbound            bool
 	persistent         bool
+	whitelisted        bool
 	knownAddresses     map[string]struct{}
 	knownInventory     *MruInventoryMap
 	knownInvMutex      sync.Mutex
@@ -166,6 +236,7 @@ type peer struct {
 	filter             *bloom.Filter
 	relayMtx           sync.Mutex
 	disableRelayTx     bool
+	wantsHeaders       bool
 	continueHash       *wire.ShaHash
 	outputQueue        chan outMsg
 	sendQueue          chan outMsg
@@ -189,6 +260,7 @@ type peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	banScore           banScore
 }
 
 // String returns the peer's address and directionality as a human-readable
@@ -218,6 +290,42 @@ func (p *peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// addBanScore increases the misbehavior score of the peer by the passed
+// persistent and transient amounts for the passed reason.  The peer is banned
+// for the configured ban duration and disconnected once the score reaches the
+// configured ban threshold.  Whitelisted peers are never banned.  It returns
+// whether the peer was banned.  It is safe for concurrent access.
+func (p *peer) addBanScore(persistent, transient uint32, reason string) bool {
+	score := p.banScore.Increase(persistent, transient)
+	if score < cfg.BanThreshold {
+		if score > cfg.BanThreshold/2 {
+			peerLog.Warnf("Misbehaving peer %s: %s -- ban score "+
+				"increased to %d", p, reason, score)
+		} else {
+			peerLog.Debugf("Misbehaving peer %s: %s -- ban score "+
+				"increased to %d", p, reason, score)
+		}
+		return false
+	}
+	if p.whitelisted {
+		peerLog.Warnf("Misbehaving whitelisted peer %s: %s -- ban "+
+			"score %d exceeds the threshold, not banning", p,
+			reason, score)
+		return false
+	}
+
+	// Only ban the peer once even though it might still misbehave while
+	// it is being disconnected.
+	if atomic.LoadInt32(&p.disconnect) != 0 {
+		return true
+	}
+	peerLog.Warnf("Misbehaving peer %s: %s -- ban score %d exceeds the "+
+		"threshold, banning and disconnecting", p, reason, score)
+	p.server.BanPeer(p)
+	p.Disconnect()
+	return true
+}
+
 // VersionKnown returns the whether or not the version of a peer is known locally.
 // It is safe for concurrent access.
 func (p *peer) VersionKnown() bool {
@@ -245,6 +353,16 @@ func (p *peer) RelayTxDisabled() bool {
 	return p.disableRelayTx
 }
 
+// WantsHeaders returns whether or not the peer asked for new blocks to be
+// announced with their headers rather than inventory vectors.  It is safe for
+// concurrent access.
+func (p *peer) WantsHeaders() bool {
+	p.relayMtx.Lock()
+	defer p.relayMtx.Unlock()
+
+	return p.wantsHeaders
+}
+
 // pushVersionMsg sends a version message to the connected peer using the
 // current state.
 func (p *peer) pushVersionMsg() error {
@@ -821,6 +939,26 @@ func (p *peer) handleHeadersMsg(msg *wire.MsgHeaders) {
 	p.server.blockManager.QueueHeaders(msg, p)
 }
 
+// handleVerAckMsg is invoked when a peer receives a verack bitcoin message.  It
+// asks the peer to announce new blocks with their headers when it supports
+// headers announcements (BIP0130).
+func (p *peer) handleVerAckMsg(msg *wire.MsgVerAck) {
+	if p.VersionKnown() &&
+		p.ProtocolVersion() >= wire.SendHeadersVersion {
+
+		p.QueueMessage(wire.NewMsgSendHeaders(), nil)
+	}
+}
+
+// handleSendHeadersMsg is invoked when a peer receives a sendheaders bitcoin
+// message.  New blocks are announced to the peer with their headers from then
+// on.
+func (p *peer) handleSendHeadersMsg(msg *wire.MsgSendHeaders) {
+	p.relayMtx.Lock()
+	p.wantsHeaders = true
+	p.relayMtx.Unlock()
+}
+
 // handleGetData is invoked when a peer receives a getdata bitcoin message and
 // is used to deliver block and transaction information.
 func (p *peer) handleGetDataMsg(msg *wire.MsgGetData) {
@@ -1060,13 +1198,15 @@ func (p *peer) handleGetHeadersMsg(msg *wire.MsgGetHeaders) {
 
 // handleFilterAddMsg is invoked when a peer receives a filteradd bitcoin
 // message and is used by remote peers to add data to an already loaded bloom
-// filter.  The peer will be disconnected if a filter is not loaded when this
-// message is received.
+// filter.  The ban score of the peer is increased and it is disconnected,
+// unless it is whitelisted, if a filter is not loaded when this message is
+// received.
 func (p *peer) handleFilterAddMsg(msg *wire.MsgFilterAdd) {
 	if !p.filter.IsLoaded() {
-		peerLog.Debugf("%s sent a filteradd request with no filter "+
-			"loaded -- disconnecting", p)
-		p.Disconnect()
+		if !p.addBanScore(banScoreFilterAbuse, 0, "sent a filteradd "+
+			"request with no filter loaded") && !p.whitelisted {
+			p.Disconnect()
+		}
 		return
 	}
 
@@ -1075,13 +1215,14 @@ func (p *peer) handleFilterAddMsg(msg *wire.MsgFilterAdd) {
 
 // handleFilterClearMsg is invoked when a peer receives a filterclear bitcoin
 // message and is used by remote peers to clear an already loaded bloom filter.
-// The peer will be disconnected if a filter is not loaded when this message is
-// received.
+// The ban score of the peer is increased and it is disconnected, unless it is
+// whitelisted, if a filter is not loaded when this message is received.
 func (p *peer) handleFilterClearMsg(msg *wire.MsgFilterClear) {
 	if !p.filter.IsLoaded() {
-		peerLog.Debugf("%s sent a filterclear request with no "+
-			"filter loaded -- disconnecting", p)
-		p.Disconnect()
+		if !p.addBanScore(banScoreFilterAbuse, 0, "sent a filterclear "+
+			"request with no filter loaded") && !p.whitelisted {
+			p.Disconnect()
+		}
 		return
 	}
 	p.filter.Unload()
@@ -1368,6 +1509,28 @@ func (p *peer) isAllowedByRegression(err error) bool {
 	return true
 }
 
+// isSkippableMessageError returns whether or not the passed error from reading
+// a message leaves the connection at the start of the next message, so the
+// malformed message can be skipped without disconnecting the peer.  This is the
+// case when the payload was read completely but its checksum didn't match or
+// it couldn't be decoded, and when the payload of a message with an unknown
+// command was discarded.  Other errors, such as a payload which is too large to
+// be read or a message from another network, leave the stream unusable.
+func isSkippableMessageError(err error) bool {
+	msgErr, ok := err.(*wire.MessageError)
+	if !ok {
+		return false
+	}
+
+	// Errors from decoding the payload come from the decode function of
+	// the message rather than from reading it.
+	if msgErr.Func != "ReadMessage" {
+		return true
+	}
+	return strings.HasPrefix(msgErr.Description, "payload checksum failed") ||
+		strings.HasPrefix(msgErr.Description, "unhandled command")
+}
+
 // inHandler handles all incoming messages for the peer.  It must be run as a
 // goroutine.
 func (p *peer) inHandler() {
@@ -1398,6 +1561,30 @@ out:
 				continue
 			}
 
+			// Skip malformed messages like the regression test
+			// mode does, but increase the ban score of the peer so
+			// it is banned when it keeps sending them.  Errors
+			// which leave the read position in the middle of a
+			// message disconnect the peer right away since the
+			// following messages can't be read.
+			if isSkippableMessageError(err) &&
+				atomic.LoadInt32(&p.disconnect) == 0 {
+
+
+				errMsg := fmt.Sprintf("Can't read message "+
+					"from %s: %v", p, err)
+				p.logError(errMsg)
+				p.PushRejectMsg("malformed", wire.RejectMalformed,
+					errMsg, nil, false)
+				if !p.addBanScore(banScoreMalformedMsg, 0,
+					"sent a malformed message") {
+
+					idleTimer.Reset(idleTimeoutMinutes *
+						time.Minute)
+					continue
+				}
+			}
+
 			// Only log the error and possibly send reject message
 			// if we're not forcibly disconnecting.
 			if atomic.LoadInt32(&p.disconnect) == 0 {
@@ -1451,7 +1638,10 @@ out:
 			markConnected = true
 
 		case *wire.MsgVerAck:
-			// Do nothing.
+			p.handleVerAckMsg(msg)
+
+		case *wire.MsgSendHeaders:
+			p.handleSendHeadersMsg(msg)
 
 		case *wire.MsgGetAddr:
 			p.handleGetAddrMsg(msg)
@@ -1915,6 +2105,7 @@ func newInboundPeer(s *server, conn net.Conn) *peer {
 	p := newPeerBase(s, true)
 	p.conn = conn
 	p.addr = conn.RemoteAddr().String()
+	p.whitelisted = isWhitelisted(conn.RemoteAddr())
 	p.timeConnected = time.Now()
 	atomic.AddInt32(&p.connected, 1)
 	return p
@@ -1968,6 +2159,11 @@ func newOutboundPeer(s *server, addr string, persistent bool, retryCount int64)
 			srvrLog.Debugf("Retrying connection to %s in %s", addr, scaledDuration)
 			time.Sleep(scaledDuration)
 		}
+		if _, banned := s.banList.IsBanned(p.na.IP); banned {
+			srvrLog.Debugf("Not connecting to banned address %s", addr)
+			p.server.donePeers <- p
+			return
+		}
 		srvrLog.Debugf("Attempting to connect to %s", addr)
 		conn, err := btcdDial("tcp", addr)
 		if err != nil {
@@ -1992,6 +2188,45 @@ func newOutboundPeer(s *server, addr string, persistent bool, retryCount int64)
 	return p
 }
 
+// peerIP returns the IP address of the passed peer.  Outbound peers which were
+// added by host name are identified by the address the name resolved to.  It
+// returns nil when the address can't be determined.
+func peerIP(p *peer) net.IP {
+	host, _, err := net.SplitHostPort(p.addr)
+	if err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return ip
+		}
+	}
+	if p.na != nil {
+		return p.na.IP
+	}
+	return nil
+}
+
+// isWhitelisted returns whether the IP address of the passed network address is
+// part of one of the whitelisted networks.
+func isWhitelisted(addr net.Addr) bool {
+	if len(cfg.whitelists) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range cfg.whitelists {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // logError makes sure that we only log errors loudly on user peers.
 func (p *peer) logError(fmt string, args ...interface{}) {
 	if p.persistent {
@@ -0,0 +1,148 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/melange-app/nmcd/wire"
+)
+
+// TestBanScore ensures the transient part of the ban score decays with the
+// configured half-life while the persistent part stays.
+func TestBanScore(t *testing.T) {
+	var s banScore
+	if score := s.Increase(10, 80); score != 90 {
+		t.Fatalf("Increase: got %d, want 90", score)
+	}
+
+	// Pretend the score was last decayed almost two half-lives ago.
+	s.lastDecay = s.lastDecay.Add(-2*banScoreHalfLife + time.Second)
+	if score := s.Int(); score != 30 {
+		t.Fatalf("Int: got %d, want 30", score)
+	}
+	if score := s.Increase(5, 0); score != 35 {
+		t.Fatalf("Increase: got %d, want 35", score)
+	}
+
+	// The transient part vanishes eventually.
+	s.lastDecay = s.lastDecay.Add(-time.Hour)
+	if score := s.Int(); score != 15 {
+		t.Fatalf("Int: got %d, want 15", score)
+	}
+}
+
+// TestWhitelistedPeer ensures peers connecting from whitelisted networks are
+// recognized and never banned however much they misbehave.
+func TestWhitelistedPeer(t *testing.T) {
+	defer func(oldCfg *config) { cfg = oldCfg }(cfg)
+	cfg = &config{BanThreshold: defaultBanThreshold}
+	for _, s := range []string{"10.0.0.0/8", "2001:db8::1"} {
+		ipnet, err := parseSubnet(s)
+		if err != nil {
+			t.Fatalf("parseSubnet(%q): %v", s, err)
+		}
+		cfg.whitelists = append(cfg.whitelists, ipnet)
+	}
+
+	tests := []struct {
+		addr        string
+		whitelisted bool
+	}{
+		{"10.1.2.3:8333", true},
+		{"11.1.2.3:8333", false},
+		{"[2001:db8::1]:8333", true},
+		{"[2001:db8::2]:8333", false},
+	}
+	for _, test := range tests {
+		addr, err := net.ResolveTCPAddr("tcp", test.addr)
+		if err != nil {
+			t.Fatalf("ResolveTCPAddr(%q): %v", test.addr, err)
+		}
+		if got := isWhitelisted(addr); got != test.whitelisted {
+			t.Errorf("isWhitelisted(%s): got %v, want %v", addr,
+				got, test.whitelisted)
+		}
+	}
+
+	// Banning would require a server, so this also ensures a whitelisted
+	// peer doesn't get that far.
+	p := &peer{addr: "10.1.2.3:8333", whitelisted: true}
+	if p.addBanScore(2*defaultBanThreshold, 0, "misbehaving") {
+		t.Errorf("addBanScore: whitelisted peer was banned")
+	}
+}
+
+// TestSendHeaders ensures a peer only wants new blocks announced with their
+// headers after sending a sendheaders message.
+func TestSendHeaders(t *testing.T) {
+	p := &peer{addr: "10.1.2.3:8333"}
+	if p.WantsHeaders() {
+		t.Errorf("WantsHeaders: got true before sendheaders")
+	}
+	p.handleSendHeadersMsg(wire.NewMsgSendHeaders())
+	if !p.WantsHeaders() {
+		t.Errorf("WantsHeaders: got false after sendheaders")
+	}
+}
+
+// rawMessage returns the serialized message with the passed header fields and
+// payload.  The checksum is calculated from the payload.
+func rawMessage(net wire.BitcoinNet, command string, length uint32, payload []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(net))
+	var cmd [wire.CommandSize]byte
+	copy(cmd[:], command)
+	buf.Write(cmd[:])
+	binary.Write(&buf, binary.LittleEndian, length)
+	buf.Write(wire.DoubleSha256(payload)[:4])
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// TestSkippableMessageError ensures only errors from reading a message which
+// leave the stream at the start of the next message allow skipping it.
+func TestSkippableMessageError(t *testing.T) {
+	net := wire.MainNet
+	ping := rawMessage(net, wire.CmdPing, 8, make([]byte, 8))
+	badChecksum := append([]byte(nil), ping...)
+	badChecksum[len(badChecksum)-1] ^= 0xff
+
+	// An inv message claiming more entries than allowed fails to decode.
+	badInv := []byte{0xfe, 0xff, 0xff, 0xff, 0xff}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		skip bool
+	}{
+		{"checksum", badChecksum, true},
+		{"decode", rawMessage(net, wire.CmdInv, 5, badInv), true},
+		{"unknown command", rawMessage(net, "foo", 2, []byte{1, 2}), true},
+		{"other network", rawMessage(wire.TestNet3, wire.CmdPing, 8,
+			make([]byte, 8)), false},
+		{"payload too large", rawMessage(net, wire.CmdPing,
+			wire.MaxMessagePayload+1, nil), false},
+		{"invalid command", rawMessage(net, "\xff", 0, nil), false},
+		{"short payload", rawMessage(net, wire.CmdPing, 8, nil), false},
+	}
+
+	for _, test := range tests {
+		_, _, err := wire.ReadMessage(bytes.NewReader(test.buf),
+			wire.ProtocolVersion, net)
+		if err == nil {
+			t.Errorf("%s: ReadMessage succeeded", test.name)
+			continue
+		}
+		if skip := isSkippableMessageError(err); skip != test.skip {
+			t.Errorf("%s: got skippable %v, want %v (%v)", test.name,
+				skip, test.skip, err)
+		}
+	}
+}
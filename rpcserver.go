@@ -20,12 +20,15 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/melange-app/nmcd/addrmgr"
 	"github.com/melange-app/nmcd/blockchain"
 	"github.com/melange-app/nmcd/btcec"
 	"github.com/melange-app/nmcd/btcjson"
@@ -118,42 +121,58 @@ type commandHandler func(*rpcServer, btcjson.Cmd, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":               handleAddNode,
+	"clearbanned":           handleClearBanned,
+	"createauxblock":        handleCreateAuxBlock,
 	"createrawtransaction":  handleCreateRawTransaction,
 	"debuglevel":            handleDebugLevel,
 	"decoderawtransaction":  handleDecodeRawTransaction,
 	"decodescript":          handleDecodeScript,
-	"estimatefee":           handleUnimplemented,
-	"estimatepriority":      handleUnimplemented,
+	"estimatefee":           handleEstimateFee,
+	"estimatepriority":      handleEstimatePriority,
 	"getaddednodeinfo":      handleGetAddedNodeInfo,
+	"getauxblock":           handleGetAuxBlock,
 	"getbestblock":          handleGetBestBlock,
 	"getbestblockhash":      handleGetBestBlockHash,
 	"getblock":              handleGetBlock,
-	"getblockchaininfo":     handleUnimplemented,
+	"getblockchaininfo":     handleGetBlockChainInfo,
 	"getblockcount":         handleGetBlockCount,
 	"getblockhash":          handleGetBlockHash,
 	"getblocktemplate":      handleGetBlockTemplate,
-	"getchaintips":          handleUnimplemented,
+	"getchaintips":          handleGetChainTips,
 	"getconnectioncount":    handleGetConnectionCount,
 	"getcurrentnet":         handleGetCurrentNet,
 	"getdifficulty":         handleGetDifficulty,
 	"getgenerate":           handleGetGenerate,
 	"gethashespersec":       handleGetHashesPerSec,
 	"getinfo":               handleGetInfo,
+	"getmempoolentry":       handleGetMempoolEntry,
+	"getmempoolinfo":        handleGetMempoolInfo,
 	"getmininginfo":         handleGetMiningInfo,
 	"getnettotals":          handleGetNetTotals,
 	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getnetworkinfo":        handleUnimplemented,
+	"getnetworkinfo":        handleGetNetworkInfo,
 	"getpeerinfo":           handleGetPeerInfo,
 	"getrawmempool":         handleGetRawMempool,
 	"getrawtransaction":     handleGetRawTransaction,
 	"gettxout":              handleGetTxOut,
+	"gettxoutsetinfo":       handleGetTxOutSetInfo,
 	"getwork":               handleGetWork,
 	"help":                  handleHelp,
+	"invalidateblock":       handleInvalidateBlock,
+	"listbanned":            handleListBanned,
+	"name_filter":           handleNameFilter,
+	"name_history":          handleNameHistory,
+	"name_pending":          handleNamePending,
+	"name_scan":             handleNameScan,
+	"name_show":             handleNameShow,
 	"ping":                  handlePing,
+	"reconsiderblock":       handleReconsiderBlock,
 	"searchrawtransactions": handleSearchRawTransactions,
 	"sendrawtransaction":    handleSendRawTransaction,
+	"setban":                handleSetBan,
 	"setgenerate":           handleSetGenerate,
 	"stop":                  handleStop,
+	"submitauxblock":        handleSubmitAuxBlock,
 	"submitblock":           handleSubmitBlock,
 	"validateaddress":       handleValidateAddress,
 	"verifychain":           handleVerifyChain,
@@ -180,7 +199,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getreceivedbyaccount":   struct{}{},
 	"getreceivedbyaddress":   struct{}{},
 	"gettransaction":         struct{}{},
-	"gettxoutsetinfo":        struct{}{},
 	"getunconfirmedbalance":  struct{}{},
 	"getwalletinfo":          struct{}{},
 	"importprivkey":          struct{}{},
@@ -272,6 +290,34 @@ func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
 	}
 }
 
+// auxWorkStateTemplate houses a block template generated for a payment address
+// by the getauxblock or createauxblock RPCs along with the information needed
+// to decide when it has to be regenerated.
+type auxWorkStateTemplate struct {
+	template      *BlockTemplate
+	blockHash     wire.ShaHash
+	lastTxUpdate  time.Time
+	lastGenerated time.Time
+}
+
+// auxWorkState houses state that is used in between multiple RPC invocations to
+// getauxblock, createauxblock, and submitauxblock.
+type auxWorkState struct {
+	sync.Mutex
+	prevHash  *wire.ShaHash
+	templates map[string]*auxWorkStateTemplate
+	blocks    map[wire.ShaHash]*wire.MsgBlock
+}
+
+// newAuxWorkState returns a new instance of an auxWorkState with all internal
+// fields initialized and ready to use.
+func newAuxWorkState() *auxWorkState {
+	return &auxWorkState{
+		templates: make(map[string]*auxWorkStateTemplate),
+		blocks:    make(map[wire.ShaHash]*wire.MsgBlock),
+	}
+}
+
 // rpcServer holds the items the rpc server may need to access (config,
 // shutdown, main server, etc.)
 type rpcServer struct {
@@ -287,6 +333,7 @@ type rpcServer struct {
 	listeners    []net.Listener
 	workState    *workState
 	gbtWorkState *gbtWorkState
+	auxWorkState *auxWorkState
 	quit         chan int
 }
 
@@ -535,6 +582,7 @@ func newRPCServer(listenAddrs []string, s *server) (*rpcServer, error) {
 		statusLines:  make(map[int]string),
 		workState:    newWorkState(),
 		gbtWorkState: newGbtWorkState(s.timeSource),
+		auxWorkState: newAuxWorkState(),
 		quit:         make(chan int),
 	}
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
@@ -740,6 +788,45 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	s.server.banList.Clear()
+	return nil, nil
+}
+
+// handleCreateAuxBlock implements the createauxblock command.
+func handleCreateAuxBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateAuxBlockCmd)
+
+	payToAddr, err := btcutil.DecodeAddress(c.Address, activeNetParams.Params)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code: btcjson.ErrInvalidAddressOrKey.Code,
+			Message: fmt.Sprintf("%s: %v",
+				btcjson.ErrInvalidAddressOrKey.Message, err),
+		}
+	}
+	if !payToAddr.IsForNet(activeNetParams.Params) {
+		return nil, btcjson.Error{
+			Code: btcjson.ErrInvalidAddressOrKey.Code,
+			Message: fmt.Sprintf("%s: address is not for %s",
+				btcjson.ErrInvalidAddressOrKey.Message,
+				activeNetParams.Name),
+		}
+	}
+
+	if err := checkAuxBlockReady(s); err != nil {
+		return nil, err
+	}
+
+	// Protect concurrent access from multiple RPC invocations for merged
+	// mining work requests and submissions.
+	s.auxWorkState.Lock()
+	defer s.auxWorkState.Unlock()
+
+	return handleAuxBlockRequest(s, payToAddr)
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -1032,6 +1119,25 @@ func handleDecodeScript(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// handleEstimateFee handles estimatefee commands.
+func handleEstimateFee(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateFeeCmd)
+
+	// The estimate is -1 when not enough transactions have been observed,
+	// in which case it is returned as is rather than converted to BTC.
+	feePerKB := s.server.feeEstimator.EstimateFee(c.NumBlocks)
+	if feePerKB < 0 {
+		return feePerKB, nil
+	}
+	return feePerKB / btcutil.SatoshiPerBitcoin, nil
+}
+
+// handleEstimatePriority handles estimatepriority commands.
+func handleEstimatePriority(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimatePriorityCmd)
+	return s.server.feeEstimator.EstimatePriority(c.NumBlocks), nil
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
@@ -1115,6 +1221,237 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan stru
 	return results, nil
 }
 
+// checkAuxBlockReady returns an error when the server is in no state to hand
+// out or accept merged mining work.
+func checkAuxBlockReady(s *rpcServer) error {
+	// Return an error if there are no peers connected since there is no
+	// way to relay a found block or receive transactions to work on.
+	// However, allow this state when running in the regression test or
+	// simulation test mode.
+	if !(cfg.RegressionTest || cfg.SimNet) && s.server.ConnectedCount() == 0 {
+		return btcjson.ErrClientNotConnected
+	}
+
+	// No point in generating or accepting work before the chain is synced.
+	_, currentHeight := s.server.blockManager.chainState.Best()
+	if currentHeight != 0 && !s.server.blockManager.IsCurrent() {
+		return btcjson.ErrClientInInitialDownload
+	}
+
+	return nil
+}
+
+// handleAuxBlockRequest is a helper for handleGetAuxBlock and
+// handleCreateAuxBlock which deals with generating and returning a block paying
+// to the passed address for merged mining.
+//
+// The block is cached by its hash so it can be reconstructed when a solution is
+// submitted.  A new block is only generated for an address when the best block
+// changed or the transactions in the memory pool have been updated and it has
+// been at least one minute since the last one was generated for it.
+//
+// This function MUST be called with the RPC aux work state locked.
+func handleAuxBlockRequest(s *rpcServer, payToAddr btcutil.Address) (interface{}, error) {
+	state := s.auxWorkState
+
+	// Forget about all blocks handed out so far once the best block
+	// changed since none of them can extend the best chain anymore.
+	lastTxUpdate := s.server.txMemPool.LastUpdated()
+	latestHash, _ := s.server.blockManager.chainState.Best()
+	if state.prevHash == nil || !state.prevHash.IsEqual(latestHash) {
+		state.templates = make(map[string]*auxWorkStateTemplate)
+		state.blocks = make(map[wire.ShaHash]*wire.MsgBlock)
+		state.prevHash = latestHash
+	}
+
+	encodedAddr := payToAddr.EncodeAddress()
+	current := state.templates[encodedAddr]
+	if current == nil || (current.lastTxUpdate != lastTxUpdate &&
+		time.Now().After(current.lastGenerated.Add(time.Minute))) {
+
+		template, err := NewBlockTemplate(s.server.txMemPool, payToAddr)
+		if err != nil {
+			errStr := fmt.Sprintf("Failed to create new block "+
+				"template: %v", err)
+			rpcsLog.Error(errStr)
+			return nil, btcjson.Error{
+				Code:    btcjson.ErrInternal.Code,
+				Message: errStr,
+			}
+		}
+
+		// The parent chain block commits to the hash of the block, so
+		// the version must already signal that it is merged mined.
+		msgBlock := template.block
+		msgBlock.Header.SetAuxPowVersion(activeNetParams.AuxPowChainID)
+		blockHash, _ := msgBlock.Header.BlockSha()
+
+		current = &auxWorkStateTemplate{
+			template:      template,
+			blockHash:     blockHash,
+			lastTxUpdate:  lastTxUpdate,
+			lastGenerated: time.Now(),
+		}
+		state.templates[encodedAddr] = current
+		state.blocks[blockHash] = msgBlock
+
+		rpcsLog.Debugf("Generated merged mining block %s (timestamp "+
+			"%v, target %064x, pay to %s)", blockHash,
+			msgBlock.Header.Timestamp,
+			blockchain.CompactToBig(msgBlock.Header.Bits),
+			encodedAddr)
+	}
+
+	msgBlock := current.template.block
+	target := bigToLEUint256(blockchain.CompactToBig(msgBlock.Header.Bits))
+	reply := &btcjson.GetAuxBlockResult{
+		Hash:              current.blockHash.String(),
+		ChainID:           activeNetParams.AuxPowChainID,
+		PreviousBlockHash: msgBlock.Header.PrevBlock.String(),
+		CoinbaseValue:     msgBlock.Transactions[0].TxOut[0].Value,
+		Bits:              fmt.Sprintf("%08x", msgBlock.Header.Bits),
+		Height:            current.template.height,
+		Target:            hex.EncodeToString(target[:]),
+	}
+	return reply, nil
+}
+
+// handleAuxBlockSubmission is a helper for handleGetAuxBlock and
+// handleSubmitAuxBlock which deals with the caller submitting the auxpow of a
+// merged mined block to be verified and processed.
+//
+// This function MUST be called with the RPC aux work state locked.
+func handleAuxBlockSubmission(s *rpcServer, hashStr, auxPowHex string) (interface{}, error) {
+	blockHash, err := wire.NewShaHashFromStr(hashStr)
+	if err != nil {
+		return false, btcjson.Error{
+			Code: btcjson.ErrInvalidParameter.Code,
+			Message: fmt.Sprintf("argument must be a block hash "+
+				"(not %q)", hashStr),
+		}
+	}
+
+	// Ensure the provided auxpow is sane.
+	if len(auxPowHex)%2 != 0 {
+		auxPowHex = "0" + auxPowHex
+	}
+	serializedAuxPow, err := hex.DecodeString(auxPowHex)
+	if err != nil {
+		return false, btcjson.Error{
+			Code: btcjson.ErrDecodeHexString.Code,
+			Message: fmt.Sprintf("argument must be "+
+				"hexadecimal string (not %q)", auxPowHex),
+		}
+	}
+	var auxPow wire.AuxPow
+	err = auxPow.Deserialize(bytes.NewReader(serializedAuxPow))
+	if err != nil {
+		return false, btcjson.Error{
+			Code: btcjson.ErrDeserialization.Code,
+			Message: fmt.Sprintf("argument does not contain a "+
+				"valid auxpow: %v", err),
+		}
+	}
+
+	// Look up the block the auxpow was created for.
+	state := s.auxWorkState
+	template, ok := state.blocks[*blockHash]
+	if !ok {
+		return false, btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "block hash unknown",
+		}
+	}
+
+	// Attach the auxpow to a copy of the block so the cached block stays
+	// untouched should the submission be rejected.
+	msgBlock := *template
+	msgBlock.Header.AuxPowHeader = &auxPow
+	block := btcutil.NewBlock(&msgBlock)
+
+	// Ensure the auxpow proves the block meets the target difficulty.
+	err = blockchain.CheckAuxPowProofOfWork(block, activeNetParams.Params)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so return that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			return false, btcjson.Error{
+				Code: btcjson.ErrInternal.Code,
+				Message: fmt.Sprintf("Unexpected error while "+
+					"checking proof of work: %v", err),
+			}
+		}
+
+		rpcsLog.Debugf("Block submitted via auxpow does not meet "+
+			"the required proof of work: %v", err)
+		return false, nil
+	}
+
+	latestHash, _ := s.server.blockManager.chainState.Best()
+	if !msgBlock.Header.PrevBlock.IsEqual(latestHash) {
+		rpcsLog.Debugf("Block submitted via auxpow with previous "+
+			"block %s is stale", msgBlock.Header.PrevBlock)
+		return false, nil
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.server.blockManager.ProcessBlock(block, blockchain.BFNone)
+	if err != nil || isOrphan {
+		// Anything other than a rule violation is an unexpected error,
+		// so return that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			return false, btcjson.Error{
+				Code: btcjson.ErrInternal.Code,
+				Message: fmt.Sprintf("Unexpected error while "+
+					"processing block: %v", err),
+			}
+		}
+
+		rpcsLog.Infof("Block submitted via auxpow rejected: %v", err)
+		return false, nil
+	}
+
+	// The block was accepted.
+	rpcsLog.Infof("Block submitted via auxpow accepted: %s", blockHash)
+	return true, nil
+}
+
+// handleGetAuxBlock implements the getauxblock command.
+func handleGetAuxBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAuxBlockCmd)
+
+	// Respond with an error if there are no addresses to pay the created
+	// blocks to.
+	if c.Hash == "" && len(cfg.miningAddrs) == 0 {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: "No payment addresses specified via --miningaddr",
+		}
+	}
+
+	if err := checkAuxBlockReady(s); err != nil {
+		return nil, err
+	}
+
+	// Protect concurrent access from multiple RPC invocations for merged
+	// mining work requests and submissions.
+	s.auxWorkState.Lock()
+	defer s.auxWorkState.Unlock()
+
+	// When the caller provides an auxpow, it is a submission of a
+	// supposedly solved block that needs to be checked and submitted to
+	// the network if valid.
+	if c.Hash != "" {
+		return handleAuxBlockSubmission(s, c.Hash, c.AuxPow)
+	}
+
+	// No auxpow was provided, so the caller is requesting work.  Choose a
+	// payment address at random.
+	payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+	return handleAuxBlockRequest(s, payToAddr)
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the
@@ -1239,6 +1576,92 @@ func handleGetBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (i
 	return blockReply, nil
 }
 
+// softForks lists the block version upgrades reported by getblockchaininfo
+// along with the identifiers of the proposals which introduced them.
+var softForks = []struct {
+	id      string
+	version int32
+}{
+	{"bip34", 2},
+}
+
+// handleGetBlockChainInfo implements the getblockchaininfo command.
+func handleGetBlockChainInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	sha, height, err := s.server.db.NewestSha()
+	if err != nil {
+		rpcsLog.Errorf("Error getting sha: %v", err)
+		return nil, btcjson.ErrBlockCount
+	}
+	blockHeader, err := s.server.db.FetchBlockHeaderBySha(sha)
+	if err != nil {
+		rpcsLog.Errorf("Error getting block: %v", err)
+		return nil, btcjson.ErrDifficulty
+	}
+
+	chainState := &s.server.blockManager.chainState
+	chainState.Lock()
+	medianTime := chainState.pastMedianTime
+	chainState.Unlock()
+
+	versions := make([]int32, 0, len(softForks))
+	for _, softFork := range softForks {
+		versions = append(versions, softFork.version)
+	}
+	chainInfo := s.server.blockManager.ChainInfo(versions)
+
+	// Estimate the verification progress from the height of the best
+	// chain known to the peer the chain is synced from.
+	progress := 1.0
+	if chainInfo.syncHeight > height {
+		progress = float64(height) / float64(chainInfo.syncHeight)
+	}
+
+	var chainWork string
+	if chainInfo.chainWork != nil {
+		chainWork = fmt.Sprintf("%064x", chainInfo.chainWork)
+	}
+
+	result := &btcjson.GetBlockChainInfoResult{
+		Chain:                activeNetParams.Name,
+		Blocks:               int32(height),
+		BestBlockHash:        sha.String(),
+		Difficulty:           getDifficultyRatio(blockHeader.Bits),
+		MedianTime:           medianTime.Unix(),
+		VerificationProgress: progress,
+		ChainWork:            chainWork,
+		Pruned:               false,
+		SoftForks: make([]btcjson.SoftForkDescription, 0,
+			len(softForks)),
+		AuxPow: &btcjson.AuxPowDescription{
+			Active:        height+1 >= activeNetParams.AuxPowStartHeight,
+			ChainID:       activeNetParams.AuxPowChainID,
+			StartHeight:   activeNetParams.AuxPowStartHeight,
+			StrictChainID: activeNetParams.StrictChainID,
+		},
+	}
+	for i, upgrade := range chainInfo.upgrades {
+		result.SoftForks = append(result.SoftForks,
+			btcjson.SoftForkDescription{
+				ID:      softForks[i].id,
+				Version: upgrade.Version,
+				Enforce: btcjson.SoftForkMajority{
+					Status:   upgrade.Enforced(),
+					Found:    upgrade.Found,
+					Required: upgrade.EnforceRequired,
+					Window:   upgrade.Window,
+				},
+				Reject: btcjson.SoftForkMajority{
+					Status:   upgrade.Rejecting(),
+					Found:    upgrade.Found,
+					Required: upgrade.RejectRequired,
+					Window:   upgrade.Window,
+				},
+			})
+	}
+
+	return result, nil
+}
+
 // handleGetBlockCount implements the getblockcount command.
 func handleGetBlockCount(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	_, maxidx, err := s.server.db.NewestSha()
@@ -2049,6 +2472,22 @@ func handleGetBlockTemplate(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan stru
 	}
 }
 
+// handleGetChainTips implements the getchaintips command.
+func handleGetChainTips(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	tips := s.server.blockManager.ChainTips()
+	result := make([]btcjson.GetChainTipsResult, 0, len(tips))
+	for _, tip := range tips {
+		result = append(result, btcjson.GetChainTipsResult{
+			Height:    tip.Height,
+			Hash:      tip.Hash.String(),
+			BranchLen: tip.BranchLen,
+			Status:    tip.Status.String(),
+		})
+	}
+
+	return result, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	return s.server.ConnectedCount(), nil
@@ -2109,11 +2548,105 @@ func handleGetInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (in
 		Difficulty:      getDifficultyRatio(blkHeader.Bits),
 		TestNet:         cfg.TestNet3,
 		RelayFee:        float64(minTxRelayFee) / btcutil.SatoshiPerBitcoin,
+		MempoolBytes:    s.server.txMemPool.Size(),
+		MaxMempool:      int64(cfg.MaxMempool),
+		MempoolMinFee:   s.server.txMemPool.MinFeeRate() / btcutil.SatoshiPerBitcoin,
 	}
 
 	return ret, nil
 }
 
+// mempoolTxPriorities returns the starting and current priority of the passed
+// memory pool transaction.  Zeros are returned if one or more of the input
+// transactions can't be found for some reason.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func mempoolTxPriorities(mp *txMemPool, desc *TxDesc, nextHeight int64) (float64, float64) {
+	inputTxs, err := mp.fetchInputTransactions(desc.Tx)
+	if err != nil {
+		return 0, 0
+	}
+	return desc.StartingPriority(inputTxs),
+		desc.CurrentPriority(inputTxs, nextHeight)
+}
+
+// mempoolTxDepends returns the hashes of the unconfirmed transactions the
+// passed memory pool transaction spends outputs of.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func mempoolTxDepends(mp *txMemPool, desc *TxDesc) []string {
+	depends := make([]string, 0)
+	for _, txIn := range desc.Tx.MsgTx().TxIn {
+		hash := &txIn.PreviousOutPoint.Hash
+		if mp.haveTransaction(hash) {
+			depends = append(depends, hash.String())
+		}
+	}
+	return depends
+}
+
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+
+	txSha, err := wire.NewShaHashFromStr(c.Txid)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrBlockNotFound.Code,
+			Message: "Parameter 1 must be a hexadecimal string",
+		}
+	}
+
+	_, newestHeight, err := s.server.db.NewestSha()
+	if err != nil {
+		rpcsLog.Errorf("Cannot get newest sha: %v", err)
+		return nil, btcjson.ErrBlockNotFound
+	}
+
+	mp := s.server.txMemPool
+	mp.RLock()
+	defer mp.RUnlock()
+
+	desc, ok := mp.pool[*txSha]
+	if !ok {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrNoTxInfo.Code,
+			Message: "Transaction not in memory pool",
+		}
+	}
+
+	startingPriority, currentPriority := mempoolTxPriorities(mp, desc,
+		newestHeight+1)
+	ancestorCount, ancestorSize, ancestorFees := mp.ancestors(desc)
+	return &btcjson.GetMempoolEntryResult{
+		Size:             int32(desc.Tx.MsgTx().SerializeSize()),
+		Fee:              btcutil.Amount(desc.Fee).ToBTC(),
+		Time:             desc.Added.Unix(),
+		Height:           desc.Height,
+		StartingPriority: startingPriority,
+		CurrentPriority:  currentPriority,
+		DescendantCount:  int64(desc.descendantCount),
+		DescendantSize:   desc.descendantSize,
+		DescendantFees:   desc.descendantFees,
+		AncestorCount:    int64(ancestorCount),
+		AncestorSize:     ancestorSize,
+		AncestorFees:     ancestorFees,
+		Depends:          mempoolTxDepends(mp, desc),
+	}, nil
+}
+
+// handleGetMempoolInfo implements the getmempoolinfo command.
+func handleGetMempoolInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	mp := s.server.txMemPool
+	return &btcjson.GetMempoolInfoResult{
+		Size:          int64(mp.Count()),
+		Bytes:         mp.Size(),
+		Usage:         mp.Usage(),
+		MaxMempool:    int64(cfg.MaxMempool),
+		MempoolMinFee: mp.MinFeeRate() / btcutil.SatoshiPerBitcoin,
+	}, nil
+}
+
 // handleGetMiningInfo implements the getmininginfo command. We only return the
 // fields that are not related to wallet functionality.
 func handleGetMiningInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
@@ -2273,6 +2806,68 @@ func handleGetNetworkHashPS(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan stru
 	return hashesPerSec.Int64(), nil
 }
 
+// handleGetNetworkInfo implements the getnetworkinfo command.
+func handleGetNetworkInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	// Onion addresses are dialed through the onion specific proxy when one
+	// is configured and through the general proxy otherwise.  They can't
+	// be reached without a proxy or when tor is disabled.
+	onionProxy := cfg.OnionProxy
+	if onionProxy == "" {
+		onionProxy = cfg.Proxy
+	}
+	if cfg.NoOnion {
+		onionProxy = ""
+	}
+	networks := []btcjson.NetworksResult{
+		{Name: "ipv4", Reachable: true, Proxy: cfg.Proxy},
+		{Name: "ipv6", Reachable: true, Proxy: cfg.Proxy},
+		{
+			Name:      "onion",
+			Limited:   cfg.NoOnion,
+			Reachable: onionProxy != "",
+			Proxy:     onionProxy,
+		},
+	}
+
+	localAddrs := s.server.addrManager.LocalAddresses()
+	addrs := make([]btcjson.LocalAddressesResult, 0, len(localAddrs))
+	for _, la := range localAddrs {
+		// The address key is used for the host so onion addresses are
+		// shown in their usual form.
+		host, _, err := net.SplitHostPort(addrmgr.NetAddressKey(la.NetAddress))
+		if err != nil {
+			return nil, btcjson.Error{
+				Code:    btcjson.ErrInternal.Code,
+				Message: err.Error(),
+			}
+		}
+		addrs = append(addrs, btcjson.LocalAddressesResult{
+			Address: host,
+			Port:    la.NetAddress.Port,
+			Score:   int32(la.Score),
+		})
+	}
+
+	// The memory pool raises the minimum fee rate above the relay fee
+	// while it is full.
+	relayFee := s.server.txMemPool.MinFeeRate()
+	if relayFee < minTxRelayFee {
+		relayFee = minTxRelayFee
+	}
+
+	reply := &btcjson.GetNetworkInfoResult{
+		Version:         int32(1000000*appMajor + 10000*appMinor + 100*appPatch),
+		ProtocolVersion: int32(maxProtocolVersion),
+		LocalServices:   fmt.Sprintf("%016x", uint64(supportedServices)),
+		TimeOffset:      int64(s.server.timeSource.Offset().Seconds()),
+		Connections:     s.server.ConnectedCount(),
+		Networks:        networks,
+		RelayFee:        relayFee / btcutil.SatoshiPerBitcoin,
+		LocalAddresses:  addrs,
+	}
+	return reply, nil
+}
+
 // handleGetPeerInfo implements the getpeerinfo command.
 func handleGetPeerInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	return s.server.PeerInfo(), nil
@@ -2297,15 +2892,9 @@ func handleGetRawMempool(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{
 		defer mp.RUnlock()
 		for _, desc := range descs {
 			// Calculate the starting and current priority from the
-			// the tx's inputs.  Use zeros if one or more of the
-			// input transactions can't be found for some reason.
-			var startingPriority, currentPriority float64
-			inputTxs, err := mp.fetchInputTransactions(desc.Tx)
-			if err == nil {
-				startingPriority = desc.StartingPriority(inputTxs)
-				currentPriority = desc.CurrentPriority(inputTxs,
-					newestHeight+1)
-			}
+			// the tx's inputs.
+			startingPriority, currentPriority :=
+				mempoolTxPriorities(mp, desc, newestHeight+1)
 
 			mpd := &btcjson.GetRawMempoolResult{
 				Size:             int32(desc.Tx.MsgTx().SerializeSize()),
@@ -2314,14 +2903,10 @@ func handleGetRawMempool(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{
 				Height:           desc.Height,
 				StartingPriority: startingPriority,
 				CurrentPriority:  currentPriority,
-				Depends:          make([]string, 0),
-			}
-			for _, txIn := range desc.Tx.MsgTx().TxIn {
-				hash := &txIn.PreviousOutPoint.Hash
-				if s.server.txMemPool.haveTransaction(hash) {
-					mpd.Depends = append(mpd.Depends,
-						hash.String())
-				}
+				DescendantCount:  int64(desc.descendantCount),
+				DescendantSize:   desc.descendantSize,
+				DescendantFees:   desc.descendantFees,
+				Depends:          mempoolTxDepends(mp, desc),
 			}
 
 			result[desc.Tx.Sha().String()] = mpd
@@ -2550,6 +3135,105 @@ func handleGetTxOut(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (i
 	return txOutReply, nil
 }
 
+// handleGetTxOutSetInfo handles gettxoutsetinfo commands.  The unspent
+// transaction outputs are walked in the order of the block chain.  For every
+// transaction with unspent outputs, its hash, version, height and whether it is
+// a coinbase are serialized, followed by the index, value and script of each
+// unspent output.  The hash of the serialized set is the double sha256 of the
+// best block hash followed by the sha256 of the serialized transactions.
+func handleGetTxOutSetInfo(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	// Outputs only hold an active name while they hold its current state
+	// and the name has not expired.  The names are read along with the
+	// unspent transactions so both reflect the same block.
+	var activeNames map[wire.OutPoint]struct{}
+	namesFunc := func(names []*database.NameEntry) error {
+		activeNames = make(map[wire.OutPoint]struct{}, len(names))
+		for _, entry := range names {
+			if !entry.Expired {
+				op := wire.NewOutPoint(&entry.TxSha,
+					entry.TxOutIndex)
+				activeNames[*op] = struct{}{}
+			}
+		}
+		return nil
+	}
+
+	var reply btcjson.GetTxOutSetInfoResult
+	var totalAmount, nameAmount int64
+	var buf bytes.Buffer
+	hasher := fastsha256.New()
+	var scratch [8]byte
+	walkFunc := func(txReply *database.TxListReply) error {
+		select {
+		case <-closeChan:
+			return ErrClientQuit
+		default:
+		}
+
+		// The best block hash isn't known until the walk is done, so
+		// hash the serialized transactions on their own for now.
+		buf.Reset()
+		buf.Write(txReply.Sha[:])
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(txReply.Tx.Version))
+		buf.Write(scratch[:4])
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(txReply.Height))
+		buf.Write(scratch[:4])
+		if blockchain.IsCoinBase(btcutil.NewTx(txReply.Tx)) {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+
+		for i, txOut := range txReply.Tx.TxOut {
+			if txReply.TxSpent[i] {
+				continue
+			}
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(i))
+			buf.Write(scratch[:4])
+			binary.LittleEndian.PutUint64(scratch[:], uint64(txOut.Value))
+			buf.Write(scratch[:])
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(txOut.PkScript)))
+			buf.Write(scratch[:4])
+			buf.Write(txOut.PkScript)
+
+			reply.TxOuts++
+			totalAmount += txOut.Value
+			op := wire.NewOutPoint(txReply.Sha, uint32(i))
+			if _, ok := activeNames[*op]; ok {
+				reply.NameTxOuts++
+				nameAmount += txOut.Value
+			}
+		}
+
+		reply.Transactions++
+		reply.BytesSerialized += int64(buf.Len())
+		hasher.Write(buf.Bytes())
+		return nil
+	}
+	bestSha, bestHeight, err := s.server.db.ForEachUnspentTx(namesFunc,
+		walkFunc)
+	if err == ErrClientQuit {
+		return nil, err
+	}
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrDatabase.Code,
+			Message: err.Error(),
+		}
+	}
+
+	txsHash := hasher.Sum(nil)
+	hashSerialized, _ := wire.NewShaHash(wire.DoubleSha256(
+		append(bestSha.Bytes(), txsHash...)))
+
+	reply.Height = bestHeight
+	reply.BestBlock = bestSha.String()
+	reply.HashSerialized = hashSerialized.String()
+	reply.TotalAmount = float64(totalAmount) / btcutil.SatoshiPerBitcoin
+	reply.NameAmount = float64(nameAmount) / btcutil.SatoshiPerBitcoin
+	return &reply, nil
+}
+
 // handleGetWorkRequest is a helper for handleGetWork which deals with
 // generating and returning work to the caller.
 //
@@ -2794,7 +3478,7 @@ func handleGetWorkSubmission(s *rpcServer, hexData string) (interface{}, error)
 	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
 
 	// Ensure the submitted block hash is less than the target difficulty.
-	err = blockchain.CheckProofOfWork(block, activeNetParams.PowLimit)
+	err = blockchain.CheckProofOfWork(block, activeNetParams.Params)
 	if err != nil {
 		// Anything other than a rule violation is an unexpected error,
 		// so return that error as an internal error.
@@ -2936,6 +3620,270 @@ func handleHelp(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (inter
 	return getHelpText(help.Command)
 }
 
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.InvalidateBlockCmd)
+	sha, err := wire.NewShaHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrBlockNotFound.Code,
+			Message: "Parameter 1 must be a hexadecimal string",
+		}
+	}
+
+	err = s.server.blockManager.InvalidateBlock(sha)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrMisc.Code,
+			Message: err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
+// nameExpiresIn returns the number of blocks until the passed name entry
+// expires along with whether it has already expired as of the given best
+// height.
+func nameExpiresIn(entry *database.NameEntry, bestHeight int64) (int, bool) {
+	expiresIn := int(entry.ExpireHeight - bestHeight)
+	return expiresIn, entry.Expired || expiresIn <= 0
+}
+
+// createNameInfoResult returns the name_show form of the passed name entry as
+// of the given best height.
+func createNameInfoResult(entry *database.NameEntry, bestHeight int64,
+	chainParams *chaincfg.Params) *btcjson.NameInfoResult {
+
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no address to report anyways.
+	var address string
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(entry.Script, chainParams)
+	if len(addrs) > 0 {
+		address = addrs[0].EncodeAddress()
+	}
+
+	expiresIn, expired := nameExpiresIn(entry, bestHeight)
+	return &btcjson.NameInfoResult{
+		Name:      string(entry.Name),
+		Value:     string(entry.Value),
+		TX:        entry.TxSha.String(),
+		Address:   address,
+		ExpiresIn: expiresIn,
+		Expired:   expired,
+	}
+}
+
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	bans := s.server.banList.Bans()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:     ban.Subnet(),
+			BannedUntil: ban.Until.Unix(),
+			BanCreated:  ban.Created.Unix(),
+			BanReason:   ban.Reason,
+		})
+	}
+	return results, nil
+}
+
+// handleNameFilter implements the name_filter command.
+func handleNameFilter(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.NameFilterCmd)
+
+	re, err := regexp.Compile(c.Regexp)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code: btcjson.ErrInvalidParameter.Code,
+			Message: fmt.Sprintf("invalid regexp %q: %v", c.Regexp,
+				err),
+		}
+	}
+
+	_, bestHeight, err := s.server.db.NewestSha()
+	if err != nil {
+		rpcsLog.Errorf("Cannot get newest sha: %v", err)
+		return nil, btcjson.ErrBlockNotFound
+	}
+
+	entries, err := s.server.db.FetchNames(nil, 0)
+	if err != nil {
+		rpcsLog.Errorf("Cannot fetch names: %v", err)
+		return nil, btcjson.ErrDatabase
+	}
+
+	var matched int
+	results := make([]*btcjson.NameFilterResult, 0)
+	for _, entry := range entries {
+		if c.Regexp != "" && !re.Match(entry.Name) {
+			continue
+		}
+
+		// A maxage of zero matches names of any age.
+		if c.MaxAge != 0 && bestHeight-entry.Height >= int64(c.MaxAge) {
+			continue
+		}
+
+		// Skip the first matches when requested.
+		matched++
+		if matched <= c.From {
+			continue
+		}
+
+		result := &btcjson.NameFilterResult{Name: string(entry.Name)}
+		expiresIn, expired := nameExpiresIn(entry, bestHeight)
+		if expired {
+			result.Expired = 1
+		} else {
+			result.Value = string(entry.Value)
+			result.RegisteredAt = int(entry.Height)
+			result.ExpiresIn = expiresIn
+		}
+		results = append(results, result)
+
+		if c.NB > 0 && len(results) >= c.NB {
+			break
+		}
+	}
+
+	if c.Stat {
+		return &btcjson.NameFilterStatResult{
+			Blocks: int32(bestHeight),
+			Count:  len(results),
+		}, nil
+	}
+	return results, nil
+}
+
+// handleNameHistory implements the name_history command.
+func handleNameHistory(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.NameHistoryCmd)
+
+	history, err := s.server.db.FetchNameHistory([]byte(c.Name))
+	if err != nil {
+		rpcsLog.Errorf("Cannot fetch history of name %q: %v", c.Name,
+			err)
+		return nil, btcjson.ErrDatabase
+	}
+	if len(history) == 0 {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "failed to read from name DB",
+		}
+	}
+
+	_, bestHeight, err := s.server.db.NewestSha()
+	if err != nil {
+		rpcsLog.Errorf("Cannot get newest sha: %v", err)
+		return nil, btcjson.ErrBlockNotFound
+	}
+
+	results := make([]*btcjson.NameInfoResult, 0, len(history))
+	for _, entry := range history {
+		results = append(results, createNameInfoResult(entry,
+			bestHeight, s.server.chainParams))
+	}
+	return results, nil
+}
+
+// handleNamePending implements the name_pending command.
+func handleNamePending(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	pending := s.server.txMemPool.PendingNames()
+	names := make([]string, 0, len(pending))
+	for name := range pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]*btcjson.NamePendingResult, 0, len(names))
+	for _, name := range names {
+		tx := pending[name]
+		for _, txOut := range tx.MsgTx().TxOut {
+			ns := txscript.ExtractNameScript(txOut.PkScript)
+			if ns == nil || string(ns.Name) != name {
+				continue
+			}
+
+			op := "name_update"
+			if ns.Op == txscript.OP_NAME_FIRSTUPDATE {
+				op = "name_firstupdate"
+			}
+			results = append(results, &btcjson.NamePendingResult{
+				Name:  name,
+				TX:    tx.Sha().String(),
+				Op:    op,
+				Value: string(ns.Value),
+			})
+			break
+		}
+	}
+	return results, nil
+}
+
+// handleNameScan implements the name_scan command.
+func handleNameScan(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.NameScanCmd)
+
+	// FetchNames treats a limit of zero as no limit, while namecoind
+	// returns nothing at all.
+	results := make([]*btcjson.NameScanResult, 0)
+	if c.MaxReturned <= 0 {
+		return results, nil
+	}
+
+	_, bestHeight, err := s.server.db.NewestSha()
+	if err != nil {
+		rpcsLog.Errorf("Cannot get newest sha: %v", err)
+		return nil, btcjson.ErrBlockNotFound
+	}
+
+	entries, err := s.server.db.FetchNames([]byte(c.StartName),
+		c.MaxReturned)
+	if err != nil {
+		rpcsLog.Errorf("Cannot fetch names: %v", err)
+		return nil, btcjson.ErrDatabase
+	}
+
+	for _, entry := range entries {
+		result := &btcjson.NameScanResult{Name: string(entry.Name)}
+		expiresIn, expired := nameExpiresIn(entry, bestHeight)
+		if expired {
+			result.Expired = 1
+		} else {
+			result.Value = string(entry.Value)
+			result.ExpiresIn = expiresIn
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleNameShow implements the name_show command.
+func handleNameShow(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.NameShowCmd)
+
+	entry, err := s.server.db.FetchName([]byte(c.Name))
+	if err == database.ErrNameMissing {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "failed to read from name DB",
+		}
+	} else if err != nil {
+		rpcsLog.Errorf("Cannot fetch name %q: %v", c.Name, err)
+		return nil, btcjson.ErrDatabase
+	}
+
+	_, bestHeight, err := s.server.db.NewestSha()
+	if err != nil {
+		rpcsLog.Errorf("Cannot get newest sha: %v", err)
+		return nil, btcjson.ErrBlockNotFound
+	}
+
+	return createNameInfoResult(entry, bestHeight, s.server.chainParams), nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -2949,6 +3897,28 @@ func handlePing(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ReconsiderBlockCmd)
+	sha, err := wire.NewShaHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrBlockNotFound.Code,
+			Message: "Parameter 1 must be a hexadecimal string",
+		}
+	}
+
+	err = s.server.blockManager.ReconsiderBlock(sha)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrMisc.Code,
+			Message: err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
 // handleSearchRawTransaction implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	if !cfg.AddrIndex {
@@ -3122,6 +4092,57 @@ func handleSendRawTransaction(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan st
 	return tx.Sha().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := parseSubnet(c.Subnet)
+	if err != nil {
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+	}
+
+	switch c.SubCmd {
+	case "add":
+		if c.BanTime < 0 {
+			return nil, btcjson.Error{
+				Code:    btcjson.ErrInvalidParameter.Code,
+				Message: "Ban time must not be negative",
+			}
+		}
+		banDuration := cfg.BanDuration
+		if c.BanTime > 0 {
+			banDuration = time.Duration(c.BanTime) * time.Second
+		}
+		s.server.banList.Ban(subnet, time.Now().Add(banDuration),
+			banReasonManual)
+		rpcsLog.Infof("Banned subnet %s for %v", subnet, banDuration)
+
+		// Drop the connections to the peers which are banned now.
+		s.server.DisconnectSubnet(subnet)
+
+	case "remove":
+		if !s.server.banList.Unban(subnet) {
+			return nil, btcjson.Error{
+				Code:    btcjson.ErrMisc.Code,
+				Message: fmt.Sprintf("Subnet %s is not banned", subnet),
+			}
+		}
+		rpcsLog.Infof("Lifted the ban of subnet %s", subnet)
+
+	default:
+		return nil, btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3160,6 +4181,22 @@ func handleStop(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (inter
 	return "btcd stopping.", nil
 }
 
+// handleSubmitAuxBlock implements the submitauxblock command.
+func handleSubmitAuxBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SubmitAuxBlockCmd)
+
+	if err := checkAuxBlockReady(s); err != nil {
+		return nil, err
+	}
+
+	// Protect concurrent access from multiple RPC invocations for merged
+	// mining work requests and submissions.
+	s.auxWorkState.Lock()
+	defer s.auxWorkState.Unlock()
+
+	return handleAuxBlockSubmission(s, c.Hash, c.AuxPow)
+}
+
 // handleSubmitBlock implements the submitblock command.
 func handleSubmitBlock(s *rpcServer, cmd btcjson.Cmd, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SubmitBlockCmd)
@@ -3232,7 +4269,7 @@ func verifyChain(db database.Db, level, depth int32, timeSource blockchain.Media
 		// Level 1 does basic chain sanity checks.
 		if level > 0 {
 			err := blockchain.CheckBlockSanity(block,
-				activeNetParams.PowLimit, timeSource)
+				activeNetParams.Params, timeSource)
 			if err != nil {
 				rpcsLog.Errorf("Verify is unable to "+
 					"validate block at sha %v height "+
@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
@@ -50,6 +51,7 @@ type wsCommandHandler func(*wsClient, btcjson.Cmd) (interface{}, *btcjson.Error)
 // functions.
 var wsHandlers = map[string]wsCommandHandler{
 	"notifyblocks":          handleNotifyBlocks,
+	"notifynames":           handleNotifyNames,
 	"notifynewtransactions": handleNotifyNewTransactions,
 	"notifyreceived":        handleNotifyReceived,
 	"notifyspent":           handleNotifySpent,
@@ -227,9 +229,39 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyNameExpired passes a name which expired when a block was connected to
+// the best chain to the notification manager for name notification
+// processing.
+func (m *wsNotificationManager) NotifyNameExpired(entry *database.NameEntry) {
+	// As NotifyNameExpired will be called by the block manager and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueueing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationNameExpired)(entry):
+	case <-m.quit:
+	}
+}
+
+// NotifyNameExpiryReverted passes a name which is active again since the block
+// which expired it was disconnected from the best chain to the notification
+// manager for name notification processing.
+func (m *wsNotificationManager) NotifyNameExpiryReverted(entry *database.NameEntry) {
+	// As NotifyNameExpiryReverted will be called by the block manager and
+	// the RPC server may no longer be running, use a select statement to
+	// unblock enqueueing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationNameExpiryReverted)(entry):
+	case <-m.quit:
+	}
+}
+
 // Notification types
 type notificationBlockConnected btcutil.Block
 type notificationBlockDisconnected btcutil.Block
+type notificationNameExpired database.NameEntry
+type notificationNameExpiryReverted database.NameEntry
 type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *btcutil.Tx
@@ -258,6 +290,10 @@ type notificationUnregisterAddr struct {
 	wsc  *wsClient
 	addr string
 }
+type notificationRegisterNamePrefix struct {
+	wsc    *wsClient
+	prefix string
+}
 
 // notificationHandler reads notifications and control messages from the queue
 // handler and processes one at a time.
@@ -276,6 +312,7 @@ func (m *wsNotificationManager) notificationHandler() {
 	txNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
+	watchedNamePrefixes := make(map[string]map[chan struct{}]*wsClient)
 
 out:
 	for {
@@ -292,6 +329,10 @@ out:
 					m.notifyBlockConnected(blockNotifications,
 						block)
 				}
+				if len(watchedNamePrefixes) != 0 {
+					m.notifyNameOperations(watchedNamePrefixes,
+						block, true)
+				}
 
 				// Skip iterating through all txs if no
 				// tx notification requests exist.
@@ -305,8 +346,21 @@ out:
 				}
 
 			case *notificationBlockDisconnected:
+				block := (*btcutil.Block)(n)
 				m.notifyBlockDisconnected(blockNotifications,
-					(*btcutil.Block)(n))
+					block)
+				if len(watchedNamePrefixes) != 0 {
+					m.notifyNameOperations(watchedNamePrefixes,
+						block, false)
+				}
+
+			case *notificationNameExpired:
+				m.notifyNameExpiry(watchedNamePrefixes,
+					(*database.NameEntry)(n), true)
+
+			case *notificationNameExpiryReverted:
+				m.notifyNameExpiry(watchedNamePrefixes,
+					(*database.NameEntry)(n), false)
 
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
@@ -339,6 +393,10 @@ out:
 				for addr := range wsc.addrRequests {
 					m.removeAddrRequest(watchedAddrs, wsc, addr)
 				}
+				for prefix := range wsc.namePrefixRequests {
+					m.removeNamePrefixRequest(watchedNamePrefixes,
+						wsc, prefix)
+				}
 				delete(clients, wsc.quit)
 
 			case *notificationRegisterSpent:
@@ -353,6 +411,10 @@ out:
 			case *notificationUnregisterAddr:
 				m.removeAddrRequest(watchedAddrs, n.wsc, n.addr)
 
+			case *notificationRegisterNamePrefix:
+				m.addNamePrefixRequest(watchedNamePrefixes, n.wsc,
+					n.prefix)
+
 			case *notificationRegisterNewMempoolTxs:
 				wsc := (*wsClient)(n)
 				txNotifications[wsc.quit] = wsc
@@ -776,6 +838,152 @@ func (*wsNotificationManager) removeAddrRequest(addrs map[string]map[chan struct
 	}
 }
 
+// RegisterNamePrefixRequest requests notifications to the passed websocket
+// client when a name starting with the passed prefix is updated or expires.
+func (m *wsNotificationManager) RegisterNamePrefixRequest(wsc *wsClient, prefix string) {
+	m.queueNotification <- &notificationRegisterNamePrefix{
+		wsc:    wsc,
+		prefix: prefix,
+	}
+}
+
+// addNamePrefixRequest adds the websocket client wsc to the name prefix to
+// client set prefixes so wsc will be notified about any name starting with
+// prefix.
+func (*wsNotificationManager) addNamePrefixRequest(prefixes map[string]map[chan struct{}]*wsClient,
+	wsc *wsClient, prefix string) {
+
+	// Track the request in the client as well so it can be quickly be
+	// removed on disconnect.
+	wsc.namePrefixRequests[prefix] = struct{}{}
+
+	// Add the client to the set of clients to notify when a matching name
+	// changes.  Create map as needed.
+	cmap, ok := prefixes[prefix]
+	if !ok {
+		cmap = make(map[chan struct{}]*wsClient)
+		prefixes[prefix] = cmap
+	}
+	cmap[wsc.quit] = wsc
+}
+
+// removeNamePrefixRequest removes the websocket client wsc from the name
+// prefix to client set prefixes so it will no longer receive notifications
+// about names starting with prefix.
+func (*wsNotificationManager) removeNamePrefixRequest(prefixes map[string]map[chan struct{}]*wsClient,
+	wsc *wsClient, prefix string) {
+
+	// Remove the request tracking from the client.
+	delete(wsc.namePrefixRequests, prefix)
+
+	// Remove the client from the list to notify.
+	cmap, ok := prefixes[prefix]
+	if !ok {
+		rpcsLog.Warnf("Attempt to remove nonexistent name prefix "+
+			"request <%s> for websocket client %s", prefix, wsc.addr)
+		return
+	}
+	delete(cmap, wsc.quit)
+
+	// Remove the map entry altogether if there are no more clients
+	// interested in it.
+	if len(cmap) == 0 {
+		delete(prefixes, prefix)
+	}
+}
+
+// clientsForName returns the set of websocket clients which registered for a
+// prefix of the passed name.  Each client is only included once even when it
+// registered for several matching prefixes.
+func clientsForName(prefixes map[string]map[chan struct{}]*wsClient,
+	name string) map[chan struct{}]*wsClient {
+
+	clients := make(map[chan struct{}]*wsClient)
+	for prefix, cmap := range prefixes {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		for quit, wsc := range cmap {
+			clients[quit] = wsc
+		}
+	}
+	return clients
+}
+
+// notifyNameOperations notifies websocket clients that have registered for a
+// matching name prefix about the name operations in a block which was connected
+// to or disconnected from the main chain.  A name_new does not reveal the name
+// it reserves, so it is never notified.
+func (*wsNotificationManager) notifyNameOperations(prefixes map[string]map[chan struct{}]*wsClient,
+	block *btcutil.Block, connected bool) {
+
+	for _, tx := range block.Transactions() {
+		for _, txOut := range tx.MsgTx().TxOut {
+			ns := txscript.ExtractNameScript(txOut.PkScript)
+			if ns == nil || ns.Op == txscript.OP_NAME_NEW {
+				continue
+			}
+
+			clients := clientsForName(prefixes, string(ns.Name))
+			if len(clients) == 0 {
+				continue
+			}
+
+			var ntfn btcjson.Cmd
+			if connected {
+				ntfn = btcws.NewNameUpdatedNtfn(string(ns.Name),
+					string(ns.Value), tx.Sha().String(),
+					int32(block.Height()))
+			} else {
+				ntfn = btcws.NewNameUpdateRevertedNtfn(
+					string(ns.Name), string(ns.Value),
+					tx.Sha().String(), int32(block.Height()))
+			}
+			marshalledJSON, err := json.Marshal(ntfn)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal %s notification: "+
+					"%v", ntfn.Method(), err)
+				continue
+			}
+			for _, wsc := range clients {
+				wsc.QueueNotification(marshalledJSON)
+			}
+		}
+	}
+}
+
+// notifyNameExpiry notifies websocket clients that have registered for a
+// matching name prefix when a name expires or becomes active again because the
+// block which expired it was disconnected.
+func (*wsNotificationManager) notifyNameExpiry(prefixes map[string]map[chan struct{}]*wsClient,
+	entry *database.NameEntry, expired bool) {
+
+	clients := clientsForName(prefixes, string(entry.Name))
+	if len(clients) == 0 {
+		return
+	}
+
+	var ntfn btcjson.Cmd
+	if expired {
+		ntfn = btcws.NewNameExpiredNtfn(string(entry.Name),
+			string(entry.Value), entry.TxSha.String(),
+			int32(entry.Height))
+	} else {
+		ntfn = btcws.NewNameExpiryRevertedNtfn(string(entry.Name),
+			string(entry.Value), entry.TxSha.String(),
+			int32(entry.Height))
+	}
+	marshalledJSON, err := json.Marshal(ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal %s notification: %v",
+			ntfn.Method(), err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // AddClient adds the passed websocket client to the notification manager.
 func (m *wsNotificationManager) AddClient(wsc *wsClient) {
 	m.queueNotification <- (*notificationRegisterClient)(wsc)
@@ -908,6 +1116,12 @@ type wsClient struct {
 	// Owned by the notification manager.
 	spentRequests map[wire.OutPoint]struct{}
 
+	// namePrefixRequests is a set of name prefixes the caller has requested
+	// to be notified about.  It is maintained here so all requests can be
+	// removed when a client disconnects.  Owned by the notification
+	// manager.
+	namePrefixRequests map[string]struct{}
+
 	// Networking infrastructure.
 	asyncStarted bool
 	asyncChan    chan btcjson.Cmd
@@ -1370,16 +1584,17 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 	remoteAddr string, authenticated bool) *wsClient {
 
 	return &wsClient{
-		conn:          conn,
-		addr:          remoteAddr,
-		authenticated: authenticated,
-		server:        server,
-		addrRequests:  make(map[string]struct{}),
-		spentRequests: make(map[wire.OutPoint]struct{}),
-		ntfnChan:      make(chan []byte, 1),      // nonblocking sync
-		asyncChan:     make(chan btcjson.Cmd, 1), // nonblocking sync
-		sendChan:      make(chan wsResponse, websocketSendBufferSize),
-		quit:          make(chan struct{}),
+		conn:               conn,
+		addr:               remoteAddr,
+		authenticated:      authenticated,
+		server:             server,
+		addrRequests:       make(map[string]struct{}),
+		spentRequests:      make(map[wire.OutPoint]struct{}),
+		namePrefixRequests: make(map[string]struct{}),
+		ntfnChan:           make(chan []byte, 1),      // nonblocking sync
+		asyncChan:          make(chan btcjson.Cmd, 1), // nonblocking sync
+		sendChan:           make(chan wsResponse, websocketSendBufferSize),
+		quit:               make(chan struct{}),
 	}
 }
 
@@ -1390,6 +1605,20 @@ func handleNotifyBlocks(wsc *wsClient, icmd btcjson.Cmd) (interface{}, *btcjson.
 	return nil, nil
 }
 
+// handleNotifyNames implements the notifynames command extension for
+// websocket connections.
+func handleNotifyNames(wsc *wsClient, icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	cmd, ok := icmd.(*btcws.NotifyNamesCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	for _, prefix := range cmd.Prefixes {
+		wsc.server.ntfnMgr.RegisterNamePrefixRequest(wsc, prefix)
+	}
+	return nil, nil
+}
+
 // handleNotifySpent implements the notifyspent command extension for
 // websocket connections.
 func handleNotifySpent(wsc *wsClient, icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
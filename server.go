@@ -13,6 +13,7 @@ import (
 	"math"
 	mrand "math/rand"
 	"net"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"sync"
@@ -87,6 +88,8 @@ type server struct {
 	blockManager         *blockManager
 	addrIndexer          *addrIndexer
 	txMemPool            *txMemPool
+	feeEstimator         *feeEstimator
+	banList              *banList
 	cpuMiner             *CPUMiner
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *peer
@@ -107,7 +110,6 @@ type peerState struct {
 	peers            *list.List
 	outboundPeers    *list.List
 	persistentPeers  *list.List
-	banned           map[string]time.Time
 	outboundGroups   map[string]int
 	maxOutboundPeers int
 }
@@ -200,23 +202,18 @@ func (s *server) handleAddPeerMsg(state *peerState, p *peer) bool {
 		return false
 	}
 
-	// Disconnect banned peers.
-	host, _, err := net.SplitHostPort(p.addr)
-	if err != nil {
-		srvrLog.Debugf("can't split hostport %v", err)
+	// Disconnect banned peers unless they are whitelisted.
+	ip := peerIP(p)
+	if ip == nil {
+		srvrLog.Debugf("can't determine IP address of peer %s", p)
 		p.Shutdown()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - "+
-				"disconnecting", host, banEnd.Sub(time.Now()))
-			p.Shutdown()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	if banEnd, banned := s.banList.IsBanned(ip); banned && !p.whitelisted {
+		srvrLog.Debugf("Peer %s is banned for another %v - "+
+			"disconnecting", ip, banEnd.Sub(time.Now()))
+		p.Shutdown()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
@@ -282,16 +279,16 @@ func (s *server) handleDonePeerMsg(state *peerState, p *peer) {
 // handleBanPeerMsg deals with banning peers.  It is invoked from the
 // peerHandler goroutine.
 func (s *server) handleBanPeerMsg(state *peerState, p *peer) {
-	host, _, err := net.SplitHostPort(p.addr)
-	if err != nil {
-		srvrLog.Debugf("can't split ban peer %s %v", p.addr, err)
+	ip := peerIP(p)
+	if ip == nil {
+		srvrLog.Debugf("can't determine IP address of ban peer %s", p)
 		return
 	}
 	direction := directionString(p.inbound)
-	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
+	srvrLog.Infof("Banned peer %s (%s) for %v", ip, direction,
 		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
-
+	s.banList.Ban(singleIPSubnet(ip), time.Now().Add(cfg.BanDuration),
+		banReasonMisbehaving)
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
@@ -302,6 +299,30 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			return
 		}
 
+		// Announce the block with its header, including the AuxPow,
+		// when the peer asked for headers announcements.  This is only
+		// done when the peer is known to have the previous block, so
+		// it can connect the header.  Otherwise, fall back to an
+		// inventory announcement so the peer requests the missing
+		// blocks.
+		if msg.invVect.Type == wire.InvTypeBlock && p.WantsHeaders() &&
+			!p.isKnownInventory(msg.invVect) {
+
+			blockHeader, ok := msg.data.(*wire.BlockHeader)
+			prevInv := wire.InvVect{Type: wire.InvTypeBlock}
+			if ok {
+				prevInv.Hash = blockHeader.PrevBlock
+			}
+			if ok && p.isKnownInventory(&prevInv) {
+				headersMsg := &wire.MsgHeaders{
+					Headers: []*wire.BlockHeader{blockHeader},
+				}
+				p.AddKnownInventory(msg.invVect)
+				p.QueueMessage(headersMsg, nil)
+				return
+			}
+		}
+
 		if msg.invVect.Type == wire.InvTypeTx {
 			// Don't relay the transaction to the peer when it has
 			// transaction relaying disabled.
@@ -375,6 +396,11 @@ type getAddedNodesMsg struct {
 	reply chan []*peer
 }
 
+type disconnectSubnetMsg struct {
+	subnet *net.IPNet
+	reply  chan int
+}
+
 // handleQuery is the central handler for all queries and commands from other
 // goroutines related to peer state.
 func (s *server) handleQuery(querymsg interface{}, state *peerState) {
@@ -413,8 +439,9 @@ func (s *server) handleQuery(querymsg interface{}, state *peerState) {
 				SubVer:         p.userAgent,
 				Inbound:        p.inbound,
 				StartingHeight: p.lastBlock,
-				BanScore:       0,
+				BanScore:       int32(p.banScore.Int()),
 				SyncNode:       p == syncPeer,
+				Whitelisted:    p.whitelisted,
 			}
 			info.PingTime = float64(p.lastPingMicros)
 			if p.lastPingNonce != 0 {
@@ -478,6 +505,19 @@ func (s *server) handleQuery(querymsg interface{}, state *peerState) {
 			peers = append(peers, peer)
 		}
 		msg.reply <- peers
+
+	// Disconnect the peers in a subnet which was banned.
+	case disconnectSubnetMsg:
+		disconnected := 0
+		state.forAllPeers(func(p *peer) {
+			ip := peerIP(p)
+			if ip != nil && msg.subnet.Contains(ip) && !p.whitelisted {
+				srvrLog.Infof("Disconnecting banned peer %s", p)
+				p.Disconnect()
+				disconnected++
+			}
+		})
+		msg.reply <- disconnected
 	}
 }
 
@@ -495,6 +535,19 @@ func (s *server) listenHandler(listener net.Listener) {
 			}
 			continue
 		}
+
+		// Drop connections from banned subnets right away unless
+		// they are whitelisted.
+		if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok &&
+			!isWhitelisted(tcpAddr) {
+
+			if _, banned := s.banList.IsBanned(tcpAddr.IP); banned {
+				srvrLog.Debugf("Rejected connection from banned "+
+					"address %s", tcpAddr)
+				conn.Close()
+				continue
+			}
+		}
 		s.AddPeer(newInboundPeer(s, conn))
 	}
 	s.wg.Done()
@@ -565,7 +618,6 @@ func (s *server) peerHandler() {
 		peers:            list.New(),
 		persistentPeers:  list.New(),
 		outboundPeers:    list.New(),
-		banned:           make(map[string]time.Time),
 		maxOutboundPeers: defaultMaxOutbound,
 		outboundGroups:   make(map[string]int),
 	}
@@ -676,6 +728,11 @@ out:
 
 			// XXX if we have limited that address skip
 
+			// Never connect to banned addresses.
+			if _, banned := s.banList.IsBanned(addr.NetAddress().IP); banned {
+				continue
+			}
+
 			// only allow recent nodes (10mins) after we failed 30
 			// times
 			if time.Now().After(addr.LastAttempt().Add(10*time.Minute)) &&
@@ -711,11 +768,40 @@ out:
 		s.addrIndexer.Stop()
 	}
 	s.blockManager.Stop()
+	s.feeEstimator.Save()
 	s.addrManager.Stop()
 	s.wg.Done()
 	srvrLog.Tracef("Peer handler done")
 }
 
+// mempoolHandler reloads the transactions which were in the memory pool on
+// the last shutdown and saves the memory pool again when the server shuts
+// down.  The saved memory pool is left untouched when the server shuts down
+// before all of its transactions were reloaded.  In between, expired
+// transactions are periodically removed from the memory pool.  It must be run
+// as a goroutine.
+func (s *server) mempoolHandler() {
+	file := filepath.Join(cfg.DataDir, mempoolFileName)
+	if s.txMemPool.Load(file, s.quit) {
+		expireTicker := time.NewTicker(mempoolExpireScanInterval)
+	out:
+		for {
+			select {
+			case <-expireTicker.C:
+				s.txMemPool.ExpireTransactions()
+
+			case <-s.quit:
+				break out
+			}
+		}
+		expireTicker.Stop()
+		s.txMemPool.Save(file)
+	}
+
+	s.wg.Done()
+	srvrLog.Tracef("Memory pool handler done")
+}
+
 // AddPeer adds a new peer that has already been connected to the server.
 func (s *server) AddPeer(p *peer) {
 	s.newPeers <- p
@@ -727,7 +813,9 @@ func (s *server) BanPeer(p *peer) {
 }
 
 // RelayInventory relays the passed inventory to all connected peers that are
-// not already known to have it.
+// not already known to have it.  The data is the transaction for transaction
+// inventory and the block header for block inventory, which is used to
+// announce the block to peers which asked for headers announcements.
 func (s *server) RelayInventory(invVect *wire.InvVect, data interface{}) {
 	s.relayInv <- relayMsg{invVect: invVect, data: data}
 }
@@ -779,6 +867,16 @@ func (s *server) AddAddr(addr string, permanent bool) error {
 	return <-replyChan
 }
 
+// DisconnectSubnet disconnects all peers which are not whitelisted and whose IP
+// address is in the passed subnet and returns how many were disconnected.
+func (s *server) DisconnectSubnet(subnet *net.IPNet) int {
+	replyChan := make(chan int)
+
+	s.query <- disconnectSubnetMsg{subnet: subnet, reply: replyChan}
+
+	return <-replyChan
+}
+
 // RemoveAddr removes `addr' from the list of persistent peers if present.
 // An error will be returned if the peer was not found.
 func (s *server) RemoveAddr(addr string) error {
@@ -895,6 +993,11 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Start the handler which reloads the memory pool and saves it on
+	// shutdown.
+	s.wg.Add(1)
+	go s.mempoolHandler()
+
 	if s.nat != nil {
 		s.wg.Add(1)
 		go s.upnpUpdateThread()
@@ -1254,6 +1357,9 @@ func newServer(listenAddrs []string, db database.Db, chainParams *chaincfg.Param
 	}
 	s.blockManager = bm
 	s.txMemPool = newTxMemPool(&s)
+	s.feeEstimator = newFeeEstimator(filepath.Join(cfg.DataDir,
+		feeEstimatorFileName))
+	s.banList = newBanList(filepath.Join(cfg.DataDir, banListFileName))
 	s.cpuMiner = newCPUMiner(&s)
 
 	if cfg.AddrIndex {
@@ -0,0 +1,109 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+// Namecoin marks name operations by prefixing an ordinary output script with
+// one of the small integer opcodes followed by the operation arguments, which
+// are then dropped from the stack again before the ownership script runs.
+const (
+	OP_NAME_NEW         = OP_1 // OP_NAME_NEW <hash> OP_2DROP
+	OP_NAME_FIRSTUPDATE = OP_2 // OP_NAME_FIRSTUPDATE <name> <rand> <value> OP_2DROP OP_2DROP
+	OP_NAME_UPDATE      = OP_3 // OP_NAME_UPDATE <name> <value> OP_2DROP OP_DROP
+)
+
+// NameScript houses the components of a parsed Namecoin name script.
+type NameScript struct {
+	// Op is the name operation performed by the script.  It is one of
+	// OP_NAME_NEW, OP_NAME_FIRSTUPDATE or OP_NAME_UPDATE.
+	Op byte
+
+	// Hash is the salted hash commitment of a name_new operation.
+	Hash []byte
+
+	// Name is the name a name_firstupdate or name_update operates on.
+	Name []byte
+
+	// Rand is the salt revealed by a name_firstupdate operation.
+	Rand []byte
+
+	// Value is the value a name_firstupdate or name_update assigns.
+	Value []byte
+
+	// AddressScript is the script following the name prefix which controls
+	// ownership of the name.
+	AddressScript []byte
+}
+
+// nameScriptArgs returns the number of pushed arguments and the trailing drop
+// opcodes of the name operation op.
+func nameScriptArgs(op byte) (int, []byte) {
+	switch op {
+	case OP_NAME_NEW:
+		return 1, []byte{OP_2DROP}
+	case OP_NAME_FIRSTUPDATE:
+		return 3, []byte{OP_2DROP, OP_2DROP}
+	case OP_NAME_UPDATE:
+		return 2, []byte{OP_2DROP, OP_DROP}
+	}
+	return 0, nil
+}
+
+// parseNameScript returns the parsed name script of pops or nil when pops is
+// not a well-formed name script.
+func parseNameScript(pops []parsedOpcode) *NameScript {
+	if len(pops) == 0 {
+		return nil
+	}
+
+	op := pops[0].opcode.value
+	nargs, drops := nameScriptArgs(op)
+	if nargs == 0 || len(pops) < 1+nargs+len(drops) {
+		return nil
+	}
+
+	// The arguments must all be data pushes.
+	args := pops[1 : 1+nargs]
+	for _, pop := range args {
+		if pop.opcode.value > OP_PUSHDATA4 {
+			return nil
+		}
+	}
+	for i, drop := range drops {
+		if pops[1+nargs+i].opcode.value != drop {
+			return nil
+		}
+	}
+
+	addrScript, err := unparseScript(pops[1+nargs+len(drops):])
+	if err != nil {
+		return nil
+	}
+
+	ns := &NameScript{Op: op, AddressScript: addrScript}
+	switch op {
+	case OP_NAME_NEW:
+		ns.Hash = args[0].data
+	case OP_NAME_FIRSTUPDATE:
+		ns.Name = args[0].data
+		ns.Rand = args[1].data
+		ns.Value = args[2].data
+	case OP_NAME_UPDATE:
+		ns.Name = args[0].data
+		ns.Value = args[1].data
+	}
+
+	return ns
+}
+
+// ExtractNameScript parses the passed public key script as a Namecoin name
+// script.  It returns nil if the script does not perform a name operation.
+func ExtractNameScript(pkScript []byte) *NameScript {
+	pops, err := parseScript(pkScript)
+	if err != nil {
+		return nil
+	}
+
+	return parseNameScript(pops)
+}
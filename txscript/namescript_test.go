@@ -0,0 +1,129 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/melange-app/nmcd/txscript"
+)
+
+// mustBuildScript returns the script of the passed builder and panics if it
+// fails to build.  It is only used in the tests to build fixed scripts.
+func mustBuildScript(builder *txscript.ScriptBuilder) []byte {
+	script, err := builder.Script()
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// TestExtractNameScript ensures name scripts are parsed into their components
+// and that malformed or ordinary scripts are not treated as name scripts.
+func TestExtractNameScript(t *testing.T) {
+	// p2pkh is an ordinary pay-to-pubkey-hash script used as the address
+	// script of the name operations.
+	hash := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a,
+		0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14}
+	p2pkh := mustBuildScript(txscript.NewScriptBuilder().
+		AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).AddData(hash).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG))
+
+	tests := []struct {
+		name   string
+		script []byte
+		want   *txscript.NameScript
+	}{
+		{
+			name: "name_new",
+			script: append(mustBuildScript(txscript.NewScriptBuilder().
+				AddOp(txscript.OP_NAME_NEW).AddData(hash).
+				AddOp(txscript.OP_2DROP)), p2pkh...),
+			want: &txscript.NameScript{
+				Op:            txscript.OP_NAME_NEW,
+				Hash:          hash,
+				AddressScript: p2pkh,
+			},
+		},
+		{
+			name: "name_firstupdate",
+			script: append(mustBuildScript(txscript.NewScriptBuilder().
+				AddOp(txscript.OP_NAME_FIRSTUPDATE).
+				AddData([]byte("d/a")).AddData([]byte{0x01, 0x02}).
+				AddData([]byte("value")).AddOp(txscript.OP_2DROP).
+				AddOp(txscript.OP_2DROP)), p2pkh...),
+			want: &txscript.NameScript{
+				Op:            txscript.OP_NAME_FIRSTUPDATE,
+				Name:          []byte("d/a"),
+				Rand:          []byte{0x01, 0x02},
+				Value:         []byte("value"),
+				AddressScript: p2pkh,
+			},
+		},
+		{
+			name: "name_update with empty value",
+			script: append(mustBuildScript(txscript.NewScriptBuilder().
+				AddOp(txscript.OP_NAME_UPDATE).AddData([]byte("d/a")).
+				AddOp(txscript.OP_0).AddOp(txscript.OP_2DROP).
+				AddOp(txscript.OP_DROP)), p2pkh...),
+			want: &txscript.NameScript{
+				Op:            txscript.OP_NAME_UPDATE,
+				Name:          []byte("d/a"),
+				Value:         []byte{},
+				AddressScript: p2pkh,
+			},
+		},
+		{
+			name:   "ordinary script",
+			script: p2pkh,
+			want:   nil,
+		},
+		{
+			name: "name_update missing drop",
+			script: append(mustBuildScript(txscript.NewScriptBuilder().
+				AddOp(txscript.OP_NAME_UPDATE).AddData([]byte("d/a")).
+				AddOp(txscript.OP_0).AddOp(txscript.OP_2DROP)),
+				p2pkh...),
+			want: nil,
+		},
+		{
+			name: "name_update with non-push argument",
+			script: mustBuildScript(txscript.NewScriptBuilder().
+				AddOp(txscript.OP_NAME_UPDATE).AddData([]byte("d/a")).
+				AddOp(txscript.OP_DUP).AddOp(txscript.OP_2DROP).
+				AddOp(txscript.OP_DROP)),
+			want: nil,
+		},
+		{
+			name: "truncated name_firstupdate",
+			script: mustBuildScript(txscript.NewScriptBuilder().
+				AddOp(txscript.OP_NAME_FIRSTUPDATE).
+				AddData([]byte("d/a"))),
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		got := txscript.ExtractNameScript(test.script)
+		if test.want == nil {
+			if got != nil {
+				t.Errorf("ExtractNameScript (%s): unexpected name "+
+					"script %s", test.name, spew.Sdump(got))
+			}
+			continue
+		}
+
+		// An empty push is parsed as a nil slice.
+		if got != nil && got.Value == nil && test.want.Value != nil {
+			got.Value = []byte{}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ExtractNameScript (%s): got %s, want %s",
+				test.name, spew.Sdump(got), spew.Sdump(test.want))
+		}
+	}
+}
@@ -186,13 +186,15 @@ var scriptClassToName = []string{
 	ScriptHashTy:  "scripthash",
 	MultiSigTy:    "multisig",
 	NullDataTy:    "nulldata",
+
+	NameTransactionTy: "name",
 }
 
 // String implements the Stringer interface by returning the name of
 // the enum script class. If the enum is invalid then "Invalid" will be
 // returned.
 func (t ScriptClass) String() string {
-	if int(t) > len(scriptClassToName) || int(t) < 0 {
+	if int(t) >= len(scriptClassToName) || int(t) < 0 {
 		return "Invalid"
 	}
 	return scriptClassToName[t]
@@ -313,18 +315,11 @@ func isNullData(pops []parsedOpcode) bool {
 }
 
 // isNameTransaction returns true if the passed script is a namecoin
-// name transaction, false otherwise
+// name transaction, false otherwise.
 func isNameTransaction(pops []parsedOpcode) bool {
-	// A Name transaction has at least two operations, the first
-	// one is either OP_1, OP_2, or OP_3
-	l := len(pops)
-	if l < 2 {
-		return false
-	}
-
-	first := pops[0].opcode.value
-
-	return first == OP_1 || first == OP_2 || first == OP_3
+	// A name transaction starts with one of OP_1, OP_2 or OP_3 followed
+	// by the pushed arguments of the operation and the matching drops.
+	return parseNameScript(pops) != nil
 }
 
 // isPushOnly returns true if the script only pushes data, false otherwise.
@@ -1,6 +1,9 @@
 package wire
 
-import "io"
+import (
+	"fmt"
+	"io"
+)
 
 const (
 	blockVersionDefault    = (1 << 0)
@@ -9,6 +12,16 @@ const (
 	blockVersionChainEnd   = (1 << 30)
 )
 
+// MaxMerkleBranchLen is the maximum number of hashes a merkle branch in an
+// AuxPow header can contain.  A branch of this length is already enough to
+// prove membership in a tree with over a billion leaves, so anything larger
+// can only be an attempt to exhaust memory.
+const MaxMerkleBranchLen = 30
+
+// AuxPow defines the auxiliary proof of work that is attached to the header
+// of a merged mined block.  It proves that the hash of the block was committed
+// to in the coinbase of a parent chain block that satisfies the difficulty of
+// this chain.
 type AuxPow struct {
 	// CoinbaseTxn is the transaction that is in the parent block.
 	CoinbaseTx *MsgTx
@@ -16,12 +29,46 @@ type AuxPow struct {
 	// BlockHash is the hash of the ParentBlock header
 	BlockHash ShaHash
 
-	CoinbaseBranch   MerkleBranch
+	// CoinbaseBranch proves that CoinbaseTx is part of the merkle tree of
+	// the ParentBlock.
+	CoinbaseBranch MerkleBranch
+
+	// BlockchainBranch proves that the hash of the block is part of the
+	// merged mining merkle tree committed to in CoinbaseTx.
 	BlockchainBranch MerkleBranch
 
+	// ParentBlock is the header of the parent chain block.  It is always
+	// encoded as a plain 80 byte header regardless of its version.
 	ParentBlock BlockHeader
 }
 
+// SerializeSize returns the number of bytes it would take to serialize the
+// AuxPow.
+func (ap *AuxPow) SerializeSize() int {
+	n := HashSize + blockHeaderLen
+	if ap.CoinbaseTx != nil {
+		n += ap.CoinbaseTx.SerializeSize()
+	}
+
+	return n + ap.CoinbaseBranch.SerializeSize() +
+		ap.BlockchainBranch.SerializeSize()
+}
+
+// Deserialize decodes an AuxPow from r into the receiver using the same format
+// it has when it follows a merged mined block header.  This is the format
+// merged mining software uses to submit the proof of work of a block.
+func (ap *AuxPow) Deserialize(r io.Reader) error {
+	return readAuxPow(r, 0, ap)
+}
+
+// Serialize encodes the AuxPow to w using the same format it has when it
+// follows a merged mined block header.
+func (ap *AuxPow) Serialize(w io.Writer) error {
+	return writeAuxPow(w, 0, ap)
+}
+
+// MerkleBranch defines a path through a merkle tree that proves a hash is
+// part of the tree.  It is used in AuxPow headers.
 type MerkleBranch struct {
 	// BranchHash contains all of the ShaHash objects required to verify
 	// that the specified object is in the MerkleTree.
@@ -33,6 +80,16 @@ type MerkleBranch struct {
 	BranchSideMask int32
 }
 
+// SerializeSize returns the number of bytes it would take to serialize the
+// merkle branch.
+func (mb *MerkleBranch) SerializeSize() int {
+	// Serialized varint size for the number of hashes + the hashes + 4
+	// bytes for the side mask.
+	return VarIntSerializeSize(uint64(len(mb.BranchHash))) +
+		len(mb.BranchHash)*HashSize + 4
+}
+
+// readAuxPow reads an AuxPow header from r.
 func readAuxPow(r io.Reader, pver uint32, ap *AuxPow) error {
 	ap.CoinbaseTx = &MsgTx{}
 	if err := ap.CoinbaseTx.BtcDecode(r, pver); err != nil {
@@ -51,19 +108,57 @@ func readAuxPow(r io.Reader, pver uint32, ap *AuxPow) error {
 		return err
 	}
 
-	if err := readBlockHeader(r, pver, &ap.ParentBlock); err != nil {
+	if err := readBaseBlockHeader(r, pver, &ap.ParentBlock); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeAuxPow writes an AuxPow header to w.
+func writeAuxPow(w io.Writer, pver uint32, ap *AuxPow) error {
+	if ap.CoinbaseTx == nil {
+		return messageError("writeAuxPow", "auxpow is missing the "+
+			"parent coinbase transaction")
+	}
+
+	if err := ap.CoinbaseTx.BtcEncode(w, pver); err != nil {
+		return err
+	}
+
+	if err := writeElement(w, &ap.BlockHash); err != nil {
+		return err
+	}
+
+	if err := writeMerkleBranch(w, pver, &ap.CoinbaseBranch); err != nil {
+		return err
+	}
+
+	if err := writeMerkleBranch(w, pver, &ap.BlockchainBranch); err != nil {
+		return err
+	}
+
+	if err := writeBaseBlockHeader(w, pver, &ap.ParentBlock); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// readMerkleBranch reads a merkle branch of an AuxPow header from r.
 func readMerkleBranch(r io.Reader, pver uint32, mb *MerkleBranch) error {
 	count, err := readVarInt(r, pver)
 	if err != nil {
 		return err
 	}
 
+	// Prevent a hostile branch length from exhausting memory.
+	if count > MaxMerkleBranchLen {
+		str := fmt.Sprintf("too many hashes in merkle branch "+
+			"[count %d, max %d]", count, MaxMerkleBranchLen)
+		return messageError("readMerkleBranch", str)
+	}
+
 	mb.BranchHash = make([]ShaHash, count)
 	for i := uint64(0); i < count; i++ {
 		if err := readElement(r, &mb.BranchHash[i]); err != nil {
@@ -77,3 +172,29 @@ func readMerkleBranch(r io.Reader, pver uint32, mb *MerkleBranch) error {
 
 	return nil
 }
+
+// writeMerkleBranch writes a merkle branch of an AuxPow header to w.
+func writeMerkleBranch(w io.Writer, pver uint32, mb *MerkleBranch) error {
+	count := len(mb.BranchHash)
+	if count > MaxMerkleBranchLen {
+		str := fmt.Sprintf("too many hashes in merkle branch "+
+			"[count %d, max %d]", count, MaxMerkleBranchLen)
+		return messageError("writeMerkleBranch", str)
+	}
+
+	if err := writeVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+
+	for i := range mb.BranchHash {
+		if err := writeElement(w, &mb.BranchHash[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := writeElement(w, mb.BranchSideMask); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,966 @@
+// Copyright (c) 2013-2015 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire_test
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/melange-app/nmcd/wire"
+)
+
+// auxPowBlockTests are the merged mined blocks used by the round trip tests
+// along with their serialized bytes and header length.
+var auxPowBlockTests = []struct {
+	name   string
+	block  *wire.MsgBlock
+	buf    []byte
+	hdrLen int
+	hash   string
+}{
+	{
+		"no blockchain branch", &auxPowBlock, auxPowBlockBytes,
+		auxPowBlockHeaderLen, "df5b99fb2d3470f5950995e2" +
+			"9e21e95462262e2cce807c6146c632359ad1fb78",
+	},
+	{
+		"blockchain branch", &auxPowBranchBlock, auxPowBranchBlockBytes,
+		auxPowBranchBlockHeaderLen, "4b9815e0c847042c6a9c727a" +
+			"3f33207416e03bce69aa94dcab105fa886a75cec",
+	},
+}
+
+// TestAuxPowBlockWire tests the MsgBlock wire encode and decode of merged
+// mined blocks to ensure the AuxPow header survives a round trip.
+func TestAuxPowBlockWire(t *testing.T) {
+	pvers := []uint32{
+		wire.ProtocolVersion,
+		wire.BIP0035Version,
+		wire.BIP0031Version,
+		wire.NetAddressTimeVersion,
+		wire.MultipleAddressVersion,
+	}
+
+	t.Logf("Running %d tests", len(auxPowBlockTests)*len(pvers))
+	for _, test := range auxPowBlockTests {
+		for i, pver := range pvers {
+			// Encode the message to wire format.
+			var buf bytes.Buffer
+			err := test.block.BtcEncode(&buf, pver)
+			if err != nil {
+				t.Errorf("BtcEncode %s #%d error %v", test.name, i,
+					err)
+				continue
+			}
+			if !bytes.Equal(buf.Bytes(), test.buf) {
+				t.Errorf("BtcEncode %s #%d\n got: %s want: %s",
+					test.name, i, spew.Sdump(buf.Bytes()),
+					spew.Sdump(test.buf))
+				continue
+			}
+
+			// Decode the message from wire format.
+			var msg wire.MsgBlock
+			rbuf := bytes.NewReader(test.buf)
+			err = msg.BtcDecode(rbuf, pver)
+			if err != nil {
+				t.Errorf("BtcDecode %s #%d error %v", test.name, i,
+					err)
+				continue
+			}
+			if !reflect.DeepEqual(&msg, test.block) {
+				t.Errorf("BtcDecode %s #%d\n got: %s want: %s",
+					test.name, i, spew.Sdump(&msg),
+					spew.Sdump(test.block))
+				continue
+			}
+		}
+	}
+}
+
+// TestAuxPowBlockSerialize tests that merged mined blocks serialize to the
+// expected bytes, report the matching size, and that their transaction
+// locations account for the AuxPow header.
+func TestAuxPowBlockSerialize(t *testing.T) {
+	for _, test := range auxPowBlockTests {
+		var buf bytes.Buffer
+		err := test.block.Serialize(&buf)
+		if err != nil {
+			t.Errorf("Serialize %s: error %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("Serialize %s\n got: %s want: %s", test.name,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		if size := test.block.SerializeSize(); size != len(test.buf) {
+			t.Errorf("SerializeSize %s: wrong size - got %d, "+
+				"want %d", test.name, size, len(test.buf))
+		}
+		hdr := &test.block.Header
+		if size := hdr.SerializeSize(); size != test.hdrLen {
+			t.Errorf("BlockHeader.SerializeSize %s: wrong size - "+
+				"got %d, want %d", test.name, size, test.hdrLen)
+		}
+
+		var block wire.MsgBlock
+		txLocs, err := block.DeserializeTxLoc(bytes.NewBuffer(test.buf))
+		if err != nil {
+			t.Errorf("DeserializeTxLoc %s: error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(&block, test.block) {
+			t.Errorf("DeserializeTxLoc %s\n got: %s want: %s",
+				test.name, spew.Sdump(&block),
+				spew.Sdump(test.block))
+		}
+		wantTxLocs := []wire.TxLoc{{TxStart: test.hdrLen + 1,
+			TxLen: len(test.buf) - test.hdrLen - 1}}
+		if !reflect.DeepEqual(txLocs, wantTxLocs) {
+			t.Errorf("DeserializeTxLoc %s: wrong tx locations\n "+
+				"got: %s want: %s", test.name, spew.Sdump(txLocs),
+				spew.Sdump(wantTxLocs))
+		}
+	}
+}
+
+// TestAuxPowBlockSha ensures the hash of a merged mined block only covers the
+// base header and not the AuxPow header.
+func TestAuxPowBlockSha(t *testing.T) {
+	for _, test := range auxPowBlockTests {
+		wantHash, err := wire.NewShaHashFromStr(test.hash)
+		if err != nil {
+			t.Errorf("NewShaHashFromStr %s: %v", test.name, err)
+			continue
+		}
+
+		blockHash, err := test.block.BlockSha()
+		if err != nil {
+			t.Errorf("BlockSha %s: %v", test.name, err)
+		}
+		if !blockHash.IsEqual(wantHash) {
+			t.Errorf("BlockSha %s: wrong hash - got %v, want %v",
+				test.name, spew.Sprint(blockHash),
+				spew.Sprint(wantHash))
+		}
+
+		// The parent block hash is stored in the AuxPow header as well.
+		auxPow := test.block.Header.AuxPowHeader
+		parentHash, err := auxPow.ParentBlock.BlockSha()
+		if err != nil {
+			t.Errorf("BlockSha %s: %v", test.name, err)
+		}
+		if !parentHash.IsEqual(&auxPow.BlockHash) {
+			t.Errorf("BlockSha %s: wrong parent hash - got %v, "+
+				"want %v", test.name, spew.Sprint(parentHash),
+				spew.Sprint(auxPow.BlockHash))
+		}
+	}
+}
+
+// TestAuxPowHeaders tests that the AuxPow header is included when merged mined
+// block headers are sent in a headers message.
+func TestAuxPowHeaders(t *testing.T) {
+	pver := wire.ProtocolVersion
+
+	hdr := auxPowBlock.Header
+	msg := wire.NewMsgHeaders()
+	msg.AddBlockHeader(&hdr)
+
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver)
+	if err != nil {
+		t.Fatalf("BtcEncode: error %v", err)
+	}
+
+	// Number of headers + header bytes + a zero transaction count.
+	want := make([]byte, 0, auxPowBlockHeaderLen+2)
+	want = append(want, 0x01)
+	want = append(want, auxPowBlockBytes[:auxPowBlockHeaderLen]...)
+	want = append(want, 0x00)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want))
+	}
+
+	var readmsg wire.MsgHeaders
+	err = readmsg.BtcDecode(bytes.NewReader(want), pver)
+	if err != nil {
+		t.Fatalf("BtcDecode: error %v", err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode\n got: %s want: %s",
+			spew.Sdump(&readmsg), spew.Sdump(msg))
+	}
+}
+
+// TestAuxPowWireErrors performs negative tests against wire encode and decode
+// of merged mined block headers to confirm error paths work correctly.
+func TestAuxPowWireErrors(t *testing.T) {
+	pver := wire.ProtocolVersion
+
+	tests := []struct {
+		max      int   // Max size of fixed buffer to induce errors
+		writeErr error // Expected write error
+		readErr  error // Expected read error
+	}{
+		// Force error in parent coinbase version.
+		{80, io.ErrShortWrite, io.EOF},
+		// Force error in parent coinbase signature script.
+		{122, io.ErrShortWrite, io.EOF},
+		// Force error in parent block hash.
+		{214, io.ErrShortWrite, io.EOF},
+		// Force error in coinbase branch count.
+		{246, io.ErrShortWrite, io.EOF},
+		// Force error in coinbase branch hashes.
+		{247, io.ErrShortWrite, io.EOF},
+		// Force error in coinbase branch side mask.
+		{311, io.ErrShortWrite, io.EOF},
+		// Force error in blockchain branch count.
+		{315, io.ErrShortWrite, io.EOF},
+		// Force error in blockchain branch side mask.
+		{316, io.ErrShortWrite, io.EOF},
+		// Force error in parent block header.
+		{320, io.ErrShortWrite, io.EOF},
+		// Force error in parent block nonce.
+		{396, io.ErrShortWrite, io.EOF},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := wire.TstWriteBlockHeader(w, pver, &auxPowBlock.Header)
+		if err != test.writeErr {
+			t.Errorf("writeBlockHeader #%d wrong error got: %v, "+
+				"want: %v", i, err, test.writeErr)
+			continue
+		}
+
+		// Decode from wire format.
+		var bh wire.BlockHeader
+		r := newFixedReader(test.max, auxPowBlockBytes)
+		err = wire.TstReadBlockHeader(r, pver, &bh)
+		if err != test.readErr {
+			t.Errorf("readBlockHeader #%d wrong error got: %v, "+
+				"want: %v", i, err, test.readErr)
+			continue
+		}
+	}
+
+	// A header that signals auxpow without carrying one must be rejected
+	// since it could never be decoded again.
+	noAuxPow := auxPowBlock.Header
+	noAuxPow.AuxPowHeader = nil
+	var buf bytes.Buffer
+	err := wire.TstWriteBlockHeader(&buf, pver, &noAuxPow)
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("writeBlockHeader: did not receive expected error "+
+			"for missing auxpow - got %v", err)
+	}
+
+	// A merkle branch that is longer than allowed must be rejected.
+	longBranch := *auxPowBlock.Header.AuxPowHeader
+	longBranch.CoinbaseBranch.BranchHash = make([]wire.ShaHash,
+		wire.MaxMerkleBranchLen+1)
+	longHdr := auxPowBlock.Header
+	longHdr.AuxPowHeader = &longBranch
+	buf.Reset()
+	err = wire.TstWriteBlockHeader(&buf, pver, &longHdr)
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("writeBlockHeader: did not receive expected error "+
+			"for long merkle branch - got %v", err)
+	}
+
+	// Patch the encoded coinbase branch count to exceed the limit.
+	badBytes := make([]byte, len(auxPowBlockBytes))
+	copy(badBytes, auxPowBlockBytes)
+	badBytes[246] = wire.MaxMerkleBranchLen + 1
+	var bh wire.BlockHeader
+	err = wire.TstReadBlockHeader(bytes.NewReader(badBytes), pver, &bh)
+	if _, ok := err.(*wire.MessageError); !ok {
+		t.Errorf("readBlockHeader: did not receive expected error "+
+			"for long merkle branch - got %v", err)
+	}
+}
+
+// TestAuxPowSerialize tests that an AuxPow serializes on its own to the bytes
+// following the base header of a merged mined block and survives a round trip.
+func TestAuxPowSerialize(t *testing.T) {
+	want := auxPowBlockBytes[80:auxPowBlockHeaderLen]
+
+	var buf bytes.Buffer
+	err := auxPowBlock.Header.AuxPowHeader.Serialize(&buf)
+	if err != nil {
+		t.Fatalf("Serialize: error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Serialize\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want))
+	}
+
+	var auxPow wire.AuxPow
+	err = auxPow.Deserialize(bytes.NewReader(want))
+	if err != nil {
+		t.Fatalf("Deserialize: error %v", err)
+	}
+	if !reflect.DeepEqual(&auxPow, auxPowBlock.Header.AuxPowHeader) {
+		t.Errorf("Deserialize\n got: %s want: %s", spew.Sdump(&auxPow),
+			spew.Sdump(auxPowBlock.Header.AuxPowHeader))
+	}
+
+	// A truncated AuxPow must be rejected.
+	err = auxPow.Deserialize(bytes.NewReader(want[:len(want)-1]))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Deserialize: wrong error for truncated auxpow - "+
+			"got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// TestAuxPowMainNetParent tests that an AuxPow built from a Bitcoin mainnet
+// block which was merged mined with a Namecoin mainnet block survives a round
+// trip and proves the parent coinbase is part of the parent block.
+func TestAuxPowMainNetParent(t *testing.T) {
+	// Bitcoin block 277647 is stored after the network magic and its
+	// length.  Its coinbase commits to Namecoin block 180ec2f9...c5445b
+	// as the only chain in its merged mining tree, so the AuxPow of that
+	// block has an empty blockchain branch.
+	fi, err := os.Open("../blockchain/testdata/277647.dat.bz2")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer fi.Close()
+	r := bzip2.NewReader(fi)
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	var parent wire.MsgBlock
+	if err := parent.Deserialize(r); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	parentHash, _ := parent.Header.BlockSha()
+	wantParentHash := "0000000000000000054a714e580b16c583701712ab91060e" +
+		"92dbde6eb1e052a8"
+	if parentHash.String() != wantParentHash {
+		t.Fatalf("BlockSha: got %v, want %v", parentHash,
+			wantParentHash)
+	}
+
+	// Build the merkle branch of the coinbase, which is the first leaf of
+	// the transaction tree of the parent block.
+	var level []wire.ShaHash
+	for _, tx := range parent.Transactions {
+		txHash, _ := tx.TxSha()
+		level = append(level, txHash)
+	}
+	var branch wire.MerkleBranch
+	for len(level) > 1 {
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+		branch.BranchHash = append(branch.BranchHash, level[1])
+		next := make([]wire.ShaHash, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			pair := append(level[i][:], level[i+1][:]...)
+			hash, _ := wire.NewShaHash(wire.DoubleSha256(pair))
+			next = append(next, *hash)
+		}
+		level = next
+	}
+	if !level[0].IsEqual(&parent.Header.MerkleRoot) {
+		t.Fatalf("merkle root: got %v, want %v", level[0],
+			parent.Header.MerkleRoot)
+	}
+
+	auxPow := &wire.AuxPow{
+		CoinbaseTx:     parent.Transactions[0],
+		BlockHash:      parentHash,
+		CoinbaseBranch: branch,
+		BlockchainBranch: wire.MerkleBranch{
+			BranchHash: []wire.ShaHash{},
+		},
+		ParentBlock: parent.Header,
+	}
+	var buf bytes.Buffer
+	if err := auxPow.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: error %v", err)
+	}
+	if buf.Len() != auxPow.SerializeSize() {
+		t.Errorf("SerializeSize: got %d, want %d",
+			auxPow.SerializeSize(), buf.Len())
+	}
+
+	// The parent header ends the AuxPow, so it must hash to the parent
+	// block hash.
+	serialized := buf.Bytes()
+	hash, _ := wire.NewShaHash(wire.DoubleSha256(
+		serialized[len(serialized)-80:]))
+	if !hash.IsEqual(&parentHash) {
+		t.Errorf("parent header: got hash %v, want %v", hash,
+			parentHash)
+	}
+
+	var got wire.AuxPow
+	if err := got.Deserialize(bytes.NewReader(serialized)); err != nil {
+		t.Fatalf("Deserialize: error %v", err)
+	}
+	if !reflect.DeepEqual(&got, auxPow) {
+		t.Errorf("Deserialize\n got: %s want: %s", spew.Sdump(&got),
+			spew.Sdump(auxPow))
+	}
+}
+
+// TestSetAuxPowVersion ensures updating the version of a block header to
+// signal a merged mined block keeps its base version.
+func TestSetAuxPowVersion(t *testing.T) {
+	tests := []struct {
+		version int32 // Version before the update
+		chainID int32 // Chain ID to set
+		want    int32 // Expected version after the update
+	}{
+		{1, 1, 0x00010101},
+		{2, 1, 0x00010102},
+		{0x00010102, 1, 0x00010102},
+		{0x00620102, 1, 0x00010102},
+		{2, 0x62, 0x00620102},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		hdr := wire.BlockHeader{Version: test.version}
+		hdr.SetAuxPowVersion(test.chainID)
+		if hdr.Version != test.want {
+			t.Errorf("SetAuxPowVersion #%d: wrong version - got "+
+				"0x%08x, want 0x%08x", i, hdr.Version, test.want)
+			continue
+		}
+		if !hdr.IsAuxPow() {
+			t.Errorf("SetAuxPowVersion #%d: header does not signal "+
+				"auxpow", i)
+		}
+	}
+}
+
+// TestBlockHeaderChainID ensures the chain ID, base version and legacy status
+// are decoded from the version of block headers and that setting the base
+// version encodes the chain ID without signalling auxpow.
+func TestBlockHeaderChainID(t *testing.T) {
+	tests := []struct {
+		version int32 // Version of the header
+		chainID int32 // Expected chain ID
+		base    int32 // Expected base version
+		legacy  bool  // Expected legacy status
+	}{
+		{1, 0, 1, true},
+		{2, 0, 2, false},
+		{0x00010101, 1, 1, false},
+		{0x00010102, 1, 2, false},
+		{0x00620102, 0x62, 2, false},
+		{0x00010002, 1, 2, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		hdr := wire.BlockHeader{Version: test.version}
+		if chainID := hdr.ChainID(); chainID != test.chainID {
+			t.Errorf("ChainID #%d: wrong chain ID - got %d, want %d",
+				i, chainID, test.chainID)
+		}
+		if base := hdr.BaseVersion(); base != test.base {
+			t.Errorf("BaseVersion #%d: wrong base version - got %d, "+
+				"want %d", i, base, test.base)
+		}
+		if legacy := hdr.IsLegacy(); legacy != test.legacy {
+			t.Errorf("IsLegacy #%d: got %v, want %v", i, legacy,
+				test.legacy)
+		}
+	}
+
+	hdr := wire.BlockHeader{Version: 0x00010101}
+	hdr.SetBaseVersion(2, 0x62)
+	if hdr.Version != 0x00620002 || hdr.IsAuxPow() {
+		t.Errorf("SetBaseVersion: wrong version - got 0x%08x, want "+
+			"0x%08x", hdr.Version, 0x00620002)
+	}
+}
+
+// auxPowBlockHeaderLen is the length of the header of auxPowBlock including
+// its AuxPow header.
+const auxPowBlockHeaderLen = 400
+
+// auxPowBlock is a merged mined block constructed for the tests rather than
+// taken from a network.  Its parent block commits to it through the merged
+// mining header in the parent coinbase and includes that coinbase alongside two
+// other transactions.
+var auxPowBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version: 0x00010101,
+		PrevBlock: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+			0xe1, 0xd3, 0xe4, 0x4c, 0xdf, 0x18, 0x4c, 0x1b,
+			0x5e, 0x7e, 0x2a, 0x5f, 0xbc, 0xd9, 0x2b, 0xcb,
+			0x7b, 0x6d, 0x2e, 0x1e, 0xa7, 0xd3, 0xbf, 0xd5,
+			0xe2, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}),
+		MerkleRoot: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+			0xe5, 0x28, 0xab, 0x5a, 0xce, 0x86, 0x3a, 0xc2,
+			0xd0, 0x6c, 0xc0, 0x3e, 0x38, 0x26, 0x41, 0x0a,
+			0x28, 0x60, 0x63, 0x28, 0x6f, 0x74, 0x19, 0x19,
+			0xed, 0x27, 0xbc, 0x64, 0x9f, 0xeb, 0x28, 0x0f,
+		}),
+		Timestamp: time.Unix(0x4e4a1f20, 0),
+		Bits:      0x1b00b269,
+		Nonce:     0,
+		AuxPowHeader: &wire.AuxPow{
+			CoinbaseTx: &wire.MsgTx{
+				Version: 1,
+				TxIn: []*wire.TxIn{
+					{
+						PreviousOutPoint: wire.OutPoint{
+							Hash:  wire.ShaHash{},
+							Index: 0xffffffff,
+						},
+						SignatureScript: []byte{
+							0x03, 0xa0, 0xbb, 0x02, // Block height
+							0x2c,                   // OP_DATA_44
+							0xfa, 0xbe, 0x6d, 0x6d, // Merged mining magic
+							0xdf, 0x5b, 0x99, 0xfb, 0x2d, 0x34, 0x70, 0xf5,
+							0x95, 0x09, 0x95, 0xe2, 0x9e, 0x21, 0xe9, 0x54,
+							0x62, 0x26, 0x2e, 0x2c, 0xce, 0x80, 0x7c, 0x61,
+							0x46, 0xc6, 0x32, 0x35, 0x9a, 0xd1, 0xfb, 0x78, // Block hash
+							0x01, 0x00, 0x00, 0x00, // Merkle size
+							0x00, 0x00, 0x00, 0x00, // Merkle nonce
+						},
+						Sequence: 0xffffffff,
+					},
+				},
+				TxOut: []*wire.TxOut{
+					{
+						Value: 0x12a05f200,
+						PkScript: []byte{
+							0x76, 0xa9, 0x14, 0x21, 0x22, 0x23, 0x24, 0x25,
+							0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d,
+							0x2e, 0x2f, 0x30, 0x31, 0x32, 0x33, 0x34, 0x88,
+							0xac,
+						},
+					},
+				},
+				LockTime: 0,
+			},
+			BlockHash: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+				0x5a, 0x7a, 0xfc, 0x86, 0x4a, 0x16, 0xab, 0xaa,
+				0x5d, 0x86, 0xee, 0xda, 0x6f, 0x46, 0x53, 0x3e,
+				0xfa, 0x3b, 0xa2, 0x3b, 0x16, 0xf5, 0xff, 0x71,
+				0x1b, 0x48, 0xd5, 0x52, 0x9a, 0x04, 0x28, 0xee,
+			}),
+			CoinbaseBranch: wire.MerkleBranch{
+				BranchHash: []wire.ShaHash{
+					wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+						0xb9, 0xf4, 0x70, 0x10, 0x75, 0x2f, 0xa1, 0xef,
+						0x6e, 0xaa, 0x19, 0x39, 0x9c, 0xaa, 0xcc, 0x17,
+						0xe6, 0x40, 0x05, 0x85, 0x0d, 0x08, 0x9a, 0xc6,
+						0x6d, 0xf8, 0x6e, 0x7c, 0x57, 0x26, 0xff, 0x0a,
+					}),
+					wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+						0x11, 0x38, 0xfc, 0xda, 0x34, 0xeb, 0x9f, 0xb4,
+						0x7b, 0xd3, 0xcf, 0xc9, 0xe2, 0x19, 0x4f, 0x09,
+						0xb4, 0x68, 0xda, 0xc2, 0x8f, 0xc8, 0xef, 0x24,
+						0xd7, 0x4d, 0xb0, 0x19, 0x75, 0xe5, 0x83, 0x90,
+					}),
+				},
+				BranchSideMask: 0,
+			},
+			BlockchainBranch: wire.MerkleBranch{
+				BranchHash:     []wire.ShaHash{},
+				BranchSideMask: 0,
+			},
+			ParentBlock: wire.BlockHeader{
+				Version: 1,
+				PrevBlock: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+					0x1d, 0x6a, 0x2c, 0x2b, 0x2b, 0x2f, 0x0b, 0x2f,
+					0x5a, 0x4d, 0xf1, 0x5c, 0xba, 0xb8, 0x53, 0x8f,
+					0x2f, 0x3a, 0x8e, 0x9c, 0xed, 0xd0, 0x54, 0x0b,
+					0x2c, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				}),
+				MerkleRoot: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+					0x25, 0x2a, 0x32, 0x07, 0x65, 0x46, 0x34, 0xba,
+					0xfb, 0xf2, 0x61, 0xce, 0x26, 0x2b, 0x06, 0xa1,
+					0x59, 0x61, 0x0b, 0xc0, 0xd8, 0x18, 0xcf, 0x6e,
+					0xce, 0xc9, 0x4a, 0x47, 0x65, 0xcf, 0x91, 0xa7,
+				}),
+				Timestamp: time.Unix(0x4e4a1f13, 0),
+				Bits:      0x1a0d69d7,
+				Nonce:     0x8a2f9b3c,
+			},
+		},
+	},
+	Transactions: []*wire.MsgTx{
+		{
+			Version: 1,
+			TxIn: []*wire.TxIn{
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash:  wire.ShaHash{},
+						Index: 0xffffffff,
+					},
+					SignatureScript: []byte{
+						0x04, 0xb2, 0x69, 0x00, 0x1b, 0x01, 0x02,
+					},
+					Sequence: 0xffffffff,
+				},
+			},
+			TxOut: []*wire.TxOut{
+				{
+					Value: 0x12a05f200,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x01, 0x02, 0x03, 0x04, 0x05,
+						0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+						0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x88,
+						0xac,
+					},
+				},
+			},
+			LockTime: 0,
+		},
+	},
+}
+
+// auxPowBlockBytes is the serialized bytes for auxPowBlock.
+var auxPowBlockBytes = []byte{
+	0x01, 0x01, 0x01, 0x00, // Version 65793 (chain ID 1, auxpow, version 1)
+	0xe1, 0xd3, 0xe4, 0x4c, 0xdf, 0x18, 0x4c, 0x1b,
+	0x5e, 0x7e, 0x2a, 0x5f, 0xbc, 0xd9, 0x2b, 0xcb,
+	0x7b, 0x6d, 0x2e, 0x1e, 0xa7, 0xd3, 0xbf, 0xd5,
+	0xe2, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // PrevBlock
+	0xe5, 0x28, 0xab, 0x5a, 0xce, 0x86, 0x3a, 0xc2,
+	0xd0, 0x6c, 0xc0, 0x3e, 0x38, 0x26, 0x41, 0x0a,
+	0x28, 0x60, 0x63, 0x28, 0x6f, 0x74, 0x19, 0x19,
+	0xed, 0x27, 0xbc, 0x64, 0x9f, 0xeb, 0x28, 0x0f, // MerkleRoot
+	0x20, 0x1f, 0x4a, 0x4e, // Timestamp
+	0x69, 0xb2, 0x00, 0x1b, // Bits
+	0x00, 0x00, 0x00, 0x00, // Nonce
+	0x01, 0x00, 0x00, 0x00, // Parent coinbase: Version
+	0x01, // Varint for number of transaction inputs
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Previous output hash
+	0xff, 0xff, 0xff, 0xff, // Previous output index
+	0x31,                   // Varint for length of signature script
+	0x03, 0xa0, 0xbb, 0x02, // Block height
+	0x2c,                   // OP_DATA_44
+	0xfa, 0xbe, 0x6d, 0x6d, // Merged mining magic
+	0xdf, 0x5b, 0x99, 0xfb, 0x2d, 0x34, 0x70, 0xf5,
+	0x95, 0x09, 0x95, 0xe2, 0x9e, 0x21, 0xe9, 0x54,
+	0x62, 0x26, 0x2e, 0x2c, 0xce, 0x80, 0x7c, 0x61,
+	0x46, 0xc6, 0x32, 0x35, 0x9a, 0xd1, 0xfb, 0x78, // Merged mining block hash
+	0x01, 0x00, 0x00, 0x00, // Merged mining merkle size
+	0x00, 0x00, 0x00, 0x00, // Merged mining merkle nonce
+	0xff, 0xff, 0xff, 0xff, // Sequence
+	0x01,                                           // Varint for number of transaction outputs
+	0x00, 0xf2, 0x05, 0x2a, 0x01, 0x00, 0x00, 0x00, // Transaction amount
+	0x19, // Varint for length of pk script
+	0x76, 0xa9, 0x14, 0x21, 0x22, 0x23, 0x24, 0x25,
+	0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d,
+	0x2e, 0x2f, 0x30, 0x31, 0x32, 0x33, 0x34, 0x88,
+	0xac,                   // Pk script
+	0x00, 0x00, 0x00, 0x00, // Lock time
+	0x5a, 0x7a, 0xfc, 0x86, 0x4a, 0x16, 0xab, 0xaa,
+	0x5d, 0x86, 0xee, 0xda, 0x6f, 0x46, 0x53, 0x3e,
+	0xfa, 0x3b, 0xa2, 0x3b, 0x16, 0xf5, 0xff, 0x71,
+	0x1b, 0x48, 0xd5, 0x52, 0x9a, 0x04, 0x28, 0xee, // Parent block hash
+	0x02, // Varint for number of coinbase branch hashes
+	0xb9, 0xf4, 0x70, 0x10, 0x75, 0x2f, 0xa1, 0xef,
+	0x6e, 0xaa, 0x19, 0x39, 0x9c, 0xaa, 0xcc, 0x17,
+	0xe6, 0x40, 0x05, 0x85, 0x0d, 0x08, 0x9a, 0xc6,
+	0x6d, 0xf8, 0x6e, 0x7c, 0x57, 0x26, 0xff, 0x0a, // Coinbase branch hash
+	0x11, 0x38, 0xfc, 0xda, 0x34, 0xeb, 0x9f, 0xb4,
+	0x7b, 0xd3, 0xcf, 0xc9, 0xe2, 0x19, 0x4f, 0x09,
+	0xb4, 0x68, 0xda, 0xc2, 0x8f, 0xc8, 0xef, 0x24,
+	0xd7, 0x4d, 0xb0, 0x19, 0x75, 0xe5, 0x83, 0x90, // Coinbase branch hash
+	0x00, 0x00, 0x00, 0x00, // Coinbase branch side mask
+	0x00,                   // Varint for number of blockchain branch hashes
+	0x00, 0x00, 0x00, 0x00, // Blockchain branch side mask
+	0x01, 0x00, 0x00, 0x00, // Parent Version 1
+	0x1d, 0x6a, 0x2c, 0x2b, 0x2b, 0x2f, 0x0b, 0x2f,
+	0x5a, 0x4d, 0xf1, 0x5c, 0xba, 0xb8, 0x53, 0x8f,
+	0x2f, 0x3a, 0x8e, 0x9c, 0xed, 0xd0, 0x54, 0x0b,
+	0x2c, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // PrevBlock
+	0x25, 0x2a, 0x32, 0x07, 0x65, 0x46, 0x34, 0xba,
+	0xfb, 0xf2, 0x61, 0xce, 0x26, 0x2b, 0x06, 0xa1,
+	0x59, 0x61, 0x0b, 0xc0, 0xd8, 0x18, 0xcf, 0x6e,
+	0xce, 0xc9, 0x4a, 0x47, 0x65, 0xcf, 0x91, 0xa7, // MerkleRoot
+	0x13, 0x1f, 0x4a, 0x4e, // Timestamp
+	0xd7, 0x69, 0x0d, 0x1a, // Bits
+	0x3c, 0x9b, 0x2f, 0x8a, // Nonce
+	0x01,                   // TxnCount
+	0x01, 0x00, 0x00, 0x00, // Version
+	0x01, // Varint for number of transaction inputs
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Previous output hash
+	0xff, 0xff, 0xff, 0xff, // Previous output index
+	0x07,                                     // Varint for length of signature script
+	0x04, 0xb2, 0x69, 0x00, 0x1b, 0x01, 0x02, // Signature script (coinbase)
+	0xff, 0xff, 0xff, 0xff, // Sequence
+	0x01,                                           // Varint for number of transaction outputs
+	0x00, 0xf2, 0x05, 0x2a, 0x01, 0x00, 0x00, 0x00, // Transaction amount
+	0x19, // Varint for length of pk script
+	0x76, 0xa9, 0x14, 0x01, 0x02, 0x03, 0x04, 0x05,
+	0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+	0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x88,
+	0xac,                   // Pk script
+	0x00, 0x00, 0x00, 0x00, // Lock time
+}
+
+// auxPowBranchBlockHeaderLen is the length of the header of auxPowBranchBlock
+// including its AuxPow header.
+const auxPowBranchBlockHeaderLen = 432
+
+// auxPowBranchBlock is a merged mined block constructed for the tests which
+// builds on auxPowBlock.  Its parent block commits to a merged mining tree of
+// size four rather than to the block hash directly, so the AuxPow header carries
+// a non-empty blockchain merkle branch along with the merkle nonce that selects
+// its slot in the tree.
+var auxPowBranchBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version: 0x00010102,
+		PrevBlock: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+			0x78, 0xfb, 0xd1, 0x9a, 0x35, 0x32, 0xc6, 0x46,
+			0x61, 0x7c, 0x80, 0xce, 0x2c, 0x2e, 0x26, 0x62,
+			0x54, 0xe9, 0x21, 0x9e, 0xe2, 0x95, 0x09, 0x95,
+			0xf5, 0x70, 0x34, 0x2d, 0xfb, 0x99, 0x5b, 0xdf,
+		}),
+		MerkleRoot: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+			0x24, 0x98, 0xdb, 0x6c, 0x59, 0xc6, 0x8d, 0x31,
+			0x73, 0xb7, 0x32, 0x56, 0xd5, 0x5e, 0x80, 0x27,
+			0xd0, 0xa2, 0x98, 0x96, 0x34, 0xc8, 0x7e, 0x72,
+			0x93, 0xc1, 0xff, 0x10, 0xf1, 0x29, 0x3d, 0xef,
+		}),
+		Timestamp: time.Unix(0x4e4a2154, 0),
+		Bits:      0x1b00b269,
+		Nonce:     0,
+		AuxPowHeader: &wire.AuxPow{
+			CoinbaseTx: &wire.MsgTx{
+				Version: 1,
+				TxIn: []*wire.TxIn{
+					{
+						PreviousOutPoint: wire.OutPoint{
+							Hash:  wire.ShaHash{},
+							Index: 0xffffffff,
+						},
+						SignatureScript: []byte{
+							0x03, 0xa1, 0xbb, 0x02, // Block height
+							0x2c,                   // OP_DATA_44
+							0xfa, 0xbe, 0x6d, 0x6d, // Merged mining magic
+							0x88, 0xcc, 0xf5, 0x49, 0x58, 0xb1, 0x8a, 0x79,
+							0x22, 0xb8, 0x55, 0x7e, 0x3d, 0x9d, 0xec, 0x35,
+							0xb7, 0x35, 0xc4, 0xba, 0x73, 0x34, 0x95, 0x6d,
+							0x6c, 0x2f, 0x21, 0x53, 0x0f, 0xde, 0x46, 0xc2, // Merkle root
+							0x04, 0x00, 0x00, 0x00, // Merkle size
+							0x2a, 0x00, 0x00, 0x00, // Merkle nonce
+						},
+						Sequence: 0xffffffff,
+					},
+				},
+				TxOut: []*wire.TxOut{
+					{
+						Value: 0x12a05f200,
+						PkScript: []byte{
+							0x76, 0xa9, 0x14, 0x61, 0x62, 0x63, 0x64, 0x65,
+							0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d,
+							0x6e, 0x6f, 0x70, 0x71, 0x72, 0x73, 0x74, 0x88,
+							0xac,
+						},
+					},
+				},
+				LockTime: 0,
+			},
+			BlockHash: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+				0xfd, 0x3f, 0x03, 0x82, 0x1d, 0x4f, 0x65, 0x32,
+				0xc8, 0x2e, 0xab, 0x49, 0x76, 0xc2, 0x87, 0x26,
+				0x9f, 0x10, 0xe6, 0x0b, 0x51, 0xfd, 0x2f, 0xc1,
+				0x1f, 0x16, 0xf2, 0xce, 0x04, 0x7e, 0x3c, 0xca,
+			}),
+			CoinbaseBranch: wire.MerkleBranch{
+				BranchHash: []wire.ShaHash{
+					wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+						0x17, 0x50, 0xf1, 0x28, 0x2c, 0xd5, 0x04, 0xe3,
+						0x1d, 0xcf, 0x82, 0x46, 0x8d, 0xfe, 0x92, 0x70,
+						0x47, 0x71, 0x97, 0xa1, 0xc3, 0xc0, 0xb1, 0xbc,
+						0x13, 0xd9, 0x6c, 0x71, 0x1c, 0x3a, 0xe5, 0x1c,
+					}),
+				},
+				BranchSideMask: 0,
+			},
+			BlockchainBranch: wire.MerkleBranch{
+				BranchHash: []wire.ShaHash{
+					wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+						0x4f, 0x90, 0x83, 0x9a, 0x2e, 0xd5, 0x4a, 0x43,
+						0x5c, 0x23, 0x25, 0x5d, 0x9a, 0x62, 0x46, 0x63,
+						0x27, 0x81, 0xcb, 0x24, 0x9f, 0xfb, 0x0d, 0x8e,
+						0xec, 0x54, 0x02, 0x21, 0x51, 0x98, 0x50, 0x9b,
+					}),
+					wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+						0x2d, 0x03, 0xfb, 0xa4, 0x7f, 0xae, 0xd3, 0xfb,
+						0x3f, 0xda, 0x00, 0xc4, 0x67, 0xc3, 0xa0, 0x64,
+						0x92, 0x8a, 0xbb, 0x5e, 0x22, 0xbc, 0xec, 0x31,
+						0x97, 0x24, 0x2a, 0xc7, 0x05, 0xa3, 0x1f, 0xa5,
+					}),
+				},
+				BranchSideMask: 1,
+			},
+			ParentBlock: wire.BlockHeader{
+				Version: 2,
+				PrevBlock: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+					0x6a, 0x21, 0xc6, 0x30, 0xeb, 0x16, 0x1f, 0xc6,
+					0xbb, 0x8e, 0x3f, 0x09, 0x76, 0x86, 0x8c, 0xcc,
+					0xc5, 0xd9, 0x23, 0xf5, 0x30, 0x52, 0x6d, 0x0e,
+					0xbc, 0xe8, 0x51, 0x32, 0xe1, 0x32, 0x58, 0x6e,
+				}),
+				MerkleRoot: wire.ShaHash([wire.HashSize]byte{ // Make go vet happy.
+					0x6b, 0xc1, 0xf1, 0xcc, 0x16, 0x5d, 0x9a, 0x60,
+					0x39, 0x66, 0x5f, 0x44, 0x7d, 0x36, 0x93, 0x23,
+					0x6e, 0x12, 0x8a, 0xde, 0xec, 0xca, 0x07, 0x10,
+					0x5c, 0x1e, 0xe7, 0x60, 0x63, 0x5e, 0xf6, 0x4e,
+				}),
+				Timestamp: time.Unix(0x4e4a2150, 0),
+				Bits:      0x1a0d69d7,
+				Nonce:     0x1d2c3b4a,
+			},
+		},
+	},
+	Transactions: []*wire.MsgTx{
+		{
+			Version: 1,
+			TxIn: []*wire.TxIn{
+				{
+					PreviousOutPoint: wire.OutPoint{
+						Hash:  wire.ShaHash{},
+						Index: 0xffffffff,
+					},
+					SignatureScript: []byte{
+						0x04, 0xb2, 0x69, 0x00, 0x1b, 0x01, 0x0c,
+					},
+					Sequence: 0xffffffff,
+				},
+			},
+			TxOut: []*wire.TxOut{
+				{
+					Value: 0x12a05f200,
+					PkScript: []byte{
+						0x76, 0xa9, 0x14, 0x41, 0x42, 0x43, 0x44, 0x45,
+						0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d,
+						0x4e, 0x4f, 0x50, 0x51, 0x52, 0x53, 0x54, 0x88,
+						0xac,
+					},
+				},
+			},
+			LockTime: 0,
+		},
+	},
+}
+
+// auxPowBranchBlockBytes is the serialized bytes for auxPowBranchBlock.
+var auxPowBranchBlockBytes = []byte{
+	0x02, 0x01, 0x01, 0x00, // Version 65794 (chain ID 1, auxpow, version 2)
+	0x78, 0xfb, 0xd1, 0x9a, 0x35, 0x32, 0xc6, 0x46,
+	0x61, 0x7c, 0x80, 0xce, 0x2c, 0x2e, 0x26, 0x62,
+	0x54, 0xe9, 0x21, 0x9e, 0xe2, 0x95, 0x09, 0x95,
+	0xf5, 0x70, 0x34, 0x2d, 0xfb, 0x99, 0x5b, 0xdf, // PrevBlock
+	0x24, 0x98, 0xdb, 0x6c, 0x59, 0xc6, 0x8d, 0x31,
+	0x73, 0xb7, 0x32, 0x56, 0xd5, 0x5e, 0x80, 0x27,
+	0xd0, 0xa2, 0x98, 0x96, 0x34, 0xc8, 0x7e, 0x72,
+	0x93, 0xc1, 0xff, 0x10, 0xf1, 0x29, 0x3d, 0xef, // MerkleRoot
+	0x54, 0x21, 0x4a, 0x4e, // Timestamp
+	0x69, 0xb2, 0x00, 0x1b, // Bits
+	0x00, 0x00, 0x00, 0x00, // Nonce
+	0x01, 0x00, 0x00, 0x00, // Parent coinbase: Version
+	0x01, // Varint for number of transaction inputs
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Previous output hash
+	0xff, 0xff, 0xff, 0xff, // Previous output index
+	0x31,                   // Varint for length of signature script
+	0x03, 0xa1, 0xbb, 0x02, // Block height
+	0x2c,                   // OP_DATA_44
+	0xfa, 0xbe, 0x6d, 0x6d, // Merged mining magic
+	0x88, 0xcc, 0xf5, 0x49, 0x58, 0xb1, 0x8a, 0x79,
+	0x22, 0xb8, 0x55, 0x7e, 0x3d, 0x9d, 0xec, 0x35,
+	0xb7, 0x35, 0xc4, 0xba, 0x73, 0x34, 0x95, 0x6d,
+	0x6c, 0x2f, 0x21, 0x53, 0x0f, 0xde, 0x46, 0xc2, // Merged mining merkle root
+	0x04, 0x00, 0x00, 0x00, // Merged mining merkle size
+	0x2a, 0x00, 0x00, 0x00, // Merged mining merkle nonce
+	0xff, 0xff, 0xff, 0xff, // Sequence
+	0x01,                                           // Varint for number of transaction outputs
+	0x00, 0xf2, 0x05, 0x2a, 0x01, 0x00, 0x00, 0x00, // Transaction amount
+	0x19, // Varint for length of pk script
+	0x76, 0xa9, 0x14, 0x61, 0x62, 0x63, 0x64, 0x65,
+	0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d,
+	0x6e, 0x6f, 0x70, 0x71, 0x72, 0x73, 0x74, 0x88,
+	0xac,                   // Pk script
+	0x00, 0x00, 0x00, 0x00, // Lock time
+	0xfd, 0x3f, 0x03, 0x82, 0x1d, 0x4f, 0x65, 0x32,
+	0xc8, 0x2e, 0xab, 0x49, 0x76, 0xc2, 0x87, 0x26,
+	0x9f, 0x10, 0xe6, 0x0b, 0x51, 0xfd, 0x2f, 0xc1,
+	0x1f, 0x16, 0xf2, 0xce, 0x04, 0x7e, 0x3c, 0xca, // Parent block hash
+	0x01, // Varint for number of coinbase branch hashes
+	0x17, 0x50, 0xf1, 0x28, 0x2c, 0xd5, 0x04, 0xe3,
+	0x1d, 0xcf, 0x82, 0x46, 0x8d, 0xfe, 0x92, 0x70,
+	0x47, 0x71, 0x97, 0xa1, 0xc3, 0xc0, 0xb1, 0xbc,
+	0x13, 0xd9, 0x6c, 0x71, 0x1c, 0x3a, 0xe5, 0x1c, // Coinbase branch hash
+	0x00, 0x00, 0x00, 0x00, // Coinbase branch side mask
+	0x02, // Varint for number of blockchain branch hashes
+	0x4f, 0x90, 0x83, 0x9a, 0x2e, 0xd5, 0x4a, 0x43,
+	0x5c, 0x23, 0x25, 0x5d, 0x9a, 0x62, 0x46, 0x63,
+	0x27, 0x81, 0xcb, 0x24, 0x9f, 0xfb, 0x0d, 0x8e,
+	0xec, 0x54, 0x02, 0x21, 0x51, 0x98, 0x50, 0x9b, // Blockchain branch hash
+	0x2d, 0x03, 0xfb, 0xa4, 0x7f, 0xae, 0xd3, 0xfb,
+	0x3f, 0xda, 0x00, 0xc4, 0x67, 0xc3, 0xa0, 0x64,
+	0x92, 0x8a, 0xbb, 0x5e, 0x22, 0xbc, 0xec, 0x31,
+	0x97, 0x24, 0x2a, 0xc7, 0x05, 0xa3, 0x1f, 0xa5, // Blockchain branch hash
+	0x01, 0x00, 0x00, 0x00, // Blockchain branch side mask
+	0x02, 0x00, 0x00, 0x00, // Parent Version 2
+	0x6a, 0x21, 0xc6, 0x30, 0xeb, 0x16, 0x1f, 0xc6,
+	0xbb, 0x8e, 0x3f, 0x09, 0x76, 0x86, 0x8c, 0xcc,
+	0xc5, 0xd9, 0x23, 0xf5, 0x30, 0x52, 0x6d, 0x0e,
+	0xbc, 0xe8, 0x51, 0x32, 0xe1, 0x32, 0x58, 0x6e, // PrevBlock
+	0x6b, 0xc1, 0xf1, 0xcc, 0x16, 0x5d, 0x9a, 0x60,
+	0x39, 0x66, 0x5f, 0x44, 0x7d, 0x36, 0x93, 0x23,
+	0x6e, 0x12, 0x8a, 0xde, 0xec, 0xca, 0x07, 0x10,
+	0x5c, 0x1e, 0xe7, 0x60, 0x63, 0x5e, 0xf6, 0x4e, // MerkleRoot
+	0x50, 0x21, 0x4a, 0x4e, // Timestamp
+	0xd7, 0x69, 0x0d, 0x1a, // Bits
+	0x4a, 0x3b, 0x2c, 0x1d, // Nonce
+	0x01,                   // TxnCount
+	0x01, 0x00, 0x00, 0x00, // Version
+	0x01, // Varint for number of transaction inputs
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Previous output hash
+	0xff, 0xff, 0xff, 0xff, // Previous output index
+	0x07,                                     // Varint for length of signature script
+	0x04, 0xb2, 0x69, 0x00, 0x1b, 0x01, 0x0c, // Signature script (coinbase)
+	0xff, 0xff, 0xff, 0xff, // Sequence
+	0x01,                                           // Varint for number of transaction outputs
+	0x00, 0xf2, 0x05, 0x2a, 0x01, 0x00, 0x00, 0x00, // Transaction amount
+	0x19, // Varint for length of pk script
+	0x76, 0xa9, 0x14, 0x41, 0x42, 0x43, 0x44, 0x45,
+	0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d,
+	0x4e, 0x4f, 0x50, 0x51, 0x52, 0x53, 0x54, 0x88,
+	0xac,                   // Pk script
+	0x00, 0x00, 0x00, 0x00, // Lock time
+}
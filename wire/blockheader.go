@@ -57,10 +57,13 @@ func (h *BlockHeader) BlockSha() (ShaHash, error) {
 	// cause a run-time panic.  Also, SetBytes can't fail here due to the
 	// fact DoubleSha256 always returns a []byte of the right size
 	// regardless of input.
+	//
+	// Only the base header is hashed.  The AuxPow of a merged mined block
+	// is not part of its identity.
 	var buf bytes.Buffer
 	var sha ShaHash
-	_ = writeBlockHeader(&buf, 0, h)
-	_ = sha.SetBytes(DoubleSha256(buf.Bytes()[0:blockHeaderLen]))
+	_ = writeBaseBlockHeader(&buf, 0, h)
+	_ = sha.SetBytes(DoubleSha256(buf.Bytes()))
 
 	// Even though this function can't currently fail, it still returns
 	// a potential error to help future proof the API should a failure
@@ -68,6 +71,57 @@ func (h *BlockHeader) BlockSha() (ShaHash, error) {
 	return sha, nil
 }
 
+// IsAuxPow returns whether the version of the block header signals that it is
+// followed by an AuxPow header.
+func (h *BlockHeader) IsAuxPow() bool {
+	return h.Version&blockVersionAuxPow != 0
+}
+
+// ChainID returns the merged mining chain ID encoded in the version of the
+// block header.
+func (h *BlockHeader) ChainID() int32 {
+	return h.Version / blockVersionChainStart
+}
+
+// IsLegacy returns whether the block header has the legacy version 1 which
+// predates merged mining and therefore does not encode a chain ID.
+func (h *BlockHeader) IsLegacy() bool {
+	return h.Version == blockVersionDefault
+}
+
+// BaseVersion returns the version of the block header without the merged
+// mining chain ID and the flag signalling an AuxPow header.  This is the
+// version the version upgrade rules, such as those of BIP0034, apply to.
+func (h *BlockHeader) BaseVersion() int32 {
+	return h.Version % blockVersionAuxPow
+}
+
+// SetBaseVersion sets the version of the block header to the passed base
+// version of a block which is not merged mined and encodes the passed chain ID
+// in it.
+func (h *BlockHeader) SetBaseVersion(version, chainID int32) {
+	h.Version = version%blockVersionAuxPow | chainID*blockVersionChainStart
+}
+
+// SetAuxPowVersion updates the version of the block header to signal a merged
+// mined block of the chain with the passed ID while keeping its base version.
+// The AuxPow header itself must be attached separately before the header can
+// be serialized.
+func (h *BlockHeader) SetAuxPowVersion(chainID int32) {
+	h.Version = h.Version%blockVersionAuxPow | blockVersionAuxPow |
+		chainID*blockVersionChainStart
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// block header, including the AuxPow header of merged mined blocks.
+func (h *BlockHeader) SerializeSize() int {
+	if h.IsAuxPow() && h.AuxPowHeader != nil {
+		return blockHeaderLen + h.AuxPowHeader.SerializeSize()
+	}
+
+	return blockHeaderLen
+}
+
 // Deserialize decodes a block header from r into the receiver using a format
 // that is suitable for long-term storage such as a database while respecting
 // the Version field.
@@ -110,15 +164,12 @@ func NewBlockHeader(prevHash *ShaHash, merkleRootHash *ShaHash, bits uint32,
 // decoding block headers stored to disk, such as in a database, as opposed to
 // decoding from the wire.
 func readBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
-	var sec uint32
-	err := readElements(r, &bh.Version, &bh.PrevBlock, &bh.MerkleRoot, &sec,
-		&bh.Bits, &bh.Nonce)
+	err := readBaseBlockHeader(r, pver, bh)
 	if err != nil {
 		return err
 	}
-	bh.Timestamp = time.Unix(int64(sec), 0)
 
-	if bh.Version&blockVersionAuxPow != 0 {
+	if bh.IsAuxPow() {
 		// this block contains auxiliary information
 		ap := &AuxPow{}
 		err = readAuxPow(r, pver, ap)
@@ -132,10 +183,49 @@ func readBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
 	return nil
 }
 
+// readBaseBlockHeader reads the 80 byte base of a block header from r without
+// any AuxPow header that may follow it.
+func readBaseBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
+	var sec uint32
+	err := readElements(r, &bh.Version, &bh.PrevBlock, &bh.MerkleRoot, &sec,
+		&bh.Bits, &bh.Nonce)
+	if err != nil {
+		return err
+	}
+	bh.Timestamp = time.Unix(int64(sec), 0)
+
+	return nil
+}
+
 // writeBlockHeader writes a bitcoin block header to w.  See Serialize for
 // encoding block headers to be stored to disk, such as in a database, as
 // opposed to encoding for the wire.
 func writeBlockHeader(w io.Writer, pver uint32, bh *BlockHeader) error {
+	err := writeBaseBlockHeader(w, pver, bh)
+	if err != nil {
+		return err
+	}
+
+	if bh.IsAuxPow() {
+		// The version promises an AuxPow header, so a header without
+		// one could never be decoded again.
+		if bh.AuxPowHeader == nil {
+			return messageError("writeBlockHeader", "block version "+
+				"signals auxpow but the header has none")
+		}
+
+		err = writeAuxPow(w, pver, bh.AuxPowHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeBaseBlockHeader writes the 80 byte base of a block header to w without
+// any AuxPow header.
+func writeBaseBlockHeader(w io.Writer, pver uint32, bh *BlockHeader) error {
 	sec := uint32(bh.Timestamp.Unix())
 	err := writeElements(w, bh.Version, &bh.PrevBlock, &bh.MerkleRoot,
 		sec, bh.Bits, bh.Nonce)
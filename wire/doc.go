@@ -155,5 +155,6 @@ This package includes spec changes outlined by the following BIPs:
 		BIP0031 (https://en.bitcoin.it/wiki/BIP_0031)
 		BIP0035 (https://en.bitcoin.it/wiki/BIP_0035)
 		BIP0037 (https://en.bitcoin.it/wiki/BIP_0037)
+		BIP0130 (https://github.com/bitcoin/bips/blob/master/bip-0130.mediawiki)
 */
 package wire
@@ -75,6 +75,7 @@ func TestMessage(t *testing.T) {
 	bh := wire.NewBlockHeader(&wire.ShaHash{}, &wire.ShaHash{}, 0, 0)
 	msgMerkleBlock := wire.NewMsgMerkleBlock(bh)
 	msgReject := wire.NewMsgReject("block", wire.RejectDuplicate, "duplicate block")
+	msgSendHeaders := wire.NewMsgSendHeaders()
 
 	tests := []struct {
 		in     wire.Message    // Value to encode
@@ -104,6 +105,7 @@ func TestMessage(t *testing.T) {
 		{msgFilterLoad, msgFilterLoad, pver, wire.MainNet, 35},
 		{msgMerkleBlock, msgMerkleBlock, pver, wire.MainNet, 110},
 		{msgReject, msgReject, pver, wire.MainNet, 79},
+		{msgSendHeaders, msgSendHeaders, pver, wire.MainNet, 24},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -197,9 +197,9 @@ func (msg *MsgBlock) Serialize(w io.Writer) error {
 // SerializeSize returns the number of bytes it would take to serialize the
 // the block.
 func (msg *MsgBlock) SerializeSize() int {
-	// Block header bytes + Serialized varint size for the number of
-	// transactions.
-	n := blockHeaderLen + VarIntSerializeSize(uint64(len(msg.Transactions)))
+	// Block header bytes (including any AuxPow) + Serialized varint size
+	// for the number of transactions.
+	n := msg.Header.SerializeSize() + VarIntSerializeSize(uint64(len(msg.Transactions)))
 
 	for _, tx := range msg.Transactions {
 		n += tx.SerializeSize()
@@ -16,6 +16,10 @@ const (
 	// TxVersion is the current latest supported transaction version.
 	TxVersion = 1
 
+	// NamecoinTxVersion is the transaction version which marks
+	// transactions performing Namecoin name operations.
+	NamecoinTxVersion = 0x7100
+
 	// MaxTxInSequenceNum is the maximum sequence number the sequence field
 	// of a transaction input can be.
 	MaxTxInSequenceNum uint32 = 0xffffffff
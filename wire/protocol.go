@@ -12,7 +12,7 @@ import (
 
 const (
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 38000
+	ProtocolVersion uint32 = 70012
 
 	// MultipleAddressVersion is the protocol version which added multiple
 	// addresses per message (pver >= MultipleAddressVersion).
@@ -38,6 +38,10 @@ const (
 	// RejectVersion is the protocol version which added a new reject
 	// message.
 	RejectVersion uint32 = 70002
+
+	// SendHeadersVersion is the protocol version which added a new
+	// sendheaders message (pver >= SendHeadersVersion).
+	SendHeadersVersion uint32 = 70012
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.